@@ -0,0 +1,199 @@
+// Package admin exposes a loopback-only HTTP API for operating a running
+// agent without an SSH-restart loop: injecting or kicking specific peers,
+// inspecting stats, reloading safe config, and draining the node before
+// a planned removal.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/x0tta6bl4/agent/internal/config"
+	"github.com/x0tta6bl4/agent/internal/mesh"
+	"github.com/x0tta6bl4/agent/internal/telemetry"
+)
+
+// Server is the local admin HTTP API. It is meant to be bound to
+// 127.0.0.1 only; the caller is responsible for choosing a loopback
+// address in config.
+type Server struct {
+	node    *mesh.Node
+	telem   *telemetry.Reporter
+	cfg     *config.Config
+	cfgPath string
+	token   string
+
+	httpServer *http.Server
+	logger     *slog.Logger
+}
+
+// NewServer creates an admin API server. token is the expected value of
+// the X-Admin-Token header (typically sourced from X0T_ADMIN_TOKEN); an
+// empty token disables authentication, which is only appropriate for local
+// development.
+func NewServer(node *mesh.Node, telem *telemetry.Reporter, cfg *config.Config, cfgPath, listenAddr, token string) *Server {
+	s := &Server{
+		node:    node,
+		telem:   telem,
+		cfg:     cfg,
+		cfgPath: cfgPath,
+		token:   token,
+		logger:  slog.Default().With("component", "admin-api"),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/peers", s.authenticated(s.handlePeers))
+	mux.HandleFunc("/v1/peers/", s.authenticated(s.handlePeerByID))
+	mux.HandleFunc("/v1/stats", s.authenticated(s.handleStats))
+	mux.HandleFunc("/v1/config/reload", s.authenticated(s.handleConfigReload))
+	mux.HandleFunc("/v1/drain", s.authenticated(s.handleDrain))
+
+	s.httpServer = &http.Server{Addr: listenAddr, Handler: mux}
+	return s
+}
+
+// ListenAndServe starts the admin API and blocks until it stops.
+func (s *Server) ListenAndServe() error {
+	s.logger.Info("admin API listening", "addr", s.httpServer.Addr)
+	return s.httpServer.ListenAndServe()
+}
+
+// Stop shuts the admin API down.
+func (s *Server) Stop(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" && !tokensEqual(r.Header.Get("X-Admin-Token"), s.token) {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing X-Admin-Token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// tokensEqual compares got against want in constant time, so a
+// byte-by-byte timing difference on the admin token can't leak how much
+// of a guess matched.
+func tokensEqual(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// addPeerRequest is the body of POST /v1/peers.
+type addPeerRequest struct {
+	NodeID string `json:"node_id"`
+	Addr   string `json:"addr"`
+	Port   int    `json:"port"`
+	PubKey string `json:"pubkey"`
+}
+
+func (s *Server) handlePeers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.node.GetPeers())
+
+	case http.MethodPost:
+		var req addPeerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("decode request: %w", err))
+			return
+		}
+		if req.NodeID == "" || req.Addr == "" || req.Port == 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("node_id, addr, and port are required"))
+			return
+		}
+		if err := s.node.AddPeerManual(req.NodeID, req.Addr, req.Port, req.PubKey); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+func (s *Server) handlePeerByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/peers/")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing peer id"))
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	if err := s.node.RemovePeer(id); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	resp := map[string]any{
+		"node": s.node.GetStats(),
+	}
+	if s.telem != nil {
+		resp["telemetry"] = s.telem.Latest()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleConfigReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	fresh, err := config.LoadFromFile(s.cfgPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("reload config: %w", err))
+		return
+	}
+
+	// Only a safe subset is applied live; networking/identity fields
+	// require a restart to avoid tearing down an active mesh session.
+	s.cfg.LogLevel = fresh.LogLevel
+	s.cfg.HeartbeatIntervalSec = fresh.HeartbeatIntervalSec
+	s.cfg.TrafficProfile = fresh.TrafficProfile
+
+	s.logger.Info("config reloaded",
+		"log_level", s.cfg.LogLevel,
+		"heartbeat_interval_sec", s.cfg.HeartbeatIntervalSec,
+		"traffic_profile", s.cfg.TrafficProfile,
+	)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	s.node.Drain()
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
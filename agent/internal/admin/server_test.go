@@ -0,0 +1,153 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/x0tta6bl4/agent/internal/config"
+	"github.com/x0tta6bl4/agent/internal/mesh"
+	"github.com/x0tta6bl4/agent/internal/telemetry"
+)
+
+func newTestServer(token string) (*Server, *httptest.Server) {
+	node := mesh.NewNode("admin-test-node", 5100, nil)
+	telem := telemetry.NewReporter(node)
+	cfg := config.DefaultConfig()
+
+	s := NewServer(node, telem, cfg, "", "127.0.0.1:0", token)
+	ts := httptest.NewServer(s.httpServer.Handler)
+	return s, ts
+}
+
+func TestHandlePeers_PostThenGet(t *testing.T) {
+	_, ts := newTestServer("")
+	defer ts.Close()
+
+	body, _ := json.Marshal(addPeerRequest{NodeID: "peer-1", Addr: "10.0.0.5", Port: 5000})
+	resp, err := http.Post(ts.URL+"/v1/peers", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/peers")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	var peers []mesh.Peer
+	json.NewDecoder(resp.Body).Decode(&peers)
+	if len(peers) != 1 || peers[0].NodeID != "peer-1" {
+		t.Errorf("peers = %+v, want [peer-1]", peers)
+	}
+}
+
+func TestHandlePeers_PostMissingFields(t *testing.T) {
+	_, ts := newTestServer("")
+	defer ts.Close()
+
+	body, _ := json.Marshal(addPeerRequest{NodeID: "peer-1"})
+	resp, err := http.Post(ts.URL+"/v1/peers", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestHandlePeerByID_DeleteRemoves(t *testing.T) {
+	s, ts := newTestServer("")
+	defer ts.Close()
+
+	s.node.AddPeerManual("peer-2", "10.0.0.6", 5000, "")
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/peers/peer-2", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", resp.StatusCode)
+	}
+	if len(s.node.GetPeers()) != 0 {
+		t.Error("peer should have been removed")
+	}
+}
+
+func TestHandlePeerByID_DeleteNotFound(t *testing.T) {
+	_, ts := newTestServer("")
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/peers/ghost", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHandleStats_ReturnsNodeAndTelemetry(t *testing.T) {
+	_, ts := newTestServer("")
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/stats")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	var body map[string]any
+	json.NewDecoder(resp.Body).Decode(&body)
+	if _, ok := body["node"]; !ok {
+		t.Error("expected node stats in response")
+	}
+}
+
+func TestHandleDrain_TransitionsState(t *testing.T) {
+	s, ts := newTestServer("")
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/v1/drain", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if s.node.State != mesh.StateDraining {
+		t.Errorf("node state = %v, want StateDraining", s.node.State)
+	}
+}
+
+func TestAuthenticated_RejectsMissingToken(t *testing.T) {
+	_, ts := newTestServer("secret")
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/peers")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestAuthenticated_AllowsCorrectToken(t *testing.T) {
+	_, ts := newTestServer("secret")
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/peers", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
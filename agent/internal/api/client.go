@@ -19,7 +19,13 @@ type Client struct {
 	apiKey     string // assigned after registration
 	meshID     string
 	httpClient *http.Client
-	logger     *slog.Logger
+	// streamClient is used only by Subscribe's long-lived event stream:
+	// unlike httpClient, it carries no overall Timeout, since that would
+	// cut the connection off on a fixed clock regardless of activity.
+	// Subscribe relies on the caller's context for lifetime control
+	// instead.
+	streamClient *http.Client
+	logger       *slog.Logger
 }
 
 // RegistrationRequest is sent to register a new agent.
@@ -43,15 +49,195 @@ type RegistrationResponse struct {
 
 // HeartbeatRequest is the periodic status push.
 type HeartbeatRequest struct {
-	NodeID       string         `json:"node_id"`
-	State        string         `json:"state"`
-	PeersTotal   int            `json:"peers_total"`
-	PeersHealthy int            `json:"peers_healthy"`
-	HealthScore  float64        `json:"health_score"`
-	UptimeSec    float64        `json:"uptime_sec"`
-	MsgSent      int64          `json:"messages_sent"`
-	MsgRecv      int64          `json:"messages_recv"`
-	Metrics      map[string]any `json:"metrics,omitempty"`
+	NodeID       string  `json:"node_id"`
+	State        string  `json:"state"`
+	PeersTotal   int     `json:"peers_total"`
+	PeersHealthy int     `json:"peers_healthy"`
+	HealthScore  float64 `json:"health_score"`
+	UptimeSec    float64 `json:"uptime_sec"`
+	MsgSent      int64   `json:"messages_sent"`
+	MsgRecv      int64   `json:"messages_recv"`
+
+	// Subnet routing (see internal/routing)
+	RoutesAdvertised int `json:"routes_advertised"`
+	RoutesActive     int `json:"routes_active"`
+
+	Metrics map[string]any `json:"metrics,omitempty"`
+}
+
+// Event is one state change folded into a HeartbeatEnvelope's Events slice,
+// the wire form of telemetry.Event (see telemetry.DeltaTracker).
+type Event struct {
+	Seq       uint64 `json:"seq"`
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+
+	PeerID string `json:"peer_id,omitempty"`
+
+	Score float64 `json:"score,omitempty"`
+
+	Prefix  string `json:"prefix,omitempty"`
+	OldNode string `json:"old_node,omitempty"`
+	NewNode string `json:"new_node,omitempty"`
+}
+
+// HeartbeatEnvelope is the batched-delta heartbeat body sent unless
+// Config.LegacyMode is set: Events carries every state change accumulated
+// since SinceSeq instead of repeating a full snapshot every tick. Full is
+// set on the first heartbeat after (re)registration and whenever the
+// Control Plane asks for a resync (see ResyncRequiredError), so the server
+// can tell a complete snapshot from an incremental one.
+type HeartbeatEnvelope struct {
+	Full     bool             `json:"full"`
+	SinceSeq uint64           `json:"since_seq"`
+	Events   []Event          `json:"events"`
+	Metrics  HeartbeatRequest `json:"metrics"`
+}
+
+// ResyncRequiredError is returned by SendHeartbeatDelta when the Control
+// Plane responds 409 Conflict with {"resync": true}, asking for a full
+// snapshot instead of a delta on the next heartbeat.
+type ResyncRequiredError struct{}
+
+func (ResyncRequiredError) Error() string {
+	return "control plane requested a full resync"
+}
+
+// PeeringToken is issued by the Control Plane so a foreign mesh can join
+// this mesh as a federation partner without merging into it.
+type PeeringToken struct {
+	Token           string   `json:"token"`
+	LocalMeshID     string   `json:"local_mesh_id"`
+	PublicKeys      []string `json:"public_keys"`
+	GatewayAddrs    []string `json:"gateway_addrs"`
+	ExpiresAt       int64    `json:"expires_at"`
+}
+
+// generatePeeringTokenRequest is sent to mint a peering token.
+type generatePeeringTokenRequest struct {
+	PeerMeshName string `json:"peer_mesh_name"`
+}
+
+// establishPeeringRequest consumes a peering token from the other side.
+type establishPeeringRequest struct {
+	Token string `json:"token"`
+}
+
+// GeneratePeeringToken mints a signed peering token that a foreign mesh can
+// redeem with EstablishPeering to federate with this mesh.
+func (c *Client) GeneratePeeringToken(peerMeshName string) (*PeeringToken, error) {
+	if c.meshID == "" {
+		return nil, fmt.Errorf("not registered (no mesh_id)")
+	}
+
+	body, err := json.Marshal(generatePeeringTokenRequest{PeerMeshName: peerMeshName})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/maas/%s/peerings/token", c.baseURL, c.meshID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("generate peering token failed (HTTP %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result PeeringToken
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	c.logger.Info("peering token generated", "peer_mesh_name", peerMeshName)
+	return &result, nil
+}
+
+// EstablishPeering consumes a peering token minted by a foreign mesh,
+// federating this mesh with it.
+func (c *Client) EstablishPeering(token string) error {
+	if c.meshID == "" {
+		return fmt.Errorf("not registered (no mesh_id)")
+	}
+
+	body, err := json.Marshal(establishPeeringRequest{Token: token})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/maas/%s/peerings/establish", c.baseURL, c.meshID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("establish peering failed (HTTP %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	c.logger.Info("peering established")
+	return nil
+}
+
+// exchangePSKRequest forwards an already-wrapped PSK to a peer via the
+// Control Plane, which relays it using the peer's registered public key.
+type exchangePSKRequest struct {
+	EncryptedPSK []byte `json:"encrypted_psk"`
+}
+
+// ExchangePSK hands an encrypted preshared key to the Control Plane for
+// delivery to peerID. encryptedPSK must already be wrapped for the peer
+// (see security.WrapForPeer) — the Control Plane never sees it in the clear.
+func (c *Client) ExchangePSK(peerID string, encryptedPSK []byte) error {
+	if c.meshID == "" {
+		return fmt.Errorf("not registered (no mesh_id)")
+	}
+
+	body, err := json.Marshal(exchangePSKRequest{EncryptedPSK: encryptedPSK})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/maas/%s/peers/%s/psk", c.baseURL, c.meshID, peerID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("exchange PSK failed (HTTP %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	c.logger.Info("PSK exchanged", "peer_id", peerID)
+	return nil
 }
 
 // NewClient creates a new Control Plane API client.
@@ -62,7 +248,8 @@ func NewClient(baseURL, joinToken string) *Client {
 		httpClient: &http.Client{
 			Timeout: 15 * time.Second,
 		},
-		logger: slog.Default().With("component", "api-client"),
+		streamClient: &http.Client{},
+		logger:       slog.Default().With("component", "api-client"),
 	}
 }
 
@@ -139,6 +326,51 @@ func (c *Client) SendHeartbeat(hb HeartbeatRequest) error {
 	return nil
 }
 
+// SendHeartbeatDelta pushes a batched delta envelope to the Control Plane
+// (see HeartbeatEnvelope). If the Control Plane responds 409 Conflict with
+// {"resync": true}, it returns ResyncRequiredError so the caller can resend
+// with Full set on the next heartbeat.
+func (c *Client) SendHeartbeatDelta(env HeartbeatEnvelope) error {
+	if c.meshID == "" {
+		return fmt.Errorf("not registered (no mesh_id)")
+	}
+
+	body, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshal heartbeat envelope: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/maas/%s/heartbeat", c.baseURL, c.meshID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send heartbeat: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		var conflict struct {
+			Resync bool `json:"resync"`
+		}
+		json.NewDecoder(resp.Body).Decode(&conflict)
+		if conflict.Resync {
+			return ResyncRequiredError{}
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("heartbeat failed (HTTP %d)", resp.StatusCode)
+	}
+
+	return nil
+}
+
 // GetMeshID returns the assigned mesh ID.
 func (c *Client) GetMeshID() string {
 	return c.meshID
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -147,3 +148,191 @@ func TestSendHeartbeat_Success(t *testing.T) {
 		t.Errorf("received messages_sent = %d", receivedHB.MsgSent)
 	}
 }
+
+func TestSendHeartbeatDelta_Success(t *testing.T) {
+	var receivedEnv HeartbeatEnvelope
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/maas/agent/register" {
+			json.NewEncoder(w).Encode(RegistrationResponse{MeshID: "mesh-d", APIKey: "key-d"})
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&receivedEnv)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token")
+	client.Register(RegistrationRequest{NodeID: "n1"})
+
+	err := client.SendHeartbeatDelta(HeartbeatEnvelope{
+		Full:     false,
+		SinceSeq: 3,
+		Events:   []Event{{Seq: 3, Type: "peer_joined", PeerID: "peer-x"}},
+		Metrics:  HeartbeatRequest{NodeID: "n1", PeersTotal: 5},
+	})
+
+	if err != nil {
+		t.Fatalf("SendHeartbeatDelta: %v", err)
+	}
+	if receivedEnv.SinceSeq != 3 {
+		t.Errorf("SinceSeq = %d, want 3", receivedEnv.SinceSeq)
+	}
+	if len(receivedEnv.Events) != 1 || receivedEnv.Events[0].PeerID != "peer-x" {
+		t.Errorf("Events = %+v", receivedEnv.Events)
+	}
+	if receivedEnv.Metrics.PeersTotal != 5 {
+		t.Errorf("Metrics.PeersTotal = %d, want 5", receivedEnv.Metrics.PeersTotal)
+	}
+}
+
+func TestSendHeartbeatDelta_NotRegistered(t *testing.T) {
+	client := NewClient("http://localhost", "token")
+	if err := client.SendHeartbeatDelta(HeartbeatEnvelope{}); err == nil {
+		t.Fatal("expected error when not registered")
+	}
+}
+
+func TestSendHeartbeatDelta_ResyncRequested(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/maas/agent/register" {
+			json.NewEncoder(w).Encode(RegistrationResponse{MeshID: "mesh-e", APIKey: "key-e"})
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"resync":true}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token")
+	client.Register(RegistrationRequest{NodeID: "n1"})
+
+	err := client.SendHeartbeatDelta(HeartbeatEnvelope{})
+	var resyncErr ResyncRequiredError
+	if !errors.As(err, &resyncErr) {
+		t.Fatalf("err = %v, want ResyncRequiredError", err)
+	}
+}
+
+func TestGeneratePeeringToken_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/maas/agent/register" {
+			json.NewEncoder(w).Encode(RegistrationResponse{MeshID: "mesh-a", APIKey: "key-a"})
+			return
+		}
+
+		if r.URL.Path != "/api/v1/maas/mesh-a/peerings/token" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var req generatePeeringTokenRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.PeerMeshName != "mesh-b" {
+			t.Errorf("peer_mesh_name = %s", req.PeerMeshName)
+		}
+
+		json.NewEncoder(w).Encode(PeeringToken{
+			Token:        "opaque-token",
+			LocalMeshID:  "mesh-a",
+			GatewayAddrs: []string{"1.2.3.4:5000"},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token")
+	client.Register(RegistrationRequest{NodeID: "n1"})
+
+	tok, err := client.GeneratePeeringToken("mesh-b")
+	if err != nil {
+		t.Fatalf("GeneratePeeringToken: %v", err)
+	}
+	if tok.Token != "opaque-token" {
+		t.Errorf("Token = %s", tok.Token)
+	}
+}
+
+func TestGeneratePeeringToken_NotRegistered(t *testing.T) {
+	client := NewClient("http://localhost", "token")
+	if _, err := client.GeneratePeeringToken("mesh-b"); err == nil {
+		t.Fatal("expected error when not registered")
+	}
+}
+
+func TestEstablishPeering_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/maas/agent/register" {
+			json.NewEncoder(w).Encode(RegistrationResponse{MeshID: "mesh-b", APIKey: "key-b"})
+			return
+		}
+
+		if r.URL.Path != "/api/v1/maas/mesh-b/peerings/establish" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var req establishPeeringRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Token != "opaque-token" {
+			t.Errorf("token = %s", req.Token)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token")
+	client.Register(RegistrationRequest{NodeID: "n1"})
+
+	if err := client.EstablishPeering("opaque-token"); err != nil {
+		t.Fatalf("EstablishPeering: %v", err)
+	}
+}
+
+func TestExchangePSK_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/maas/agent/register" {
+			json.NewEncoder(w).Encode(RegistrationResponse{MeshID: "mesh-c", APIKey: "key-c"})
+			return
+		}
+
+		if r.URL.Path != "/api/v1/maas/mesh-c/peers/peer-1/psk" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var req exchangePSKRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.EncryptedPSK) == 0 {
+			t.Error("expected non-empty encrypted_psk")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token")
+	client.Register(RegistrationRequest{NodeID: "n1"})
+
+	if err := client.ExchangePSK("peer-1", []byte("wrapped-psk-bytes")); err != nil {
+		t.Fatalf("ExchangePSK: %v", err)
+	}
+}
+
+func TestExchangePSK_NotRegistered(t *testing.T) {
+	client := NewClient("http://localhost", "token")
+	if err := client.ExchangePSK("peer-1", []byte("x")); err == nil {
+		t.Fatal("expected error when not registered")
+	}
+}
+
+func TestEstablishPeering_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/maas/agent/register" {
+			json.NewEncoder(w).Encode(RegistrationResponse{MeshID: "mesh-b", APIKey: "key-b"})
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"detail":"expired token"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token")
+	client.Register(RegistrationRequest{NodeID: "n1"})
+
+	if err := client.EstablishPeering("bad-token"); err == nil {
+		t.Fatal("expected error for expired token")
+	}
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// subscribeInitialBackoff and subscribeMaxBackoff bound the exponential
+// backoff Subscribe uses to reconnect after the event stream drops.
+const (
+	subscribeInitialBackoff = 1 * time.Second
+	subscribeMaxBackoff     = 30 * time.Second
+)
+
+// ControlEventType identifies which typed payload a ControlEvent carries.
+type ControlEventType string
+
+const (
+	EventPeerAddressUpdate ControlEventType = "peer_address_update"
+	EventACLPolicyUpdate   ControlEventType = "acl_policy_update"
+	EventConfigPatch       ControlEventType = "config_patch"
+	EventForceReregister   ControlEventType = "force_reregister"
+	EventPSKDelivery       ControlEventType = "psk_delivery"
+)
+
+// ControlEvent is one message from the Control Plane's push channel (see
+// Subscribe). ID is opaque and only meaningful for resuming the stream via
+// Last-Event-ID; exactly one of the typed payload fields is set, selected
+// by Type.
+type ControlEvent struct {
+	ID   string           `json:"id"`
+	Type ControlEventType `json:"type"`
+
+	PeerAddressUpdate *PeerAddressUpdate `json:"peer_address_update,omitempty"`
+	ACLPolicyUpdate   *ACLPolicyUpdate   `json:"acl_policy_update,omitempty"`
+	ConfigPatch       *ConfigPatch       `json:"config_patch,omitempty"`
+	ForceReregister   *ForceReregister   `json:"force_reregister,omitempty"`
+	PSKDelivery       *PSKDelivery       `json:"psk_delivery,omitempty"`
+}
+
+// PeerAddressUpdate announces a bootstrap peer address the Control Plane
+// wants this agent to dial, so newly-joined mesh members propagate
+// without waiting for multicast discovery to find them.
+type PeerAddressUpdate struct {
+	NodeID    string   `json:"node_id"`
+	Addresses []string `json:"addresses"`
+}
+
+// ACLPolicyUpdate reflects a policy change the agent should apply
+// immediately rather than at its next restart.
+type ACLPolicyUpdate struct {
+	PolicyID string `json:"policy_id"`
+	Action   string `json:"action"` // "upsert" or "delete"
+	MeshID   string `json:"mesh_id"`
+	// AllowedServices is only set for Action == "upsert".
+	AllowedServices []string `json:"allowed_services,omitempty"`
+}
+
+// ConfigPatch carries a partial config update, keyed the same as Config's
+// yaml tags (see Config.ApplyPatch).
+type ConfigPatch struct {
+	Patch map[string]any `json:"patch"`
+}
+
+// ForceReregister tells the agent its registration is no longer valid and
+// it must call Register again before anything else will succeed.
+type ForceReregister struct {
+	Reason string `json:"reason"`
+}
+
+// PSKDelivery hands this agent a PSK another peer wrapped for it with
+// security.WrapForPeer (see ExchangePSK) — the other half of the exchange
+// that pushed EncryptedPSK to the Control Plane in the first place. The
+// agent recovers the PSK with security.UnwrapForPeer and stores it as the
+// Current key for the (NodeID, PeerID) pair.
+type PSKDelivery struct {
+	PeerID       string `json:"peer_id"`
+	EncryptedPSK []byte `json:"encrypted_psk"`
+}
+
+// Subscribe opens a long-lived streaming connection to the Control
+// Plane's push channel and returns a channel of ControlEvents, so
+// server-initiated changes (new bootstrap peers, ACL updates, config
+// patches) reach the agent without waiting on SendHeartbeat's ticker.
+// The returned channel is closed once ctx is canceled; until then,
+// Subscribe reconnects automatically with exponential backoff and
+// resumes from the last delivered event ID via Last-Event-ID.
+func (c *Client) Subscribe(ctx context.Context) (<-chan ControlEvent, error) {
+	if c.meshID == "" {
+		return nil, fmt.Errorf("not registered (no mesh_id)")
+	}
+
+	events := make(chan ControlEvent)
+	go c.streamEvents(ctx, events)
+	return events, nil
+}
+
+func (c *Client) streamEvents(ctx context.Context, events chan<- ControlEvent) {
+	defer close(events)
+
+	var lastEventID string
+	backoff := subscribeInitialBackoff
+	for ctx.Err() == nil {
+		connected, id, err := c.runEventStream(ctx, lastEventID, events)
+		if id != "" {
+			lastEventID = id
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			c.logger.Warn("control event stream disconnected, reconnecting", "error", err)
+		}
+		if connected {
+			backoff = subscribeInitialBackoff
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > subscribeMaxBackoff {
+			backoff = subscribeMaxBackoff
+		}
+	}
+}
+
+// runEventStream holds one event-stream connection open until it drops or
+// ctx is canceled, decoding newline-delimited JSON ControlEvents and
+// forwarding each to events. connected reports whether the stream ever
+// reached a healthy HTTP response, so the caller can reset its backoff
+// even if it later drops; lastEventID is the most recent event ID seen,
+// for Last-Event-ID on the next reconnect.
+func (c *Client) runEventStream(ctx context.Context, lastEventID string, events chan<- ControlEvent) (connected bool, newLastEventID string, err error) {
+	url := fmt.Sprintf("%s/api/v1/maas/agent/events", c.baseURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false, lastEventID, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return false, lastEventID, fmt.Errorf("connect: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return false, lastEventID, fmt.Errorf("event stream failed (HTTP %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev ControlEvent
+		if err := dec.Decode(&ev); err != nil {
+			if err == io.EOF {
+				return true, lastEventID, nil
+			}
+			return true, lastEventID, fmt.Errorf("decode event: %w", err)
+		}
+		lastEventID = ev.ID
+
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+			return true, lastEventID, nil
+		}
+	}
+}
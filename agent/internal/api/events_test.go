@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func registeredClientForEventsTest(t *testing.T, handler http.HandlerFunc) (*Client, *httptest.Server) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/maas/agent/register" {
+			json.NewEncoder(w).Encode(RegistrationResponse{MeshID: "mesh-a", APIKey: "key-a"})
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL, "token")
+	if _, err := client.Register(RegistrationRequest{NodeID: "n1"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return client, server
+}
+
+func TestSubscribe_NotRegistered(t *testing.T) {
+	client := NewClient("http://localhost", "token")
+	if _, err := client.Subscribe(context.Background()); err == nil {
+		t.Fatal("expected error when not registered")
+	}
+}
+
+func TestSubscribe_DeliversEvents(t *testing.T) {
+	client, _ := registeredClientForEventsTest(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/maas/agent/events" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if r.Header.Get("X-API-Key") != "key-a" {
+			t.Errorf("X-API-Key = %s", r.Header.Get("X-API-Key"))
+		}
+
+		enc := json.NewEncoder(w)
+		enc.Encode(ControlEvent{ID: "1", Type: EventPeerAddressUpdate, PeerAddressUpdate: &PeerAddressUpdate{
+			NodeID:    "peer-1",
+			Addresses: []string{"10.0.0.1:5000"},
+		}})
+		w.(http.Flusher).Flush()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	events, err := client.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Type != EventPeerAddressUpdate || ev.PeerAddressUpdate == nil || ev.PeerAddressUpdate.NodeID != "peer-1" {
+			t.Errorf("event = %+v, want a peer_address_update for peer-1", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
+
+func TestSubscribe_ReconnectsWithLastEventID(t *testing.T) {
+	var connCount int32
+
+	client, _ := registeredClientForEventsTest(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&connCount, 1)
+		if n == 1 {
+			if r.Header.Get("Last-Event-ID") != "" {
+				t.Errorf("Last-Event-ID on first connection = %q, want empty", r.Header.Get("Last-Event-ID"))
+			}
+			json.NewEncoder(w).Encode(ControlEvent{ID: "evt-1", Type: EventForceReregister, ForceReregister: &ForceReregister{Reason: "test"}})
+			w.(http.Flusher).Flush()
+			return
+		}
+		if r.Header.Get("Last-Event-ID") != "evt-1" {
+			t.Errorf("Last-Event-ID on reconnect = %q, want evt-1", r.Header.Get("Last-Event-ID"))
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	events, err := client.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	select {
+	case <-events:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for first event")
+	}
+
+	deadline := time.After(2 * time.Second)
+	for atomic.LoadInt32(&connCount) < 2 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for reconnect")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
@@ -16,12 +16,24 @@ const (
 	DefaultDataDir    = "/var/lib/x0t"
 	DefaultConfigPath = "/etc/x0t/agent.yaml"
 	DefaultLogLevel   = "info"
+	DefaultMode       = ModeEdge
+)
+
+// Node roles for Config.Mode.
+const (
+	ModeEdge      = "edge"      // default: carries user traffic, forwards for the mesh
+	ModeBootstrap = "bootstrap" // discovery-only super-node, see mesh.Node bootstrap mode
 )
 
 // Config defines the agent configuration.
 type Config struct {
 	// Node identity
-	NodeID string `yaml:"node_id"` // auto-generated UUID if empty
+	NodeID     string `yaml:"node_id"`     // auto-generated UUID if empty
+	PrivateKey string `yaml:"private_key"` // hex-encoded Ed25519 private key (see `genkey`)
+	PublicKey  string `yaml:"public_key"`  // hex-encoded Ed25519 public key, derived from PrivateKey
+
+	// Mode selects the node's role: edge (default) or bootstrap.
+	Mode string `yaml:"mode"`
 
 	// Control Plane
 	APIEndpoint string `yaml:"api_endpoint"` // Control Plane URL
@@ -33,13 +45,27 @@ type Config struct {
 	BindAddr   string `yaml:"bind_addr"`   // default "0.0.0.0"
 
 	// Discovery
-	MulticastGroup string `yaml:"multicast_group"` // default 239.255.77.77
-	MulticastPort  int    `yaml:"multicast_port"`  // default 7777
-	BootstrapNodes []string `yaml:"bootstrap_nodes"` // ["host:port", ...]
+	MulticastGroup    string   `yaml:"multicast_group"`     // default 239.255.77.77
+	MulticastPort     int      `yaml:"multicast_port"`      // default 7777
+	BootstrapNodes    []string `yaml:"bootstrap_nodes"`     // ["host:port", ...], queried when multicast is unreachable
+	NATTraversal      bool     `yaml:"nat_traversal"`       // learn a public addr via UPnP/NAT-PMP/STUN instead of the LAN IP
+	PeeringListenAddr string   `yaml:"peering_listen_addr"` // "host:port" to accept inbound mesh-to-mesh peering streams; empty disables (see `peering generate`)
+
+	// Relay fallback for NAT-blocked peers
+	RelayEndpoints  []string `yaml:"relay_endpoints"`   // wss://relay.x0tta6bl4.io/relay, ...
+	RelayAuthSecret string   `yaml:"relay_auth_secret"` // HMAC key shared with the relay server
+
+	// Cluster bootstrap (see internal/peering.EstablishPeering): joining a
+	// cluster for the first time via a gateway-issued peering token, as
+	// opposed to JoinToken above, which authenticates this node to the MaaS
+	// control plane itself.
+	ClusterToken         string `yaml:"-"`                      // one-shot bootstrap token, CLI-only (--cluster-token); never persisted
+	ClusterGatewaySecret string `yaml:"cluster_gateway_secret"` // HMAC key shared with the cluster's bootstrap gateways, verifies ClusterToken
 
 	// Security
-	PQCEnabled bool   `yaml:"pqc_enabled"` // default true
-	Obfuscation string `yaml:"obfuscation"` // none|xor|aes
+	PQCEnabled            bool   `yaml:"pqc_enabled"`             // default true
+	Obfuscation           string `yaml:"obfuscation"`             // none|xor|aes
+	RotationIntervalHours int    `yaml:"rotation_interval_hours"` // per-peer PSK rekey interval, 0 disables
 
 	// Traffic
 	TrafficProfile string `yaml:"traffic_profile"` // none|gaming|streaming|voip
@@ -51,23 +77,66 @@ type Config struct {
 	LogLevel string `yaml:"log_level"` // debug|info|warn|error
 
 	// Telemetry
-	HeartbeatIntervalSec int `yaml:"heartbeat_interval_sec"` // default 30
+	HeartbeatIntervalSec   int  `yaml:"heartbeat_interval_sec"`    // default 30
+	HeartbeatBatchWindowMs int  `yaml:"heartbeat_batch_window_ms"` // default 500; see telemetry.DeltaTracker
+	LegacyMode             bool `yaml:"legacy_mode"`               // send a full HeartbeatRequest every tick instead of a batched delta envelope, for Control Planes that predate HeartbeatEnvelope
+
+	// Routing (multi-hop overlay)
+	Routing RoutingConfig `yaml:"routing"`
+
+	// AdvertisedRoutes lists the CIDR subnets (e.g. "10.0.0.0/24") this
+	// node offers to route to as a subnet router, gossiped to peers via
+	// discovery.PeerInfo.AdvertisedRoutes (see internal/routing). Empty
+	// by default: advertising a route is opt-in.
+	AdvertisedRoutes []string `yaml:"advertised_routes"`
+
+	// Federation (cross-mesh peering)
+	PeeredMeshes []PeeringInfo `yaml:"peered_meshes"`
+
+	// Admin API (local operator control plane, see internal/admin)
+	AdminListen string `yaml:"admin_listen"` // default 127.0.0.1:5001; auth token via X0T_ADMIN_TOKEN env
+}
+
+// PeeringInfo describes one established federation with a foreign mesh.
+type PeeringInfo struct {
+	MeshID           string   `yaml:"mesh_id"`           // foreign mesh identifier
+	GatewayAddrs     []string `yaml:"gateway_addrs"`     // foreign gateway node addresses
+	AllowedServices  []string `yaml:"allowed_services"`  // ACL: services importable from this peering
+	ExportedServices []string `yaml:"exported_services"` // ACL: services replicated to this peering; empty exports everything
+}
+
+// RoutingConfig tunes the multi-hop routing subsystem (internal/mesh/routing).
+type RoutingConfig struct {
+	JitterToleranceMs         float64 `yaml:"jitter_tolerance_ms"`         // default 5
+	JitterToleranceMultiplier float64 `yaml:"jitter_tolerance_multiplier"` // default 0.2
+	RecalculateCooldownSec    int     `yaml:"recalculate_cooldown_sec"`    // default 5
+	AdvertisementIntervalSec  int     `yaml:"advertisement_interval_sec"`  // default 15
 }
 
 // DefaultConfig returns a Config with sane defaults.
 func DefaultConfig() *Config {
 	return &Config{
-		APIEndpoint:          DefaultAPIURL,
-		ListenPort:           DefaultListenPort,
-		BindAddr:             "0.0.0.0",
-		MulticastGroup:       "239.255.77.77",
-		MulticastPort:        7777,
-		PQCEnabled:           true,
-		Obfuscation:          "none",
-		TrafficProfile:       "none",
-		DataDir:              DefaultDataDir,
-		LogLevel:             DefaultLogLevel,
-		HeartbeatIntervalSec: 30,
+		APIEndpoint:            DefaultAPIURL,
+		ListenPort:             DefaultListenPort,
+		BindAddr:               "0.0.0.0",
+		MulticastGroup:         "239.255.77.77",
+		MulticastPort:          7777,
+		PQCEnabled:             true,
+		Obfuscation:            "none",
+		RotationIntervalHours:  24,
+		TrafficProfile:         "none",
+		DataDir:                DefaultDataDir,
+		LogLevel:               DefaultLogLevel,
+		HeartbeatIntervalSec:   30,
+		HeartbeatBatchWindowMs: 500,
+		AdminListen:            "127.0.0.1:5001",
+		Mode:                   DefaultMode,
+		Routing: RoutingConfig{
+			JitterToleranceMs:         5,
+			JitterToleranceMultiplier: 0.2,
+			RecalculateCooldownSec:    5,
+			AdvertisementIntervalSec:  15,
+		},
 	}
 }
 
@@ -116,6 +185,45 @@ func (c *Config) ApplyEnvOverrides() {
 	}
 }
 
+// ApplyPatch applies a partial config update pushed by the Control Plane
+// (see api.ConfigPatch), keyed the same as the yaml tags above. Only a
+// known-safe subset of fields that are sane to change live, without
+// restarting the agent, are accepted; anything else is reported as an
+// error rather than silently ignored.
+func (c *Config) ApplyPatch(patch map[string]any) error {
+	for key, val := range patch {
+		switch key {
+		case "log_level":
+			s, ok := val.(string)
+			if !ok {
+				return fmt.Errorf("config patch %q: want string, got %T", key, val)
+			}
+			c.LogLevel = s
+		case "traffic_profile":
+			s, ok := val.(string)
+			if !ok {
+				return fmt.Errorf("config patch %q: want string, got %T", key, val)
+			}
+			c.TrafficProfile = s
+		case "heartbeat_interval_sec":
+			n, ok := val.(float64)
+			if !ok {
+				return fmt.Errorf("config patch %q: want number, got %T", key, val)
+			}
+			c.HeartbeatIntervalSec = int(n)
+		case "rotation_interval_hours":
+			n, ok := val.(float64)
+			if !ok {
+				return fmt.Errorf("config patch %q: want number, got %T", key, val)
+			}
+			c.RotationIntervalHours = int(n)
+		default:
+			return fmt.Errorf("config patch: field %q is not patchable at runtime", key)
+		}
+	}
+	return nil
+}
+
 // Validate checks that the config is valid.
 func (c *Config) Validate() error {
 	if c.ListenPort < 1 || c.ListenPort > 65535 {
@@ -135,6 +243,11 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid traffic_profile: %s", c.TrafficProfile)
 	}
 
+	validMode := map[string]bool{ModeEdge: true, ModeBootstrap: true}
+	if !validMode[c.Mode] {
+		return fmt.Errorf("invalid mode: %s (valid: %s, %s)", c.Mode, ModeEdge, ModeBootstrap)
+	}
+
 	return nil
 }
 
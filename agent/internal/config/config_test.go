@@ -30,6 +30,9 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.LogLevel != "info" {
 		t.Errorf("LogLevel = %s, want info", cfg.LogLevel)
 	}
+	if cfg.RotationIntervalHours != 24 {
+		t.Errorf("RotationIntervalHours = %d, want 24", cfg.RotationIntervalHours)
+	}
 }
 
 func TestLoadFromFile_Defaults(t *testing.T) {
@@ -155,6 +158,14 @@ func TestValidate_BadTrafficProfile(t *testing.T) {
 	}
 }
 
+func TestValidate_BadMode(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Mode = "super"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid mode")
+	}
+}
+
 func TestSaveAndReload(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "saved.yaml")
@@ -1,26 +1,187 @@
 // Package pqc implements Post-Quantum Cryptography for mesh tunnels.
 // Uses ML-KEM-768 (Kyber) for key exchange + AES-256-GCM for data.
 // Wire-compatible with Python pqc_tunnel.py handshake format.
+// Sessions rekey automatically (WireGuard-style, see NeedsRekey/CreateRekeyInit)
+// and ProcessHandshakeInit can demand a MAC cookie under load (see SetUnderLoad).
+// Handshake and PQC2-framed data packets additionally carry a WireGuard-style
+// MAC1 (see computeMAC1) so a receiver can reject a corrupted or spoofed
+// packet with one cheap BLAKE2s hash before spending a KEM encapsulation or
+// AEAD open on it. Per-peer traffic shaping (see Shaper) can additionally
+// pad or rate-constantize WrapPacket/UnwrapPacket traffic against
+// passive size/timing analysis, GoVPN CPR-style. A node created with
+// NewTunnelManagerWithSuite(SuiteHybridX25519MLKEM768) instead negotiates a
+// hybrid handshake (CreateHandshakeInitHybrid/ProcessHandshakeInitHybrid)
+// that combines an ephemeral X25519 ECDH with the ML-KEM-768 encapsulation
+// and authenticates both against a TrustPeer-pinned Ed25519 identity key.
 package pqc
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"runtime"
 	"sync"
+	"time"
 
 	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
+	"golang.org/x/crypto/blake2s"
 	"golang.org/x/crypto/hkdf"
 )
 
 // PQCAvailable indicates whether real PQC crypto is available.
 var PQCAvailable = true
 
+// Rekey thresholds: a session must rotate its symmetric key after
+// whichever of these comes first, bounding how much ciphertext is ever
+// exposed to a single AES-256-GCM key.
+const (
+	RekeyAfterTime     = 120 * time.Second
+	RekeyAfterMessages = 1 << 48 // AES-256-GCM's nonce-reuse bound, WireGuard-style
+	RekeyAfterBytes    = 1 << 30 // ~1GiB
+
+	// RejectAfterTime is how long the superseded key stays valid for
+	// Decrypt after a rekey, long enough for packets already in flight
+	// under the old key to land instead of being dropped.
+	RejectAfterTime = 180 * time.Second
+)
+
+// replayWindowSize is how many trailing nonce counters Decrypt tracks per
+// session to reject replays, WireGuard-style.
+const replayWindowSize = 2048
+
+// cookieSize is the HMAC-SHA256 output size used for the cookie challenge.
+const cookieSize = 32
+
+// x25519PubSize is the width of a crypto/ecdh X25519 public key, used to
+// lay out the hybrid handshake's fixed-offset fields (see parseHybridFields).
+const x25519PubSize = 32
+
+// HandshakeSuite selects which key-exchange algorithms a TunnelManager's
+// CreateHandshakeInit*/ProcessHandshakeInit* pair uses to establish a
+// session. Selectable via NewTunnelManagerWithSuite.
+type HandshakeSuite int
+
+const (
+	// SuiteMLKEM768 is the original, wire-compatible-with-Python pure
+	// ML-KEM-768 handshake (CreateHandshakeInit/ProcessHandshakeInit).
+	SuiteMLKEM768 HandshakeSuite = iota
+	// SuiteHybridX25519MLKEM768 additionally performs an ephemeral X25519
+	// ECDH alongside the ML-KEM-768 encapsulation
+	// (CreateHandshakeInitHybrid/ProcessHandshakeInitHybrid), so a future
+	// break of either algorithm alone still leaves the session key secure,
+	// and authenticates both ephemeral keys with a long-lived Ed25519
+	// identity signature checked against a TrustPeer-pinned allowlist
+	// instead of trusting whatever node_id the peer claims.
+	SuiteHybridX25519MLKEM768
+)
+
+func (s HandshakeSuite) String() string {
+	if s == SuiteHybridX25519MLKEM768 {
+		return "hybrid-x25519-mlkem768"
+	}
+	return "mlkem768"
+}
+
+// handshakeSuiteTagHybrid tags a hybrid handshake message's leading byte,
+// letting a receiver that speaks both suites pick the right parser without
+// a separate wire message type. SuiteMLKEM768 carries no such tag at all —
+// CreateHandshakeInit's framing is unchanged — so existing Python
+// pqc_tunnel.py peers stay wire-compatible.
+const handshakeSuiteTagHybrid byte = 0x01
+
+// mac1Size is the BLAKE2s-128 output size used for the handshake and PQC2
+// data-packet MAC1 (see computeMAC1).
+const mac1Size = 16
+
+// mac1Label keys mac1Key, matching WireGuard's own "mac1--" label.
+const mac1Label = "mac1--"
+
+// cookieSecretLifetime bounds how long a cookie-MAC secret stays valid
+// before ProcessHandshakeInit rotates it, matching WireGuard's cookie
+// rotation cadence: short enough a compromised secret is useless quickly.
+const cookieSecretLifetime = 2 * time.Minute
+
+// mac1Key derives the BLAKE2s-256 key WireGuard calls mac1: an unkeyed hash
+// of the label "mac1--" concatenated with the receiver's static public key.
+func mac1Key(receiverPubKey []byte) ([]byte, error) {
+	h, err := blake2s.New256(nil)
+	if err != nil {
+		return nil, err
+	}
+	h.Write([]byte(mac1Label))
+	h.Write(receiverPubKey)
+	return h.Sum(nil), nil
+}
+
+// computeMAC1 computes a keyed BLAKE2s-128 MAC over msg, keyed by
+// mac1Key(receiverPubKey). Appended to a handshake message, it lets whoever
+// holds receiverPubKey's private key reject a corrupted or spoofed message
+// with one cheap hash before spending a KEM encapsulation or decapsulation.
+func computeMAC1(receiverPubKey, msg []byte) ([]byte, error) {
+	key, err := mac1Key(receiverPubKey)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := blake2s.New128(key)
+	if err != nil {
+		return nil, err
+	}
+	mac.Write(msg)
+	return mac.Sum(nil), nil
+}
+
+// stripAndVerifyMAC1 checks whether payload carries a trailing MAC1 (see
+// computeMAC1) keyed on ownPubKey, verifies it when present, and returns
+// payload with the MAC1 stripped off so the caller sees exactly
+// wantLen bytes. A payload of exactly wantLen bytes (no MAC1 at all) is
+// accepted unverified, for a responder that doesn't append one, such as
+// ProcessHandshakeInitHybrid's cookie challenge.
+func stripAndVerifyMAC1(data, payload, ownPubKey []byte, wantLen int) ([]byte, error) {
+	switch len(payload) {
+	case wantLen:
+		return payload, nil
+	case wantLen + mac1Size:
+		base := data[:len(data)-mac1Size]
+		gotMAC1 := data[len(data)-mac1Size:]
+		expectedMAC1, err := computeMAC1(ownPubKey, base)
+		if err != nil {
+			return nil, fmt.Errorf("compute mac1: %w", err)
+		}
+		if !hmac.Equal(gotMAC1, expectedMAC1) {
+			return nil, fmt.Errorf("pqc: handshake response MAC1 mismatch, dropping before KEM")
+		}
+		return payload[:wantLen], nil
+	default:
+		return nil, fmt.Errorf("handshake response truncated")
+	}
+}
+
+// ErrCookieRequired is returned by ProcessHandshakeResponse when the peer is
+// demanding a MAC cookie before it will spend a KEM encapsulation on us; the
+// accompanying []byte is then the cookie to echo back via
+// CreateHandshakeInitWithCookie. ProcessHandshakeInit returns it when this
+// side is the one demanding the cookie; its accompanying response message is
+// then the cookie challenge to send back to the initiator.
+var ErrCookieRequired = errors.New("peer requires a cookie: retry with CreateHandshakeInitWithCookie")
+
+// handshakeRespNormal/handshakeRespCookie tag a handshake response so
+// ProcessHandshakeResponse can tell a real KEM ciphertext from a cookie
+// challenge without a separate wire message type.
+const (
+	handshakeRespNormal byte = 0x01
+	handshakeRespCookie byte = 0x02
+)
+
 // KeyPair holds a PQC key pair.
 type KeyPair struct {
 	PublicKey  []byte
@@ -29,11 +190,138 @@ type KeyPair struct {
 	Algorithm  string
 }
 
+// replayWindowWords sizes replayWindow.bits to hold replayWindowSize bits.
+const replayWindowWords = replayWindowSize / 64
+
+// sendSaltSize is the width of SessionKey.sendSalt: together with the
+// 8-byte counter it fills a standard 12-byte AES-GCM nonce.
+const sendSaltSize = 4
+
+// newSendSalt generates a fresh random salt for a new session's outbound
+// nonces (see SessionKey.sendSalt).
+func newSendSalt() ([sendSaltSize]byte, error) {
+	var salt [sendSaltSize]byte
+	if _, err := io.ReadFull(rand.Reader, salt[:]); err != nil {
+		return salt, err
+	}
+	return salt, nil
+}
+
+// replayWindow rejects duplicate or too-far-in-the-past nonce counters: a
+// bitmask of the last replayWindowSize counters accepted relative to the
+// highest one seen, same scheme WireGuard uses for its nonce counter.
+type replayWindow struct {
+	have    bool
+	highest uint64
+	bits    [replayWindowWords]uint64 // bit i (0-indexed from the low end) means "highest-i accepted"
+}
+
+// check reports whether counter would be accepted without mutating the
+// window, letting Decrypt reject obvious replays before paying for an
+// AEAD Open call.
+func (w *replayWindow) check(counter uint64) bool {
+	if !w.have || counter > w.highest {
+		return true
+	}
+	diff := w.highest - counter
+	if diff >= replayWindowSize {
+		return false
+	}
+	return !w.testBit(diff)
+}
+
+// commit records counter as accepted. Call only after the packet it
+// belongs to has actually decrypted successfully.
+func (w *replayWindow) commit(counter uint64) {
+	if !w.have {
+		w.have = true
+		w.highest = counter
+		w.setBit(0)
+		return
+	}
+	if counter > w.highest {
+		w.shift(counter - w.highest)
+		w.highest = counter
+		w.setBit(0)
+		return
+	}
+	w.setBit(w.highest - counter)
+}
+
+// shift slides the window left by n bits (a new highest counter arrived n
+// above the old one), carrying bits across word boundaries from low index
+// (nearest highest) to high index (farthest), the same direction the
+// original 2-word version did, just generalized to replayWindowWords words.
+func (w *replayWindow) shift(n uint64) {
+	if n >= replayWindowSize {
+		w.bits = [replayWindowWords]uint64{}
+		return
+	}
+	wordShift := int(n / 64)
+	bitShift := n % 64
+
+	for i := replayWindowWords - 1; i >= 0; i-- {
+		var v uint64
+		if src := i - wordShift; src >= 0 {
+			v = w.bits[src] << bitShift
+			if bitShift > 0 && src-1 >= 0 {
+				v |= w.bits[src-1] >> (64 - bitShift)
+			}
+		}
+		w.bits[i] = v
+	}
+}
+
+func (w *replayWindow) setBit(offset uint64) {
+	word, bit := offset/64, offset%64
+	w.bits[word] |= 1 << bit
+}
+
+func (w *replayWindow) testBit(offset uint64) bool {
+	word, bit := offset/64, offset%64
+	return w.bits[word]&(1<<bit) != 0
+}
+
 // SessionKey holds an established session between two peers.
 type SessionKey struct {
 	PeerID    string
 	SharedKey []byte // 32 bytes for AES-256
 	AEAD      cipher.AEAD
+
+	// peerPubKey is set only on the side that called ProcessHandshakeInit,
+	// since the KEM encapsulation only runs in that direction; it lets
+	// that side originate a rekey (see CreateRekeyInit). The side that
+	// called ProcessHandshakeResponse never learns the peer's public key
+	// under this wire format and can only respond to an incoming rekey.
+	peerPubKey []byte
+
+	establishedAt time.Time
+	msgCount      uint64
+	byteCount     uint64
+
+	// sendSalt is a random value generated once per session and prepended
+	// to sendCounter to build each outbound nonce (see Encrypt), so that
+	// two sessions can never reuse a nonce even if their counters happen to
+	// line up — unlike a pure counter, which only guarantees uniqueness
+	// within a single session's lifetime.
+	sendSalt    [sendSaltSize]byte
+	sendCounter uint64
+	replay      replayWindow
+
+	// pendingDecrypt reserves counters that passed replay.check but whose
+	// AEAD.Open is still outstanding on the worker pool (see DecryptAsync),
+	// so a concurrent call for the same counter is rejected at check time
+	// instead of also passing check and only discovering the duplicate
+	// once both results reach commitDecrypt. Guarded by TunnelManager.mu,
+	// same as replay; entries are removed once the pending Open resolves,
+	// win or lose.
+	pendingDecrypt map[uint64]struct{}
+
+	// prevKey/prevAEAD is the key superseded by the most recent rekey,
+	// kept usable by Decrypt until rekeyedAt+RejectAfterTime.
+	prevKey   []byte
+	prevAEAD  cipher.AEAD
+	rekeyedAt time.Time
 }
 
 // TunnelManager manages PQC tunnels to multiple peers.
@@ -43,10 +331,104 @@ type TunnelManager struct {
 	keys     *KeyPair
 	sessions map[string]*SessionKey
 	logger   *slog.Logger
+
+	underLoad         bool
+	cookieSecret      []byte
+	cookieSecretSetAt time.Time
+
+	// wg tracks goroutines started by StartRekeyLoop, StartEncryptWorkers
+	// (including the per-peer transmitters EncryptAsync/DecryptAsync spawn)
+	// and StartShaperLoop, so Wait can block until they've actually exited
+	// rather than just been told to.
+	wg sync.WaitGroup
+
+	// jobs feeds the worker pool started by StartEncryptWorkers: EncryptAsync
+	// and DecryptAsync each push a closure that does the one CPU-bound step
+	// (AEAD.Seal or AEAD.Open) a worker should run. Buffered so a burst of
+	// calls doesn't stall waiting for a free worker; full just means the
+	// caller falls back to doing the work inline (see EncryptAsync).
+	jobs chan func()
+
+	// asyncCtx is the context StartEncryptWorkers was last called with, nil
+	// until then. EncryptAsync/DecryptAsync check it (and that it isn't
+	// already cancelled) before touching tm.jobs or a per-peer queue at
+	// all — with no live worker pool, tm.jobs would just buffer the job
+	// forever since nothing drains it, so nil/cancelled means seal or open
+	// inline instead.
+	asyncCtx context.Context
+
+	// peerEncQueues/peerDecQueues hold, per peer, the FIFO queue EncryptAsync/
+	// DecryptAsync enqueue onto once the worker pool is running, and
+	// runPeerEncTransmitter/runPeerDecTransmitter drain in enqueue order —
+	// WireGuard send.go/receive.go-style: the AEAD.Seal/AEAD.Open itself can
+	// complete on any worker in any order, but each peer's single sequential
+	// transmitter goroutine only ever delivers results to callers in the
+	// order they were enqueued, so per-peer ordering is a guarantee this
+	// package provides rather than something callers must arrange by reading
+	// channels in a particular sequence. Created lazily, one goroutine each,
+	// the first time a peer is seen while asyncCtx is live.
+	peerEncQueues map[string]chan *encElement
+	peerDecQueues map[string]chan *decElement
+
+	// shapers holds per-peer traffic-shaping config and state, set via
+	// SetShaper; a peer absent from this map is unshaped (ShaperNone).
+	shapers map[string]*shaperState
+
+	// suite is which handshake CreateHandshakeInit*/ProcessHandshakeInit*
+	// this TunnelManager was created for, set by NewTunnelManagerWithSuite.
+	suite HandshakeSuite
+
+	// identityPriv/identityPub is this node's long-lived Ed25519 signing
+	// key, used only by the hybrid suite to authenticate its ephemeral
+	// X25519 and ML-KEM-768 public keys (see CreateHandshakeInitHybrid).
+	// Nil under SuiteMLKEM768.
+	identityPriv ed25519.PrivateKey
+	identityPub  ed25519.PublicKey
+
+	// trustedPeers pins peerID -> expected Ed25519 identity public key for
+	// the hybrid suite (see TrustPeer). ProcessHandshakeInitHybrid and
+	// ProcessHandshakeResponseHybrid reject a handshake whose signer
+	// doesn't match the pin, rather than trusting whatever node_id and
+	// identity key the peer's message happens to claim.
+	trustedPeers map[string]ed25519.PublicKey
+
+	// pendingHybrid holds this node's own ephemeral X25519 private key for
+	// a hybrid handshake it initiated, between CreateHandshakeInitHybrid
+	// and the matching ProcessHandshakeResponseHybrid — the ECDH can't
+	// complete until the peer's ephemeral public key arrives in the
+	// response. Consumed (and deleted) the moment the response is
+	// processed.
+	pendingHybrid map[string]*pendingHybridHandshake
+}
+
+// pendingHybridHandshake is what CreateHandshakeInitHybrid stashes for an
+// outstanding hybrid handshake: the ephemeral X25519 key it generated, and
+// the exact init message bytes it sent, both needed once the response
+// arrives — the ephemeral key to complete the ECDH, the init bytes to
+// recompute the same transcript hash the responder derived its session key
+// from.
+type pendingHybridHandshake struct {
+	ephemeral *ecdh.PrivateKey
+	initMsg   []byte
 }
 
-// NewTunnelManager creates a new tunnel manager with generated ML-KEM-768 keys.
+// jobQueueSize bounds how many pending EncryptAsync/DecryptAsync jobs queue
+// up before callers fall back to sealing/opening inline.
+const jobQueueSize = 256
+
+// NewTunnelManager creates a new tunnel manager with generated ML-KEM-768
+// keys, using SuiteMLKEM768. See NewTunnelManagerWithSuite to opt into the
+// hybrid X25519+ML-KEM-768 suite instead.
 func NewTunnelManager(nodeID string) (*TunnelManager, error) {
+	return NewTunnelManagerWithSuite(nodeID, SuiteMLKEM768)
+}
+
+// NewTunnelManagerWithSuite creates a new tunnel manager with generated
+// ML-KEM-768 keys and, under SuiteHybridX25519MLKEM768, an additional
+// long-lived Ed25519 identity key used to authenticate that suite's
+// handshakes (see CreateHandshakeInitHybrid). Share GetIdentityPublicKey's
+// output with peers out of band so they can pin it via TrustPeer.
+func NewTunnelManagerWithSuite(nodeID string, suite HandshakeSuite) (*TunnelManager, error) {
 	pk, sk, err := mlkem768.GenerateKeyPair(rand.Reader)
 	if err != nil {
 		return nil, fmt.Errorf("generate ML-KEM-768 keys: %w", err)
@@ -64,12 +446,67 @@ func NewTunnelManager(nodeID string) (*TunnelManager, error) {
 		Algorithm:  "ML-KEM-768",
 	}
 
-	return &TunnelManager{
+	tm := &TunnelManager{
 		nodeID:   nodeID,
 		keys:     keys,
 		sessions: make(map[string]*SessionKey),
 		logger:   slog.Default().With("component", "pqc"),
-	}, nil
+		jobs:     make(chan func(), jobQueueSize),
+		suite:    suite,
+	}
+
+	if suite == SuiteHybridX25519MLKEM768 {
+		identityPub, identityPriv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate ed25519 identity key: %w", err)
+		}
+		tm.identityPriv = identityPriv
+		tm.identityPub = identityPub
+		tm.pendingHybrid = make(map[string]*pendingHybridHandshake)
+	}
+
+	return tm, nil
+}
+
+// GetIdentityPublicKey returns this node's long-lived Ed25519 identity
+// public key for out-of-band distribution, so a peer can pin it via
+// TrustPeer before exchanging hybrid handshakes. Nil under SuiteMLKEM768.
+func (tm *TunnelManager) GetIdentityPublicKey() []byte {
+	return tm.identityPub
+}
+
+// TrustPeer pins nodeID's Ed25519 identity public key for the hybrid
+// suite: ProcessHandshakeInitHybrid and ProcessHandshakeResponseHybrid
+// reject any handshake claiming to be from nodeID whose signature doesn't
+// verify against exactly this key, rather than trusting whatever identity
+// key the peer's message happens to carry.
+func (tm *TunnelManager) TrustPeer(nodeID string, ed25519Pub []byte) error {
+	if len(ed25519Pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("ed25519 public key must be %d bytes, got %d", ed25519.PublicKeySize, len(ed25519Pub))
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.trustedPeers == nil {
+		tm.trustedPeers = make(map[string]ed25519.PublicKey)
+	}
+	tm.trustedPeers[nodeID] = append(ed25519.PublicKey(nil), ed25519Pub...)
+	return nil
+}
+
+// checkTrustedPeer verifies claimedPub matches peerID's TrustPeer pin,
+// rejecting both an unpinned peer and a pin mismatch.
+func (tm *TunnelManager) checkTrustedPeer(peerID string, claimedPub []byte) error {
+	tm.mu.RLock()
+	pinned, ok := tm.trustedPeers[peerID]
+	tm.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("pqc: no pinned identity key for peer %s, call TrustPeer first", peerID)
+	}
+	if !hmac.Equal(pinned, claimedPub) {
+		return fmt.Errorf("pqc: identity key for peer %s does not match pinned allowlist", peerID)
+	}
+	return nil
 }
 
 // GetPublicKey returns the public key for sharing with peers.
@@ -78,31 +515,109 @@ func (tm *TunnelManager) GetPublicKey() []byte {
 }
 
 // CreateHandshakeInit creates a message for initiating a handshake.
-// Format: [node_id_len:2][node_id][public_key]
+// Format: [node_id_len:2][node_id][public_key][cookie_flag:1][cookie?]
 func (tm *TunnelManager) CreateHandshakeInit() []byte {
+	return tm.createHandshakeInit(nil)
+}
+
+// CreateHandshakeInitWithCookie retries a handshake init after the peer
+// challenged us with ErrCookieRequired, echoing back the cookie it issued
+// (see ProcessHandshakeInit's under-load path).
+func (tm *TunnelManager) CreateHandshakeInitWithCookie(cookie []byte) ([]byte, error) {
+	if len(cookie) != cookieSize {
+		return nil, fmt.Errorf("cookie must be %d bytes, got %d", cookieSize, len(cookie))
+	}
+	return tm.createHandshakeInit(cookie), nil
+}
+
+// CreateHandshakeInitForPeer is CreateHandshakeInit but additionally appends
+// a WireGuard-style MAC1 (see computeMAC1) keyed on the responder's known
+// public key, letting a responder under load reject a corrupted or
+// mismatched init with one cheap hash instead of a KEM encapsulation. Use
+// this whenever the responder's key is already known ahead of the handshake
+// (e.g. internal/peering.EstablishPeering, pinned from a bootstrap token).
+// CreateHandshakeInit's zero-knowledge callers can't compute a MAC1 at all
+// and so omit it; ProcessHandshakeInit falls back to the cookie-under-load
+// challenge (see SetUnderLoad) as their only cheap DoS defense.
+func (tm *TunnelManager) CreateHandshakeInitForPeer(peerPubKey []byte) ([]byte, error) {
+	msg := tm.createHandshakeInit(nil)
+	mac1, err := computeMAC1(peerPubKey, msg)
+	if err != nil {
+		return nil, fmt.Errorf("compute mac1: %w", err)
+	}
+	return append(msg, mac1...), nil
+}
+
+func (tm *TunnelManager) createHandshakeInit(cookie []byte) []byte {
 	nodeIDBytes := []byte(tm.nodeID)
-	msg := make([]byte, 2+len(nodeIDBytes)+len(tm.keys.PublicKey))
+	msg := make([]byte, 2+len(nodeIDBytes)+len(tm.keys.PublicKey)+1+len(cookie))
 	binary.BigEndian.PutUint16(msg[0:2], uint16(len(nodeIDBytes)))
 	copy(msg[2:], nodeIDBytes)
 	copy(msg[2+len(nodeIDBytes):], tm.keys.PublicKey)
+
+	offset := 2 + len(nodeIDBytes) + len(tm.keys.PublicKey)
+	if len(cookie) == cookieSize {
+		msg[offset] = 1
+		copy(msg[offset+1:], cookie)
+	}
 	return msg
 }
 
-// ProcessHandshakeInit processes an incoming handshake init and returns a response.
-// Returns: peerNodeID, sharedSecret, responseMessage, error
-func (tm *TunnelManager) ProcessHandshakeInit(data []byte) (string, []byte, []byte, error) {
+// ProcessHandshakeInit processes an incoming handshake init and returns a
+// response. sourceIP identifies the requester for the under-load cookie
+// challenge (see SetUnderLoad); it is ignored otherwise.
+// Returns: peerNodeID, sharedSecret, responseMessage, error. When under
+// load and the init doesn't carry a valid cookie, sharedSecret is nil,
+// responseMessage is a cookie challenge to send back, and err is
+// ErrCookieRequired.
+func (tm *TunnelManager) ProcessHandshakeInit(data []byte, sourceIP string) (string, []byte, []byte, error) {
 	if len(data) < 2 {
 		return "", nil, nil, fmt.Errorf("handshake message too short")
 	}
 
 	nodeIDLen := int(binary.BigEndian.Uint16(data[0:2]))
-	if len(data) < 2+nodeIDLen+mlkem768.PublicKeySize {
+	if len(data) < 2+nodeIDLen+mlkem768.PublicKeySize+1 {
 		return "", nil, nil, fmt.Errorf("handshake message truncated")
 	}
 
 	peerID := string(data[2 : 2+nodeIDLen])
 	peerPubKeyBytes := data[2+nodeIDLen : 2+nodeIDLen+mlkem768.PublicKeySize]
 
+	offset := 2 + nodeIDLen + mlkem768.PublicKeySize
+	hasCookie := data[offset] == 1
+	var gotCookie []byte
+	baseLen := offset + 1
+	if hasCookie {
+		if len(data) < offset+1+cookieSize {
+			return "", nil, nil, fmt.Errorf("handshake message truncated")
+		}
+		gotCookie = data[offset+1 : offset+1+cookieSize]
+		baseLen += cookieSize
+	}
+
+	// MAC1 (see computeMAC1), when the sender knew our key in advance (see
+	// CreateHandshakeInitForPeer), rides as the final mac1Size bytes of the
+	// message. Reject a mismatch here, before any KEM work below.
+	if len(data) == baseLen+mac1Size {
+		gotMAC1 := data[baseLen:]
+		expectedMAC1, err := computeMAC1(tm.keys.PublicKey, data[:baseLen])
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("compute mac1: %w", err)
+		}
+		if !hmac.Equal(gotMAC1, expectedMAC1) {
+			return "", nil, nil, fmt.Errorf("pqc: handshake init MAC1 mismatch, dropping before KEM")
+		}
+	}
+
+	if tm.isUnderLoad() {
+		expected := tm.computeCookie(sourceIP)
+		if !hasCookie || !hmac.Equal(gotCookie, expected) {
+			tm.logger.Info("PQC handshake challenged with cookie", "peer", peerID, "source_ip", sourceIP)
+			resp := buildHandshakeResponse(tm.nodeID, handshakeRespCookie, expected)
+			return peerID, nil, appendMAC1(resp, peerPubKeyBytes), ErrCookieRequired
+		}
+	}
+
 	var peerPK mlkem768.PublicKey
 	if err := peerPK.Unpack(peerPubKeyBytes); err != nil {
 		return "", nil, nil, fmt.Errorf("invalid peer public key: %w", err)
@@ -128,40 +643,58 @@ func (tm *TunnelManager) ProcessHandshakeInit(data []byte) (string, []byte, []by
 	if err != nil {
 		return peerID, ss, nil, fmt.Errorf("create GCM: %w", err)
 	}
+	salt, err := newSendSalt()
+	if err != nil {
+		return peerID, ss, nil, fmt.Errorf("generate send salt: %w", err)
+	}
 
 	tm.mu.Lock()
 	tm.sessions[peerID] = &SessionKey{
-		PeerID:    peerID,
-		SharedKey: derivedKey,
-		AEAD:      aead,
+		PeerID:        peerID,
+		SharedKey:     derivedKey,
+		AEAD:          aead,
+		peerPubKey:    append([]byte(nil), peerPubKeyBytes...),
+		establishedAt: time.Now(),
+		sendSalt:      salt,
 	}
 	tm.mu.Unlock()
 
-	// Create response: [node_id_len:2][node_id][ciphertext]
-	ourIDBytes := []byte(tm.nodeID)
-	resp := make([]byte, 2+len(ourIDBytes)+len(ct))
-	binary.BigEndian.PutUint16(resp[0:2], uint16(len(ourIDBytes)))
-	copy(resp[2:], ourIDBytes)
-	copy(resp[2+len(ourIDBytes):], ct)
-
 	tm.logger.Info("PQC handshake initiated", "peer", peerID)
-	return peerID, ss, resp, nil
+	resp := buildHandshakeResponse(tm.nodeID, handshakeRespNormal, ct)
+	return peerID, ss, appendMAC1(resp, peerPubKeyBytes), nil
 }
 
 // ProcessHandshakeResponse processes a response to our initiation.
-// Returns: peerNodeID, sharedSecret, error
+// Returns: peerNodeID, sharedSecret, error. If the response is a cookie
+// challenge, sharedSecret holds the cookie to echo and err is
+// ErrCookieRequired.
 func (tm *TunnelManager) ProcessHandshakeResponse(data []byte) (string, []byte, error) {
 	if len(data) < 2 {
 		return "", nil, fmt.Errorf("handshake response too short")
 	}
 
 	nodeIDLen := int(binary.BigEndian.Uint16(data[0:2]))
-	if len(data) < 2+nodeIDLen+mlkem768.CiphertextSize {
+	if len(data) < 2+nodeIDLen+1 {
 		return "", nil, fmt.Errorf("handshake response truncated")
 	}
 
 	peerID := string(data[2 : 2+nodeIDLen])
-	ct := data[2+nodeIDLen : 2+nodeIDLen+mlkem768.CiphertextSize]
+	kind := data[2+nodeIDLen]
+	payload := data[2+nodeIDLen+1:]
+
+	if kind == handshakeRespCookie {
+		payload, err := stripAndVerifyMAC1(data, payload, tm.keys.PublicKey, cookieSize)
+		if err != nil {
+			return "", nil, err
+		}
+		return peerID, payload, ErrCookieRequired
+	}
+
+	payload, err := stripAndVerifyMAC1(data, payload, tm.keys.PublicKey, mlkem768.CiphertextSize)
+	if err != nil {
+		return "", nil, err
+	}
+	ct := payload[:mlkem768.CiphertextSize]
 
 	var sk mlkem768.PrivateKey
 	if err := sk.Unpack(tm.keys.PrivateKey); err != nil {
@@ -187,12 +720,18 @@ func (tm *TunnelManager) ProcessHandshakeResponse(data []byte) (string, []byte,
 	if err != nil {
 		return peerID, ss, fmt.Errorf("create GCM: %w", err)
 	}
+	salt, err := newSendSalt()
+	if err != nil {
+		return peerID, ss, fmt.Errorf("generate send salt: %w", err)
+	}
 
 	tm.mu.Lock()
 	tm.sessions[peerID] = &SessionKey{
-		PeerID:    peerID,
-		SharedKey: derivedKey,
-		AEAD:      aead,
+		PeerID:        peerID,
+		SharedKey:     derivedKey,
+		AEAD:          aead,
+		establishedAt: time.Now(),
+		sendSalt:      salt,
 	}
 	tm.mu.Unlock()
 
@@ -200,9 +739,110 @@ func (tm *TunnelManager) ProcessHandshakeResponse(data []byte) (string, []byte,
 	return peerID, ss, nil
 }
 
-// deriveKey uses HKDF to derive a 32-byte AES-256 key from a shared secret.
-func (tm *TunnelManager) deriveKey(sharedSecret []byte) ([]byte, error) {
-	kdf := hkdf.New(sha256.New, sharedSecret, nil, []byte("x0tta6bl4-pqc-tunnel-v1"))
+// hybridSignedFields lays out the field block a hybrid handshake message's
+// Ed25519 signature covers: [node_id_len:2][node_id][ed25519_pub]
+// [x25519_pub][lastBlob]. lastBlob is the ML-KEM-768 public key on an init
+// message or the ML-KEM-768 ciphertext on a response — the two message
+// kinds otherwise share this exact layout.
+func hybridSignedFields(nodeID string, ed25519Pub, x25519Pub, lastBlob []byte) []byte {
+	idBytes := []byte(nodeID)
+	fields := make([]byte, 0, 2+len(idBytes)+len(ed25519Pub)+len(x25519Pub)+len(lastBlob))
+	var idLen [2]byte
+	binary.BigEndian.PutUint16(idLen[:], uint16(len(idBytes)))
+	fields = append(fields, idLen[:]...)
+	fields = append(fields, idBytes...)
+	fields = append(fields, ed25519Pub...)
+	fields = append(fields, x25519Pub...)
+	fields = append(fields, lastBlob...)
+	return fields
+}
+
+// splitHybridInit splits a hybrid handshake init (see
+// CreateHandshakeInitHybrid) into its optional cookie-echo prefix and
+// signed field block + trailing Ed25519 signature (see splitHybridSigned),
+// after checking the leading suite tag. Unlike a hybrid response, an init
+// carries the [has_cookie:1][cookie:32?] prefix ProcessHandshakeInitHybrid
+// checks under load, ahead of the fields a response shares the same
+// signed-field layout with.
+func splitHybridInit(data []byte) (cookie, fields, sig []byte, err error) {
+	if len(data) < 2 || data[0] != handshakeSuiteTagHybrid {
+		return nil, nil, nil, fmt.Errorf("pqc: not a hybrid handshake message")
+	}
+	offset := 2
+	if data[1] == 1 {
+		if len(data) < offset+cookieSize {
+			return nil, nil, nil, fmt.Errorf("hybrid handshake message truncated")
+		}
+		cookie = data[offset : offset+cookieSize]
+		offset += cookieSize
+	}
+	body := data[offset:]
+	if len(body) < ed25519.SignatureSize {
+		return nil, nil, nil, fmt.Errorf("hybrid handshake message truncated")
+	}
+	return cookie, body[:len(body)-ed25519.SignatureSize], body[len(body)-ed25519.SignatureSize:], nil
+}
+
+// splitHybridSigned splits a hybrid handshake response into its signed
+// field block (see hybridSignedFields) and trailing Ed25519 signature,
+// after checking the leading suite tag.
+func splitHybridSigned(data []byte) (fields, sig []byte, err error) {
+	if len(data) < 1 || data[0] != handshakeSuiteTagHybrid {
+		return nil, nil, fmt.Errorf("pqc: not a hybrid handshake message")
+	}
+	if len(data) < 1+ed25519.SignatureSize {
+		return nil, nil, fmt.Errorf("hybrid handshake message truncated")
+	}
+	body := data[1:]
+	return body[:len(body)-ed25519.SignatureSize], body[len(body)-ed25519.SignatureSize:], nil
+}
+
+// parseHybridFields parses the field block hybridSignedFields built,
+// returning an error if its length doesn't exactly match nodeIDLen's claim
+// plus the fixed-size fields, given lastBlobSize for whichever of the two
+// message kinds fields came from.
+func parseHybridFields(fields []byte, lastBlobSize int) (nodeID string, ed25519Pub, x25519Pub, lastBlob []byte, err error) {
+	if len(fields) < 2 {
+		return "", nil, nil, nil, fmt.Errorf("hybrid handshake message too short")
+	}
+	nodeIDLen := int(binary.BigEndian.Uint16(fields[0:2]))
+	want := 2 + nodeIDLen + ed25519.PublicKeySize + x25519PubSize + lastBlobSize
+	if len(fields) != want {
+		return "", nil, nil, nil, fmt.Errorf("hybrid handshake message truncated")
+	}
+
+	offset := 2
+	nodeID = string(fields[offset : offset+nodeIDLen])
+	offset += nodeIDLen
+	ed25519Pub = fields[offset : offset+ed25519.PublicKeySize]
+	offset += ed25519.PublicKeySize
+	x25519Pub = fields[offset : offset+x25519PubSize]
+	offset += x25519PubSize
+	lastBlob = fields[offset : offset+lastBlobSize]
+	return nodeID, ed25519Pub, x25519Pub, lastBlob, nil
+}
+
+// verifyHybridSig reports whether sig is claimedPub's Ed25519 signature
+// over fields, rejecting a malformed claimedPub or sig outright rather
+// than letting ed25519.Verify panic on the wrong key size.
+func verifyHybridSig(claimedPub, fields, sig []byte) bool {
+	if len(claimedPub) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(claimedPub), fields, sig)
+}
+
+// deriveHybridKey derives the hybrid suite's AES-256 session key via
+// HKDF(ssX25519||ssMLKEM, transcriptHash): transcriptHash —
+// SHA256(init_msg||resp_msg) — salts the derivation so the key is bound to
+// this exact handshake transcript and can't be replayed across sessions, a
+// distinct info string domain-separates it from deriveKey/deriveRekeyedKey.
+func (tm *TunnelManager) deriveHybridKey(ssX25519, ssMLKEM, transcriptHash []byte) ([]byte, error) {
+	combined := make([]byte, 0, len(ssX25519)+len(ssMLKEM))
+	combined = append(combined, ssX25519...)
+	combined = append(combined, ssMLKEM...)
+
+	kdf := hkdf.New(sha256.New, combined, transcriptHash, []byte("x0tta6bl4-pqc-tunnel-hybrid-v1"))
 	derivedKey := make([]byte, 32)
 	if _, err := io.ReadFull(kdf, derivedKey); err != nil {
 		return nil, err
@@ -210,91 +850,1522 @@ func (tm *TunnelManager) deriveKey(sharedSecret []byte) ([]byte, error) {
 	return derivedKey, nil
 }
 
-// Encrypt encrypts data for a peer. Package framing (PQC1 magic) is NOT applied here.
-func (tm *TunnelManager) Encrypt(data []byte, peerID string) ([]byte, error) {
-	tm.mu.RLock()
-	session, ok := tm.sessions[peerID]
-	tm.mu.RUnlock()
+// CreateHandshakeInitHybrid builds a SuiteHybridX25519MLKEM768 handshake
+// init for peerID: [suite_tag:1][has_cookie:1][cookie:32?][node_id_len:2]
+// [node_id][ed25519_pub][x25519_pub][mlkem_pub][sig], where sig is this
+// node's long-lived Ed25519 identity key signing every field from
+// node_id_len on — proof the ephemeral X25519 and ML-KEM-768 public keys
+// really belong to node_id, which the peer's ProcessHandshakeInitHybrid
+// checks against its TrustPeer-pinned allowlist. peerID identifies who
+// this handshake is for so the ephemeral X25519 private key generated
+// here can be recovered by ProcessHandshakeResponseHybrid once the
+// matching response arrives; only one handshake may be outstanding per
+// peerID at a time. Only valid on a TunnelManager created with
+// NewTunnelManagerWithSuite(SuiteHybridX25519MLKEM768).
+func (tm *TunnelManager) CreateHandshakeInitHybrid(peerID string) ([]byte, error) {
+	return tm.createHandshakeInitHybrid(peerID, nil)
+}
 
-	if !ok {
-		return nil, fmt.Errorf("no session with peer: %s", peerID)
+// CreateHandshakeInitHybridWithCookie retries a hybrid handshake init
+// after the peer challenged us with ErrCookieRequired, echoing back the
+// cookie it issued (see ProcessHandshakeInitHybrid's under-load path).
+func (tm *TunnelManager) CreateHandshakeInitHybridWithCookie(peerID string, cookie []byte) ([]byte, error) {
+	if len(cookie) != cookieSize {
+		return nil, fmt.Errorf("cookie must be %d bytes, got %d", cookieSize, len(cookie))
 	}
+	return tm.createHandshakeInitHybrid(peerID, cookie)
+}
 
-	nonce := make([]byte, session.AEAD.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, fmt.Errorf("generate nonce: %w", err)
+func (tm *TunnelManager) createHandshakeInitHybrid(peerID string, cookie []byte) ([]byte, error) {
+	if tm.identityPriv == nil {
+		return nil, fmt.Errorf("pqc: hybrid handshake requires NewTunnelManagerWithSuite(SuiteHybridX25519MLKEM768)")
 	}
 
-	// Encrypt: nonce || ciphertext
-	ciphertext := session.AEAD.Seal(nonce, nonce, data, nil)
-	return ciphertext, nil
-}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate ephemeral x25519 key: %w", err)
+	}
 
-// Decrypt decrypts data from a peer.
-func (tm *TunnelManager) Decrypt(data []byte, peerID string) ([]byte, error) {
-	tm.mu.RLock()
-	session, ok := tm.sessions[peerID]
-	tm.mu.RUnlock()
+	fields := hybridSignedFields(tm.nodeID, tm.identityPub, ephemeral.PublicKey().Bytes(), tm.keys.PublicKey)
+	sig := ed25519.Sign(tm.identityPriv, fields)
 
-	if !ok {
-		return nil, fmt.Errorf("no session with peer: %s", peerID)
+	prefixLen := 1
+	if len(cookie) == cookieSize {
+		prefixLen += cookieSize
 	}
-
-	nonceSize := session.AEAD.NonceSize()
-	if len(data) < nonceSize {
-		return nil, fmt.Errorf("ciphertext too short")
+	msg := make([]byte, 1+prefixLen+len(fields)+len(sig))
+	msg[0] = handshakeSuiteTagHybrid
+	if len(cookie) == cookieSize {
+		msg[1] = 1
+		copy(msg[2:], cookie)
 	}
+	copy(msg[1+prefixLen:], fields)
+	copy(msg[1+prefixLen+len(fields):], sig)
 
-	nonce := data[:nonceSize]
-	ciphertext := data[nonceSize:]
+	tm.mu.Lock()
+	tm.pendingHybrid[peerID] = &pendingHybridHandshake{ephemeral: ephemeral, initMsg: msg}
+	tm.mu.Unlock()
 
-	plaintext, err := session.AEAD.Open(nil, nonce, ciphertext, nil)
+	return msg, nil
+}
+
+// ProcessHandshakeInitHybrid processes an incoming SuiteHybridX25519MLKEM768
+// handshake init (see CreateHandshakeInitHybrid) and returns a response in
+// the same field layout, carrying an ML-KEM-768 ciphertext in place of the
+// init's public key. sourceIP identifies the requester for the under-load
+// cookie challenge (see SetUnderLoad), same as ProcessHandshakeInit.
+// Returns: peerNodeID, ML-KEM shared secret, response message, error. When
+// under load and the init doesn't carry a valid cookie, the shared secret
+// is nil, the response message is a cookie challenge to send back (in
+// ProcessHandshakeResponse's generic response framing, not the hybrid
+// field layout), and err is ErrCookieRequired — before the signature
+// verify, ECDH, and KEM encapsulation below are ever reached.
+func (tm *TunnelManager) ProcessHandshakeInitHybrid(data []byte, sourceIP string) (string, []byte, []byte, error) {
+	if tm.identityPriv == nil {
+		return "", nil, nil, fmt.Errorf("pqc: hybrid handshake requires NewTunnelManagerWithSuite(SuiteHybridX25519MLKEM768)")
+	}
+
+	gotCookie, fields, sig, err := splitHybridInit(data)
 	if err != nil {
-		return nil, fmt.Errorf("decrypt: %w", err)
+		return "", nil, nil, err
+	}
+	peerID, peerIdentityPub, peerX25519PubBytes, peerMLKEMPubBytes, err := parseHybridFields(fields, mlkem768.PublicKeySize)
+	if err != nil {
+		return "", nil, nil, err
 	}
 
-	return plaintext, nil
-}
+	if tm.isUnderLoad() {
+		expected := tm.computeCookie(sourceIP)
+		if len(gotCookie) != cookieSize || !hmac.Equal(gotCookie, expected) {
+			tm.logger.Info("PQC hybrid handshake challenged with cookie", "peer", peerID, "source_ip", sourceIP)
+			resp := buildHandshakeResponse(tm.nodeID, handshakeRespCookie, expected)
+			return peerID, nil, resp, ErrCookieRequired
+		}
+	}
 
-// WrapPacket adds framing to encrypted data: b"PQC1" + [length:4] + encrypted_data
-func (tm *TunnelManager) WrapPacket(data []byte, peerID string) ([]byte, error) {
-	encrypted, err := tm.Encrypt(data, peerID)
+	if !verifyHybridSig(peerIdentityPub, fields, sig) {
+		return "", nil, nil, fmt.Errorf("pqc: hybrid handshake init signature invalid")
+	}
+	if err := tm.checkTrustedPeer(peerID, peerIdentityPub); err != nil {
+		return "", nil, nil, err
+	}
+
+	var peerMLKEMPub mlkem768.PublicKey
+	if err := peerMLKEMPub.Unpack(peerMLKEMPubBytes); err != nil {
+		return "", nil, nil, fmt.Errorf("invalid peer ML-KEM public key: %w", err)
+	}
+	ct := make([]byte, mlkem768.CiphertextSize)
+	ssMLKEM := make([]byte, mlkem768.SharedKeySize)
+	peerMLKEMPub.EncapsulateTo(ct, ssMLKEM, nil)
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
 	if err != nil {
-		return nil, err
+		return "", nil, nil, fmt.Errorf("generate ephemeral x25519 key: %w", err)
+	}
+	peerX25519Pub, err := ecdh.X25519().NewPublicKey(peerX25519PubBytes)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid peer x25519 public key: %w", err)
+	}
+	ssX25519, err := ephemeral.ECDH(peerX25519Pub)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("x25519 ecdh: %w", err)
 	}
 
-	msg := make([]byte, 8+len(encrypted))
-	copy(msg[0:4], "PQC1")
-	binary.BigEndian.PutUint32(msg[4:8], uint32(len(encrypted)))
-	copy(msg[8:], encrypted)
-	return msg, nil
-}
+	respFields := hybridSignedFields(tm.nodeID, tm.identityPub, ephemeral.PublicKey().Bytes(), ct)
+	respSig := ed25519.Sign(tm.identityPriv, respFields)
+	resp := make([]byte, 1+len(respFields)+len(respSig))
+	resp[0] = handshakeSuiteTagHybrid
+	copy(resp[1:], respFields)
+	copy(resp[1+len(respFields):], respSig)
 
-// UnwrapPacket validates framing and decrypts data.
-func (tm *TunnelManager) UnwrapPacket(data []byte, peerID string) ([]byte, error) {
-	if len(data) < 8 || string(data[0:4]) != "PQC1" {
-		return nil, fmt.Errorf("invalid PQC packet magic")
+	transcript := make([]byte, 0, len(data)+len(resp))
+	transcript = append(transcript, data...)
+	transcript = append(transcript, resp...)
+	transcriptHash := sha256.Sum256(transcript)
+
+	derivedKey, err := tm.deriveHybridKey(ssX25519, ssMLKEM, transcriptHash[:])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("derive hybrid key: %w", err)
+	}
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return peerID, ssMLKEM, nil, fmt.Errorf("create GCM: %w", err)
+	}
+	salt, err := newSendSalt()
+	if err != nil {
+		return peerID, ssMLKEM, nil, fmt.Errorf("generate send salt: %w", err)
 	}
 
-	length := binary.BigEndian.Uint32(data[4:8])
-	if len(data) < int(8+length) {
-		return nil, fmt.Errorf("PQC packet truncated")
+	tm.mu.Lock()
+	tm.sessions[peerID] = &SessionKey{
+		PeerID:        peerID,
+		SharedKey:     derivedKey,
+		AEAD:          aead,
+		establishedAt: time.Now(),
+		sendSalt:      salt,
 	}
+	tm.mu.Unlock()
 
-	return tm.Decrypt(data[8:8+length], peerID)
+	tm.logger.Info("PQC hybrid handshake initiated", "peer", peerID)
+	return peerID, ssMLKEM, resp, nil
 }
 
-// HasSession checks if a session exists with a peer.
-func (tm *TunnelManager) HasSession(peerID string) bool {
-	tm.mu.RLock()
-	defer tm.mu.RUnlock()
-	_, ok := tm.sessions[peerID]
-	return ok
+// processHybridCookieChallenge parses data as a cookie-challenge response
+// in ProcessHandshakeResponse's generic [node_id_len:2][node_id][kind:1]
+// [payload] framing — what ProcessHandshakeInitHybrid sends back under
+// load instead of a hybrid-tagged response — and returns ErrCookieRequired
+// with the cookie to echo via CreateHandshakeInitHybridWithCookie.
+func (tm *TunnelManager) processHybridCookieChallenge(data []byte) (string, []byte, error) {
+	if len(data) < 2 {
+		return "", nil, fmt.Errorf("handshake response too short")
+	}
+	nodeIDLen := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+nodeIDLen+1 {
+		return "", nil, fmt.Errorf("handshake response truncated")
+	}
+	peerID := string(data[2 : 2+nodeIDLen])
+	kind := data[2+nodeIDLen]
+	payload := data[2+nodeIDLen+1:]
+	if kind != handshakeRespCookie {
+		return "", nil, fmt.Errorf("pqc: unexpected hybrid handshake response kind %d", kind)
+	}
+	payload, err := stripAndVerifyMAC1(data, payload, tm.keys.PublicKey, cookieSize)
+	if err != nil {
+		return "", nil, err
+	}
+	return peerID, payload, ErrCookieRequired
 }
 
-// RemoveSession removes a session with a peer.
-func (tm *TunnelManager) RemoveSession(peerID string) {
-	tm.mu.Lock()
-	delete(tm.sessions, peerID)
-	tm.mu.Unlock()
+// ProcessHandshakeResponseHybrid processes a response to our
+// CreateHandshakeInitHybrid. If the peer's ProcessHandshakeInitHybrid was
+// under load, the response is instead a cookie challenge in
+// ProcessHandshakeResponse's generic framing (the hybrid suite tag can't
+// appear as its first byte for any node ID under 256 bytes — see
+// handshakeSuiteTagHybrid); CreateHandshakeInitHybridWithCookie retries
+// with the returned cookie. Returns: peerNodeID, ML-KEM shared secret
+// (or, on a cookie challenge, the cookie to echo), error — ErrCookieRequired
+// on the latter, same convention as ProcessHandshakeResponse.
+func (tm *TunnelManager) ProcessHandshakeResponseHybrid(data []byte) (string, []byte, error) {
+	if len(data) > 0 && data[0] != handshakeSuiteTagHybrid {
+		return tm.processHybridCookieChallenge(data)
+	}
+
+	fields, sig, err := splitHybridSigned(data)
+	if err != nil {
+		return "", nil, err
+	}
+	peerID, peerIdentityPub, peerX25519PubBytes, ct, err := parseHybridFields(fields, mlkem768.CiphertextSize)
+	if err != nil {
+		return "", nil, err
+	}
+	if !verifyHybridSig(peerIdentityPub, fields, sig) {
+		return "", nil, fmt.Errorf("pqc: hybrid handshake response signature invalid")
+	}
+	if err := tm.checkTrustedPeer(peerID, peerIdentityPub); err != nil {
+		return "", nil, err
+	}
+
+	tm.mu.Lock()
+	pending, ok := tm.pendingHybrid[peerID]
+	if ok {
+		delete(tm.pendingHybrid, peerID)
+	}
+	tm.mu.Unlock()
+	if !ok {
+		return "", nil, fmt.Errorf("pqc: no pending hybrid handshake with peer %s", peerID)
+	}
+
+	peerX25519Pub, err := ecdh.X25519().NewPublicKey(peerX25519PubBytes)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid peer x25519 public key: %w", err)
+	}
+	ssX25519, err := pending.ephemeral.ECDH(peerX25519Pub)
+	if err != nil {
+		return "", nil, fmt.Errorf("x25519 ecdh: %w", err)
+	}
+
+	var sk mlkem768.PrivateKey
+	if err := sk.Unpack(tm.keys.PrivateKey); err != nil {
+		return "", nil, fmt.Errorf("invalid local private key: %w", err)
+	}
+	ssMLKEM := make([]byte, mlkem768.SharedKeySize)
+	sk.DecapsulateTo(ssMLKEM, ct)
+
+	transcript := make([]byte, 0, len(pending.initMsg)+len(data))
+	transcript = append(transcript, pending.initMsg...)
+	transcript = append(transcript, data...)
+	transcriptHash := sha256.Sum256(transcript)
+
+	derivedKey, err := tm.deriveHybridKey(ssX25519, ssMLKEM, transcriptHash[:])
+	if err != nil {
+		return "", nil, fmt.Errorf("derive hybrid key: %w", err)
+	}
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return peerID, ssMLKEM, fmt.Errorf("create GCM: %w", err)
+	}
+	salt, err := newSendSalt()
+	if err != nil {
+		return peerID, ssMLKEM, fmt.Errorf("generate send salt: %w", err)
+	}
+
+	tm.mu.Lock()
+	tm.sessions[peerID] = &SessionKey{
+		PeerID:        peerID,
+		SharedKey:     derivedKey,
+		AEAD:          aead,
+		establishedAt: time.Now(),
+		sendSalt:      salt,
+	}
+	tm.mu.Unlock()
+
+	tm.logger.Info("PQC hybrid session established", "peer", peerID)
+	return peerID, ssMLKEM, nil
+}
+
+// appendMAC1 appends a MAC1 (see computeMAC1) keyed on receiverPubKey to
+// msg. Used to tag handshake responses so the initiator can reject a
+// corrupted or spoofed one before spending a KEM decapsulation on it.
+// Computing MAC1 itself can't fail (mac1Key always yields a 32-byte key), so
+// the error path here is defensive only: it leaves msg untagged rather than
+// dropping the response outright.
+func appendMAC1(msg, receiverPubKey []byte) []byte {
+	mac1, err := computeMAC1(receiverPubKey, msg)
+	if err != nil {
+		return msg
+	}
+	return append(msg, mac1...)
+}
+
+// buildHandshakeResponse frames a handshake response as
+// [node_id_len:2][node_id][kind:1][payload].
+func buildHandshakeResponse(nodeID string, kind byte, payload []byte) []byte {
+	idBytes := []byte(nodeID)
+	resp := make([]byte, 2+len(idBytes)+1+len(payload))
+	binary.BigEndian.PutUint16(resp[0:2], uint16(len(idBytes)))
+	copy(resp[2:], idBytes)
+	resp[2+len(idBytes)] = kind
+	copy(resp[2+len(idBytes)+1:], payload)
+	return resp
+}
+
+// SetUnderLoad toggles whether ProcessHandshakeInit demands a MAC cookie
+// echo before performing the comparatively expensive KEM encapsulation —
+// a cheap defense against handshake-flood DoS. Callers (e.g. a handshake
+// rate limiter upstream) flip this on once incoming handshake volume
+// crosses their own threshold.
+func (tm *TunnelManager) SetUnderLoad(underLoad bool) {
+	tm.mu.Lock()
+	tm.underLoad = underLoad
+	tm.mu.Unlock()
+}
+
+func (tm *TunnelManager) isUnderLoad() bool {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.underLoad
+}
+
+// computeCookie derives the MAC cookie challenge for sourceIP: an
+// HMAC-SHA256 over the IP under a secret that rotates every
+// cookieSecretLifetime, so forging or replaying a cookie past its window
+// costs an attacker a fresh guess.
+func (tm *TunnelManager) computeCookie(sourceIP string) []byte {
+	mac := hmac.New(sha256.New, tm.currentCookieSecret())
+	mac.Write([]byte(sourceIP))
+	return mac.Sum(nil)
+}
+
+func (tm *TunnelManager) currentCookieSecret() []byte {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.cookieSecret == nil || time.Since(tm.cookieSecretSetAt) > cookieSecretLifetime {
+		secret := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+			tm.logger.Warn("failed to generate cookie secret", "error", err)
+		} else {
+			tm.cookieSecret = secret
+		}
+		tm.cookieSecretSetAt = time.Now()
+	}
+	return tm.cookieSecret
+}
+
+// deriveKey uses HKDF to derive a 32-byte AES-256 key from a shared secret.
+func (tm *TunnelManager) deriveKey(sharedSecret []byte) ([]byte, error) {
+	kdf := hkdf.New(sha256.New, sharedSecret, nil, []byte("x0tta6bl4-pqc-tunnel-v1"))
+	derivedKey := make([]byte, 32)
+	if _, err := io.ReadFull(kdf, derivedKey); err != nil {
+		return nil, err
+	}
+	return derivedKey, nil
+}
+
+// deriveRekeyedKey derives the next session key per the rekey contract:
+// HKDF over previous_key || new_shared_secret. A distinct info string from
+// deriveKey domain-separates the initial-handshake and rekey derivations.
+func (tm *TunnelManager) deriveRekeyedKey(previousKey, newSharedSecret []byte) ([]byte, error) {
+	combined := make([]byte, 0, len(previousKey)+len(newSharedSecret))
+	combined = append(combined, previousKey...)
+	combined = append(combined, newSharedSecret...)
+
+	kdf := hkdf.New(sha256.New, combined, nil, []byte("x0tta6bl4-pqc-tunnel-rekey-v1"))
+	derivedKey := make([]byte, 32)
+	if _, err := io.ReadFull(kdf, derivedKey); err != nil {
+		return nil, err
+	}
+	return derivedKey, nil
+}
+
+// NeedsRekey reports whether the session with peerID has crossed its
+// REKEY_AFTER_TIME, REKEY_AFTER_MESSAGES, or REKEY_AFTER_BYTES threshold
+// and should be rotated via CreateRekeyInit.
+func (tm *TunnelManager) NeedsRekey(peerID string) bool {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	session, ok := tm.sessions[peerID]
+	if !ok {
+		return false
+	}
+	return time.Since(session.establishedAt) >= RekeyAfterTime ||
+		session.msgCount >= RekeyAfterMessages ||
+		session.byteCount >= RekeyAfterBytes
+}
+
+// SessionsNeedingRekey reports how many active sessions NeedsRekey
+// considers due for rotation. Meant to feed a StatsProvider's
+// "sessions_needing_rekey" stat for healing.Monitor (see
+// healing.Observation.SessionsNeedingRekey), not the rekey loop itself —
+// StartRekeyLoop already calls NeedsRekey directly per session.
+func (tm *TunnelManager) SessionsNeedingRekey() int {
+	tm.mu.RLock()
+	peerIDs := make([]string, 0, len(tm.sessions))
+	for peerID := range tm.sessions {
+		peerIDs = append(peerIDs, peerID)
+	}
+	tm.mu.RUnlock()
+
+	count := 0
+	for _, peerID := range peerIDs {
+		if tm.NeedsRekey(peerID) {
+			count++
+		}
+	}
+	return count
+}
+
+// CreateRekeyInit begins a WireGuard-style rekey for peerID: it
+// re-encapsulates a fresh shared secret against the peer's public key
+// (only available on the side that processed the peer's original
+// handshake init, see SessionKey.peerPubKey) and derives the next session
+// key over the previous key and the new secret. Send the returned bytes
+// to the peer piggybacked on a data frame; ProcessRekeyInit on the other
+// side completes the swap.
+func (tm *TunnelManager) CreateRekeyInit(peerID string) ([]byte, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	session, ok := tm.sessions[peerID]
+	if !ok {
+		return nil, fmt.Errorf("no session with peer: %s", peerID)
+	}
+	if session.peerPubKey == nil {
+		return nil, fmt.Errorf("cannot initiate rekey with %s: peer public key unknown", peerID)
+	}
+
+	var peerPK mlkem768.PublicKey
+	if err := peerPK.Unpack(session.peerPubKey); err != nil {
+		return nil, fmt.Errorf("invalid stored peer public key: %w", err)
+	}
+
+	ct := make([]byte, mlkem768.CiphertextSize)
+	ss := make([]byte, mlkem768.SharedKeySize)
+	peerPK.EncapsulateTo(ct, ss, nil)
+
+	if err := tm.rotateSessionKeyLocked(session, ss); err != nil {
+		return nil, err
+	}
+
+	idBytes := []byte(tm.nodeID)
+	msg := make([]byte, 2+len(idBytes)+len(ct))
+	binary.BigEndian.PutUint16(msg[0:2], uint16(len(idBytes)))
+	copy(msg[2:], idBytes)
+	copy(msg[2+len(idBytes):], ct)
+
+	tm.logger.Info("PQC rekey initiated", "peer", peerID)
+	return msg, nil
+}
+
+// ProcessRekeyInit completes a rekey begun by the peer's CreateRekeyInit:
+// it decapsulates the fresh ciphertext with our static private key and
+// derives the next session key the same way, keeping the superseded key
+// usable for RejectAfterTime so packets already in flight still decrypt.
+func (tm *TunnelManager) ProcessRekeyInit(data []byte) (string, error) {
+	if len(data) < 2 {
+		return "", fmt.Errorf("rekey message too short")
+	}
+	nodeIDLen := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+nodeIDLen+mlkem768.CiphertextSize {
+		return "", fmt.Errorf("rekey message truncated")
+	}
+
+	peerID := string(data[2 : 2+nodeIDLen])
+	ct := data[2+nodeIDLen : 2+nodeIDLen+mlkem768.CiphertextSize]
+
+	var sk mlkem768.PrivateKey
+	if err := sk.Unpack(tm.keys.PrivateKey); err != nil {
+		return "", fmt.Errorf("invalid local private key: %w", err)
+	}
+	ss := make([]byte, mlkem768.SharedKeySize)
+	sk.DecapsulateTo(ss, ct)
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	session, ok := tm.sessions[peerID]
+	if !ok {
+		return "", fmt.Errorf("no session with peer: %s", peerID)
+	}
+	if err := tm.rotateSessionKeyLocked(session, ss); err != nil {
+		return "", err
+	}
+
+	tm.logger.Info("PQC session rekeyed", "peer", peerID)
+	return peerID, nil
+}
+
+// rotateSessionKeyLocked swaps session onto a freshly derived key, keeping
+// the old one around for RejectAfterTime. Callers must hold tm.mu.
+func (tm *TunnelManager) rotateSessionKeyLocked(session *SessionKey, newSharedSecret []byte) error {
+	newKey, err := tm.deriveRekeyedKey(session.SharedKey, newSharedSecret)
+	if err != nil {
+		return fmt.Errorf("derive rekeyed key: %w", err)
+	}
+	block, err := aes.NewCipher(newKey)
+	if err != nil {
+		return fmt.Errorf("create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("create GCM: %w", err)
+	}
+
+	session.prevKey = session.SharedKey
+	session.prevAEAD = session.AEAD
+	session.rekeyedAt = time.Now()
+
+	session.SharedKey = newKey
+	session.AEAD = aead
+	session.establishedAt = time.Now()
+	session.msgCount = 0
+	session.byteCount = 0
+	// sendCounter and replay continue across the rekey: the nonce
+	// counter is per-session, not per-key.
+
+	return nil
+}
+
+// Encrypt encrypts data for a peer. Package framing (PQC1 magic) is NOT applied here.
+func (tm *TunnelManager) Encrypt(data []byte, peerID string) ([]byte, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	session, ok := tm.sessions[peerID]
+	if !ok {
+		return nil, fmt.Errorf("no session with peer: %s", peerID)
+	}
+
+	counter := session.sendCounter
+	session.sendCounter++
+	session.msgCount++
+	session.byteCount += uint64(len(data))
+
+	nonce := make([]byte, session.AEAD.NonceSize())
+	copy(nonce, session.sendSalt[:])
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], counter)
+
+	// Encrypt: nonce || ciphertext
+	ciphertext := session.AEAD.Seal(nonce, nonce, data, nil)
+	return ciphertext, nil
+}
+
+// Decrypt decrypts data from a peer, rejecting replayed or too-old nonce
+// counters (see replayWindow) and falling back to the superseded key
+// within RejectAfterTime of a rekey.
+func (tm *TunnelManager) Decrypt(data []byte, peerID string) ([]byte, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	session, ok := tm.sessions[peerID]
+	if !ok {
+		return nil, fmt.Errorf("no session with peer: %s", peerID)
+	}
+
+	nonceSize := session.AEAD.NonceSize()
+	if len(data) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce := data[:nonceSize]
+	ciphertext := data[nonceSize:]
+	counter := binary.BigEndian.Uint64(nonce[nonceSize-8:])
+
+	if !session.replay.check(counter) {
+		return nil, fmt.Errorf("replay detected for peer %s (counter %d)", peerID, counter)
+	}
+	// Decrypt holds tm.mu for its whole duration, so unlike DecryptAsync it
+	// never needs to reserve counter itself — but it must still defer to a
+	// reservation DecryptAsync made for the same counter and hasn't
+	// resolved yet, or the two paths could double-accept one replay.
+	if _, pending := session.pendingDecrypt[counter]; pending {
+		return nil, fmt.Errorf("replay detected for peer %s (counter %d)", peerID, counter)
+	}
+
+	plaintext, err := session.AEAD.Open(nil, nonce, ciphertext, nil)
+	if err != nil && session.prevAEAD != nil && time.Since(session.rekeyedAt) < RejectAfterTime {
+		plaintext, err = session.prevAEAD.Open(nil, nonce, ciphertext, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+
+	session.replay.commit(counter)
+	return plaintext, nil
+}
+
+// maxMessageSize upper-bounds a single mesh data packet, sizing the buffers
+// packetBufPool recycles for EncryptAsync/EncryptBatch.
+const maxMessageSize = 64 * 1024
+
+// packetBufPool recycles the byte slices EncryptAsync seals into, so
+// steady-state encryption under StartEncryptWorkers doesn't allocate a
+// fresh buffer per packet. Callers that want the reuse must call
+// EncryptResult.Release once they're done with Ciphertext; skipping it is
+// safe; it just costs an allocation next time instead of a pool hit.
+var packetBufPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, maxMessageSize)
+		return &buf
+	},
+}
+
+// EncryptResult is what EncryptAsync/EncryptBatch report back once the
+// AEAD.Seal call they dispatched to the worker pool has run.
+type EncryptResult struct {
+	Ciphertext []byte
+	Err        error
+
+	// buf, when non-nil, is Ciphertext's backing array on loan from
+	// packetBufPool. Release returns it.
+	buf *[]byte
+}
+
+// Release returns r.Ciphertext's backing buffer to packetBufPool. Safe to
+// call on a zero EncryptResult or more than once — only the first call has
+// any effect. Call it once Ciphertext has been written to the wire (or is
+// otherwise no longer needed); until then, the buffer is still live and
+// must not be reused.
+func (r *EncryptResult) Release() {
+	if r.buf == nil {
+		return
+	}
+	packetBufPool.Put(r.buf)
+	r.buf = nil
+}
+
+// DecryptResult is what DecryptAsync/DecryptBatch report back once the
+// AEAD.Open call they dispatched to the worker pool has run.
+type DecryptResult struct {
+	Plaintext []byte
+	Err       error
+}
+
+// StartEncryptWorkers spawns numWorkers goroutines (runtime.GOMAXPROCS(0) if
+// numWorkers <= 0) that drain tm.jobs, parallelizing the CPU-bound AEAD
+// calls EncryptAsync and DecryptAsync dispatch to them — modeled on
+// WireGuard's send.go worker pool: the part that has to run sequentially
+// (nonce assignment for Encrypt, the replay-window check for Decrypt) is
+// cheap, and the AEAD call that dominates CPU time isn't, so it's the one
+// worth spreading across cores. Like StartRekeyLoop, workers exit on ctx
+// cancellation and Wait blocks until they have. Also records ctx as the
+// pool's lifetime, so EncryptAsync/DecryptAsync (and the per-peer
+// transmitters they spawn) know the pool is actually live rather than
+// just assuming tm.jobs has someone draining it.
+func (tm *TunnelManager) StartEncryptWorkers(ctx context.Context, numWorkers int) {
+	if numWorkers <= 0 {
+		numWorkers = runtime.GOMAXPROCS(0)
+	}
+
+	tm.mu.Lock()
+	tm.asyncCtx = ctx
+	tm.mu.Unlock()
+
+	for i := 0; i < numWorkers; i++ {
+		tm.wg.Add(1)
+		go func() {
+			defer tm.wg.Done()
+			for {
+				select {
+				case job := <-tm.jobs:
+					job()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+}
+
+// encElement is what EncryptAsync enqueues onto its peer's FIFO (see
+// peerEncQueue) once the worker pool is running: sealed is filled,
+// possibly by any worker, in whatever order AEAD.Seal calls happen to
+// finish in, but runPeerEncTransmitter only ever reads a peer's queue
+// FIFO, so resultCh is always delivered in the order EncryptAsync was
+// called for that peer regardless of seal order.
+type encElement struct {
+	resultCh chan EncryptResult
+	sealed   chan EncryptResult
+}
+
+// peerEncQueue returns peerID's outbound FIFO, spawning its one sequential
+// transmitter goroutine (runPeerEncTransmitter) the first time peerID is
+// seen under this ctx. Must only be called while ctx is live; EncryptAsync
+// checks that itself before calling in.
+func (tm *TunnelManager) peerEncQueue(peerID string, ctx context.Context) chan *encElement {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.peerEncQueues == nil {
+		tm.peerEncQueues = make(map[string]chan *encElement)
+	}
+	queue, ok := tm.peerEncQueues[peerID]
+	if !ok {
+		queue = make(chan *encElement, jobQueueSize)
+		tm.peerEncQueues[peerID] = queue
+		tm.wg.Add(1)
+		go tm.runPeerEncTransmitter(ctx, queue)
+	}
+	return queue
+}
+
+// runPeerEncTransmitter is the sequential transmitter for one peer's
+// outbound queue: it drains elem FIFO, waits for each one's AEAD.Seal to
+// finish (wherever it ran), and only then delivers it to the caller's
+// resultCh — so two calls to EncryptAsync for the same peer always
+// deliver in the order they were made, independent of worker scheduling.
+func (tm *TunnelManager) runPeerEncTransmitter(ctx context.Context, queue chan *encElement) {
+	defer tm.wg.Done()
+	for {
+		select {
+		case elem := <-queue:
+			select {
+			case res := <-elem.sealed:
+				elem.resultCh <- res
+			case <-ctx.Done():
+				elem.resultCh <- EncryptResult{Err: ctx.Err()}
+			}
+			close(elem.resultCh)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// EncryptAsync is Encrypt, but the AEAD.Seal call runs on the worker pool
+// started by StartEncryptWorkers instead of blocking the caller. Nonce
+// assignment still happens synchronously here under the same lock Encrypt
+// uses; the result is then handed to the peer's sequential transmitter
+// (see runPeerEncTransmitter), which is what actually guarantees resultCh
+// is delivered in the same order EncryptAsync was called in for that peer
+// — not something the caller has to arrange by reading channels in a
+// particular sequence itself. If the pool isn't running (StartEncryptWorkers
+// was never called, or its ctx has since been cancelled), EncryptAsync
+// seals inline instead of touching tm.jobs or spinning up a transmitter.
+func (tm *TunnelManager) EncryptAsync(data []byte, peerID string) <-chan EncryptResult {
+	resultCh := make(chan EncryptResult, 1)
+
+	tm.mu.Lock()
+	session, ok := tm.sessions[peerID]
+	if !ok {
+		tm.mu.Unlock()
+		resultCh <- EncryptResult{Err: fmt.Errorf("no session with peer: %s", peerID)}
+		close(resultCh)
+		return resultCh
+	}
+	counter := session.sendCounter
+	session.sendCounter++
+	session.msgCount++
+	session.byteCount += uint64(len(data))
+	aead := session.AEAD
+	salt := session.sendSalt
+	ctx := tm.asyncCtx
+	tm.mu.Unlock()
+
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, salt[:])
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], counter)
+
+	seal := func() EncryptResult {
+		bufPtr := packetBufPool.Get().(*[]byte)
+		buf := append((*bufPtr)[:0], nonce...)
+		ciphertext := aead.Seal(buf, nonce, data, nil)
+		*bufPtr = ciphertext
+		return EncryptResult{Ciphertext: ciphertext, buf: bufPtr}
+	}
+
+	if ctx == nil || ctx.Err() != nil {
+		resultCh <- seal()
+		close(resultCh)
+		return resultCh
+	}
+
+	elem := &encElement{resultCh: resultCh, sealed: make(chan EncryptResult, 1)}
+	select {
+	case tm.peerEncQueue(peerID, ctx) <- elem:
+	case <-ctx.Done():
+		// runPeerEncTransmitter may have already observed ctx.Done() and
+		// returned, in which case nothing will ever drain this peer's
+		// queue again — deliver the cancellation directly instead of
+		// blocking forever on a send nobody will receive.
+		resultCh <- EncryptResult{Err: ctx.Err()}
+		close(resultCh)
+		return resultCh
+	}
+
+	job := func() { elem.sealed <- seal() }
+	select {
+	case tm.jobs <- job:
+	default:
+		job()
+	}
+	return resultCh
+}
+
+// EncryptRequest is one item of an EncryptBatch call.
+type EncryptRequest struct {
+	Data   []byte
+	PeerID string
+}
+
+// EncryptBatch dispatches every request to EncryptAsync in order and returns
+// their results in that same order, once all of them have completed.
+func (tm *TunnelManager) EncryptBatch(reqs []EncryptRequest) []EncryptResult {
+	chans := make([]<-chan EncryptResult, len(reqs))
+	for i, req := range reqs {
+		chans[i] = tm.EncryptAsync(req.Data, req.PeerID)
+	}
+	results := make([]EncryptResult, len(reqs))
+	for i, ch := range chans {
+		results[i] = <-ch
+	}
+	return results
+}
+
+// decElement is what DecryptAsync enqueues onto its peer's FIFO (see
+// peerDecQueue) once the worker pool is running: opened is filled,
+// possibly by any worker, in whatever order AEAD.Open calls happen to
+// finish in, but runPeerDecTransmitter only ever reads a peer's queue
+// FIFO, so both replay.commit and resultCh delivery happen in the order
+// DecryptAsync was called for that peer regardless of open order.
+type decElement struct {
+	counter  uint64
+	resultCh chan DecryptResult
+	opened   chan DecryptResult
+}
+
+// peerDecQueue returns peerID's inbound FIFO, spawning its one sequential
+// transmitter goroutine (runPeerDecTransmitter) the first time peerID is
+// seen under this ctx. Must only be called while ctx is live; DecryptAsync
+// checks that itself before calling in.
+func (tm *TunnelManager) peerDecQueue(peerID string, ctx context.Context) chan *decElement {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.peerDecQueues == nil {
+		tm.peerDecQueues = make(map[string]chan *decElement)
+	}
+	queue, ok := tm.peerDecQueues[peerID]
+	if !ok {
+		queue = make(chan *decElement, jobQueueSize)
+		tm.peerDecQueues[peerID] = queue
+		tm.wg.Add(1)
+		go tm.runPeerDecTransmitter(ctx, peerID, queue)
+	}
+	return queue
+}
+
+// runPeerDecTransmitter is the sequential transmitter for one peer's
+// inbound queue: it drains elem FIFO, waits for each one's AEAD.Open to
+// finish (wherever it ran), commits the replay window (commitDecrypt) and
+// only then delivers to the caller's resultCh — so both the replay window
+// and DecryptAsync's result order reflect the order calls were made in for
+// that peer, independent of worker scheduling.
+func (tm *TunnelManager) runPeerDecTransmitter(ctx context.Context, peerID string, queue chan *decElement) {
+	defer tm.wg.Done()
+	for {
+		select {
+		case elem := <-queue:
+			select {
+			case res := <-elem.opened:
+				elem.resultCh <- tm.commitDecrypt(peerID, elem.counter, res)
+			case <-ctx.Done():
+				tm.releasePendingDecrypt(peerID, elem.counter)
+				elem.resultCh <- DecryptResult{Err: ctx.Err()}
+			}
+			close(elem.resultCh)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// commitDecrypt releases counter's pendingDecrypt reservation (see
+// DecryptAsync) and, if res is a successful Open, commits it to peerID's
+// replay window — so a failed decryption never advances the window, but
+// either way stops blocking a legitimate retry at the same counter.
+func (tm *TunnelManager) commitDecrypt(peerID string, counter uint64, res DecryptResult) DecryptResult {
+	tm.mu.Lock()
+	if session, ok := tm.sessions[peerID]; ok {
+		delete(session.pendingDecrypt, counter)
+		if res.Err == nil {
+			session.replay.commit(counter)
+		}
+	}
+	tm.mu.Unlock()
+	return res
+}
+
+// releasePendingDecrypt clears counter's pendingDecrypt reservation
+// without committing it, for paths that abandon a reserved counter before
+// its AEAD.Open result is known (worker-pool shutdown racing DecryptAsync
+// or runPeerDecTransmitter).
+func (tm *TunnelManager) releasePendingDecrypt(peerID string, counter uint64) {
+	tm.mu.Lock()
+	if session, ok := tm.sessions[peerID]; ok {
+		delete(session.pendingDecrypt, counter)
+	}
+	tm.mu.Unlock()
+}
+
+// DecryptAsync is Decrypt, but the AEAD.Open call runs on the worker pool
+// started by StartEncryptWorkers. The replay-window check — cheap, so worth
+// doing before the AEAD call rather than after — still happens
+// synchronously here, and counter is also reserved in pendingDecrypt in
+// that same critical section, so a second concurrent call for the same
+// (replayed) counter is rejected at check time rather than also passing
+// check and racing the first call to commitDecrypt. replay.commit itself
+// only runs once Open actually succeeds, via the peer's sequential
+// transmitter (see runPeerDecTransmitter), which is what guarantees both
+// the replay window and resultCh delivery follow call order for that peer
+// rather than worker-completion order. If the pool isn't running
+// (StartEncryptWorkers was never called, or its ctx has since been
+// cancelled), DecryptAsync opens inline instead of touching tm.jobs or
+// spinning up a transmitter.
+func (tm *TunnelManager) DecryptAsync(data []byte, peerID string) <-chan DecryptResult {
+	resultCh := make(chan DecryptResult, 1)
+
+	tm.mu.Lock()
+	session, ok := tm.sessions[peerID]
+	if !ok {
+		tm.mu.Unlock()
+		resultCh <- DecryptResult{Err: fmt.Errorf("no session with peer: %s", peerID)}
+		close(resultCh)
+		return resultCh
+	}
+	nonceSize := session.AEAD.NonceSize()
+	if len(data) < nonceSize {
+		tm.mu.Unlock()
+		resultCh <- DecryptResult{Err: fmt.Errorf("ciphertext too short")}
+		close(resultCh)
+		return resultCh
+	}
+	nonce := data[:nonceSize]
+	ciphertext := data[nonceSize:]
+	counter := binary.BigEndian.Uint64(nonce[nonceSize-8:])
+	if !session.replay.check(counter) {
+		tm.mu.Unlock()
+		resultCh <- DecryptResult{Err: fmt.Errorf("replay detected for peer %s (counter %d)", peerID, counter)}
+		close(resultCh)
+		return resultCh
+	}
+	if _, pending := session.pendingDecrypt[counter]; pending {
+		tm.mu.Unlock()
+		resultCh <- DecryptResult{Err: fmt.Errorf("replay detected for peer %s (counter %d)", peerID, counter)}
+		close(resultCh)
+		return resultCh
+	}
+	// Reserve counter now, still under tm.mu, so a second concurrent
+	// DecryptAsync/Decrypt call for the same (replayed) counter is
+	// rejected here instead of also passing check and racing this call to
+	// commitDecrypt. See pendingDecrypt's doc comment on SessionKey.
+	if session.pendingDecrypt == nil {
+		session.pendingDecrypt = make(map[uint64]struct{})
+	}
+	session.pendingDecrypt[counter] = struct{}{}
+	aead, prevAEAD, rekeyedAt := session.AEAD, session.prevAEAD, session.rekeyedAt
+	ctx := tm.asyncCtx
+	tm.mu.Unlock()
+
+	open := func() DecryptResult {
+		plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+		if err != nil && prevAEAD != nil && time.Since(rekeyedAt) < RejectAfterTime {
+			plaintext, err = prevAEAD.Open(nil, nonce, ciphertext, nil)
+		}
+		if err != nil {
+			return DecryptResult{Err: fmt.Errorf("decrypt: %w", err)}
+		}
+		return DecryptResult{Plaintext: plaintext}
+	}
+
+	if ctx == nil || ctx.Err() != nil {
+		resultCh <- tm.commitDecrypt(peerID, counter, open())
+		close(resultCh)
+		return resultCh
+	}
+
+	elem := &decElement{counter: counter, resultCh: resultCh, opened: make(chan DecryptResult, 1)}
+	select {
+	case tm.peerDecQueue(peerID, ctx) <- elem:
+	case <-ctx.Done():
+		// runPeerDecTransmitter may have already observed ctx.Done() and
+		// returned, in which case nothing will ever drain this peer's
+		// queue again — deliver the cancellation directly instead of
+		// blocking forever on a send nobody will receive.
+		tm.releasePendingDecrypt(peerID, counter)
+		resultCh <- DecryptResult{Err: ctx.Err()}
+		close(resultCh)
+		return resultCh
+	}
+
+	job := func() { elem.opened <- open() }
+	select {
+	case tm.jobs <- job:
+	default:
+		job()
+	}
+	return resultCh
+}
+
+// DecryptRequest is one item of a DecryptBatch call.
+type DecryptRequest struct {
+	Data   []byte
+	PeerID string
+}
+
+// DecryptBatch dispatches every request to DecryptAsync in order and returns
+// their results in that same order, once all of them have completed.
+func (tm *TunnelManager) DecryptBatch(reqs []DecryptRequest) []DecryptResult {
+	chans := make([]<-chan DecryptResult, len(reqs))
+	for i, req := range reqs {
+		chans[i] = tm.DecryptAsync(req.Data, req.PeerID)
+	}
+	results := make([]DecryptResult, len(reqs))
+	for i, ch := range chans {
+		results[i] = <-ch
+	}
+	return results
+}
+
+// ShaperMode selects how WrapPacket/UnwrapPacket and StartShaperLoop treat
+// a peer's traffic. Each mode trades bandwidth for resistance to the
+// traffic-analysis side channels AEAD encryption alone doesn't hide:
+// plaintext length and send timing. Modeled on GoVPN's CPR (constant
+// packet rate) and noise-padding modes.
+type ShaperMode int
+
+const (
+	// ShaperNone applies no shaping: WrapPacket/UnwrapPacket behave exactly
+	// as they did before Shaper existed.
+	ShaperNone ShaperMode = iota
+	// ShaperPad rounds every plaintext up to the next paddingBucketSize
+	// bytes with a random tail, so an observer sees only a handful of
+	// distinct packet sizes per peer instead of the plaintext's exact
+	// length.
+	ShaperPad
+	// ShaperCPR additionally emits one padded frame every
+	// 1000/RatePerSecond ms for the peer regardless of whether real
+	// traffic is queued, via StartShaperLoop, so an observer sees a
+	// constant packet rate instead of one that tracks actual usage.
+	// Frames are padded to the paddingBucketSize boundary, not to one
+	// fixed size: a plaintext longer than paddingBucketSize-2 bytes
+	// yields a larger frame than a keepalive.
+	ShaperCPR
+)
+
+func (m ShaperMode) String() string {
+	switch m {
+	case ShaperPad:
+		return "pad"
+	case ShaperCPR:
+		return "cpr"
+	default:
+		return "none"
+	}
+}
+
+// paddingBucketSize is the granularity ShaperPad and ShaperCPR round a
+// framed plaintext's length up to.
+const paddingBucketSize = 256
+
+// shaperQueueSize bounds how many WrapPacket calls ShaperCPR buffers for a
+// peer before StartShaperLoop has drained them; WrapPacket drops the
+// newest frame and counts it rather than growing the queue (and the
+// latency) unboundedly.
+const shaperQueueSize = 64
+
+// Shaper configures traffic shaping for one peer (see SetShaper).
+type Shaper struct {
+	Mode ShaperMode
+	// RatePerSecond is how many padded frames per second StartShaperLoop
+	// emits for this peer. Ignored outside ShaperCPR.
+	RatePerSecond int
+}
+
+// shaperState is the per-peer runtime state backing Shaper: the queue
+// ShaperCPR drains in StartShaperLoop and the counters ShaperStats reports.
+type shaperState struct {
+	cfg      Shaper
+	queue    chan []byte
+	nextSend time.Time
+
+	sent       uint64
+	keepalives uint64
+	drops      uint64
+}
+
+// SetShaper configures (or replaces) traffic shaping for peerID. Safe to
+// call before or after a session with peerID is established; WrapPacket
+// and StartShaperLoop just see no shaping configured until it is.
+func (tm *TunnelManager) SetShaper(peerID string, cfg Shaper) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.shapers == nil {
+		tm.shapers = make(map[string]*shaperState)
+	}
+	tm.shapers[peerID] = &shaperState{cfg: cfg, queue: make(chan []byte, shaperQueueSize), nextSend: time.Now()}
+}
+
+// padFrame builds the `[plaintext_len:2][plaintext][padding]` frame
+// ShaperPad/ShaperCPR encrypt in place of the raw plaintext: a two-byte
+// length prefix followed by plaintext, followed by random padding out to
+// the next paddingBucketSize boundary. unpadFrame reverses it after
+// AEAD.Open.
+func padFrame(plaintext []byte) ([]byte, error) {
+	if len(plaintext) > 1<<16-1 {
+		return nil, fmt.Errorf("pqc: plaintext of %d bytes too large to shape (max %d)", len(plaintext), 1<<16-1)
+	}
+	total := ((2 + len(plaintext) + paddingBucketSize - 1) / paddingBucketSize) * paddingBucketSize
+	frame := make([]byte, total)
+	binary.BigEndian.PutUint16(frame[0:2], uint16(len(plaintext)))
+	copy(frame[2:], plaintext)
+	if _, err := io.ReadFull(rand.Reader, frame[2+len(plaintext):]); err != nil {
+		return nil, fmt.Errorf("pad frame: %w", err)
+	}
+	return frame, nil
+}
+
+// unpadFrame reverses padFrame, returning the original plaintext.
+func unpadFrame(frame []byte) ([]byte, error) {
+	if len(frame) < 2 {
+		return nil, fmt.Errorf("pqc: shaped frame truncated")
+	}
+	n := binary.BigEndian.Uint16(frame[0:2])
+	if int(n) > len(frame)-2 {
+		return nil, fmt.Errorf("pqc: shaped frame length prefix exceeds frame size")
+	}
+	return frame[2 : 2+n], nil
+}
+
+// shaperMode reports the shaping configured for peerID, ShaperNone if
+// SetShaper was never called for it.
+func (tm *TunnelManager) shaperMode(peerID string) ShaperMode {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	state, ok := tm.shapers[peerID]
+	if !ok {
+		return ShaperNone
+	}
+	return state.cfg.Mode
+}
+
+// sealFrame applies the shaping configured for peerID to data (if any),
+// then encrypts and PQC1-frames the result. Shared by WrapPacket's direct
+// return path and StartShaperLoop's queue drain/keepalive path, so both
+// produce identical wire framing.
+func (tm *TunnelManager) sealFrame(data []byte, peerID string, mode ShaperMode) ([]byte, error) {
+	plaintext := data
+	if mode != ShaperNone {
+		framed, err := padFrame(data)
+		if err != nil {
+			return nil, err
+		}
+		plaintext = framed
+	}
+
+	encrypted, err := tm.Encrypt(plaintext, peerID)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := make([]byte, 8+len(encrypted))
+	copy(msg[0:4], "PQC1")
+	binary.BigEndian.PutUint32(msg[4:8], uint32(len(encrypted)))
+	copy(msg[8:], encrypted)
+	return msg, nil
+}
+
+// WrapPacket adds framing to encrypted data: b"PQC1" + [length:4] + encrypted_data.
+// If peerID has ShaperPad configured (see SetShaper), the plaintext is
+// padded (see padFrame) before encryption. If it has ShaperCPR configured,
+// WrapPacket instead enqueues the sealed frame for StartShaperLoop to emit
+// on schedule and returns (nil, nil); the caller isn't meant to send
+// anything itself in that case.
+func (tm *TunnelManager) WrapPacket(data []byte, peerID string) ([]byte, error) {
+	mode := tm.shaperMode(peerID)
+	msg, err := tm.sealFrame(data, peerID, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != ShaperCPR {
+		return msg, nil
+	}
+
+	tm.mu.Lock()
+	state := tm.shapers[peerID]
+	tm.mu.Unlock()
+	select {
+	case state.queue <- msg:
+	default:
+		tm.mu.Lock()
+		state.drops++
+		tm.mu.Unlock()
+	}
+	return nil, nil
+}
+
+// UnwrapPacket validates framing and decrypts data. If peerID has
+// ShaperPad or ShaperCPR configured locally (see SetShaper) — which must
+// agree with the shaping the sender applied, UnwrapPacket has no way to
+// detect that from the wire alone — the decrypted plaintext is unpadded
+// (see unpadFrame) before being returned. A zero-length result after
+// unpadding is a ShaperCPR keepalive frame; UnwrapPacket returns (nil, nil)
+// for it, so callers can tell it apart from a failed decryption by checking
+// the error instead of the length. A shaped message whose plaintext was
+// genuinely empty also unpads to zero length and returns (nil, nil); the
+// two cannot be told apart.
+func (tm *TunnelManager) UnwrapPacket(data []byte, peerID string) ([]byte, error) {
+	if len(data) < 8 || string(data[0:4]) != "PQC1" {
+		return nil, fmt.Errorf("invalid PQC packet magic")
+	}
+
+	length := binary.BigEndian.Uint32(data[4:8])
+	if len(data) < int(8+length) {
+		return nil, fmt.Errorf("PQC packet truncated")
+	}
+
+	plaintext, err := tm.Decrypt(data[8:8+length], peerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if tm.shaperMode(peerID) == ShaperNone {
+		return plaintext, nil
+	}
+	unpadded, err := unpadFrame(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if len(unpadded) == 0 {
+		return nil, nil
+	}
+	return unpadded, nil
+}
+
+// pqc2Magic tags a data packet framed with a trailing MAC1 (see
+// computeMAC1), keyed on the session's peerPubKey, so the receiver can drop
+// a corrupted or spoofed packet with one cheap hash before paying for an
+// AEAD Open. Distinct from the plain PQC1 framing WrapPacket/UnwrapPacket
+// use so a receiver that doesn't expect MAC1 framing isn't handed one.
+const pqc2Magic = "PQC2"
+
+// WrapPacketV2 is WrapPacket but additionally appends a MAC1 keyed on
+// peerID's session.peerPubKey. Per the one-way-KEM handshake (see
+// SessionKey.peerPubKey), that's only populated on the side that called
+// ProcessHandshakeInit; callers on the ProcessHandshakeResponse side have no
+// peer public key to key a MAC1 with and should keep using WrapPacket.
+func (tm *TunnelManager) WrapPacketV2(data []byte, peerID string) ([]byte, error) {
+	tm.mu.RLock()
+	session, ok := tm.sessions[peerID]
+	tm.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no session with peer: %s", peerID)
+	}
+	if session.peerPubKey == nil {
+		return nil, fmt.Errorf("cannot MAC1-frame packet to %s: peer public key unknown", peerID)
+	}
+
+	encrypted, err := tm.Encrypt(data, peerID)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := make([]byte, 8+len(encrypted))
+	copy(msg[0:4], pqc2Magic)
+	binary.BigEndian.PutUint32(msg[4:8], uint32(len(encrypted)))
+	copy(msg[8:], encrypted)
+
+	mac1, err := computeMAC1(session.peerPubKey, msg)
+	if err != nil {
+		return nil, fmt.Errorf("compute mac1: %w", err)
+	}
+	return append(msg, mac1...), nil
+}
+
+// UnwrapPacketV2 validates PQC2 framing and its trailing MAC1 (see
+// WrapPacketV2) before decrypting, keyed on our own public key since the
+// sender is the side that addressed this MAC1 to us.
+func (tm *TunnelManager) UnwrapPacketV2(data []byte, peerID string) ([]byte, error) {
+	if len(data) < 8+mac1Size || string(data[0:4]) != pqc2Magic {
+		return nil, fmt.Errorf("invalid PQC2 packet magic")
+	}
+
+	base := data[:len(data)-mac1Size]
+	gotMAC1 := data[len(data)-mac1Size:]
+	expectedMAC1, err := computeMAC1(tm.keys.PublicKey, base)
+	if err != nil {
+		return nil, fmt.Errorf("compute mac1: %w", err)
+	}
+	if !hmac.Equal(gotMAC1, expectedMAC1) {
+		return nil, fmt.Errorf("pqc: PQC2 packet MAC1 mismatch, dropping before AEAD")
+	}
+
+	length := binary.BigEndian.Uint32(base[4:8])
+	if len(base) < int(8+length) {
+		return nil, fmt.Errorf("PQC2 packet truncated")
+	}
+
+	return tm.Decrypt(base[8:8+length], peerID)
+}
+
+// HasSession checks if a session exists with a peer.
+func (tm *TunnelManager) HasSession(peerID string) bool {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	_, ok := tm.sessions[peerID]
+	return ok
+}
+
+// RemoveSession removes a session with a peer.
+func (tm *TunnelManager) RemoveSession(peerID string) {
+	tm.mu.Lock()
+	delete(tm.sessions, peerID)
+	tm.mu.Unlock()
+}
+
+// RekeyCheckInterval is how often StartRekeyLoop polls every session's
+// NeedsRekey, matching the other mesh background loops' ticker cadence.
+const RekeyCheckInterval = 10 * time.Second
+
+// pqcCloseFrameMagic tags the optional close notification Shutdown sends
+// to a peer when draining its session. It's a distinct wire marker from
+// the handshake and rekey formats above, so a receiver that doesn't
+// recognize it can simply drop it.
+const pqcCloseFrameMagic = "PQCX"
+
+// StartRekeyLoop spawns a goroutine that polls every session against
+// NeedsRekey every RekeyCheckInterval and, for any that are due and whose
+// peer public key is known (see CreateRekeyInit), hands the resulting
+// rekey-init frame to send so the caller can put it on the wire however
+// it dispatches PQC frames. It exits once ctx is cancelled; call Wait
+// afterward to block until it actually has.
+func (tm *TunnelManager) StartRekeyLoop(ctx context.Context, send func(peerID string, frame []byte)) {
+	tm.wg.Add(1)
+	go func() {
+		defer tm.wg.Done()
+		ticker := time.NewTicker(RekeyCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				tm.rekeyDueSessions(send)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Wait blocks until every goroutine started by StartRekeyLoop,
+// StartEncryptWorkers (and the per-peer transmitters it enables) and
+// StartShaperLoop has actually returned.
+func (tm *TunnelManager) Wait() {
+	tm.wg.Wait()
+}
+
+// rekeyDueSessions calls CreateRekeyInit for every session NeedsRekey
+// reports as due, passing each resulting frame to send.
+func (tm *TunnelManager) rekeyDueSessions(send func(peerID string, frame []byte)) {
+	tm.mu.RLock()
+	peerIDs := make([]string, 0, len(tm.sessions))
+	for peerID := range tm.sessions {
+		peerIDs = append(peerIDs, peerID)
+	}
+	tm.mu.RUnlock()
+
+	for _, peerID := range peerIDs {
+		if !tm.NeedsRekey(peerID) {
+			continue
+		}
+		frame, err := tm.CreateRekeyInit(peerID)
+		if err != nil {
+			// Most commonly: this side never learned the peer's public
+			// key (see CreateRekeyInit), so it can't originate a rekey —
+			// it can only receive one via ProcessRekeyInit. Not an error
+			// worth logging every tick.
+			continue
+		}
+		send(peerID, frame)
+	}
+}
+
+// Shutdown drains every active session: if send is non-nil, it's called
+// once per peer with a close-frame notification (see pqcCloseFrameMagic)
+// before the session is removed, giving the far end a chance to tear down
+// its own state instead of waiting for it to time out. send may be nil to
+// just clear sessions without notifying anyone.
+func (tm *TunnelManager) Shutdown(send func(peerID string, frame []byte)) {
+	tm.mu.RLock()
+	peerIDs := make([]string, 0, len(tm.sessions))
+	for peerID := range tm.sessions {
+		peerIDs = append(peerIDs, peerID)
+	}
+	tm.mu.RUnlock()
+
+	for _, peerID := range peerIDs {
+		if send != nil {
+			send(peerID, []byte(pqcCloseFrameMagic))
+		}
+		tm.RemoveSession(peerID)
+	}
+}
+
+// shaperTickInterval is how often StartShaperLoop checks every ShaperCPR
+// peer against its nextSend deadline. Fine-grained relative to
+// RekeyCheckInterval since CPR rates are typically several frames per
+// second, not one every few seconds.
+const shaperTickInterval = 10 * time.Millisecond
+
+// StartShaperLoop spawns a goroutine that, every shaperTickInterval,
+// checks every peer configured with ShaperCPR (see SetShaper) against its
+// next scheduled send time and, once due, emits either the oldest frame
+// queued for it by WrapPacket or — if nothing is queued — a zero-length
+// keepalive frame, via send, then schedules the next send 1000/RatePerSecond
+// ms later. It exits once ctx is cancelled; call Wait afterward to block
+// until it actually has.
+func (tm *TunnelManager) StartShaperLoop(ctx context.Context, send func(peerID string, frame []byte)) {
+	tm.wg.Add(1)
+	go func() {
+		defer tm.wg.Done()
+		ticker := time.NewTicker(shaperTickInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				tm.emitDueCPRFrames(send)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// emitDueCPRFrames sends one frame for every ShaperCPR peer whose
+// nextSend deadline has passed: the oldest frame WrapPacket queued for it,
+// or a keepalive if none is queued.
+func (tm *TunnelManager) emitDueCPRFrames(send func(peerID string, frame []byte)) {
+	now := time.Now()
+
+	tm.mu.RLock()
+	type due struct {
+		peerID string
+		state  *shaperState
+	}
+	var dueStates []due
+	for peerID, state := range tm.shapers {
+		if state.cfg.Mode == ShaperCPR && state.cfg.RatePerSecond > 0 && !now.Before(state.nextSend) {
+			dueStates = append(dueStates, due{peerID, state})
+		}
+	}
+	tm.mu.RUnlock()
+
+	for _, d := range dueStates {
+		var frame []byte
+		select {
+		case frame = <-d.state.queue:
+			tm.mu.Lock()
+			d.state.sent++
+			tm.mu.Unlock()
+		default:
+			keepalive, err := tm.sealFrame(nil, d.peerID, ShaperCPR)
+			if err != nil {
+				// Most commonly: no session with this peer (yet). Skip this
+				// tick rather than advancing nextSend on a frame that was
+				// never actually sent.
+				continue
+			}
+			frame = keepalive
+			tm.mu.Lock()
+			d.state.keepalives++
+			tm.mu.Unlock()
+		}
+
+		send(d.peerID, frame)
+
+		tm.mu.Lock()
+		interval := time.Second / time.Duration(d.state.cfg.RatePerSecond)
+		d.state.nextSend = d.state.nextSend.Add(interval)
+		if now.Sub(d.state.nextSend) > interval {
+			// Fell behind by more than one interval (e.g. the process was
+			// stalled); resync instead of bursting to catch up.
+			d.state.nextSend = now.Add(interval)
+		}
+		tm.mu.Unlock()
+	}
+}
+
+// ShaperStats reports per-peer traffic-shaping counters for every peer
+// SetShaper has been called for: queued frames StartShaperLoop sent,
+// keepalive frames sent, frames dropped because the peer's queue was full,
+// and the fraction of emitted frames that carried real traffic rather than
+// a keepalive. All of these counters only move under ShaperCPR; ShaperPad
+// traffic is returned straight from WrapPacket and is not counted. Meant to feed a StatsProvider (see
+// healing.Monitor) so healing.Observation can see shaping is active and,
+// e.g., downgrade a CPR rate instead of rerouting on packet loss.
+func (tm *TunnelManager) ShaperStats() map[string]any {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	stats := make(map[string]any, len(tm.shapers))
+	for peerID, state := range tm.shapers {
+		utilization := 0.0
+		if total := state.sent + state.keepalives; total > 0 {
+			utilization = float64(state.sent) / float64(total)
+		}
+		stats[peerID] = map[string]any{
+			"mode":            state.cfg.Mode.String(),
+			"sent":            state.sent,
+			"keepalives":      state.keepalives,
+			"drops":           state.drops,
+			"avg_utilization": utilization,
+		}
+	}
+	return stats
 }
@@ -2,10 +2,34 @@ package pqc
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 )
 
+// handshake is a small helper that establishes an alice/bob session, used
+// by the rekey/replay/cookie tests below that don't care about the
+// handshake itself.
+func handshake(t *testing.T) (alice, bob *TunnelManager) {
+	t.Helper()
+	alice, _ = NewTunnelManager("alice")
+	bob, _ = NewTunnelManager("bob")
+
+	init := alice.CreateHandshakeInit()
+	_, _, resp, err := bob.ProcessHandshakeInit(init, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("ProcessHandshakeInit: %v", err)
+	}
+	if _, _, err := alice.ProcessHandshakeResponse(resp); err != nil {
+		t.Fatalf("ProcessHandshakeResponse: %v", err)
+	}
+	return alice, bob
+}
+
 func TestNewTunnelManager(t *testing.T) {
 	tm, err := NewTunnelManager("node-a")
 	if err != nil {
@@ -31,7 +55,7 @@ func TestHandshakeRoundTrip(t *testing.T) {
 	initMsg := alice.CreateHandshakeInit()
 
 	// 2. Bob processes init and sends response
-	peerID_B, ss_B, respMsg, err := bob.ProcessHandshakeInit(initMsg)
+	peerID_B, ss_B, respMsg, err := bob.ProcessHandshakeInit(initMsg, "10.0.0.1")
 	if err != nil {
 		t.Fatalf("Bob failed to process init: %v", err)
 	}
@@ -66,7 +90,7 @@ func TestEncryptDecrypt_Session(t *testing.T) {
 	bob, _ := NewTunnelManager("bob")
 
 	init := alice.CreateHandshakeInit()
-	_, _, resp, _ := bob.ProcessHandshakeInit(init)
+	_, _, resp, _ := bob.ProcessHandshakeInit(init, "10.0.0.1")
 	alice.ProcessHandshakeResponse(resp)
 
 	plaintext := []byte("hello quantum world")
@@ -88,7 +112,7 @@ func TestWrapUnwrap(t *testing.T) {
 	alice, _ := NewTunnelManager("alice")
 	bob, _ := NewTunnelManager("bob")
 	init := alice.CreateHandshakeInit()
-	_, _, resp, _ := bob.ProcessHandshakeInit(init)
+	_, _, resp, _ := bob.ProcessHandshakeInit(init, "10.0.0.1")
 	alice.ProcessHandshakeResponse(resp)
 
 	data := []byte("framed data")
@@ -130,7 +154,7 @@ func TestHandshakeErrors(t *testing.T) {
 	tm, _ := NewTunnelManager("node")
 
 	// Truncated message
-	_, _, _, err := tm.ProcessHandshakeInit([]byte{0, 5, 'a'})
+	_, _, _, err := tm.ProcessHandshakeInit([]byte{0, 5, 'a'}, "10.0.0.1")
 	if err == nil {
 		t.Error("expected error for truncated init")
 	}
@@ -140,7 +164,7 @@ func TestHandshakeErrors(t *testing.T) {
 	binary.BigEndian.PutUint16(badPK[0:2], 4)
 	copy(badPK[2:], "peer")
 	copy(badPK[6:], []byte("not-a-pk"))
-	_, _, _, err = tm.ProcessHandshakeInit(badPK)
+	_, _, _, err = tm.ProcessHandshakeInit(badPK, "10.0.0.1")
 	if err == nil {
 		t.Error("expected error for invalid PK")
 	}
@@ -150,7 +174,7 @@ func TestRemoveSession(t *testing.T) {
 	alice, _ := NewTunnelManager("alice")
 	bob, _ := NewTunnelManager("bob")
 	init := alice.CreateHandshakeInit()
-	_, _, resp, _ := bob.ProcessHandshakeInit(init)
+	_, _, resp, _ := bob.ProcessHandshakeInit(init, "10.0.0.1")
 	alice.ProcessHandshakeResponse(resp)
 
 	if !alice.HasSession("bob") {
@@ -169,3 +193,967 @@ func TestKeyPairUniqueness(t *testing.T) {
 		t.Error("different nodes should have different keys")
 	}
 }
+
+func TestDecrypt_RejectsReplayedCounter(t *testing.T) {
+	alice, bob := handshake(t)
+
+	ciphertext, err := alice.Encrypt([]byte("first"), "bob")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	if _, err := bob.Decrypt(ciphertext, "alice"); err != nil {
+		t.Fatalf("first Decrypt: %v", err)
+	}
+
+	if _, err := bob.Decrypt(ciphertext, "alice"); err == nil {
+		t.Error("expected replay of the same ciphertext to be rejected")
+	}
+}
+
+func TestDecrypt_RejectsCounterTooOld(t *testing.T) {
+	alice, bob := handshake(t)
+
+	var ciphertexts [][]byte
+	for i := 0; i < replayWindowSize+1; i++ {
+		ct, err := alice.Encrypt([]byte("msg"), "bob")
+		if err != nil {
+			t.Fatalf("Encrypt: %v", err)
+		}
+		ciphertexts = append(ciphertexts, ct)
+	}
+
+	// Deliver the newest message first, sliding the window past counter 0.
+	if _, err := bob.Decrypt(ciphertexts[len(ciphertexts)-1], "alice"); err != nil {
+		t.Fatalf("Decrypt newest: %v", err)
+	}
+
+	// The oldest counter (0) is now outside the trailing window and must
+	// be rejected even though it was never actually delivered before.
+	if _, err := bob.Decrypt(ciphertexts[0], "alice"); err == nil {
+		t.Error("expected a too-old counter to be rejected")
+	}
+}
+
+func TestDecrypt_AcceptsOutOfOrderWithinWindow(t *testing.T) {
+	alice, bob := handshake(t)
+
+	ct1, _ := alice.Encrypt([]byte("one"), "bob")
+	ct2, _ := alice.Encrypt([]byte("two"), "bob")
+
+	if _, err := bob.Decrypt(ct2, "alice"); err != nil {
+		t.Fatalf("Decrypt ct2: %v", err)
+	}
+	if _, err := bob.Decrypt(ct1, "alice"); err != nil {
+		t.Errorf("out-of-order but in-window counter should be accepted: %v", err)
+	}
+}
+
+func TestNeedsRekey_MessageThreshold(t *testing.T) {
+	alice, _ := handshake(t)
+
+	if alice.NeedsRekey("bob") {
+		t.Error("freshly established session should not need a rekey yet")
+	}
+
+	alice.mu.Lock()
+	alice.sessions["bob"].msgCount = RekeyAfterMessages
+	alice.mu.Unlock()
+
+	if !alice.NeedsRekey("bob") {
+		t.Error("session at RekeyAfterMessages should need a rekey")
+	}
+}
+
+func TestRekey_RotatesKeyAndGraceWindowAcceptsOldKey(t *testing.T) {
+	alice, bob := handshake(t)
+
+	// Bob processed alice's handshake init, so only bob holds alice's
+	// public key and can originate the rekey.
+	if _, err := alice.CreateRekeyInit("bob"); err == nil {
+		t.Fatal("alice should not be able to originate a rekey without bob's public key")
+	}
+
+	// Encrypt one message under the pre-rekey key before rotating, to
+	// simulate it being in flight when the rekey lands.
+	inFlight, err := bob.Encrypt([]byte("in flight before rekey"), "alice")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	rekeyMsg, err := bob.CreateRekeyInit("alice")
+	if err != nil {
+		t.Fatalf("CreateRekeyInit: %v", err)
+	}
+
+	peerID, err := alice.ProcessRekeyInit(rekeyMsg)
+	if err != nil {
+		t.Fatalf("ProcessRekeyInit: %v", err)
+	}
+	if peerID != "bob" {
+		t.Errorf("peerID = %s, want bob", peerID)
+	}
+
+	// The pre-rekey message should still decrypt during the grace window.
+	if _, err := alice.Decrypt(inFlight, "bob"); err != nil {
+		t.Errorf("in-flight packet under the old key should still decrypt: %v", err)
+	}
+
+	// Post-rekey traffic under the new key round-trips too.
+	ciphertext, err := bob.Encrypt([]byte("after rekey"), "alice")
+	if err != nil {
+		t.Fatalf("Encrypt after rekey: %v", err)
+	}
+	plaintext, err := alice.Decrypt(ciphertext, "bob")
+	if err != nil {
+		t.Fatalf("Decrypt after rekey: %v", err)
+	}
+	if string(plaintext) != "after rekey" {
+		t.Errorf("plaintext = %q, want %q", plaintext, "after rekey")
+	}
+}
+
+func TestProcessHandshakeInit_RequiresCookieUnderLoad(t *testing.T) {
+	bob, _ := NewTunnelManager("bob")
+	charlie, _ := NewTunnelManager("charlie")
+	bob.SetUnderLoad(true)
+
+	init := charlie.CreateHandshakeInit()
+	peerID, ss, resp, err := bob.ProcessHandshakeInit(init, "203.0.113.9")
+	if !errors.Is(err, ErrCookieRequired) {
+		t.Fatalf("err = %v, want ErrCookieRequired", err)
+	}
+	if peerID != "charlie" {
+		t.Errorf("peerID = %s, want charlie", peerID)
+	}
+	if ss != nil {
+		t.Error("no session should be established before the cookie is echoed")
+	}
+	if bob.HasSession("charlie") {
+		t.Error("no session should be recorded until the cookie handshake completes")
+	}
+
+	_, cookie, err := charlie.ProcessHandshakeResponse(resp)
+	if !errors.Is(err, ErrCookieRequired) {
+		t.Fatalf("err = %v, want ErrCookieRequired", err)
+	}
+
+	retry, err := charlie.CreateHandshakeInitWithCookie(cookie)
+	if err != nil {
+		t.Fatalf("CreateHandshakeInitWithCookie: %v", err)
+	}
+
+	peerID, _, _, err = bob.ProcessHandshakeInit(retry, "203.0.113.9")
+	if err != nil {
+		t.Fatalf("ProcessHandshakeInit with echoed cookie: %v", err)
+	}
+	if peerID != "charlie" {
+		t.Errorf("peerID = %s, want charlie", peerID)
+	}
+	if !bob.HasSession("charlie") {
+		t.Error("session should be established once the cookie is echoed back")
+	}
+}
+
+func TestProcessHandshakeInit_WrongSourceIPRejectsCookie(t *testing.T) {
+	bob, _ := NewTunnelManager("bob")
+	charlie, _ := NewTunnelManager("charlie")
+	bob.SetUnderLoad(true)
+
+	init := charlie.CreateHandshakeInit()
+	_, _, resp, err := bob.ProcessHandshakeInit(init, "203.0.113.9")
+	if !errors.Is(err, ErrCookieRequired) {
+		t.Fatalf("err = %v, want ErrCookieRequired", err)
+	}
+	_, cookie, _ := charlie.ProcessHandshakeResponse(resp)
+
+	retry, _ := charlie.CreateHandshakeInitWithCookie(cookie)
+
+	// Same cookie, different source IP: must be challenged again rather
+	// than accepted, since the cookie is bound to the requester's address.
+	_, _, _, err = bob.ProcessHandshakeInit(retry, "198.51.100.2")
+	if !errors.Is(err, ErrCookieRequired) {
+		t.Fatalf("err = %v, want ErrCookieRequired for a cookie replayed from a different IP", err)
+	}
+}
+
+func TestRekeyDueSessions_SendsRekeyFrameOnlyWhenDue(t *testing.T) {
+	alice, bob := handshake(t)
+
+	var mu sync.Mutex
+	sentFrames := map[string][]byte{}
+	send := func(peerID string, frame []byte) {
+		mu.Lock()
+		sentFrames[peerID] = frame
+		mu.Unlock()
+	}
+
+	bob.rekeyDueSessions(send)
+	if len(sentFrames) != 0 {
+		t.Fatalf("sentFrames = %v, want none for a freshly established session", sentFrames)
+	}
+
+	// Bob processed alice's handshake init, so he holds alice's public
+	// key and can originate a rekey with her (see CreateRekeyInit).
+	bob.mu.Lock()
+	bob.sessions["alice"].msgCount = RekeyAfterMessages
+	bob.mu.Unlock()
+
+	bob.rekeyDueSessions(send)
+	frame, ok := sentFrames["alice"]
+	if !ok || len(sentFrames) != 1 {
+		t.Fatalf("sentFrames = %v, want exactly one frame for alice", sentFrames)
+	}
+
+	if _, err := alice.ProcessRekeyInit(frame); err != nil {
+		t.Fatalf("ProcessRekeyInit on the frame rekeyDueSessions sent: %v", err)
+	}
+}
+
+func TestStartRekeyLoop_ContextCancelStopsPromptly(t *testing.T) {
+	alice, _ := handshake(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	alice.StartRekeyLoop(ctx, func(peerID string, frame []byte) {})
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		alice.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("rekey loop did not exit within 1s of context cancellation")
+	}
+}
+
+func TestShutdown_RemovesSessionsAndNotifiesPeer(t *testing.T) {
+	alice, _ := handshake(t)
+
+	var notified []string
+	alice.Shutdown(func(peerID string, frame []byte) {
+		notified = append(notified, peerID)
+		if string(frame) != pqcCloseFrameMagic {
+			t.Errorf("frame = %q, want %q", frame, pqcCloseFrameMagic)
+		}
+	})
+
+	if len(notified) != 1 || notified[0] != "bob" {
+		t.Fatalf("notified = %v, want [bob]", notified)
+	}
+	if alice.HasSession("bob") {
+		t.Error("session should be removed after Shutdown")
+	}
+}
+
+func TestCreateHandshakeInitForPeer_MAC1RoundTrip(t *testing.T) {
+	alice, _ := NewTunnelManager("alice")
+	bob, _ := NewTunnelManager("bob")
+
+	init, err := alice.CreateHandshakeInitForPeer(bob.GetPublicKey())
+	if err != nil {
+		t.Fatalf("CreateHandshakeInitForPeer: %v", err)
+	}
+
+	peerID, _, resp, err := bob.ProcessHandshakeInit(init, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("ProcessHandshakeInit: %v", err)
+	}
+	if peerID != "alice" {
+		t.Errorf("peerID = %s, want alice", peerID)
+	}
+
+	if _, _, err := alice.ProcessHandshakeResponse(resp); err != nil {
+		t.Fatalf("ProcessHandshakeResponse: %v", err)
+	}
+}
+
+func TestProcessHandshakeInit_RejectsTamperedMAC1(t *testing.T) {
+	alice, _ := NewTunnelManager("alice")
+	bob, _ := NewTunnelManager("bob")
+
+	init, err := alice.CreateHandshakeInitForPeer(bob.GetPublicKey())
+	if err != nil {
+		t.Fatalf("CreateHandshakeInitForPeer: %v", err)
+	}
+	init[len(init)-1] ^= 0xFF
+
+	if _, _, _, err := bob.ProcessHandshakeInit(init, "10.0.0.1"); err == nil {
+		t.Error("ProcessHandshakeInit should reject an init whose MAC1 no longer matches")
+	}
+}
+
+func TestProcessHandshakeInit_MAC1KeyedToWrongReceiverRejected(t *testing.T) {
+	alice, _ := NewTunnelManager("alice")
+	bob, _ := NewTunnelManager("bob")
+	mallory, _ := NewTunnelManager("mallory")
+
+	// MAC1 computed for mallory's key must not verify against bob's.
+	init, err := alice.CreateHandshakeInitForPeer(mallory.GetPublicKey())
+	if err != nil {
+		t.Fatalf("CreateHandshakeInitForPeer: %v", err)
+	}
+
+	if _, _, _, err := bob.ProcessHandshakeInit(init, "10.0.0.1"); err == nil {
+		t.Error("ProcessHandshakeInit should reject a MAC1 keyed to a different receiver")
+	}
+}
+
+func TestWrapUnwrapV2_RoundTrip(t *testing.T) {
+	alice, bob := handshake(t)
+
+	data := []byte("framed data, MAC1 tagged")
+	wrapped, err := bob.WrapPacketV2(data, "alice")
+	if err != nil {
+		t.Fatalf("WrapPacketV2: %v", err)
+	}
+	if string(wrapped[0:4]) != pqc2Magic {
+		t.Errorf("magic = %s, want %s", wrapped[0:4], pqc2Magic)
+	}
+
+	unwrapped, err := alice.UnwrapPacketV2(wrapped, "bob")
+	if err != nil {
+		t.Fatalf("UnwrapPacketV2: %v", err)
+	}
+	if !bytes.Equal(data, unwrapped) {
+		t.Errorf("unwrapped = %q, want %q", unwrapped, data)
+	}
+}
+
+func TestWrapPacketV2_ErrorsWithoutKnownPeerKey(t *testing.T) {
+	alice, _ := handshake(t)
+
+	// alice is the ProcessHandshakeResponse side: she never learned bob's
+	// public key under this wire format (see SessionKey.peerPubKey), so she
+	// can't key a MAC1 to frame a PQC2 packet.
+	if _, err := alice.WrapPacketV2([]byte("data"), "bob"); err == nil {
+		t.Error("WrapPacketV2 should fail when the session has no known peer public key")
+	}
+}
+
+func TestUnwrapPacketV2_RejectsTamperedMAC1(t *testing.T) {
+	alice, bob := handshake(t)
+
+	wrapped, err := bob.WrapPacketV2([]byte("framed data"), "alice")
+	if err != nil {
+		t.Fatalf("WrapPacketV2: %v", err)
+	}
+	wrapped[len(wrapped)-1] ^= 0xFF
+
+	if _, err := alice.UnwrapPacketV2(wrapped, "bob"); err == nil {
+		t.Error("UnwrapPacketV2 should reject a packet whose MAC1 no longer matches")
+	}
+}
+
+func TestEncryptAsyncDecryptAsync_RoundTripInline(t *testing.T) {
+	alice, bob := handshake(t)
+
+	// No StartEncryptWorkers call: EncryptAsync/DecryptAsync must fall back
+	// to sealing/opening inline rather than block forever on an empty pool.
+	plaintext := []byte("async, no worker pool running")
+	encRes := <-alice.EncryptAsync(plaintext, "bob")
+	if encRes.Err != nil {
+		t.Fatalf("EncryptAsync: %v", encRes.Err)
+	}
+	defer encRes.Release()
+
+	decRes := <-bob.DecryptAsync(encRes.Ciphertext, "alice")
+	if decRes.Err != nil {
+		t.Fatalf("DecryptAsync: %v", decRes.Err)
+	}
+	if !bytes.Equal(plaintext, decRes.Plaintext) {
+		t.Errorf("decrypted = %q, want %q", decRes.Plaintext, plaintext)
+	}
+}
+
+func TestEncryptAsyncDecryptAsync_RoundTripWithWorkerPool(t *testing.T) {
+	alice, bob := handshake(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	alice.StartEncryptWorkers(ctx, 2)
+	bob.StartEncryptWorkers(ctx, 2)
+
+	plaintext := []byte("async, with workers running")
+	encRes := <-alice.EncryptAsync(plaintext, "bob")
+	if encRes.Err != nil {
+		t.Fatalf("EncryptAsync: %v", encRes.Err)
+	}
+	defer encRes.Release()
+
+	decRes := <-bob.DecryptAsync(encRes.Ciphertext, "alice")
+	if decRes.Err != nil {
+		t.Fatalf("DecryptAsync: %v", decRes.Err)
+	}
+	if !bytes.Equal(plaintext, decRes.Plaintext) {
+		t.Errorf("decrypted = %q, want %q", decRes.Plaintext, plaintext)
+	}
+}
+
+func TestEncryptAsyncDecryptAsync_PreservesPerPeerOrderUnderConcurrency(t *testing.T) {
+	alice, bob := handshake(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	alice.StartEncryptWorkers(ctx, 4)
+	bob.StartEncryptWorkers(ctx, 4)
+
+	const n = 50
+	chans := make([]<-chan EncryptResult, n)
+	for i := 0; i < n; i++ {
+		chans[i] = alice.EncryptAsync([]byte(fmt.Sprintf("msg-%d", i)), "bob")
+	}
+
+	// Reading the channels back in call order must see results in that
+	// same order — a library guarantee from alice's per-peer sequential
+	// transmitter, not something this test arranges by luck — and bob's
+	// replay window, fed in that same order, must accept every one.
+	for i, ch := range chans {
+		res := <-ch
+		if res.Err != nil {
+			t.Fatalf("EncryptAsync[%d]: %v", i, res.Err)
+		}
+		decRes := <-bob.DecryptAsync(res.Ciphertext, "alice")
+		if decRes.Err != nil {
+			t.Fatalf("DecryptAsync[%d]: %v", i, decRes.Err)
+		}
+		want := fmt.Sprintf("msg-%d", i)
+		if string(decRes.Plaintext) != want {
+			t.Errorf("DecryptAsync[%d] = %q, want %q", i, decRes.Plaintext, want)
+		}
+		res.Release()
+	}
+}
+
+func TestEncryptBatchDecryptBatch_PreservesOrder(t *testing.T) {
+	alice, bob := handshake(t)
+
+	reqs := make([]EncryptRequest, 5)
+	want := make([][]byte, 5)
+	for i := range reqs {
+		want[i] = []byte(fmt.Sprintf("message %d", i))
+		reqs[i] = EncryptRequest{Data: want[i], PeerID: "bob"}
+	}
+
+	encResults := alice.EncryptBatch(reqs)
+	decReqs := make([]DecryptRequest, len(encResults))
+	for i, r := range encResults {
+		if r.Err != nil {
+			t.Fatalf("EncryptBatch[%d]: %v", i, r.Err)
+		}
+		decReqs[i] = DecryptRequest{Data: r.Ciphertext, PeerID: "alice"}
+	}
+
+	decResults := bob.DecryptBatch(decReqs)
+	for i, r := range decResults {
+		if r.Err != nil {
+			t.Fatalf("DecryptBatch[%d]: %v", i, r.Err)
+		}
+		if !bytes.Equal(r.Plaintext, want[i]) {
+			t.Errorf("DecryptBatch[%d] = %q, want %q", i, r.Plaintext, want[i])
+		}
+	}
+}
+
+func TestDecryptAsync_RejectsReplayedCounter(t *testing.T) {
+	alice, bob := handshake(t)
+
+	encRes := <-alice.EncryptAsync([]byte("once only"), "bob")
+	if encRes.Err != nil {
+		t.Fatalf("EncryptAsync: %v", encRes.Err)
+	}
+
+	if r := <-bob.DecryptAsync(encRes.Ciphertext, "alice"); r.Err != nil {
+		t.Fatalf("first DecryptAsync: %v", r.Err)
+	}
+	if r := <-bob.DecryptAsync(encRes.Ciphertext, "alice"); r.Err == nil {
+		t.Error("DecryptAsync should reject a replayed counter")
+	}
+}
+
+// TestDecryptAsync_RejectsConcurrentReplayedCounter fires two DecryptAsync
+// calls for the identical ciphertext without waiting for the first to
+// finish, so both can reach the replay-window check before either reaches
+// commitDecrypt — the race the pendingDecrypt reservation closes. Run with
+// -race to catch the data race this used to also have.
+func TestDecryptAsync_RejectsConcurrentReplayedCounter(t *testing.T) {
+	alice, bob := handshake(t)
+	bob.StartEncryptWorkers(context.Background(), 4)
+
+	encRes := <-alice.EncryptAsync([]byte("once only"), "bob")
+	if encRes.Err != nil {
+		t.Fatalf("EncryptAsync: %v", encRes.Err)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]DecryptResult, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = <-bob.DecryptAsync(encRes.Ciphertext, "alice")
+		}(i)
+	}
+	wg.Wait()
+
+	successes := 0
+	for _, r := range results {
+		if r.Err == nil {
+			successes++
+		}
+	}
+	if successes != 1 {
+		t.Errorf("got %d successful concurrent decrypts of the same counter, want exactly 1", successes)
+	}
+}
+
+func TestStartEncryptWorkers_ContextCancelStopsPromptly(t *testing.T) {
+	alice, _ := handshake(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	alice.StartEncryptWorkers(ctx, 2)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		alice.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("encrypt workers did not exit within 1s of context cancellation")
+	}
+}
+
+func TestEncryptAsync_ContextCancelDoesNotHangOnAbandonedPeerQueue(t *testing.T) {
+	alice, _ := handshake(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	alice.StartEncryptWorkers(ctx, 2)
+
+	// Warm up: this creates "bob"'s per-peer transmitter.
+	res := <-alice.EncryptAsync([]byte("warmup"), "bob")
+	if res.Err != nil {
+		t.Fatalf("warmup EncryptAsync: %v", res.Err)
+	}
+	res.Release()
+
+	cancel()
+	// Give runPeerEncTransmitter time to observe ctx.Done() and return, so
+	// the next EncryptAsync races against an already-abandoned queue —
+	// exactly the window where an unconditional queue send would hang
+	// forever with nothing left to read it.
+	time.Sleep(50 * time.Millisecond)
+
+	// Whether the transmitter or the cancellation wins the race is
+	// intentionally not asserted — both are legitimate outcomes (the
+	// transmitter may still drain this one last element, or it may already
+	// be gone). What must never happen is EncryptAsync blocking forever
+	// because nothing is left to read the queue send.
+	done := make(chan EncryptResult, 1)
+	go func() { done <- <-alice.EncryptAsync([]byte("after cancel"), "bob") }()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("EncryptAsync hung after its peer's transmitter had already exited")
+	}
+}
+
+func TestEncryptResult_ReleaseIsSafeToCallTwiceOrOnZeroValue(t *testing.T) {
+	var zero EncryptResult
+	zero.Release()
+	zero.Release()
+
+	alice, _ := handshake(t)
+	encRes := <-alice.EncryptAsync([]byte("data"), "bob")
+	if encRes.Err != nil {
+		t.Fatalf("EncryptAsync: %v", encRes.Err)
+	}
+	encRes.Release()
+	encRes.Release()
+}
+
+func TestEncrypt_NonceSaltDiffersAcrossSessionsWithSameCounter(t *testing.T) {
+	alice1, bob1 := handshake(t)
+	alice2, bob2 := handshake(t)
+
+	ct1, err := alice1.Encrypt([]byte("msg"), "bob")
+	if err != nil {
+		t.Fatalf("Encrypt alice1: %v", err)
+	}
+	ct2, err := alice2.Encrypt([]byte("msg"), "bob")
+	if err != nil {
+		t.Fatalf("Encrypt alice2: %v", err)
+	}
+
+	// Both sessions' first message uses sendCounter 0, so the nonces can
+	// only differ in their leading sendSalt bytes. A collision there would
+	// mean two sessions reused the exact same nonce under (presumably)
+	// different keys, which is the scenario sendSalt exists to rule out.
+	if bytes.Equal(ct1[:sendSaltSize], ct2[:sendSaltSize]) {
+		t.Error("two independent sessions produced the same nonce salt")
+	}
+
+	if _, err := bob1.Decrypt(ct1, "alice"); err != nil {
+		t.Errorf("Decrypt ct1: %v", err)
+	}
+	if _, err := bob2.Decrypt(ct2, "alice"); err != nil {
+		t.Errorf("Decrypt ct2: %v", err)
+	}
+}
+
+func TestSessionsNeedingRekey_CountsOnlyDueSessions(t *testing.T) {
+	alice, _ := handshake(t)
+	alice2, _ := handshake(t)
+	alice.sessions["carol"] = alice2.sessions["bob"]
+
+	if n := alice.SessionsNeedingRekey(); n != 0 {
+		t.Errorf("SessionsNeedingRekey = %d, want 0 before any session is due", n)
+	}
+
+	alice.mu.Lock()
+	alice.sessions["carol"].msgCount = RekeyAfterMessages
+	alice.mu.Unlock()
+
+	if n := alice.SessionsNeedingRekey(); n != 1 {
+		t.Errorf("SessionsNeedingRekey = %d, want 1 once one session crosses the threshold", n)
+	}
+}
+
+func TestWrapPacketUnwrapPacket_ShaperPadRoundTrip(t *testing.T) {
+	alice, bob := handshake(t)
+	alice.SetShaper("bob", Shaper{Mode: ShaperPad})
+	bob.SetShaper("alice", Shaper{Mode: ShaperPad})
+
+	wrapped, err := alice.WrapPacket([]byte("hello"), "bob")
+	if err != nil {
+		t.Fatalf("WrapPacket: %v", err)
+	}
+
+	plaintext, err := bob.UnwrapPacket(wrapped, "alice")
+	if err != nil {
+		t.Fatalf("UnwrapPacket: %v", err)
+	}
+	if string(plaintext) != "hello" {
+		t.Errorf("plaintext = %q, want %q", plaintext, "hello")
+	}
+}
+
+func TestWrapPacket_ShaperPadFramesAreBucketSized(t *testing.T) {
+	alice, _ := handshake(t)
+	alice.SetShaper("bob", Shaper{Mode: ShaperPad})
+
+	short, err := alice.WrapPacket([]byte("hi"), "bob")
+	if err != nil {
+		t.Fatalf("WrapPacket short: %v", err)
+	}
+	long, err := alice.WrapPacket(bytes.Repeat([]byte("x"), 200), "bob")
+	if err != nil {
+		t.Fatalf("WrapPacket long: %v", err)
+	}
+
+	// Both plaintexts (2 and 200 bytes) round up into the same
+	// paddingBucketSize bucket once framed, so the wire-visible length
+	// should match despite the large difference in actual content.
+	if len(short) != len(long) {
+		t.Errorf("wrapped lengths differ: short=%d long=%d, want equal (same padding bucket)", len(short), len(long))
+	}
+}
+
+func TestWrapPacket_ShaperCPRQueuesInsteadOfReturning(t *testing.T) {
+	alice, _ := handshake(t)
+	alice.SetShaper("bob", Shaper{Mode: ShaperCPR, RatePerSecond: 100})
+
+	msg, err := alice.WrapPacket([]byte("queued"), "bob")
+	if err != nil {
+		t.Fatalf("WrapPacket: %v", err)
+	}
+	if msg != nil {
+		t.Errorf("WrapPacket under ShaperCPR should return nil, got %d bytes", len(msg))
+	}
+}
+
+func TestStartShaperLoop_EmitsQueuedFrameThenKeepalives(t *testing.T) {
+	alice, bob := handshake(t)
+	alice.SetShaper("bob", Shaper{Mode: ShaperCPR, RatePerSecond: 100})
+	bob.SetShaper("alice", Shaper{Mode: ShaperCPR, RatePerSecond: 100})
+
+	if _, err := alice.WrapPacket([]byte("queued"), "bob"); err != nil {
+		t.Fatalf("WrapPacket: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	frames := make(chan []byte, 8)
+	alice.StartShaperLoop(ctx, func(peerID string, frame []byte) {
+		frames <- frame
+	})
+
+	var gotReal, gotKeepalive bool
+	deadline := time.After(time.Second)
+	for !gotReal || !gotKeepalive {
+		select {
+		case frame := <-frames:
+			plaintext, err := bob.UnwrapPacket(frame, "alice")
+			if err != nil {
+				t.Fatalf("UnwrapPacket: %v", err)
+			}
+			if plaintext == nil {
+				gotKeepalive = true
+			} else if string(plaintext) == "queued" {
+				gotReal = true
+			}
+		case <-deadline:
+			t.Fatalf("timed out waiting for both a real frame and a keepalive (real=%v keepalive=%v)", gotReal, gotKeepalive)
+		}
+	}
+}
+
+func TestStartShaperLoop_ContextCancelStopsPromptly(t *testing.T) {
+	alice, _ := handshake(t)
+	alice.SetShaper("bob", Shaper{Mode: ShaperCPR, RatePerSecond: 10})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	alice.StartShaperLoop(ctx, func(peerID string, frame []byte) {})
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		alice.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("shaper loop did not exit within 1s of context cancellation")
+	}
+}
+
+func TestShaperStats_ReportsDropsWhenQueueFull(t *testing.T) {
+	alice, _ := handshake(t)
+	alice.SetShaper("bob", Shaper{Mode: ShaperCPR, RatePerSecond: 1})
+
+	for i := 0; i < shaperQueueSize+1; i++ {
+		if _, err := alice.WrapPacket([]byte("msg"), "bob"); err != nil {
+			t.Fatalf("WrapPacket #%d: %v", i, err)
+		}
+	}
+
+	stats := alice.ShaperStats()
+	bobStats, ok := stats["bob"].(map[string]any)
+	if !ok {
+		t.Fatalf("ShaperStats missing peer bob: %v", stats)
+	}
+	if drops := bobStats["drops"].(uint64); drops == 0 {
+		t.Error("expected at least one drop once the CPR queue filled up")
+	}
+}
+
+// hybridPeers builds an alice/bob pair on SuiteHybridX25519MLKEM768 and
+// pins each side's Ed25519 identity key on the other via TrustPeer, ready
+// for CreateHandshakeInitHybrid.
+func hybridPeers(t *testing.T) (alice, bob *TunnelManager) {
+	t.Helper()
+	alice, err := NewTunnelManagerWithSuite("alice", SuiteHybridX25519MLKEM768)
+	if err != nil {
+		t.Fatalf("NewTunnelManagerWithSuite(alice): %v", err)
+	}
+	bob, err = NewTunnelManagerWithSuite("bob", SuiteHybridX25519MLKEM768)
+	if err != nil {
+		t.Fatalf("NewTunnelManagerWithSuite(bob): %v", err)
+	}
+	if err := alice.TrustPeer("bob", bob.GetIdentityPublicKey()); err != nil {
+		t.Fatalf("alice.TrustPeer(bob): %v", err)
+	}
+	if err := bob.TrustPeer("alice", alice.GetIdentityPublicKey()); err != nil {
+		t.Fatalf("bob.TrustPeer(alice): %v", err)
+	}
+	return alice, bob
+}
+
+func TestHybridHandshake_RoundTrip(t *testing.T) {
+	alice, bob := hybridPeers(t)
+
+	init, err := alice.CreateHandshakeInitHybrid("bob")
+	if err != nil {
+		t.Fatalf("CreateHandshakeInitHybrid: %v", err)
+	}
+
+	peerID, ssBob, resp, err := bob.ProcessHandshakeInitHybrid(init, "203.0.113.1")
+	if err != nil {
+		t.Fatalf("ProcessHandshakeInitHybrid: %v", err)
+	}
+	if peerID != "alice" {
+		t.Errorf("peerID = %s, want alice", peerID)
+	}
+
+	peerID, ssAlice, err := alice.ProcessHandshakeResponseHybrid(resp)
+	if err != nil {
+		t.Fatalf("ProcessHandshakeResponseHybrid: %v", err)
+	}
+	if peerID != "bob" {
+		t.Errorf("peerID = %s, want bob", peerID)
+	}
+
+	// Both sides only ever see the ML-KEM-768 half of the shared secret
+	// returned to the caller; the X25519 half is folded in via deriveHybridKey.
+	if !bytes.Equal(ssAlice, ssBob) {
+		t.Error("ML-KEM shared secrets do not match")
+	}
+	if !alice.HasSession("bob") || !bob.HasSession("alice") {
+		t.Error("both peers should have an established session")
+	}
+
+	ct, err := alice.Encrypt([]byte("hello over hybrid"), "bob")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	pt, err := bob.Decrypt(ct, "alice")
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if string(pt) != "hello over hybrid" {
+		t.Errorf("Decrypt = %q, want %q", pt, "hello over hybrid")
+	}
+}
+
+func TestHybridHandshake_RequiresCookieUnderLoad(t *testing.T) {
+	alice, bob := hybridPeers(t)
+	bob.SetUnderLoad(true)
+
+	init, err := alice.CreateHandshakeInitHybrid("bob")
+	if err != nil {
+		t.Fatalf("CreateHandshakeInitHybrid: %v", err)
+	}
+
+	peerID, ss, resp, err := bob.ProcessHandshakeInitHybrid(init, "203.0.113.9")
+	if !errors.Is(err, ErrCookieRequired) {
+		t.Fatalf("err = %v, want ErrCookieRequired", err)
+	}
+	if peerID != "alice" {
+		t.Errorf("peerID = %s, want alice", peerID)
+	}
+	if ss != nil {
+		t.Error("no shared secret should be returned before the cookie is echoed")
+	}
+	if bob.HasSession("alice") {
+		t.Error("no session should be recorded until the cookie handshake completes")
+	}
+
+	_, cookie, err := alice.ProcessHandshakeResponseHybrid(resp)
+	if !errors.Is(err, ErrCookieRequired) {
+		t.Fatalf("err = %v, want ErrCookieRequired", err)
+	}
+
+	retry, err := alice.CreateHandshakeInitHybridWithCookie("bob", cookie)
+	if err != nil {
+		t.Fatalf("CreateHandshakeInitHybridWithCookie: %v", err)
+	}
+
+	peerID, _, resp2, err := bob.ProcessHandshakeInitHybrid(retry, "203.0.113.9")
+	if err != nil {
+		t.Fatalf("ProcessHandshakeInitHybrid with echoed cookie: %v", err)
+	}
+	if peerID != "alice" {
+		t.Errorf("peerID = %s, want alice", peerID)
+	}
+	if !bob.HasSession("alice") {
+		t.Error("session should be established once the cookie is echoed back")
+	}
+
+	if _, _, err := alice.ProcessHandshakeResponseHybrid(resp2); err != nil {
+		t.Fatalf("ProcessHandshakeResponseHybrid with retried init's response: %v", err)
+	}
+	if !alice.HasSession("bob") {
+		t.Error("alice should have an established session after completing the retried handshake")
+	}
+}
+
+func TestHybridHandshake_RejectsUnpinnedPeer(t *testing.T) {
+	alice, err := NewTunnelManagerWithSuite("alice", SuiteHybridX25519MLKEM768)
+	if err != nil {
+		t.Fatalf("NewTunnelManagerWithSuite(alice): %v", err)
+	}
+	bob, err := NewTunnelManagerWithSuite("bob", SuiteHybridX25519MLKEM768)
+	if err != nil {
+		t.Fatalf("NewTunnelManagerWithSuite(bob): %v", err)
+	}
+	// Note: no TrustPeer call on bob for alice's identity key.
+
+	init, err := alice.CreateHandshakeInitHybrid("bob")
+	if err != nil {
+		t.Fatalf("CreateHandshakeInitHybrid: %v", err)
+	}
+
+	if _, _, _, err := bob.ProcessHandshakeInitHybrid(init, "203.0.113.1"); err == nil {
+		t.Error("ProcessHandshakeInitHybrid should reject a peer with no TrustPeer pin")
+	}
+}
+
+func TestHybridHandshake_RejectsMismatchedPin(t *testing.T) {
+	alice, err := NewTunnelManagerWithSuite("alice", SuiteHybridX25519MLKEM768)
+	if err != nil {
+		t.Fatalf("NewTunnelManagerWithSuite(alice): %v", err)
+	}
+	bob, err := NewTunnelManagerWithSuite("bob", SuiteHybridX25519MLKEM768)
+	if err != nil {
+		t.Fatalf("NewTunnelManagerWithSuite(bob): %v", err)
+	}
+	mallory, err := NewTunnelManagerWithSuite("mallory", SuiteHybridX25519MLKEM768)
+	if err != nil {
+		t.Fatalf("NewTunnelManagerWithSuite(mallory): %v", err)
+	}
+	// Bob pins a different key than the one alice will actually sign with.
+	if err := bob.TrustPeer("alice", mallory.GetIdentityPublicKey()); err != nil {
+		t.Fatalf("bob.TrustPeer(alice): %v", err)
+	}
+
+	init, err := alice.CreateHandshakeInitHybrid("bob")
+	if err != nil {
+		t.Fatalf("CreateHandshakeInitHybrid: %v", err)
+	}
+
+	if _, _, _, err := bob.ProcessHandshakeInitHybrid(init, "203.0.113.1"); err == nil {
+		t.Error("ProcessHandshakeInitHybrid should reject a signature not matching the pinned key")
+	}
+}
+
+func TestHybridHandshake_RejectsTamperedSignature(t *testing.T) {
+	alice, bob := hybridPeers(t)
+
+	init, err := alice.CreateHandshakeInitHybrid("bob")
+	if err != nil {
+		t.Fatalf("CreateHandshakeInitHybrid: %v", err)
+	}
+	init[len(init)-1] ^= 0xFF
+
+	if _, _, _, err := bob.ProcessHandshakeInitHybrid(init, "203.0.113.1"); err == nil {
+		t.Error("ProcessHandshakeInitHybrid should reject a tampered signature")
+	}
+}
+
+func TestHybridHandshake_LegacySuiteUnaffected(t *testing.T) {
+	// A node built with the default SuiteMLKEM768 never tags its messages
+	// and can't speak the hybrid functions at all.
+	alice, err := NewTunnelManager("alice")
+	if err != nil {
+		t.Fatalf("NewTunnelManager: %v", err)
+	}
+	if _, err := alice.CreateHandshakeInitHybrid("bob"); err == nil {
+		t.Error("CreateHandshakeInitHybrid should fail without SuiteHybridX25519MLKEM768")
+	}
+
+	bob, _ := NewTunnelManager("bob")
+	init := alice.CreateHandshakeInit()
+	if _, _, _, err := bob.ProcessHandshakeInit(init, "10.0.0.1"); err != nil {
+		t.Fatalf("legacy ProcessHandshakeInit should be unaffected: %v", err)
+	}
+}
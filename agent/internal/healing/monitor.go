@@ -4,17 +4,55 @@
 package healing
 
 import (
+	"context"
 	"log/slog"
+	"math"
+	"math/rand"
 	"sync"
 	"time"
 )
 
-// Thresholds for anomaly detection.
+// Thresholds for anomaly detection. LatencyThresholdMs and
+// PacketLossThreshold are only the fallback analyze uses before a metric's
+// per-State EWMA baseline has warmed up (see ewmaWarmupSamples); once warm,
+// analyze flags an anomaly at k standard deviations from the learned mean
+// instead (see Monitor.AnomalyK).
 const (
-	LatencyThresholdMs   = 500.0
-	PacketLossThreshold  = 0.05 // 5%
-	PeerLossThreshold    = 0.5  // 50% of peers lost
-	CheckInterval        = 10 * time.Second
+	LatencyThresholdMs  = 500.0
+	PacketLossThreshold = 0.05 // 5%
+	PeerLossThreshold   = 0.5  // 50% of peers lost
+	CheckInterval       = 10 * time.Second
+
+	// OverlayScoreCollapseThreshold triggers a reroute when the mesh
+	// overlay's average peer score (see overlay.Overlay.Stats) drops below
+	// this, catching degradation earlier than waiting for peers_healthy to
+	// fall — a low-scoring mesh can still report every peer "healthy" by
+	// the stale-LastSeen check alone.
+	OverlayScoreCollapseThreshold = -5.0
+
+	// EWMAAlpha is the weight a new sample gets in the per-metric EWMA
+	// mean/variance update (see ewmaStats), matching the α≈0.1 from
+	// standard EWMA control-chart practice: slow enough that a few
+	// churn-era outliers don't swing the baseline, fast enough to track
+	// a genuine shift within a few dozen observations.
+	EWMAAlpha = 0.1
+
+	// ewmaWarmupSamples is how many observations a (State, metric) baseline
+	// needs before analyze trusts it over the constant fallback thresholds
+	// — too few samples and the variance estimate is noise, not signal.
+	ewmaWarmupSamples = 5
+
+	// AnomalyKDefault is how many standard deviations from a warmed-up
+	// EWMA baseline analyze flags as anomalous, absent a Monitor.AnomalyK
+	// override.
+	AnomalyKDefault = 3.0
+
+	// EpsilonDefault is 0: analyze purely exploits the historically
+	// best-known action for a diagnosis and never explores, so a fresh
+	// Monitor with no recorded rewards (see RecordReward) behaves exactly
+	// like the fixed rule engine it replaces. Set Monitor.Epsilon > 0 to
+	// let analyze occasionally try an alternative action instead.
+	EpsilonDefault = 0.0
 )
 
 // Action represents a healing action.
@@ -26,6 +64,8 @@ const (
 	ActionReconnect
 	ActionRestartDiscovery
 	ActionAlertControlPlane
+	ActionRekey
+	ActionDowngradeShaper
 )
 
 func (a Action) String() string {
@@ -38,6 +78,10 @@ func (a Action) String() string {
 		return "restart_discovery"
 	case ActionAlertControlPlane:
 		return "alert_control_plane"
+	case ActionRekey:
+		return "rekey"
+	case ActionDowngradeShaper:
+		return "downgrade_shaper"
 	default:
 		return "none"
 	}
@@ -45,12 +89,28 @@ func (a Action) String() string {
 
 // Observation is a single monitoring data point.
 type Observation struct {
-	Timestamp    time.Time
-	PeerCount    int
-	HealthyPeers int
-	AvgLatencyMs float64
-	PacketLoss   float64
-	State        string
+	Timestamp       time.Time
+	PeerCount       int
+	HealthyPeers    int
+	AvgLatencyMs    float64
+	PacketLoss      float64
+	State           string
+	OverlayScoreAvg float64
+	HasOverlayScore bool
+
+	// SessionsNeedingRekey is how many PQC sessions StatsProvider reports as
+	// due for rotation (see pqc.TunnelManager.NeedsRekey), via an optional
+	// "sessions_needing_rekey" stats key. A StatsProvider that doesn't track
+	// PQC sessions simply omits the key, leaving this at its zero value,
+	// which analyze treats the same as "nothing due."
+	SessionsNeedingRekey int
+
+	// ShaperCPRActive reports whether any peer has ShaperCPR traffic
+	// shaping configured (see pqc.TunnelManager.SetShaper), via an optional
+	// "pqc_shaper_cpr_active" stats key. Lets analyze prefer downgrading
+	// the CPR rate over rerouting when packet loss is high, since a
+	// saturated constant-rate shaper can itself be the cause.
+	ShaperCPRActive bool
 }
 
 // HealingEvent records an action taken by the MAPE-K loop.
@@ -60,6 +120,77 @@ type HealingEvent struct {
 	Diagnosis   string
 	Action      Action
 	Success     bool
+
+	// Reward is filled in later, if at all, by RecordReward — e.g. once a
+	// control plane or operator judges whether the action actually helped.
+	// Zero until then, which is indistinguishable from an explicitly
+	// recorded zero reward; callers that need to tell the two apart should
+	// track that themselves.
+	Reward float64
+}
+
+// ewmaStats is a per-metric exponentially weighted mean/variance, the unit
+// of the knowledge store's learned baselines (see Monitor.baselines).
+type ewmaStats struct {
+	mean     float64
+	variance float64
+	count    int
+}
+
+// updateEWMA folds sample x into s using weight alpha (see EWMAAlpha). The
+// first sample seeds the mean directly rather than weighting it against a
+// meaningless zero-initialized mean/variance.
+func updateEWMA(s *ewmaStats, x, alpha float64) {
+	if s.count == 0 {
+		s.mean = x
+	} else {
+		delta := x - s.mean
+		s.mean += alpha * delta
+		s.variance = (1 - alpha) * (s.variance + alpha*delta*delta)
+	}
+	s.count++
+}
+
+// actionKey identifies a (diagnosis, action) pair for the reward-tracking
+// knowledge store (see Monitor.actionStats).
+type actionKey struct {
+	diagnosis string
+	action    Action
+}
+
+// actionOutcome accumulates reward feedback for one actionKey.
+type actionOutcome struct {
+	attempts int
+	reward   float64
+}
+
+// EWMABaseline is one (State, metric) learned baseline, as captured by
+// Monitor.Snapshot.
+type EWMABaseline struct {
+	State    string
+	Metric   string
+	Mean     float64
+	Variance float64
+	Count    int
+}
+
+// ActionStat is the accumulated reward history for one (diagnosis, action)
+// pair, as captured by Monitor.Snapshot.
+type ActionStat struct {
+	Diagnosis string
+	Action    Action
+	Attempts  int
+	Reward    float64
+}
+
+// KnowledgeSnapshot is a point-in-time copy of everything the Knowledge
+// stage has learned — EWMA baselines and action reward history — so it can
+// be persisted and restored across restarts via Monitor.Snapshot and
+// Monitor.RestoreSnapshot. BaselinePeers is included for the same reason.
+type KnowledgeSnapshot struct {
+	BaselinePeers int
+	EWMABaselines []EWMABaseline
+	ActionStats   []ActionStat
 }
 
 // StatsProvider supplies current node statistics.
@@ -83,39 +214,89 @@ type Monitor struct {
 	events       []HealingEvent
 	maxHistory   int
 
-	running bool
-	stopCh  chan struct{}
-	logger  *slog.Logger
+	// ctx/cancel govern loop: cancelling it (via Stop or the parent
+	// context passed to Start) is the sole signal loop's select waits on,
+	// so a cancelled context terminates a pending cycle's ticker wait
+	// promptly. wg lets Wait block until loop has actually returned.
+	// stopOnce makes Stop safe to call more than once or concurrently.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+	logger   *slog.Logger
 
 	// Baseline (learned from first N observations)
 	baselinePeers int
+
+	// baselines holds the Knowledge stage's learned per-State, per-metric
+	// EWMA baselines (e.g. baselines["running"]["latency_ms"]), populated by
+	// observeMetric. Nil until the first observation, like the rest of
+	// Monitor's learned state.
+	baselines map[string]map[string]*ewmaStats
+
+	// AnomalyK overrides AnomalyKDefault when positive; see anomalyK.
+	AnomalyK float64
+
+	// actionStats accumulates RecordReward feedback per (diagnosis, action)
+	// pair, driving epsilonGreedyAction's choice between a rule's default
+	// action and its listed alternatives.
+	actionStats map[actionKey]*actionOutcome
+
+	// Epsilon overrides EpsilonDefault when positive; see epsilonGreedyAction.
+	Epsilon float64
+
+	// OnObservation, if set, is called at the end of every monitoring cycle
+	// with the current health ratio (HealthyPeers/PeerCount, 0 if no peers),
+	// the same value Node.GetStats reports as health_score. Lets callers
+	// (see telemetry.DeltaTracker.OnHealthChange) fold health changes into
+	// an event stream without polling GetLatestObservation themselves.
+	OnObservation func(healthRatio float64)
 }
 
-// NewMonitor creates a new healing monitor.
+// NewMonitor creates a new healing monitor. Its loop context defaults to
+// context.Background; pass a caller-derived context to Start to tie the
+// monitor's lifetime to a wider shutdown signal.
 func NewMonitor(sp StatsProvider, exec ActionExecutor) *Monitor {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Monitor{
 		statsProvider: sp,
 		executor:      exec,
 		observations:  make([]Observation, 0, 100),
 		events:        make([]HealingEvent, 0, 50),
 		maxHistory:    100,
-		stopCh:        make(chan struct{}),
+		ctx:           ctx,
+		cancel:        cancel,
 		logger:        slog.Default().With("component", "healing"),
 	}
 }
 
-// Start begins the MAPE-K loop.
-func (m *Monitor) Start() {
-	m.running = true
-	go m.loop()
+// Start begins the MAPE-K loop. ctx governs it: cancelling it (or calling
+// Stop, which cancels an internally derived child of it) terminates a
+// pending cycle promptly and returns from loop. Call Wait afterward to
+// block until loop has actually exited.
+func (m *Monitor) Start(ctx context.Context) {
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.loop()
+	}()
 	m.logger.Info("MAPE-K healing loop started", "interval", CheckInterval)
 }
 
-// Stop halts the MAPE-K loop.
+// Stop halts the MAPE-K loop. Safe to call more than once or concurrently
+// with itself; only the first call has any effect.
 func (m *Monitor) Stop() {
-	m.running = false
-	close(m.stopCh)
-	m.logger.Info("MAPE-K healing loop stopped")
+	m.stopOnce.Do(func() {
+		m.cancel()
+		m.logger.Info("MAPE-K healing loop stopped")
+	})
+}
+
+// Wait blocks until loop has actually returned following Stop or context
+// cancellation.
+func (m *Monitor) Wait() {
+	m.wg.Wait()
 }
 
 // GetEvents returns the history of healing events.
@@ -146,7 +327,7 @@ func (m *Monitor) loop() {
 		select {
 		case <-ticker.C:
 			m.cycle()
-		case <-m.stopCh:
+		case <-m.ctx.Done():
 			return
 		}
 	}
@@ -156,6 +337,14 @@ func (m *Monitor) cycle() {
 	// M — Monitor
 	obs := m.monitor()
 
+	if m.OnObservation != nil {
+		healthRatio := 0.0
+		if obs.PeerCount > 0 {
+			healthRatio = float64(obs.HealthyPeers) / float64(obs.PeerCount)
+		}
+		m.OnObservation(healthRatio)
+	}
+
 	// A — Analyze
 	diagnosis, action := m.analyze(obs)
 
@@ -207,12 +396,188 @@ func (m *Monitor) monitor() Observation {
 	peersTotal, _ := stats["peers_total"].(int)
 	peersHealthy, _ := stats["peers_healthy"].(int)
 	state, _ := stats["state"].(string)
+	overlayScore, hasOverlayScore := stats["overlay_mesh_score_avg"].(float64)
+	sessionsNeedingRekey, _ := stats["sessions_needing_rekey"].(int)
+	shaperCPRActive, _ := stats["pqc_shaper_cpr_active"].(bool)
 
 	return Observation{
-		Timestamp:    time.Now(),
-		PeerCount:    peersTotal,
-		HealthyPeers: peersHealthy,
-		State:        state,
+		Timestamp:            time.Now(),
+		PeerCount:            peersTotal,
+		HealthyPeers:         peersHealthy,
+		State:                state,
+		OverlayScoreAvg:      overlayScore,
+		HasOverlayScore:      hasOverlayScore,
+		SessionsNeedingRekey: sessionsNeedingRekey,
+		ShaperCPRActive:      shaperCPRActive,
+	}
+}
+
+// anomalyK returns Monitor.AnomalyK if set, else AnomalyKDefault.
+func (m *Monitor) anomalyK() float64 {
+	if m.AnomalyK > 0 {
+		return m.AnomalyK
+	}
+	return AnomalyKDefault
+}
+
+// baselineStats reports the (state, metric) baseline's current mean,
+// standard deviation, and whether it has seen enough samples
+// (ewmaWarmupSamples) for analyze to trust it over a constant threshold,
+// without folding in a new sample. analyze uses this — rather than
+// observeMetric's own return values — to judge whether the incoming
+// observation is anomalous against the baseline as it stood before this
+// observation, not one already pulled toward it.
+func (m *Monitor) baselineStats(state, metric string) (mean, stddev float64, warm bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.baselines[state][metric]
+	if !ok {
+		return 0, 0, false
+	}
+	return s.mean, math.Sqrt(s.variance), s.count >= ewmaWarmupSamples
+}
+
+// observeMetric folds value into the EWMA baseline for (state, metric),
+// creating it on first use, and reports the baseline's current mean,
+// standard deviation, and whether it has seen enough samples
+// (ewmaWarmupSamples) for analyze to trust it over a constant threshold.
+func (m *Monitor) observeMetric(state, metric string, value float64) (mean, stddev float64, warm bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.baselines == nil {
+		m.baselines = make(map[string]map[string]*ewmaStats)
+	}
+	perMetric, ok := m.baselines[state]
+	if !ok {
+		perMetric = make(map[string]*ewmaStats)
+		m.baselines[state] = perMetric
+	}
+	s, ok := perMetric[metric]
+	if !ok {
+		s = &ewmaStats{}
+		perMetric[metric] = s
+	}
+	updateEWMA(s, value, EWMAAlpha)
+
+	return s.mean, math.Sqrt(s.variance), s.count >= ewmaWarmupSamples
+}
+
+// avgReward returns the mean recorded reward for (diagnosis, action) and
+// whether RecordReward has ever been called for that pair.
+func (m *Monitor) avgReward(diagnosis string, action Action) (float64, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	o, ok := m.actionStats[actionKey{diagnosis: diagnosis, action: action}]
+	if !ok || o.attempts == 0 {
+		return 0, false
+	}
+	return o.reward / float64(o.attempts), true
+}
+
+// RecordReward feeds back the outcome of a past (diagnosis, action) pair
+// so epsilonGreedyAction can learn to prefer whichever action performs
+// best for that diagnosis, and backfills the most recent matching
+// HealingEvent's Reward field.
+func (m *Monitor) RecordReward(diagnosis string, action Action, reward float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.actionStats == nil {
+		m.actionStats = make(map[actionKey]*actionOutcome)
+	}
+	key := actionKey{diagnosis: diagnosis, action: action}
+	o, ok := m.actionStats[key]
+	if !ok {
+		o = &actionOutcome{}
+		m.actionStats[key] = o
+	}
+	o.attempts++
+	o.reward += reward
+
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if m.events[i].Diagnosis == diagnosis && m.events[i].Action == action {
+			m.events[i].Reward = reward
+			break
+		}
+	}
+}
+
+// epsilonGreedyAction picks defaultAction unless RecordReward history
+// shows one of alternatives has performed better for diagnosis, or (with
+// probability Monitor.Epsilon) exploration picks one at random. With the
+// zero-value Epsilon and no reward history — true for a fresh Monitor —
+// this always returns defaultAction, so it's a no-op over the original
+// fixed rule engine until a caller opts into learning.
+func (m *Monitor) epsilonGreedyAction(diagnosis string, defaultAction Action, alternatives ...Action) Action {
+	if m.Epsilon > 0 && rand.Float64() < m.Epsilon {
+		if len(alternatives) > 0 {
+			return alternatives[rand.Intn(len(alternatives))]
+		}
+		return defaultAction
+	}
+
+	best := defaultAction
+	bestReward, bestSeen := m.avgReward(diagnosis, defaultAction)
+	for _, alt := range alternatives {
+		reward, seen := m.avgReward(diagnosis, alt)
+		if seen && (!bestSeen || reward > bestReward) {
+			best, bestReward, bestSeen = alt, reward, true
+		}
+	}
+	return best
+}
+
+// Snapshot captures everything the Knowledge stage has learned so far —
+// EWMA baselines and action reward history — for persistence across
+// restarts. Pair with RestoreSnapshot.
+func (m *Monitor) Snapshot() KnowledgeSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snap := KnowledgeSnapshot{BaselinePeers: m.baselinePeers}
+	for state, metrics := range m.baselines {
+		for metric, s := range metrics {
+			snap.EWMABaselines = append(snap.EWMABaselines, EWMABaseline{
+				State: state, Metric: metric,
+				Mean: s.mean, Variance: s.variance, Count: s.count,
+			})
+		}
+	}
+	for key, o := range m.actionStats {
+		snap.ActionStats = append(snap.ActionStats, ActionStat{
+			Diagnosis: key.diagnosis, Action: key.action,
+			Attempts: o.attempts, Reward: o.reward,
+		})
+	}
+	return snap
+}
+
+// RestoreSnapshot replaces the Knowledge stage's learned state with snap,
+// as previously captured by Snapshot.
+func (m *Monitor) RestoreSnapshot(snap KnowledgeSnapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.baselinePeers = snap.BaselinePeers
+
+	m.baselines = make(map[string]map[string]*ewmaStats)
+	for _, b := range snap.EWMABaselines {
+		perMetric, ok := m.baselines[b.State]
+		if !ok {
+			perMetric = make(map[string]*ewmaStats)
+			m.baselines[b.State] = perMetric
+		}
+		perMetric[b.Metric] = &ewmaStats{mean: b.Mean, variance: b.Variance, count: b.Count}
+	}
+
+	m.actionStats = make(map[actionKey]*actionOutcome)
+	for _, a := range snap.ActionStats {
+		m.actionStats[actionKey{diagnosis: a.Diagnosis, action: a.Action}] = &actionOutcome{
+			attempts: a.Attempts, reward: a.Reward,
+		}
 	}
 }
 
@@ -230,7 +595,8 @@ func (m *Monitor) analyze(obs Observation) (string, Action) {
 	if baseline > 0 {
 		lossRatio := 1.0 - float64(obs.PeerCount)/float64(baseline)
 		if lossRatio > PeerLossThreshold {
-			return "significant peer loss", ActionReconnect
+			diagnosis := "significant peer loss"
+			return diagnosis, m.epsilonGreedyAction(diagnosis, ActionReconnect, ActionAlertControlPlane)
 		}
 	}
 
@@ -238,18 +604,72 @@ func (m *Monitor) analyze(obs Observation) (string, Action) {
 	if obs.PeerCount > 0 {
 		unhealthyRatio := 1.0 - float64(obs.HealthyPeers)/float64(obs.PeerCount)
 		if unhealthyRatio > 0.5 {
-			return "majority of peers unhealthy", ActionReroute
+			diagnosis := "majority of peers unhealthy"
+			return diagnosis, m.epsilonGreedyAction(diagnosis, ActionReroute, ActionAlertControlPlane)
 		}
 	}
 
-	// Rule 4: High latency
-	if obs.AvgLatencyMs > LatencyThresholdMs {
-		return "high latency detected", ActionReroute
+	// Rule 3b: Overlay mesh score collapse — reroute even while peers still
+	// look healthy by LastSeen alone.
+	if obs.HasOverlayScore && obs.OverlayScoreAvg < OverlayScoreCollapseThreshold {
+		diagnosis := "overlay mesh score collapse"
+		return diagnosis, m.epsilonGreedyAction(diagnosis, ActionReroute, ActionAlertControlPlane)
+	}
+
+	// Rule 4: High latency. Once the per-State baseline has warmed up, an
+	// anomaly is k standard deviations above the learned mean rather than
+	// the fixed LatencyThresholdMs, so a mesh whose normal latency
+	// legitimately runs high or low doesn't mis-trigger against a
+	// one-size-fits-all constant. Judged against the baseline as it stood
+	// before this observation (baselineStats, not observeMetric's return),
+	// so a single anomalous sample can't drag the mean/stddev toward
+	// itself and mask the very spike it's supposed to catch. A baseline
+	// that has gone perfectly flat (stddev == 0, e.g. a freshly warmed
+	// constant signal) flags any amount above the mean rather than
+	// requiring a multiple of a zero spread that could never be exceeded.
+	latMean, latStddev, latWarm := m.baselineStats(obs.State, "latency_ms")
+	latAnomaly := obs.AvgLatencyMs > LatencyThresholdMs
+	if latWarm {
+		if latStddev > 0 {
+			latAnomaly = obs.AvgLatencyMs-latMean > m.anomalyK()*latStddev
+		} else {
+			latAnomaly = obs.AvgLatencyMs > latMean
+		}
+	}
+	m.observeMetric(obs.State, "latency_ms", obs.AvgLatencyMs)
+	if latAnomaly {
+		diagnosis := "high latency detected"
+		return diagnosis, m.epsilonGreedyAction(diagnosis, ActionReroute, ActionAlertControlPlane)
+	}
+
+	// Rule 5: High packet loss, same learned-baseline treatment as Rule 4.
+	// A saturated ShaperCPR rate can itself produce packet loss that looks
+	// identical to a routing problem, so try downgrading it first rather
+	// than immediately rerouting traffic that isn't actually the cause.
+	lossMean, lossStddev, lossWarm := m.baselineStats(obs.State, "packet_loss")
+	lossAnomaly := obs.PacketLoss > PacketLossThreshold
+	if lossWarm {
+		if lossStddev > 0 {
+			lossAnomaly = obs.PacketLoss-lossMean > m.anomalyK()*lossStddev
+		} else {
+			lossAnomaly = obs.PacketLoss > lossMean
+		}
+	}
+	m.observeMetric(obs.State, "packet_loss", obs.PacketLoss)
+	if lossAnomaly {
+		if obs.ShaperCPRActive {
+			return "high packet loss with CPR shaping active", ActionDowngradeShaper
+		}
+		diagnosis := "high packet loss"
+		return diagnosis, m.epsilonGreedyAction(diagnosis, ActionReroute, ActionAlertControlPlane)
 	}
 
-	// Rule 5: High packet loss
-	if obs.PacketLoss > PacketLossThreshold {
-		return "high packet loss", ActionReroute
+	// Rule 6: PQC sessions due for rekey. Lowest priority: none of the
+	// above are mutually exclusive with also needing a rekey, but they're
+	// all more urgent than rotating a key that's still safe to use for a
+	// little longer.
+	if obs.SessionsNeedingRekey > 0 {
+		return "PQC sessions due for rekey", ActionRekey
 	}
 
 	return "", ActionNone
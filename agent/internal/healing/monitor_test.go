@@ -1,22 +1,28 @@
 package healing
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 )
 
 // mockStats implements StatsProvider for testing.
 type mockStats struct {
-	peersTotal   int
-	peersHealthy int
-	state        string
+	peersTotal           int
+	peersHealthy         int
+	state                string
+	sessionsNeedingRekey int
+	shaperCPRActive      bool
 }
 
 func (m *mockStats) GetStats() map[string]any {
 	return map[string]any{
-		"peers_total":   m.peersTotal,
-		"peers_healthy": m.peersHealthy,
-		"state":         m.state,
+		"peers_total":            m.peersTotal,
+		"peers_healthy":          m.peersHealthy,
+		"state":                  m.state,
+		"sessions_needing_rekey": m.sessionsNeedingRekey,
+		"pqc_shaper_cpr_active":  m.shaperCPRActive,
 	}
 }
 
@@ -120,6 +126,78 @@ func TestAnalyze_HighPacketLoss(t *testing.T) {
 	}
 }
 
+func TestAnalyze_OverlayScoreCollapse(t *testing.T) {
+	stats := &mockStats{peersTotal: 5, peersHealthy: 5}
+	mon := NewMonitor(stats, nil)
+
+	obs := Observation{PeerCount: 5, HealthyPeers: 5, HasOverlayScore: true, OverlayScoreAvg: -20}
+	diagnosis, action := mon.analyze(obs)
+
+	if action != ActionReroute {
+		t.Errorf("action = %v, want ActionReroute for overlay score collapse", action)
+	}
+	if diagnosis != "overlay mesh score collapse" {
+		t.Errorf("diagnosis = %s", diagnosis)
+	}
+}
+
+func TestAnalyze_HighPacketLossWithCPRShapingDowngradesInsteadOfRerouting(t *testing.T) {
+	stats := &mockStats{peersTotal: 5, peersHealthy: 5}
+	mon := NewMonitor(stats, nil)
+
+	obs := Observation{PeerCount: 5, HealthyPeers: 5, PacketLoss: 0.1, ShaperCPRActive: true}
+	diagnosis, action := mon.analyze(obs)
+
+	if action != ActionDowngradeShaper {
+		t.Errorf("action = %v, want ActionDowngradeShaper", action)
+	}
+	if diagnosis != "high packet loss with CPR shaping active" {
+		t.Errorf("diagnosis = %s", diagnosis)
+	}
+}
+
+func TestMonitor_PopulatesShaperCPRActiveFromStats(t *testing.T) {
+	stats := &mockStats{peersTotal: 5, peersHealthy: 5, shaperCPRActive: true}
+	mon := NewMonitor(stats, nil)
+
+	obs := mon.monitor()
+	if !obs.ShaperCPRActive {
+		t.Error("ShaperCPRActive = false, want true")
+	}
+}
+
+func TestAnalyze_SessionsNeedingRekey(t *testing.T) {
+	stats := &mockStats{peersTotal: 5, peersHealthy: 5, sessionsNeedingRekey: 2}
+	mon := NewMonitor(stats, nil)
+
+	obs := mon.monitor()
+	if obs.SessionsNeedingRekey != 2 {
+		t.Fatalf("SessionsNeedingRekey = %d, want 2", obs.SessionsNeedingRekey)
+	}
+
+	diagnosis, action := mon.analyze(obs)
+	if action != ActionRekey {
+		t.Errorf("action = %v, want ActionRekey", action)
+	}
+	if diagnosis != "PQC sessions due for rekey" {
+		t.Errorf("diagnosis = %s", diagnosis)
+	}
+}
+
+func TestAnalyze_NoOverlayScoreIgnoredWhenAbsent(t *testing.T) {
+	stats := &mockStats{peersTotal: 5, peersHealthy: 5}
+	mon := NewMonitor(stats, nil)
+
+	// HasOverlayScore false (the zero value) even though OverlayScoreAvg
+	// would otherwise read as a collapsed score.
+	obs := Observation{PeerCount: 5, HealthyPeers: 5, OverlayScoreAvg: -20}
+	_, action := mon.analyze(obs)
+
+	if action != ActionNone {
+		t.Errorf("action = %v, want ActionNone when overlay score metric is absent", action)
+	}
+}
+
 func TestCycle_ExecutesAction(t *testing.T) {
 	stats := &mockStats{peersTotal: 0}
 	exec := &mockExecutor{}
@@ -196,6 +274,8 @@ func TestActionString(t *testing.T) {
 		{ActionReconnect, "reconnect"},
 		{ActionRestartDiscovery, "restart_discovery"},
 		{ActionAlertControlPlane, "alert_control_plane"},
+		{ActionRekey, "rekey"},
+		{ActionDowngradeShaper, "downgrade_shaper"},
 	}
 	for _, tt := range tests {
 		if got := tt.action.String(); got != tt.want {
@@ -204,6 +284,27 @@ func TestActionString(t *testing.T) {
 	}
 }
 
+func TestCycle_CallsOnObservationWithHealthRatio(t *testing.T) {
+	stats := &mockStats{peersTotal: 4, peersHealthy: 3}
+	mon := NewMonitor(stats, nil)
+
+	var gotRatio float64
+	var called bool
+	mon.OnObservation = func(healthRatio float64) {
+		called = true
+		gotRatio = healthRatio
+	}
+
+	mon.cycle()
+
+	if !called {
+		t.Fatal("OnObservation was not called")
+	}
+	if gotRatio != 0.75 {
+		t.Errorf("healthRatio = %v, want 0.75", gotRatio)
+	}
+}
+
 func TestBaselineAutoLearn(t *testing.T) {
 	stats := &mockStats{peersTotal: 8, peersHealthy: 8}
 	mon := NewMonitor(stats, nil)
@@ -218,3 +319,157 @@ func TestBaselineAutoLearn(t *testing.T) {
 		t.Errorf("baseline = %d, want 8", mon.baselinePeers)
 	}
 }
+
+func TestStart_ContextCancelTerminatesLoopPromptly(t *testing.T) {
+	stats := &mockStats{peersTotal: 4, peersHealthy: 4}
+	mon := NewMonitor(stats, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mon.Start(ctx)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		mon.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("loop did not exit within 1s of context cancellation")
+	}
+}
+
+func TestObserveMetric_WarmsUpAfterEnoughSamples(t *testing.T) {
+	stats := &mockStats{peersTotal: 5, peersHealthy: 5}
+	mon := NewMonitor(stats, nil)
+
+	var warm bool
+	for i := 0; i < ewmaWarmupSamples; i++ {
+		_, _, warm = mon.observeMetric("running", "latency_ms", 100)
+		if i < ewmaWarmupSamples-1 && warm {
+			t.Fatalf("warm became true after only %d samples", i+1)
+		}
+	}
+	if !warm {
+		t.Errorf("baseline not warm after %d samples", ewmaWarmupSamples)
+	}
+}
+
+func TestAnalyze_HighLatencyUsesLearnedBaselineOnceWarm(t *testing.T) {
+	stats := &mockStats{peersTotal: 5, peersHealthy: 5, state: "running"}
+	mon := NewMonitor(stats, nil)
+
+	// Warm the "running" baseline on a steady, low latency well under
+	// LatencyThresholdMs, then present a value that would pass the fixed
+	// threshold but is a clear anomaly against the learned baseline.
+	for i := 0; i < ewmaWarmupSamples; i++ {
+		mon.observeMetric("running", "latency_ms", 20)
+	}
+
+	obs := Observation{PeerCount: 5, HealthyPeers: 5, State: "running", AvgLatencyMs: 50}
+	_, action := mon.analyze(obs)
+
+	if action != ActionReroute {
+		t.Errorf("action = %v, want ActionReroute once baseline is warm", action)
+	}
+}
+
+func TestAnalyze_LowLatencyIgnoredBeforeWarmup(t *testing.T) {
+	stats := &mockStats{peersTotal: 5, peersHealthy: 5, state: "running"}
+	mon := NewMonitor(stats, nil)
+
+	// A single call against a fresh baseline isn't warm yet, so the fixed
+	// LatencyThresholdMs fallback applies and 50ms shouldn't trigger.
+	obs := Observation{PeerCount: 5, HealthyPeers: 5, State: "running", AvgLatencyMs: 50}
+	_, action := mon.analyze(obs)
+
+	if action != ActionNone {
+		t.Errorf("action = %v, want ActionNone before baseline warmup", action)
+	}
+}
+
+func TestRecordReward_PrefersHigherRewardAlternative(t *testing.T) {
+	stats := &mockStats{peersTotal: 10, peersHealthy: 10}
+	mon := NewMonitor(stats, nil)
+	mon.baselinePeers = 10
+
+	mon.RecordReward("significant peer loss", ActionReconnect, -1.0)
+	mon.RecordReward("significant peer loss", ActionAlertControlPlane, 1.0)
+
+	stats.peersTotal = 4
+	stats.peersHealthy = 4
+	obs := mon.monitor()
+	diagnosis, action := mon.analyze(obs)
+
+	if diagnosis != "significant peer loss" {
+		t.Fatalf("diagnosis = %s", diagnosis)
+	}
+	if action != ActionAlertControlPlane {
+		t.Errorf("action = %v, want ActionAlertControlPlane (higher recorded reward)", action)
+	}
+}
+
+func TestRecordReward_BackfillsMostRecentMatchingEvent(t *testing.T) {
+	stats := &mockStats{peersTotal: 0}
+	exec := &mockExecutor{}
+	mon := NewMonitor(stats, exec)
+
+	mon.cycle()
+	mon.RecordReward("no peers detected", ActionRestartDiscovery, 0.5)
+
+	events := mon.GetEvents()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	if events[0].Reward != 0.5 {
+		t.Errorf("Reward = %v, want 0.5", events[0].Reward)
+	}
+}
+
+func TestSnapshot_RestoreSnapshotRoundTrip(t *testing.T) {
+	stats := &mockStats{peersTotal: 5, peersHealthy: 5, state: "running"}
+	mon := NewMonitor(stats, nil)
+	mon.baselinePeers = 7
+	mon.observeMetric("running", "latency_ms", 42)
+	mon.RecordReward("majority of peers unhealthy", ActionReroute, 2.0)
+
+	snap := mon.Snapshot()
+
+	restored := NewMonitor(stats, nil)
+	restored.RestoreSnapshot(snap)
+
+	if restored.baselinePeers != 7 {
+		t.Errorf("baselinePeers = %d, want 7", restored.baselinePeers)
+	}
+	mean, _, _ := restored.observeMetric("running", "latency_ms", 42)
+	if mean <= 0 {
+		t.Errorf("restored latency_ms baseline mean = %v, want > 0", mean)
+	}
+	reward, seen := restored.avgReward("majority of peers unhealthy", ActionReroute)
+	if !seen || reward != 2.0 {
+		t.Errorf("avgReward = (%v, %v), want (2.0, true)", reward, seen)
+	}
+}
+
+func TestStop_IdempotentAndStopsLoop(t *testing.T) {
+	stats := &mockStats{peersTotal: 4, peersHealthy: 4}
+	mon := NewMonitor(stats, nil)
+
+	mon.Start(context.Background())
+	mon.Stop()
+	mon.Stop() // must not panic
+
+	done := make(chan struct{})
+	go func() {
+		mon.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("loop did not exit within 1s of Stop")
+	}
+}
@@ -0,0 +1,35 @@
+// Package identity generates and formats node identities: an Ed25519
+// keypair used to authenticate a node beyond its self-asserted NodeID.
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// KeyPair is a hex-encoded Ed25519 node identity.
+type KeyPair struct {
+	PublicKeyHex  string
+	PrivateKeyHex string
+}
+
+// Generate creates a fresh Ed25519 keypair for a new node identity.
+func Generate() (KeyPair, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("generate ed25519 key: %w", err)
+	}
+
+	return KeyPair{
+		PublicKeyHex:  hex.EncodeToString(pub),
+		PrivateKeyHex: hex.EncodeToString(priv),
+	}, nil
+}
+
+// NodeInfo formats a node's identity for pasting into another node's
+// BootstrapNodes list: nodeid@ip:port#pubkey.
+func NodeInfo(nodeID, ip string, port int, pubKeyHex string) string {
+	return fmt.Sprintf("%s@%s:%d#%s", nodeID, ip, port, pubKeyHex)
+}
@@ -0,0 +1,46 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+)
+
+func TestGenerate_ProducesValidKeys(t *testing.T) {
+	kp, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	pub, err := hex.DecodeString(kp.PublicKeyHex)
+	if err != nil {
+		t.Fatalf("decode public key: %v", err)
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		t.Errorf("public key len = %d, want %d", len(pub), ed25519.PublicKeySize)
+	}
+
+	priv, err := hex.DecodeString(kp.PrivateKeyHex)
+	if err != nil {
+		t.Fatalf("decode private key: %v", err)
+	}
+	if len(priv) != ed25519.PrivateKeySize {
+		t.Errorf("private key len = %d, want %d", len(priv), ed25519.PrivateKeySize)
+	}
+}
+
+func TestGenerate_KeysAreUnique(t *testing.T) {
+	a, _ := Generate()
+	b, _ := Generate()
+	if a.PrivateKeyHex == b.PrivateKeyHex {
+		t.Error("two calls to Generate should not produce the same key")
+	}
+}
+
+func TestNodeInfo_Format(t *testing.T) {
+	got := NodeInfo("x0t-abcd", "192.168.1.10", 5000, "deadbeef")
+	want := "x0t-abcd@192.168.1.10:5000#deadbeef"
+	if got != want {
+		t.Errorf("NodeInfo() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package identity
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"fmt"
+	"math/big"
+)
+
+// curve25519P is the field prime 2^255 - 19 both Ed25519 and X25519 are
+// defined over, needed to convert an Edwards25519 y-coordinate into its
+// birationally equivalent Montgomery u-coordinate.
+var curve25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// reverse returns a copy of b with byte order reversed, for converting
+// between the little-endian wire encodings this package's keys use and the
+// big-endian math/big expects.
+func reverse(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, v := range b {
+		out[len(b)-1-i] = v
+	}
+	return out
+}
+
+// X25519PublicKey derives the X25519 public key birationally equivalent to
+// an Ed25519 public key, the same conversion libsodium's
+// crypto_sign_ed25519_pk_to_curve25519 performs: an Ed25519 point is
+// encoded as its y-coordinate (plus a sign bit for x), and the Montgomery
+// u-coordinate of the same point is u = (1+y)/(1-y) mod p — a function of
+// y alone, so no point decompression is needed. This lets a peer's single
+// Ed25519 identity key also serve as its PSK-wrap key (see
+// security.WrapForPeer) instead of requiring a second registered keypair.
+func X25519PublicKey(pub ed25519.PublicKey) (*ecdh.PublicKey, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key length: %d", len(pub))
+	}
+
+	buf := make([]byte, ed25519.PublicKeySize)
+	copy(buf, pub)
+	buf[31] &= 0x7f // clear the sign bit; only y is needed for u
+	y := new(big.Int).SetBytes(reverse(buf))
+
+	one := big.NewInt(1)
+	num := new(big.Int).Mod(new(big.Int).Add(one, y), curve25519P)
+	den := new(big.Int).Mod(new(big.Int).Sub(one, y), curve25519P)
+	denInv := new(big.Int).ModInverse(den, curve25519P)
+	if denInv == nil {
+		return nil, fmt.Errorf("ed25519 public key has no birational x25519 equivalent")
+	}
+	u := new(big.Int).Mod(new(big.Int).Mul(num, denInv), curve25519P)
+
+	uBytes := reverse(u.FillBytes(make([]byte, 32)))
+	return ecdh.X25519().NewPublicKey(uBytes)
+}
+
+// X25519PrivateKey derives the X25519 private key corresponding to
+// X25519PublicKey's conversion of the matching Ed25519 public key: Ed25519
+// itself computes its signing scalar as the (clamped) first half of
+// SHA-512(seed), which is already exactly the scalar X25519 expects, so no
+// further conversion is needed beyond that hash and clamp.
+func X25519PrivateKey(priv ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid ed25519 private key length: %d", len(priv))
+	}
+
+	h := sha512.Sum512(priv.Seed())
+	scalar := h[:32]
+	scalar[0] &= 248
+	scalar[31] &= 127
+	scalar[31] |= 64
+
+	return ecdh.X25519().NewPrivateKey(scalar)
+}
@@ -0,0 +1,65 @@
+package identity
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+func TestX25519_ConversionProducesSharedSecretBothSidesAgreeOn(t *testing.T) {
+	kp, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	pub, err := hex.DecodeString(kp.PublicKeyHex)
+	if err != nil {
+		t.Fatalf("decode public key: %v", err)
+	}
+	priv, err := hex.DecodeString(kp.PrivateKeyHex)
+	if err != nil {
+		t.Fatalf("decode private key: %v", err)
+	}
+
+	xPub, err := X25519PublicKey(ed25519.PublicKey(pub))
+	if err != nil {
+		t.Fatalf("X25519PublicKey: %v", err)
+	}
+	xPriv, err := X25519PrivateKey(ed25519.PrivateKey(priv))
+	if err != nil {
+		t.Fatalf("X25519PrivateKey: %v", err)
+	}
+
+	if string(xPriv.PublicKey().Bytes()) != string(xPub.Bytes()) {
+		t.Error("X25519PrivateKey's public half should match X25519PublicKey's conversion of the same keypair")
+	}
+
+	other, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ephemeral key: %v", err)
+	}
+	s1, err := xPriv.ECDH(other.PublicKey())
+	if err != nil {
+		t.Fatalf("ecdh: %v", err)
+	}
+	s2, err := other.ECDH(xPub)
+	if err != nil {
+		t.Fatalf("ecdh: %v", err)
+	}
+	if string(s1) != string(s2) {
+		t.Error("shared secret should match from both sides")
+	}
+}
+
+func TestX25519PublicKey_RejectsWrongLength(t *testing.T) {
+	if _, err := X25519PublicKey(make([]byte, 10)); err == nil {
+		t.Error("expected error for a wrong-length public key")
+	}
+}
+
+func TestX25519PrivateKey_RejectsWrongLength(t *testing.T) {
+	if _, err := X25519PrivateKey(make([]byte, 10)); err == nil {
+		t.Error("expected error for a wrong-length private key")
+	}
+}
@@ -0,0 +1,88 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+// resolveSeed turns one BootstrapSeeds entry (a DNS name or static
+// host:port string) into concrete UDP addresses. A seed with no port is
+// assumed to listen on mcastPort.
+func (d *Discovery) resolveSeed(seed string) []*net.UDPAddr {
+	host, port, err := net.SplitHostPort(seed)
+	if err != nil {
+		host = seed
+		port = fmt.Sprintf("%d", d.mcastPort)
+	}
+
+	ips, err := net.LookupHost(host)
+	if err != nil {
+		d.logger.Warn("bootstrap seed lookup failed", "seed", seed, "err", err)
+		return nil
+	}
+
+	var addrs []*net.UDPAddr
+	for _, ip := range ips {
+		if addr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(ip, port)); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// resolveBootstrapSeeds resolves every configured BootstrapSeeds entry to
+// concrete UDP addresses to unicast a bootstrap MsgQuery to.
+func (d *Discovery) resolveBootstrapSeeds() []*net.UDPAddr {
+	var addrs []*net.UDPAddr
+	for _, seed := range d.BootstrapSeeds {
+		addrs = append(addrs, d.resolveSeed(seed)...)
+	}
+	return addrs
+}
+
+// queryBootstrapSeeds unicasts a MsgQuery to each configured bootstrap
+// seed, so this node can still learn peers when multicast discovery
+// (239.255.77.77) is unreachable, e.g. across cloud regions or inside
+// containers. Responses come back as ordinary MsgResponse messages,
+// handled by handleResponse like any query reply.
+func (d *Discovery) queryBootstrapSeeds() {
+	d.querySeeds(d.resolveBootstrapSeeds())
+}
+
+// querySeeds unicasts a MsgQuery to each of addrs.
+func (d *Discovery) querySeeds(addrs []*net.UDPAddr) {
+	if len(addrs) == 0 {
+		d.logger.Warn("no bootstrap seeds resolved; peer discovery limited to manually-learned addresses")
+		return
+	}
+	if d.transport == nil {
+		d.logger.Warn("discovery not started yet; bootstrap query skipped")
+		return
+	}
+
+	payload, _ := json.Marshal(struct{}{})
+	msg := d.newMessage(MsgQuery, payload)
+	data, _ := json.Marshal(msg)
+
+	for _, addr := range addrs {
+		if err := d.transport.Send(data, addr); err != nil {
+			d.logger.Warn("bootstrap query failed", "addr", addr, "err", err)
+			continue
+		}
+		d.logger.Info("queried bootstrap seed", "addr", addr)
+	}
+}
+
+// AddBootstrapSeed appends seed to BootstrapSeeds and immediately queries
+// it, so a peer address learned after Start — e.g. pushed by the Control
+// Plane via api.Client.Subscribe's PeerAddressUpdate — is dialed right
+// away; Start reads BootstrapSeeds only once, and only when multicast is
+// unavailable. Safe to call after Start.
+func (d *Discovery) AddBootstrapSeed(seed string) {
+	d.mu.Lock()
+	d.BootstrapSeeds = append(d.BootstrapSeeds, seed)
+	d.mu.Unlock()
+
+	d.querySeeds(d.resolveSeed(seed))
+}
@@ -0,0 +1,21 @@
+package discovery
+
+import "testing"
+
+func TestAddBootstrapSeed_AppendsToBootstrapSeeds(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	d.AddBootstrapSeed("127.0.0.1:9000")
+
+	if len(d.BootstrapSeeds) != 1 || d.BootstrapSeeds[0] != "127.0.0.1:9000" {
+		t.Errorf("BootstrapSeeds = %v, want [127.0.0.1:9000]", d.BootstrapSeeds)
+	}
+}
+
+func TestAddBootstrapSeed_UnresolvableSeedDoesNotPanic(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	d.AddBootstrapSeed("not-a-real-host.invalid:9000")
+
+	if len(d.BootstrapSeeds) != 1 {
+		t.Errorf("BootstrapSeeds = %v, want the seed recorded even if unresolvable", d.BootstrapSeeds)
+	}
+}
@@ -6,12 +6,21 @@
 package discovery
 
 import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	mrand "math/rand"
 	"net"
+	"net/netip"
 	"sync"
 	"time"
+
+	"github.com/x0tta6bl4/agent/internal/mesh/discovery/pbdiscovery"
 )
 
 // Message types — must match Python MessageType enum exactly.
@@ -33,22 +42,174 @@ const (
 	MaxMessageSize        = 4096
 )
 
+// Active liveness probing (see probeLoop). Rather than pinging every
+// peer every tick, each round unicasts MsgPing to a bounded random
+// subset — neo-go's broadcast-style fanout instead of an O(n) sweep —
+// and evicts a peer once ProbeFailureLimit consecutive probes of it go
+// unanswered, even if its passively-announced LastSeen is still fresh.
+const (
+	ProbeInterval     = 5 * time.Second
+	ProbeFanout       = 5
+	ProbeFailureLimit = 3
+)
+
+// ChurnQuietPeriod is how long the mesh must go without a peer joining
+// or leaving before announceLoop backs its interval off toward
+// PeerTimeout/3 (see currentAnnounceInterval); any churn snaps it back
+// down to AnnounceInterval.
+const ChurnQuietPeriod = 1 * time.Minute
+
+// rttAlpha weights new RTT samples into PeerInfo.RTTMS, matching the
+// smoothing TCP's RTO estimator uses for its own SRTT.
+const rttAlpha = 0.125
+
+// MaxPeersPerBucket caps how many distinct peers may share an address
+// bucket (a /24 for v4, a /48 for v6), so a single attacker-controlled
+// subnet can't flood the peer table and evict honest entries.
+const MaxPeersPerBucket = 8
+
+// LevelTrace sits one notch below slog.LevelDebug, for the
+// dropped/ignored message diagnostics in handleMessage that are too
+// noisy to enable even at Debug in a healthy mesh.
+const LevelTrace = slog.Level(-8)
+
+// These are the logAt subsystem keys LogLevel recognizes: the listen,
+// announce, cleanup, and probe loops.
+const (
+	subsystemListen   = "listen"
+	subsystemAnnounce = "announce"
+	subsystemCleanup  = "cleanup"
+	subsystemProbe    = "probe"
+)
+
+// bucketKey returns the /24 (v4) or /48 (v6) address bucket ip falls
+// into, used to rate-limit how many distinct peers one subnet may
+// register in the peer table.
+func bucketKey(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.0/24", v4[0], v4[1], v4[2])
+	}
+	if v6 := ip.To16(); v6 != nil {
+		return hex.EncodeToString(v6[:6]) + "/48"
+	}
+	return ip.String()
+}
+
 // PeerInfo matches Python PeerInfo.to_dict() format.
 type PeerInfo struct {
-	NodeID    string     `json:"node_id"`
+	NodeID    string    `json:"node_id"`
 	Addresses [][]any   `json:"addresses"` // [[ip, port], ...]
 	Services  []string  `json:"services"`
 	Version   string    `json:"version"`
 	LastSeen  time.Time `json:"-"`
 	RTTMS     float64   `json:"-"`
+
+	// AdvertisedRoutes and RoutePriority are the subnet-router
+	// advertisement extension (see internal/routing): the CIDR prefixes
+	// this peer claims it can route to, and the priority to weigh it
+	// against other advertisers of the same prefix (lower wins, ties
+	// broken by node ID). Both are omitted from the wire payload when
+	// empty/zero, so unsigned/older peers stay wire-compatible.
+	AdvertisedRoutes []netip.Prefix `json:"advertised_routes,omitempty"`
+	RoutePriority    int            `json:"route_priority,omitempty"`
+
+	// Logger is bound once, at first discovery, with this peer's
+	// node_id, addr and mesh_id already attached — every subsequent
+	// event about this peer (timeout, leave, RTT samples) should log
+	// through it instead of re-attributing d.logger each time.
+	Logger *slog.Logger `json:"-"`
 }
 
-// Message matches Python DiscoveryMessage wire format.
+// Message matches Python DiscoveryMessage wire format. Signature is a
+// detached Ed25519 signature over (Sender || Payload || TS), populated
+// when the Discovery instance was created with a signer (see NewSigned)
+// and omitted entirely for unsigned deployments to stay wire-compatible
+// with the Python side.
 type Message struct {
-	Type    int             `json:"type"`
-	Sender  string          `json:"sender"`
-	Payload json.RawMessage `json:"payload"`
-	TS      int64           `json:"ts"`
+	Type      int             `json:"type"`
+	Sender    string          `json:"sender"`
+	Payload   json.RawMessage `json:"payload"`
+	TS        int64           `json:"ts"`
+	Signature string          `json:"sig,omitempty"`
+}
+
+// signingDigest returns the canonical bytes a signature is computed over.
+func signingDigest(sender string, payload json.RawMessage, ts int64) []byte {
+	digest := make([]byte, 0, len(sender)+len(payload)+8)
+	digest = append(digest, sender...)
+	digest = append(digest, payload...)
+	digest = append(digest, fmt.Sprintf("%d", ts)...)
+	return digest
+}
+
+// PubKeyFromNodeID recovers the Ed25519 public key a NodeID was derived
+// from. NodeIDs minted by signed Discovery instances are the hex encoding
+// of their public key (see NewSigned), so this is just a decode + length
+// check.
+func PubKeyFromNodeID(nodeID string) (ed25519.PublicKey, bool) {
+	raw, err := hex.DecodeString(nodeID)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, false
+	}
+	return ed25519.PublicKey(raw), true
+}
+
+// sign attaches a detached signature over msg's (Sender, Payload, TS) to
+// msg.Signature. A no-op when d wasn't built with NewSigned.
+func (d *Discovery) sign(msg *Message) {
+	if d.signer == nil {
+		return
+	}
+	digest := signingDigest(msg.Sender, msg.Payload, msg.TS)
+	sig, err := d.signer.Sign(rand.Reader, digest, crypto.Hash(0))
+	if err != nil {
+		d.logger.Error("sign message", "type", msg.Type, "err", err)
+		return
+	}
+	msg.Signature = hex.EncodeToString(sig)
+}
+
+// verify reports whether msg carries a valid signature. If d.trustedRoots
+// is set, msg must verify against one of those pinned keys; otherwise it
+// must verify against the pubkey encoded in msg.Sender.
+func (d *Discovery) verify(msg Message) bool {
+	if msg.Signature == "" {
+		return false
+	}
+	sig, err := hex.DecodeString(msg.Signature)
+	if err != nil {
+		return false
+	}
+	digest := signingDigest(msg.Sender, msg.Payload, msg.TS)
+
+	if len(d.trustedRoots) > 0 {
+		for _, root := range d.trustedRoots {
+			if ed25519.Verify(root, digest, sig) {
+				return true
+			}
+		}
+		return false
+	}
+
+	pub, ok := PubKeyFromNodeID(msg.Sender)
+	if !ok {
+		return false
+	}
+	return ed25519.Verify(pub, digest, sig)
+}
+
+// newMessage builds an outgoing Message with the given type and payload,
+// stamping it with the current time and, for signed Discovery instances,
+// a detached signature.
+func (d *Discovery) newMessage(msgType int, payload []byte) Message {
+	msg := Message{
+		Type:    msgType,
+		Sender:  d.nodeID,
+		Payload: payload,
+		TS:      time.Now().UnixMilli(),
+	}
+	d.sign(&msg)
+	return msg
 }
 
 // AnnouncePayload is the payload for ANNOUNCE messages.
@@ -77,17 +238,96 @@ type Discovery struct {
 	mcastGroup  string
 	mcastPort   int
 
-	mu    sync.RWMutex
-	peers map[string]*PeerInfo
-
-	conn    *net.UDPConn
-	running bool
-	stopCh  chan struct{}
+	mu          sync.RWMutex
+	peers       map[string]*PeerInfo
+	peerBucket  map[string]string // nodeID -> address bucket, see bucketKey
+	bucketCount map[string]int    // address bucket -> distinct peers in it
+
+	transport Transport
+
+	// ctx/cancel govern listenLoop, announceLoop, cleanupLoop, and
+	// probeLoop: cancelling it (via Stop or the parent context passed to
+	// Start) is the sole signal each loop's select (or, for listenLoop's
+	// blocking Recv, ctx.Err() check) waits on. stopOnce makes Stop safe
+	// to call more than once or concurrently.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+
+	// BootstrapSeeds is a list of DNS names or static host:port peers
+	// queried with a unicast MsgQuery on Start when multicast discovery
+	// isn't reachable (e.g. cloud regions/containers where 239.255.77.77
+	// can't be routed). Set before calling Start.
+	BootstrapSeeds []string
+
+	// NATTraversal, when set before Start, makes Discovery try to learn a
+	// publicly routable address for servicePort (UPnP-IGD, then NAT-PMP,
+	// then STUN) and advertise that instead of the LAN address from
+	// getLocalIP. See nat.go.
+	NATTraversal bool
+	publicAddr   string
+
+	// AdvertisedRoutes and RoutePriority, when set before Start, are
+	// included in this node's own ANNOUNCE payload (see sendAnnounce) so
+	// peers running internal/routing can treat this node as a candidate
+	// subnet router for the listed prefixes.
+	AdvertisedRoutes []netip.Prefix
+	RoutePriority    int
+
+	// signer, trustedRoots and requireSignatures hold the optional signed
+	// deployment state set up by NewSigned. When requireSignatures is
+	// false (the default, unsigned New), Discovery behaves exactly as
+	// before and stays wire-compatible with the Python side.
+	signer            crypto.Signer
+	trustedRoots      []ed25519.PublicKey
+	requireSignatures bool
 
 	OnPeerDiscovered OnPeerFunc
 	OnPeerLost       OnPeerFunc
 
+	// LogLevel sets the minimum level emitted per subsystem ("listen",
+	// "announce", "cleanup", "probe"), so an operator can turn on Trace-level
+	// dropped-message diagnostics for just one of them without
+	// recompiling. Subsystems missing from the map default to
+	// slog.LevelInfo. Safe to mutate at any time; it's consulted on
+	// every log call rather than baked into the handler at Start.
+	LogLevel map[string]slog.Level
+
 	logger *slog.Logger
+
+	// PeeringListenAddr, when set before Start, makes Discovery accept
+	// inbound cross-mesh peering streams (see peering.go) on that
+	// "host:port" in addition to its normal multicast discovery.
+	PeeringListenAddr string
+
+	// federatedPeers holds peers learned from federated (peered) meshes,
+	// keyed by federatedPeerKey(meshID, nodeID) so distinct meshes never
+	// collide even if they reuse NodeIDs.
+	federatedPeers map[string]*pbdiscovery.PeerInfo
+
+	// exportedServices, guarded by mu like federatedPeers, maps a
+	// federated mesh ID to the allow-list of local service names
+	// sendPeeringSnapshots will replicate to it (see
+	// SetExportedServices and filterPeersForExport). A mesh ID with no
+	// entry is sent everything, unfiltered — the backward-compatible
+	// default for a peering nobody has restricted.
+	exportedServices map[string][]string
+
+	// pendingProbes and probeFailures back probeLoop's active liveness
+	// checks: pendingProbes records when an outstanding MsgPing to a
+	// nodeID was sent, and probeFailures counts how many rounds in a row
+	// it's gone unanswered. Guarded by mu like the rest of the peer
+	// table.
+	pendingProbes map[string]time.Time
+	probeFailures map[string]int
+
+	// announceInterval is the live announce period announceLoop actually
+	// uses; it starts at AnnounceInterval and backs off toward
+	// PeerTimeout/3 once the mesh has gone ChurnQuietPeriod without a
+	// peer joining or leaving (see currentAnnounceInterval). lastChurnAt
+	// tracks that quiet period. Both guarded by mu.
+	announceInterval time.Duration
+	lastChurnAt      time.Time
 }
 
 // New creates a new Discovery instance.
@@ -102,45 +342,132 @@ func New(nodeID string, servicePort int, services []string, mcastGroup string, m
 		services = []string{"mesh"}
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Discovery{
-		nodeID:      nodeID,
-		servicePort: servicePort,
-		services:    services,
-		mcastGroup:  mcastGroup,
-		mcastPort:   mcastPort,
-		peers:       make(map[string]*PeerInfo),
-		stopCh:      make(chan struct{}),
-		logger:      slog.Default().With("component", "discovery"),
+		nodeID:           nodeID,
+		servicePort:      servicePort,
+		services:         services,
+		mcastGroup:       mcastGroup,
+		mcastPort:        mcastPort,
+		peers:            make(map[string]*PeerInfo),
+		peerBucket:       make(map[string]string),
+		bucketCount:      make(map[string]int),
+		ctx:              ctx,
+		cancel:           cancel,
+		logger:           slog.Default().With("component", "discovery"),
+		federatedPeers:   make(map[string]*pbdiscovery.PeerInfo),
+		exportedServices: make(map[string][]string),
+		pendingProbes:    make(map[string]time.Time),
+		probeFailures:    make(map[string]int),
+		announceInterval: AnnounceInterval,
+		lastChurnAt:      time.Now(),
 	}
 }
 
-// Start begins multicast listening and announcing.
-func (d *Discovery) Start() error {
-	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf(":%d", d.mcastPort))
-	if err != nil {
-		return fmt.Errorf("resolve multicast addr: %w", err)
+// SetLogHandler replaces Discovery's slog handler, preserving the
+// "component", "discovery" attribute every log line carries. Call it
+// before Start to route discovery's logs into an operator's structured
+// logging pipeline instead of slog.Default().
+func (d *Discovery) SetLogHandler(h slog.Handler) {
+	d.logger = slog.New(h).With("component", "discovery")
+}
+
+// logLevelFor returns the configured minimum level for subsystem,
+// defaulting to slog.LevelInfo when LogLevel doesn't mention it.
+func (d *Discovery) logLevelFor(subsystem string) slog.Level {
+	if lvl, ok := d.LogLevel[subsystem]; ok {
+		return lvl
 	}
+	return slog.LevelInfo
+}
 
-	conn, err := net.ListenMulticastUDP("udp4", nil, &net.UDPAddr{
-		IP:   net.ParseIP(d.mcastGroup),
-		Port: d.mcastPort,
-	})
+// logAt emits msg through d.logger at level if subsystem's configured
+// LogLevel permits it, which is what lets an operator flip a subsystem
+// down to LevelTrace at runtime without recompiling.
+func (d *Discovery) logAt(subsystem string, level slog.Level, msg string, args ...any) {
+	if level < d.logLevelFor(subsystem) {
+		return
+	}
+	d.logger.Log(context.Background(), level, msg, args...)
+}
+
+// peerLogger returns peer.Logger if one was bound at discovery time,
+// falling back to d.logger for peers that never had one bound (those
+// learned from a bootstrap RESPONSE in handleResponse).
+func (d *Discovery) peerLogger(peer *PeerInfo) *slog.Logger {
+	if peer != nil && peer.Logger != nil {
+		return peer.Logger
+	}
+	return d.logger
+}
+
+// NewSigned creates a Discovery instance that signs every outgoing
+// Message with signer (a crypto.Signer backed by an ed25519.PrivateKey,
+// e.g. one minted by package identity) and derives its NodeID from
+// signer's public key, the same way go-ethereum's bootnode derives its
+// enode ID from -nodekey. Incoming messages that don't carry a valid
+// signature are dropped in handleMessage before they ever reach the
+// peer table.
+//
+// If trustedRoots is non-empty, incoming signatures must additionally
+// verify against one of those pinned keys rather than whatever key the
+// message's own NodeID claims — CA-style pinning for deployments that
+// only trust a fixed set of signers regardless of who claims to be them.
+func NewSigned(signer crypto.Signer, trustedRoots []ed25519.PublicKey, servicePort int, services []string, mcastGroup string, mcastPort int) (*Discovery, error) {
+	pub, ok := signer.Public().(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("discovery: signer must be ed25519, got %T", signer.Public())
+	}
+
+	d := New(hex.EncodeToString(pub), servicePort, services, mcastGroup, mcastPort)
+	d.signer = signer
+	d.trustedRoots = trustedRoots
+	d.requireSignatures = true
+	return d, nil
+}
+
+// Start begins listening and announcing. It opens the multicast
+// transport and, when multicast isn't reachable (e.g. in containers),
+// falls back to a plain unicast socket and actively queries
+// BootstrapSeeds instead of just giving up on peer bootstrap. ctx governs
+// listenLoop, announceLoop, cleanupLoop, and probeLoop; cancelling it (or
+// calling Stop, which cancels an internally derived child of it) tells
+// all four to return.
+func (d *Discovery) Start(ctx context.Context) error {
+	transport, fellBack, err := newMulticastTransport(d.mcastGroup, d.mcastPort)
 	if err != nil {
-		// Fallback: plain UDP if multicast fails (e.g., in containers)
-		conn2, err2 := net.ListenUDP("udp4", addr)
-		if err2 != nil {
-			return fmt.Errorf("listen multicast: %w (fallback: %w)", err, err2)
-		}
-		conn = conn2
-		d.logger.Warn("multicast unavailable, using plain UDP", "addr", addr)
+		return err
 	}
+	d.transport = transport
+	d.ctx, d.cancel = context.WithCancel(ctx)
 
-	d.conn = conn
-	d.running = true
+	if fellBack {
+		d.logger.Warn("multicast unavailable, using plain UDP", "group", d.mcastGroup, "port", d.mcastPort)
+		d.queryBootstrapSeeds()
+	}
+
+	if d.NATTraversal {
+		if ip, ok := reflexiveAddr(d.servicePort); ok {
+			d.publicAddr = ip
+			d.logger.Info("learned reflexive address for NAT traversal", "addr", ip)
+		} else {
+			d.logger.Warn("NAT traversal failed, advertising LAN address")
+		}
+	}
 
 	go d.listenLoop()
 	go d.announceLoop()
 	go d.cleanupLoop()
+	go d.probeLoop()
+
+	if d.PeeringListenAddr != "" {
+		ln, err := net.Listen("tcp", d.PeeringListenAddr)
+		if err != nil {
+			return fmt.Errorf("discovery: listen for peering on %s: %w", d.PeeringListenAddr, err)
+		}
+		go d.acceptPeeringLoop(ln)
+		d.logger.Info("accepting cross-mesh peering connections", "addr", d.PeeringListenAddr)
+	}
 
 	// Immediate announce
 	d.sendAnnounce()
@@ -153,18 +480,17 @@ func (d *Discovery) Start() error {
 	return nil
 }
 
-// Stop shuts down discovery gracefully.
+// Stop shuts down discovery gracefully. Safe to call more than once or
+// concurrently with itself; only the first call has any effect.
 func (d *Discovery) Stop() {
-	if !d.running {
-		return
-	}
-	d.running = false
-	d.sendLeave()
-	close(d.stopCh)
-	if d.conn != nil {
-		d.conn.Close()
-	}
-	d.logger.Info("discovery stopped")
+	d.stopOnce.Do(func() {
+		d.sendLeave()
+		d.cancel()
+		if d.transport != nil {
+			d.transport.Close()
+		}
+		d.logger.Info("discovery stopped")
+	})
 }
 
 // GetPeers returns all known live peers.
@@ -201,16 +527,20 @@ func (d *Discovery) PeerCount() int {
 
 func (d *Discovery) listenLoop() {
 	buf := make([]byte, MaxMessageSize)
-	for d.running {
-		d.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
-		n, remoteAddr, err := d.conn.ReadFromUDP(buf)
+	for d.ctx.Err() == nil {
+		n, remoteAddr, err := d.transport.Recv(buf)
 		if err != nil {
 			continue
 		}
+		d.logAt(subsystemListen, LevelTrace, "received datagram", "addr", remoteAddr, "bytes", n)
 		d.handleMessage(buf[:n], remoteAddr)
 	}
 }
 
+// announceLoop sends periodic ANNOUNCE broadcasts, adapting its own
+// period via currentAnnounceInterval: it backs off toward PeerTimeout/3
+// in a stable mesh and snaps back to AnnounceInterval as soon as
+// membership churns again.
 func (d *Discovery) announceLoop() {
 	ticker := time.NewTicker(AnnounceInterval)
 	defer ticker.Stop()
@@ -218,8 +548,10 @@ func (d *Discovery) announceLoop() {
 	for {
 		select {
 		case <-ticker.C:
+			d.logAt(subsystemAnnounce, LevelTrace, "sending periodic announce")
 			d.sendAnnounce()
-		case <-d.stopCh:
+			ticker.Reset(d.currentAnnounceInterval())
+		case <-d.ctx.Done():
 			return
 		}
 	}
@@ -232,21 +564,74 @@ func (d *Discovery) cleanupLoop() {
 	for {
 		select {
 		case <-ticker.C:
+			d.logAt(subsystemCleanup, LevelTrace, "running expiry sweep")
 			d.cleanupExpired()
-		case <-d.stopCh:
+		case <-d.ctx.Done():
 			return
 		}
 	}
 }
 
+// probeLoop drives active liveness probing: every ProbeInterval it
+// advances the previous round's outstanding pings into timeouts (or RTT
+// samples, via handlePong) and fires a fresh bounded-fanout round.
+func (d *Discovery) probeLoop() {
+	ticker := time.NewTicker(ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.runProbeRound()
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// currentAnnounceInterval returns AnnounceInterval while the mesh has
+// churned within ChurnQuietPeriod, and backs off toward PeerTimeout/3
+// once it's been stable that long; see markChurn for what resets it.
+func (d *Discovery) currentAnnounceInterval() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if backoff := PeerTimeout / 3; time.Since(d.lastChurnAt) >= ChurnQuietPeriod && d.announceInterval < backoff {
+		d.announceInterval = backoff
+	}
+	return d.announceInterval
+}
+
+// markChurn records that a peer just joined or left, resetting the
+// stable-mesh clock and snapping announceLoop's live interval back down
+// to AnnounceInterval. Callers must not hold mu.
+func (d *Discovery) markChurn() {
+	d.mu.Lock()
+	d.lastChurnAt = time.Now()
+	d.announceInterval = AnnounceInterval
+	d.mu.Unlock()
+}
+
 func (d *Discovery) handleMessage(data []byte, addr *net.UDPAddr) {
+	if len(data) >= MaxMessageSize {
+		d.logAt(subsystemListen, LevelTrace, "dropping message", "reason", "oversize", "addr", addr, "bytes", len(data))
+		return
+	}
+
 	var msg Message
 	if err := json.Unmarshal(data, &msg); err != nil {
+		d.logAt(subsystemListen, LevelTrace, "dropping message", "reason", "unparseable", "addr", addr, "err", err)
 		return
 	}
 
 	// Ignore our own messages
 	if msg.Sender == d.nodeID {
+		d.logAt(subsystemListen, LevelTrace, "dropping message", "reason", "self", "type", msg.Type)
+		return
+	}
+
+	if d.requireSignatures && !d.verify(msg) {
+		d.logger.Warn("dropping message with invalid signature", "sender", msg.Sender, "type", msg.Type)
 		return
 	}
 
@@ -257,8 +642,14 @@ func (d *Discovery) handleMessage(data []byte, addr *net.UDPAddr) {
 		d.handleLeave(msg)
 	case MsgQuery:
 		d.handleQuery(msg, addr)
+	case MsgResponse:
+		d.handleResponse(msg)
 	case MsgPing:
 		d.handlePing(msg, addr)
+	case MsgPong:
+		d.handlePong(msg)
+	default:
+		d.logAt(subsystemListen, LevelTrace, "dropping message", "reason", "unknown_type", "sender", msg.Sender, "type", msg.Type)
 	}
 }
 
@@ -290,27 +681,56 @@ func (d *Discovery) handleAnnounce(msg Message, addr *net.UDPAddr) {
 
 	d.mu.Lock()
 	_, exists := d.peers[peer.NodeID]
+	if !exists {
+		bucket := bucketKey(addr.IP)
+		if d.bucketCount[bucket] >= MaxPeersPerBucket {
+			d.mu.Unlock()
+			d.logger.Warn("dropping announce: address bucket full", "node_id", peer.NodeID, "bucket", bucket)
+			return
+		}
+		d.peerBucket[peer.NodeID] = bucket
+		d.bucketCount[bucket]++
+		peer.Logger = d.logger.With("node_id", peer.NodeID, "addr", addr.String(), "mesh_id", d.mcastGroup)
+	} else {
+		prev := d.peers[peer.NodeID]
+		peer.Logger, peer.RTTMS = prev.Logger, prev.RTTMS
+	}
 	d.peers[peer.NodeID] = &peer
 	d.mu.Unlock()
 
 	if !exists {
-		d.logger.Info("peer discovered", "node_id", peer.NodeID, "addr", addr)
+		peer.Logger.Info("peer discovered")
+		d.markChurn()
 		if d.OnPeerDiscovered != nil {
 			d.OnPeerDiscovered(peer)
 		}
 	}
 }
 
+// removePeerLocked deletes nodeID from peers and its address bucket.
+// Callers must hold d.mu.
+func (d *Discovery) removePeerLocked(nodeID string) {
+	delete(d.peers, nodeID)
+	if bucket, ok := d.peerBucket[nodeID]; ok {
+		delete(d.peerBucket, nodeID)
+		d.bucketCount[bucket]--
+		if d.bucketCount[bucket] <= 0 {
+			delete(d.bucketCount, bucket)
+		}
+	}
+}
+
 func (d *Discovery) handleLeave(msg Message) {
 	d.mu.Lock()
 	peer, exists := d.peers[msg.Sender]
 	if exists {
-		delete(d.peers, msg.Sender)
+		d.removePeerLocked(msg.Sender)
 	}
 	d.mu.Unlock()
 
 	if exists {
-		d.logger.Info("peer left", "node_id", msg.Sender)
+		d.peerLogger(peer).Info("peer left")
+		d.markChurn()
 		if d.OnPeerLost != nil && peer != nil {
 			d.OnPeerLost(*peer)
 		}
@@ -326,80 +746,229 @@ func (d *Discovery) handleQuery(msg Message, addr *net.UDPAddr) {
 	d.mu.RUnlock()
 
 	payload, _ := json.Marshal(ResponsePayload{Peers: peers})
-	resp := Message{
-		Type:    MsgResponse,
-		Sender:  d.nodeID,
-		Payload: payload,
-		TS:      time.Now().UnixMilli(),
-	}
+	resp := d.newMessage(MsgResponse, payload)
 
 	data, _ := json.Marshal(resp)
-	d.conn.WriteToUDP(data, addr)
+	d.transport.Send(data, addr)
+}
+
+// handleResponse merges peers learned from a RESPONSE (a reply to our own
+// MsgQuery, typically from a bootstrap seed — see queryBootstrapSeeds)
+// into the peer table. These peers arrive without a UDP remote address to
+// bucket on, so they aren't subject to MaxPeersPerBucket: the responder
+// already passed that check when it first learned of them.
+func (d *Discovery) handleResponse(msg Message) {
+	var payload ResponsePayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return
+	}
+
+	for _, peer := range payload.Peers {
+		if peer.NodeID == d.nodeID {
+			continue
+		}
+		peer.LastSeen = time.Now()
+
+		d.mu.Lock()
+		_, exists := d.peers[peer.NodeID]
+		d.peers[peer.NodeID] = &peer
+		d.mu.Unlock()
+
+		if !exists {
+			d.logger.Info("peer discovered via bootstrap response", "node_id", peer.NodeID)
+			if d.OnPeerDiscovered != nil {
+				d.OnPeerDiscovered(peer)
+			}
+		}
+	}
 }
 
 func (d *Discovery) handlePing(msg Message, addr *net.UDPAddr) {
 	payload, _ := json.Marshal(PingPayload{PingTS: msg.TS})
-	pong := Message{
-		Type:    MsgPong,
-		Sender:  d.nodeID,
-		Payload: payload,
-		TS:      time.Now().UnixMilli(),
-	}
+	pong := d.newMessage(MsgPong, payload)
 
 	data, _ := json.Marshal(pong)
-	d.conn.WriteToUDP(data, addr)
+	d.transport.Send(data, addr)
+}
+
+// handlePong matches an inbound MsgPong against probeLoop's outstanding
+// pings, updates the sender's RTTMS via EWMA, and clears its probe
+// failure count. Pongs that don't match a pending probe (late replies
+// to an already-timed-out round, or unsolicited ones) are ignored.
+func (d *Discovery) handlePong(msg Message) {
+	d.mu.Lock()
+	sentAt, outstanding := d.pendingProbes[msg.Sender]
+	if !outstanding {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.pendingProbes, msg.Sender)
+	delete(d.probeFailures, msg.Sender)
+
+	rttMS := float64(time.Since(sentAt).Milliseconds())
+	peer, ok := d.peers[msg.Sender]
+	if ok {
+		peer.RTTMS = ewmaRTT(peer.RTTMS, rttMS)
+	}
+	d.mu.Unlock()
+
+	if ok {
+		d.peerLogger(peer).Log(context.Background(), LevelTrace, "rtt sample", "rtt_ms", rttMS)
+	}
+}
+
+// ewmaRTT folds sample into prev with rttAlpha, seeding the average with
+// the first sample instead of smoothing from zero.
+func ewmaRTT(prev, sample float64) float64 {
+	if prev <= 0 {
+		return sample
+	}
+	return (1-rttAlpha)*prev + rttAlpha*sample
+}
+
+// peerUDPAddr resolves the first entry in peer.Addresses (a [ip, port]
+// pair, see PeerInfo) into a unicast destination for sendPing.
+func peerUDPAddr(peer *PeerInfo) *net.UDPAddr {
+	if len(peer.Addresses) == 0 || len(peer.Addresses[0]) < 2 {
+		return nil
+	}
+	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%v:%v", peer.Addresses[0][0], peer.Addresses[0][1]))
+	if err != nil {
+		return nil
+	}
+	return addr
+}
+
+// pickRandomSubset returns up to n distinct elements of ids in random
+// order, the bounded fanout that keeps probeLoop from pinging every peer
+// every round in a large mesh.
+func pickRandomSubset(ids []string, n int) []string {
+	if len(ids) <= n {
+		return ids
+	}
+	shuffled := make([]string, len(ids))
+	copy(shuffled, ids)
+	mrand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	return shuffled[:n]
+}
+
+// sendPing unicasts a MsgPing to nodeID's last-known address. A no-op if
+// the peer has since been removed or has no usable address.
+func (d *Discovery) sendPing(nodeID string) {
+	d.mu.RLock()
+	peer, ok := d.peers[nodeID]
+	d.mu.RUnlock()
+	if !ok {
+		return
+	}
+	addr := peerUDPAddr(peer)
+	if addr == nil {
+		return
+	}
+
+	payload, _ := json.Marshal(struct{}{})
+	msg := d.newMessage(MsgPing, payload)
+	data, _ := json.Marshal(msg)
+	if err := d.transport.Send(data, addr); err != nil {
+		d.logAt(subsystemProbe, LevelTrace, "ping send failed", "node_id", nodeID, "err", err)
+	}
+}
+
+// runProbeRound is probeLoop's per-tick body: it first resolves the
+// previous round's outstanding pings (any still pending timed out),
+// evicting peers that have now failed ProbeFailureLimit probes in a row
+// even though passive announces kept their LastSeen fresh, then fires a
+// fresh bounded-fanout round of pings at a random subset of survivors.
+func (d *Discovery) runProbeRound() {
+	d.mu.Lock()
+	var evicted []PeerInfo
+	for nodeID := range d.pendingProbes {
+		delete(d.pendingProbes, nodeID)
+		d.probeFailures[nodeID]++
+		if d.probeFailures[nodeID] < ProbeFailureLimit {
+			continue
+		}
+		delete(d.probeFailures, nodeID)
+		if peer, ok := d.peers[nodeID]; ok {
+			d.peerLogger(peer).Warn("peer evicted", "reason", "probe_timeout", "failures", ProbeFailureLimit)
+			evicted = append(evicted, *peer)
+			d.removePeerLocked(nodeID)
+		}
+	}
+
+	candidates := make([]string, 0, len(d.peers))
+	for nodeID := range d.peers {
+		candidates = append(candidates, nodeID)
+	}
+	targets := pickRandomSubset(candidates, ProbeFanout)
+	now := time.Now()
+	for _, nodeID := range targets {
+		d.pendingProbes[nodeID] = now
+	}
+	d.mu.Unlock()
+
+	if len(evicted) > 0 {
+		d.markChurn()
+	}
+	for _, peer := range evicted {
+		if d.OnPeerLost != nil {
+			d.OnPeerLost(peer)
+		}
+	}
+	for _, nodeID := range targets {
+		d.sendPing(nodeID)
+	}
 }
 
 func (d *Discovery) sendAnnounce() {
 	localIP := getLocalIP()
+	if d.publicAddr != "" {
+		localIP = d.publicAddr
+	}
 
 	peer := PeerInfo{
-		NodeID:    d.nodeID,
-		Addresses: [][]any{{localIP, d.servicePort}},
-		Services:  d.services,
-		Version:   "1.0.0",
+		NodeID:           d.nodeID,
+		Addresses:        [][]any{{localIP, d.servicePort}},
+		Services:         d.services,
+		Version:          "1.0.0",
+		AdvertisedRoutes: d.AdvertisedRoutes,
+		RoutePriority:    d.RoutePriority,
 	}
 
 	payload, _ := json.Marshal(AnnouncePayload{Peer: peer})
-	msg := Message{
-		Type:    MsgAnnounce,
-		Sender:  d.nodeID,
-		Payload: payload,
-		TS:      time.Now().UnixMilli(),
-	}
+	msg := d.newMessage(MsgAnnounce, payload)
 
 	data, _ := json.Marshal(msg)
-	dst := &net.UDPAddr{IP: net.ParseIP(d.mcastGroup), Port: d.mcastPort}
-	d.conn.WriteToUDP(data, dst)
+	d.transport.Send(data, nil)
 }
 
 func (d *Discovery) sendLeave() {
 	payload, _ := json.Marshal(struct{}{})
-	msg := Message{
-		Type:    MsgLeave,
-		Sender:  d.nodeID,
-		Payload: payload,
-		TS:      time.Now().UnixMilli(),
-	}
+	msg := d.newMessage(MsgLeave, payload)
 
 	data, _ := json.Marshal(msg)
-	dst := &net.UDPAddr{IP: net.ParseIP(d.mcastGroup), Port: d.mcastPort}
-	d.conn.WriteToUDP(data, dst)
+	d.transport.Send(data, nil)
 }
 
 func (d *Discovery) cleanupExpired() {
 	d.mu.Lock()
-	defer d.mu.Unlock()
-
+	var expired []PeerInfo
 	now := time.Now()
 	for nodeID, peer := range d.peers {
 		if now.Sub(peer.LastSeen) > PeerTimeout {
-			p := *peer
-			delete(d.peers, nodeID)
-			d.logger.Info("peer timeout", "node_id", nodeID)
-			if d.OnPeerLost != nil {
-				d.OnPeerLost(p)
-			}
+			expired = append(expired, *peer)
+			d.removePeerLocked(nodeID)
+			d.peerLogger(peer).Info("peer timeout")
+		}
+	}
+	d.mu.Unlock()
+
+	if len(expired) > 0 {
+		d.markChurn()
+	}
+	if d.OnPeerLost != nil {
+		for _, p := range expired {
+			d.OnPeerLost(p)
 		}
 	}
 }
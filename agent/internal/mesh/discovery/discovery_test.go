@@ -1,7 +1,15 @@
 package discovery
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
 	"testing"
 	"time"
 )
@@ -191,3 +199,165 @@ func TestMessageTypes(t *testing.T) {
 		t.Errorf("MsgLeave = %d, want 7", MsgLeave)
 	}
 }
+
+func TestNewSigned_DerivesNodeIDFromPubKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewSigned(priv, nil, 5000, nil, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.nodeID != hex.EncodeToString(pub) {
+		t.Errorf("nodeID = %s, want hex(pubkey)", d.nodeID)
+	}
+	if !d.requireSignatures {
+		t.Error("requireSignatures should be true for NewSigned instances")
+	}
+}
+
+func TestNewSigned_RejectsNonEd25519Signer(t *testing.T) {
+	if _, err := NewSigned(rsaFakeSigner{}, nil, 5000, nil, "", 0); err == nil {
+		t.Error("expected error for non-ed25519 signer")
+	}
+}
+
+// rsaFakeSigner is a crypto.Signer whose Public key isn't ed25519, used to
+// exercise NewSigned's type check without pulling in a real RSA key.
+type rsaFakeSigner struct{}
+
+func (rsaFakeSigner) Public() crypto.PublicKey                                  { return "not-ed25519" }
+func (rsaFakeSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) { return nil, nil }
+
+func TestSignAndVerify_RoundTrip(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	d, err := NewSigned(priv, nil, 5000, nil, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, _ := json.Marshal(struct{}{})
+	msg := d.newMessage(MsgPing, payload)
+	if msg.Signature == "" {
+		t.Fatal("signed Discovery should stamp a signature")
+	}
+	if !d.verify(msg) {
+		t.Error("verify should accept a message signed by this instance")
+	}
+
+	tampered := msg
+	tampered.TS++
+	if d.verify(tampered) {
+		t.Error("verify should reject a message whose signed fields changed")
+	}
+}
+
+func TestHandleMessage_DropsUnsignedWhenSignaturesRequired(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	d, err := NewSigned(priv, nil, 5000, nil, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer := PeerInfo{NodeID: "impostor", Addresses: [][]any{{"127.0.0.1", float64(5000)}}}
+	payload, _ := json.Marshal(AnnouncePayload{Peer: peer})
+	msg := Message{Type: MsgAnnounce, Sender: "impostor", Payload: payload, TS: time.Now().UnixMilli()}
+	data, _ := json.Marshal(msg)
+	d.handleMessage(data, &net.UDPAddr{IP: net.ParseIP("10.0.0.1")})
+
+	if d.PeerCount() != 0 {
+		t.Error("unsigned announce should be dropped before reaching the peer table")
+	}
+}
+
+func TestHandleMessage_TrustedRootsPinning(t *testing.T) {
+	trustedPub, trustedPriv, _ := ed25519.GenerateKey(rand.Reader)
+	_, untrustedPriv, _ := ed25519.GenerateKey(rand.Reader)
+
+	d, err := NewSigned(untrustedPriv, []ed25519.PublicKey{trustedPub}, 5000, nil, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted, _ := NewSigned(trustedPriv, nil, 5000, nil, "", 0)
+	payload, _ := json.Marshal(AnnouncePayload{Peer: PeerInfo{NodeID: trusted.nodeID}})
+	msg := trusted.newMessage(MsgAnnounce, payload)
+	if !d.verify(msg) {
+		t.Error("message signed by a trusted root should verify even though NodeID differs from d's own")
+	}
+}
+
+func TestBucketKey(t *testing.T) {
+	a := bucketKey(net.ParseIP("10.0.1.5"))
+	b := bucketKey(net.ParseIP("10.0.1.200"))
+	c := bucketKey(net.ParseIP("10.0.2.5"))
+	if a != b {
+		t.Errorf("addresses in the same /24 should share a bucket: %s != %s", a, b)
+	}
+	if a == c {
+		t.Errorf("addresses in different /24s should not share a bucket: %s == %s", a, c)
+	}
+}
+
+func TestHandleAnnounce_CapsPeersPerBucket(t *testing.T) {
+	d := New("self", 5000, nil, "", 0)
+
+	for i := 0; i < MaxPeersPerBucket+2; i++ {
+		peer := PeerInfo{NodeID: fmt.Sprintf("peer-%d", i)}
+		payload, _ := json.Marshal(AnnouncePayload{Peer: peer})
+		msg := Message{Type: MsgAnnounce, Sender: peer.NodeID, Payload: payload, TS: time.Now().UnixMilli()}
+		data, _ := json.Marshal(msg)
+		d.handleMessage(data, &net.UDPAddr{IP: net.ParseIP(fmt.Sprintf("192.168.1.%d", i+1))})
+	}
+
+	if d.PeerCount() != MaxPeersPerBucket {
+		t.Errorf("PeerCount = %d, want %d (bucket cap)", d.PeerCount(), MaxPeersPerBucket)
+	}
+}
+
+func TestHandleAnnounce_PropagatesAdvertisedRoutes(t *testing.T) {
+	d := New("self", 5000, nil, "", 0)
+	addr := &net.UDPAddr{IP: net.ParseIP("192.168.3.1")}
+
+	prefix := netip.MustParsePrefix("10.0.0.0/24")
+	peer := PeerInfo{NodeID: "peer-router", AdvertisedRoutes: []netip.Prefix{prefix}, RoutePriority: 10}
+	payload, _ := json.Marshal(AnnouncePayload{Peer: peer})
+	msg := Message{Type: MsgAnnounce, Sender: peer.NodeID, Payload: payload, TS: time.Now().UnixMilli()}
+	data, _ := json.Marshal(msg)
+	d.handleMessage(data, addr)
+
+	got := d.GetPeer("peer-router")
+	if got == nil {
+		t.Fatal("peer-router not found")
+	}
+	if len(got.AdvertisedRoutes) != 1 || got.AdvertisedRoutes[0] != prefix {
+		t.Errorf("AdvertisedRoutes = %v, want [%v]", got.AdvertisedRoutes, prefix)
+	}
+	if got.RoutePriority != 10 {
+		t.Errorf("RoutePriority = %d, want 10", got.RoutePriority)
+	}
+}
+
+func TestHandleLeave_FreesBucketSlot(t *testing.T) {
+	d := New("self", 5000, nil, "", 0)
+	addr := &net.UDPAddr{IP: net.ParseIP("192.168.2.1")}
+
+	announce := func(nodeID string) {
+		payload, _ := json.Marshal(AnnouncePayload{Peer: PeerInfo{NodeID: nodeID}})
+		msg := Message{Type: MsgAnnounce, Sender: nodeID, Payload: payload, TS: time.Now().UnixMilli()}
+		data, _ := json.Marshal(msg)
+		d.handleMessage(data, addr)
+	}
+
+	announce("peer-a")
+	leave, _ := json.Marshal(Message{Type: MsgLeave, Sender: "peer-a", Payload: json.RawMessage("{}"), TS: time.Now().UnixMilli()})
+	d.handleMessage(leave, addr)
+
+	if _, ok := d.peerBucket["peer-a"]; ok {
+		t.Error("peerBucket entry should be freed when a peer leaves")
+	}
+	if d.bucketCount[bucketKey(addr.IP)] != 0 {
+		t.Error("bucketCount should drop back to 0 after the only peer in it leaves")
+	}
+}
@@ -0,0 +1,130 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"testing"
+)
+
+// recordingHandler collects every record it's handed, ignoring level
+// filtering entirely — logAt is what's responsible for level gating, so
+// the handler here just needs to observe what got through.
+type recordingHandler struct {
+	records *[]slog.Record
+}
+
+func (h recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	*h.records = append(*h.records, r)
+	return nil
+}
+func (h recordingHandler) WithAttrs(attrs []slog.Attr) slog.Handler { return h }
+func (h recordingHandler) WithGroup(name string) slog.Handler      { return h }
+
+func attrValue(r slog.Record, key string) (string, bool) {
+	var val string
+	var found bool
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == key {
+			val = a.Value.String()
+			found = true
+			return false
+		}
+		return true
+	})
+	return val, found
+}
+
+func TestLogAt_DefaultsToInfoThreshold(t *testing.T) {
+	var records []slog.Record
+	d := New("node-1", 5000, nil, "", 0)
+	d.SetLogHandler(recordingHandler{&records})
+
+	d.logAt(subsystemListen, LevelTrace, "should be dropped")
+	if len(records) != 0 {
+		t.Fatalf("Trace log emitted with no LogLevel override, got %d records", len(records))
+	}
+
+	d.logAt(subsystemListen, slog.LevelInfo, "should pass")
+	if len(records) != 1 {
+		t.Fatalf("Info log was dropped, got %d records", len(records))
+	}
+}
+
+func TestLogAt_PerSubsystemOverride(t *testing.T) {
+	var records []slog.Record
+	d := New("node-1", 5000, nil, "", 0)
+	d.SetLogHandler(recordingHandler{&records})
+	d.LogLevel = map[string]slog.Level{subsystemListen: LevelTrace}
+
+	d.logAt(subsystemListen, LevelTrace, "listen trace")
+	d.logAt(subsystemAnnounce, LevelTrace, "announce trace")
+
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1 (only listen's override should pass)", len(records))
+	}
+	if records[0].Message != "listen trace" {
+		t.Errorf("message = %q, want %q", records[0].Message, "listen trace")
+	}
+}
+
+func TestHandleMessage_LogsDropReasons(t *testing.T) {
+	var records []slog.Record
+	d := New("self-node", 5000, nil, "", 0)
+	d.SetLogHandler(recordingHandler{&records})
+	d.LogLevel = map[string]slog.Level{subsystemListen: LevelTrace}
+
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000}
+
+	d.handleMessage([]byte("not json"), addr)
+	d.handleMessage([]byte(`{"type":99,"sender":"other"}`), addr)
+	d.handleMessage([]byte(`{"type":1,"sender":"self-node"}`), addr)
+	d.handleMessage(make([]byte, MaxMessageSize), addr)
+
+	wantReasons := []string{"unparseable", "unknown_type", "self", "oversize"}
+	if len(records) != len(wantReasons) {
+		t.Fatalf("got %d drop logs, want %d", len(records), len(wantReasons))
+	}
+	for i, want := range wantReasons {
+		got, ok := attrValue(records[i], "reason")
+		if !ok || got != want {
+			t.Errorf("record %d: reason = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestHandleAnnounce_BindsPeerLogger(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+
+	// newMessage stamps the sender as the originating Discovery's own
+	// nodeID, and handleMessage drops anything from "self" (see
+	// TestHandleMessage_LogsDropReasons), so the announce has to come
+	// from a distinct instance rather than d itself.
+	sender := New("peer-1", 5001, nil, "", 0)
+	peer := PeerInfo{NodeID: "peer-1"}
+	payload, _ := json.Marshal(AnnouncePayload{Peer: peer})
+	msg := sender.newMessage(MsgAnnounce, payload)
+	addr := &net.UDPAddr{IP: net.ParseIP("10.0.0.5"), Port: 5000}
+
+	d.handleMessage(mustMarshal(t, msg), addr)
+
+	got := d.GetPeer("peer-1")
+	if got == nil {
+		t.Fatal("peer was not recorded")
+	}
+	stored := d.peers["peer-1"]
+	if stored.Logger == nil {
+		t.Fatal("expected a bound per-peer Logger after first discovery")
+	}
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,314 @@
+package discovery
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// stunServers are tried in order as a last-resort way to learn this
+// node's server-reflexive address when no UPnP/NAT-PMP gateway responds.
+var stunServers = []string{"stun.l.google.com:19302", "stun1.l.google.com:19302"}
+
+// reflexiveAddr attempts to learn this node's publicly routable address
+// for servicePort, trying UPnP-IGD port mapping, then NAT-PMP, then a
+// STUN binding request, in that order. ok is false if none of them
+// succeed, in which case the caller should keep using the LAN address
+// from getLocalIP.
+func reflexiveAddr(servicePort int) (ip string, ok bool) {
+	if ip, ok := tryUPnP(servicePort); ok {
+		return ip, true
+	}
+	if ip, ok := tryNATPMP(servicePort); ok {
+		return ip, true
+	}
+	return tryStun()
+}
+
+// --- UPnP-IGD ---
+
+var controlURLRe = regexp.MustCompile(`(?s)<serviceType>urn:schemas-upnp-org:service:WAN(?:IP|PPP)Connection:\d</serviceType>.*?<controlURL>([^<]+)</controlURL>`)
+var externalIPRe = regexp.MustCompile(`<NewExternalIPAddress>([^<]+)</NewExternalIPAddress>`)
+
+const wanIPServiceType = "urn:schemas-upnp-org:service:WANIPConnection:1"
+
+// tryUPnP asks the LAN's UPnP Internet Gateway Device to map servicePort
+// and reports the gateway's external IP on success. Best-effort: any
+// failure along the way (no gateway, malformed description, SOAP error)
+// just falls through to the next traversal method.
+func tryUPnP(servicePort int) (string, bool) {
+	loc, ok := ssdpDiscover(800 * time.Millisecond)
+	if !ok {
+		return "", false
+	}
+	controlURL, ok := fetchControlURL(loc)
+	if !ok {
+		return "", false
+	}
+	extIP, ok := soapGetExternalIP(controlURL)
+	if !ok {
+		return "", false
+	}
+	soapAddPortMapping(controlURL, servicePort) // best-effort, ignore failure
+	return extIP, true
+}
+
+// ssdpDiscover sends an SSDP M-SEARCH for an InternetGatewayDevice and
+// returns the LOCATION URL of the first reply received within timeout.
+func ssdpDiscover(timeout time.Duration) (string, bool) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", false
+	}
+	defer conn.Close()
+
+	req := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 1\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n\r\n"
+	dst := &net.UDPAddr{IP: net.ParseIP("239.255.255.250"), Port: 1900}
+	if _, err := conn.WriteToUDP([]byte(req), dst); err != nil {
+		return "", false
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		return "", false
+	}
+
+	for _, line := range strings.Split(string(buf[:n]), "\r\n") {
+		if len(line) > len("location:") && strings.EqualFold(line[:len("location:")], "location:") {
+			return strings.TrimSpace(line[len("location:"):]), true
+		}
+	}
+	return "", false
+}
+
+// fetchControlURL downloads the IGD device description at descURL and
+// extracts the WANIPConnection/WANPPPConnection controlURL from it.
+func fetchControlURL(descURL string) (string, bool) {
+	client := &http.Client{Timeout: 1500 * time.Millisecond}
+	resp, err := client.Get(descURL)
+	if err != nil {
+		return "", false
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", false
+	}
+	m := controlURLRe.FindSubmatch(body)
+	if m == nil {
+		return "", false
+	}
+	path := string(m[1])
+	if strings.HasPrefix(path, "http") {
+		return path, true
+	}
+
+	base, err := url.Parse(descURL)
+	if err != nil {
+		return "", false
+	}
+	base.Path = ""
+	base.RawQuery = ""
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return base.String() + path, true
+}
+
+// soapCall POSTs a SOAP request for action to controlURL and returns the
+// raw response body on an HTTP 200.
+func soapCall(controlURL, action, serviceType, body string) (string, bool) {
+	envelope := fmt.Sprintf(`<?xml version="1.0"?>`+
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">`+
+		`<s:Body><u:%s xmlns:u="%s">%s</u:%s></s:Body></s:Envelope>`, action, serviceType, body, action)
+
+	req, err := http.NewRequest(http.MethodPost, controlURL, strings.NewReader(envelope))
+	if err != nil {
+		return "", false
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPACTION", fmt.Sprintf(`"%s#%s"`, serviceType, action))
+
+	client := &http.Client{Timeout: 1500 * time.Millisecond}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false
+	}
+	defer resp.Body.Close()
+
+	out, err := io.ReadAll(io.LimitReader(resp.Body, 8*1024))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return "", false
+	}
+	return string(out), true
+}
+
+func soapGetExternalIP(controlURL string) (string, bool) {
+	out, ok := soapCall(controlURL, "GetExternalIPAddress", wanIPServiceType, "")
+	if !ok {
+		return "", false
+	}
+	m := externalIPRe.FindStringSubmatch(out)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+func soapAddPortMapping(controlURL string, port int) {
+	body := fmt.Sprintf(
+		`<NewRemoteHost></NewRemoteHost><NewExternalPort>%d</NewExternalPort><NewProtocol>UDP</NewProtocol>`+
+			`<NewInternalPort>%d</NewInternalPort><NewInternalClient>%s</NewInternalClient><NewEnabled>1</NewEnabled>`+
+			`<NewPortMappingDescription>x0tta6bl4 mesh</NewPortMappingDescription><NewLeaseDuration>0</NewLeaseDuration>`,
+		port, port, getLocalIP())
+	soapCall(controlURL, "AddPortMapping", wanIPServiceType, body)
+}
+
+// --- NAT-PMP ---
+
+// tryNATPMP speaks a minimal NAT-PMP (RFC 6886) client to the LAN default
+// gateway, assumed to be the ".1" host on this node's local /24 since
+// Go's net package exposes no portable way to read the OS route table.
+func tryNATPMP(servicePort int) (string, bool) {
+	gw := defaultGatewayGuess()
+	if gw == "" {
+		return "", false
+	}
+
+	conn, err := net.DialTimeout("udp4", net.JoinHostPort(gw, "5351"), 500*time.Millisecond)
+	if err != nil {
+		return "", false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(800 * time.Millisecond))
+
+	// Opcode 0: public address request.
+	if _, err := conn.Write([]byte{0, 0}); err != nil {
+		return "", false
+	}
+	resp := make([]byte, 12)
+	if _, err := io.ReadFull(conn, resp); err != nil || resp[1] != 128 {
+		return "", false
+	}
+	extIP := net.IPv4(resp[8], resp[9], resp[10], resp[11]).String()
+
+	// Opcode 1: UDP port mapping request, best-effort (ignore the reply).
+	req := make([]byte, 12)
+	req[1] = 1
+	binary.BigEndian.PutUint16(req[4:6], uint16(servicePort))
+	binary.BigEndian.PutUint16(req[6:8], uint16(servicePort))
+	binary.BigEndian.PutUint32(req[8:12], 3600)
+	conn.Write(req)
+
+	return extIP, true
+}
+
+func defaultGatewayGuess() string {
+	conn, err := net.Dial("udp", "8.8.8.8:80")
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	local := conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	if local == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d.1", local[0], local[1], local[2])
+}
+
+// --- STUN ---
+
+const stunMagicCookie = 0x2112A442
+
+// tryStun learns this node's server-reflexive address via a single
+// unauthenticated STUN (RFC 5389) Binding request, tried against each of
+// stunServers in turn.
+func tryStun() (string, bool) {
+	for _, server := range stunServers {
+		if ip, ok := stunBindingRequest(server); ok {
+			return ip, true
+		}
+	}
+	return "", false
+}
+
+func stunBindingRequest(server string) (string, bool) {
+	conn, err := net.DialTimeout("udp4", server, 800*time.Millisecond)
+	if err != nil {
+		return "", false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(800 * time.Millisecond))
+
+	txID := make([]byte, 12)
+	rand.Read(txID)
+
+	req := make([]byte, 20)
+	binary.BigEndian.PutUint16(req[0:2], 0x0001) // Binding Request
+	binary.BigEndian.PutUint16(req[2:4], 0)      // message length
+	binary.BigEndian.PutUint32(req[4:8], stunMagicCookie)
+	copy(req[8:20], txID)
+
+	if _, err := conn.Write(req); err != nil {
+		return "", false
+	}
+
+	resp := make([]byte, 512)
+	n, err := conn.Read(resp)
+	if err != nil || n < 20 {
+		return "", false
+	}
+	return parseXorMappedAddress(resp[:n])
+}
+
+// parseXorMappedAddress extracts the IPv4 address from a STUN Binding
+// response's XOR-MAPPED-ADDRESS attribute (RFC 5389 §15.2).
+func parseXorMappedAddress(msg []byte) (string, bool) {
+	length := int(binary.BigEndian.Uint16(msg[2:4]))
+	if 20+length > len(msg) {
+		return "", false
+	}
+	attrs := msg[20 : 20+length]
+
+	cookie := make([]byte, 4)
+	binary.BigEndian.PutUint32(cookie, stunMagicCookie)
+
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+attrLen > len(attrs) {
+			break
+		}
+		val := attrs[4 : 4+attrLen]
+
+		if attrType == 0x0020 && attrLen >= 8 { // XOR-MAPPED-ADDRESS
+			family := val[1]
+			if family != 0x01 { // IPv4 only
+				return "", false
+			}
+			var ip [4]byte
+			for i := 0; i < 4; i++ {
+				ip[i] = val[4+i] ^ cookie[i]
+			}
+			return net.IP(ip[:]).String(), true
+		}
+
+		pad := (4 - attrLen%4) % 4 // attributes are padded to 4-byte boundaries
+		attrs = attrs[4+attrLen+pad:]
+	}
+	return "", false
+}
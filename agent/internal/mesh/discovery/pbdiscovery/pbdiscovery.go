@@ -0,0 +1,27 @@
+// Package pbdiscovery holds the wire messages exchanged between two
+// federated meshes over a peering stream (see
+// discovery.Discovery.EstablishPeering). They mirror what a generated
+// protobuf package would expose, but this build has no protoc step wired
+// in, so they're hand-maintained plain Go structs serialized as
+// newline-delimited JSON — the same compromise package discovery already
+// makes for its own multicast wire format.
+package pbdiscovery
+
+// PeerInfo is one peer in a cross-mesh snapshot, translated from
+// discovery.PeerInfo: addresses are flattened to "host:port" strings
+// since the tuple-of-any encoding discovery.PeerInfo uses for Python
+// wire-compat has no meaning to a remote mesh.
+type PeerInfo struct {
+	NodeID    string   `json:"node_id"`
+	Addresses []string `json:"addresses"`
+	Services  []string `json:"services"`
+}
+
+// Snapshot is what each side of a peering stream sends, repeatedly, for
+// as long as the stream is up: "here is everything I currently know
+// about my mesh."
+type Snapshot struct {
+	MeshID string     `json:"mesh_id"`
+	NodeID string     `json:"node_id"`
+	Peers  []PeerInfo `json:"peers"`
+}
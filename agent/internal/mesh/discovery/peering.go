@@ -0,0 +1,296 @@
+package discovery
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/x0tta6bl4/agent/internal/mesh/discovery/pbdiscovery"
+)
+
+// PeeringSnapshotInterval is how often an established peering stream
+// re-sends this node's GetPeers() snapshot to the remote mesh.
+const PeeringSnapshotInterval = 15 * time.Second
+
+// peeringToken is the payload signed into a bearer token minted by
+// GeneratePeeringToken, modeled on Consul's peer-replication design: it
+// carries everything the remote mesh needs to dial back in (our node
+// identity and reachable addresses) without either side joining the
+// other's multicast group.
+type peeringToken struct {
+	MeshID    string   `json:"mesh_id"`
+	NodeID    string   `json:"node_id"`
+	Addresses []string `json:"addresses"`
+	IssuedAt  int64    `json:"issued_at"`
+}
+
+// GeneratePeeringToken mints a signed bearer token for meshID carrying
+// this node's public key (its NodeID), its PeeringListenAddr, and the
+// mesh identifier the remote should record the federation under. It
+// requires a signed Discovery (see NewSigned): the remote verifies the
+// token against the pubkey its own NodeID claims, so an unsigned
+// Discovery has no key to trust the token with.
+func (d *Discovery) GeneratePeeringToken(meshID string) (string, error) {
+	if d.signer == nil {
+		return "", fmt.Errorf("discovery: GeneratePeeringToken requires a signed Discovery (see NewSigned)")
+	}
+	if d.PeeringListenAddr == "" {
+		return "", fmt.Errorf("discovery: GeneratePeeringToken requires PeeringListenAddr to be set")
+	}
+
+	payload, err := json.Marshal(peeringToken{
+		MeshID:    meshID,
+		NodeID:    d.nodeID,
+		Addresses: []string{d.PeeringListenAddr},
+		IssuedAt:  time.Now().Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := d.signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	if err != nil {
+		return "", fmt.Errorf("sign peering token: %w", err)
+	}
+
+	envelope := struct {
+		Payload   []byte `json:"payload"`
+		Signature []byte `json:"signature"`
+	}{Payload: payload, Signature: sig}
+
+	raw, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(raw), nil
+}
+
+// parsePeeringToken decodes a token minted by GeneratePeeringToken and
+// verifies it against the pubkey its own embedded NodeID claims.
+func parsePeeringToken(token string) (peeringToken, error) {
+	raw, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return peeringToken{}, fmt.Errorf("decode peering token: %w", err)
+	}
+
+	var envelope struct {
+		Payload   []byte `json:"payload"`
+		Signature []byte `json:"signature"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return peeringToken{}, fmt.Errorf("unmarshal peering token: %w", err)
+	}
+
+	var tok peeringToken
+	if err := json.Unmarshal(envelope.Payload, &tok); err != nil {
+		return peeringToken{}, fmt.Errorf("unmarshal peering claims: %w", err)
+	}
+
+	pub, ok := PubKeyFromNodeID(tok.NodeID)
+	if !ok {
+		return peeringToken{}, fmt.Errorf("peering token: invalid node id %q", tok.NodeID)
+	}
+	if !ed25519.Verify(pub, envelope.Payload, envelope.Signature) {
+		return peeringToken{}, fmt.Errorf("peering token: signature verification failed")
+	}
+	return tok, nil
+}
+
+// EstablishPeering dials the remote mesh node identified by token and
+// starts a long-lived, bidirectional snapshot exchange: this node's
+// GetPeers() is sent to the remote every PeeringSnapshotInterval, and
+// whatever the remote sends back is merged into federatedPeers. The
+// exchange runs in a background goroutine; EstablishPeering returns once
+// the connection is up, not once it closes.
+func (d *Discovery) EstablishPeering(token string) error {
+	tok, err := parsePeeringToken(token)
+	if err != nil {
+		return err
+	}
+	if len(tok.Addresses) == 0 {
+		return fmt.Errorf("discovery: peering token for mesh %q carries no addresses", tok.MeshID)
+	}
+
+	var conn net.Conn
+	var dialErr error
+	for _, addr := range tok.Addresses {
+		conn, dialErr = net.DialTimeout("tcp", addr, 5*time.Second)
+		if dialErr == nil {
+			break
+		}
+	}
+	if dialErr != nil {
+		return fmt.Errorf("discovery: dial peering addresses for mesh %q: %w", tok.MeshID, dialErr)
+	}
+
+	go d.runPeeringStream(conn, tok.MeshID)
+	return nil
+}
+
+// acceptPeeringLoop runs the accept loop for inbound peering connections
+// on ln, started by Start when PeeringListenAddr is set.
+func (d *Discovery) acceptPeeringLoop(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		// The remote mesh ID isn't known yet for an inbound connection;
+		// it's learned from the remote's first snapshot in runPeeringStream.
+		go d.runPeeringStream(conn, "")
+	}
+}
+
+// runPeeringStream drives one peering connection in both directions: a
+// sender goroutine pushes this node's snapshot every
+// PeeringSnapshotInterval, while the calling goroutine reads whatever the
+// remote sends and merges it into federatedPeers. An inbound stream
+// (empty meshID) takes the mesh ID from the remote's first snapshot and
+// starts its sender only then, so exports are filtered for that mesh.
+func (d *Discovery) runPeeringStream(conn net.Conn, meshID string) {
+	defer conn.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	sending := meshID != ""
+	if sending {
+		go d.sendPeeringSnapshots(conn, meshID, stop)
+	}
+
+	dec := json.NewDecoder(conn)
+	for {
+		var snap pbdiscovery.Snapshot
+		if err := dec.Decode(&snap); err != nil {
+			d.logger.Warn("peering stream closed", "mesh_id", meshID, "err", err)
+			return
+		}
+		if !sending {
+			meshID, sending = snap.MeshID, true
+			go d.sendPeeringSnapshots(conn, meshID, stop)
+		}
+		d.mergeFederatedSnapshot(snap)
+	}
+}
+
+func (d *Discovery) sendPeeringSnapshots(conn net.Conn, meshID string, stop <-chan struct{}) {
+	enc := json.NewEncoder(conn)
+	send := func() bool {
+		peers := d.GetPeers()
+		pbPeers := make([]pbdiscovery.PeerInfo, 0, len(peers))
+		for _, p := range peers {
+			pbPeers = append(pbPeers, translatePeerInfo(p))
+		}
+		pbPeers = d.filterPeersForExport(meshID, pbPeers)
+		return enc.Encode(pbdiscovery.Snapshot{MeshID: meshID, NodeID: d.nodeID, Peers: pbPeers}) == nil
+	}
+
+	if !send() {
+		return
+	}
+	ticker := time.NewTicker(PeeringSnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !send() {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// SetExportedServices restricts which locally discovered services this
+// node replicates to meshID's peering stream to just those named in
+// services: each outgoing snapshot drops any peer whose Services have no
+// overlap with the list, and narrows the rest down to the intersection.
+// A meshID with no call to SetExportedServices (or a nil services list)
+// exports everything, unchanged — the default for peerings nobody has
+// restricted. Pass an empty, non-nil slice to export nothing.
+func (d *Discovery) SetExportedServices(meshID string, services []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.exportedServices[meshID] = services
+}
+
+// filterPeersForExport narrows pbPeers down to d.exportedServices[meshID]
+// before a snapshot is sent out over a peering stream for meshID. No
+// configured allow-list for meshID exports pbPeers unchanged.
+func (d *Discovery) filterPeersForExport(meshID string, pbPeers []pbdiscovery.PeerInfo) []pbdiscovery.PeerInfo {
+	d.mu.RLock()
+	allowed, ok := d.exportedServices[meshID]
+	d.mu.RUnlock()
+	if !ok {
+		return pbPeers
+	}
+
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, svc := range allowed {
+		allowedSet[svc] = true
+	}
+
+	out := make([]pbdiscovery.PeerInfo, 0, len(pbPeers))
+	for _, p := range pbPeers {
+		var kept []string
+		for _, svc := range p.Services {
+			if allowedSet[svc] {
+				kept = append(kept, svc)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		p.Services = kept
+		out = append(out, p)
+	}
+	return out
+}
+
+// translatePeerInfo flattens an internal PeerInfo's Python-wire-format
+// [[ip, port], ...] addresses into the plain "host:port" strings the
+// cross-mesh peering wire format uses.
+func translatePeerInfo(p PeerInfo) pbdiscovery.PeerInfo {
+	addrs := make([]string, 0, len(p.Addresses))
+	for _, a := range p.Addresses {
+		if len(a) >= 2 {
+			addrs = append(addrs, fmt.Sprintf("%v:%v", a[0], a[1]))
+		}
+	}
+	return pbdiscovery.PeerInfo{NodeID: p.NodeID, Addresses: addrs, Services: p.Services}
+}
+
+// federatedPeerKey keys federatedPeers by (meshID, nodeID) so peers from
+// different federated meshes never collide even if they reuse NodeIDs.
+func federatedPeerKey(meshID, nodeID string) string {
+	return meshID + "/" + nodeID
+}
+
+// mergeFederatedSnapshot records a remote mesh's peer snapshot into
+// federatedPeers.
+func (d *Discovery) mergeFederatedSnapshot(snap pbdiscovery.Snapshot) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range snap.Peers {
+		p := snap.Peers[i]
+		d.federatedPeers[federatedPeerKey(snap.MeshID, p.NodeID)] = &p
+	}
+}
+
+// GetFederatedPeers returns all peers learned from federated (peered)
+// meshes, as opposed to GetPeers which only returns this mesh's own
+// locally-discovered peers.
+func (d *Discovery) GetFederatedPeers() []pbdiscovery.PeerInfo {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	out := make([]pbdiscovery.PeerInfo, 0, len(d.federatedPeers))
+	for _, p := range d.federatedPeers {
+		out = append(out, *p)
+	}
+	return out
+}
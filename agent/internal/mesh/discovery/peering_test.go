@@ -0,0 +1,146 @@
+package discovery
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/x0tta6bl4/agent/internal/mesh/discovery/pbdiscovery"
+)
+
+func newSignedForTest(t *testing.T, peeringAddr string) *Discovery {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	d, err := NewSigned(priv, nil, 5000, nil, "", 0)
+	if err != nil {
+		t.Fatalf("NewSigned: %v", err)
+	}
+	d.PeeringListenAddr = peeringAddr
+	return d
+}
+
+func TestGeneratePeeringToken_RequiresSigner(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	d.PeeringListenAddr = "127.0.0.1:9000"
+	if _, err := d.GeneratePeeringToken("mesh-b"); err == nil {
+		t.Fatal("expected error for unsigned Discovery")
+	}
+}
+
+func TestGeneratePeeringToken_RequiresListenAddr(t *testing.T) {
+	d := newSignedForTest(t, "")
+	if _, err := d.GeneratePeeringToken("mesh-b"); err == nil {
+		t.Fatal("expected error when PeeringListenAddr is unset")
+	}
+}
+
+func TestGeneratePeeringToken_RoundTrip(t *testing.T) {
+	d := newSignedForTest(t, "127.0.0.1:9000")
+
+	token, err := d.GeneratePeeringToken("mesh-b")
+	if err != nil {
+		t.Fatalf("GeneratePeeringToken: %v", err)
+	}
+
+	tok, err := parsePeeringToken(token)
+	if err != nil {
+		t.Fatalf("parsePeeringToken: %v", err)
+	}
+	if tok.MeshID != "mesh-b" {
+		t.Errorf("MeshID = %s, want mesh-b", tok.MeshID)
+	}
+	if tok.NodeID != d.nodeID {
+		t.Errorf("NodeID = %s, want %s", tok.NodeID, d.nodeID)
+	}
+	if len(tok.Addresses) != 1 || tok.Addresses[0] != "127.0.0.1:9000" {
+		t.Errorf("Addresses = %v, want [127.0.0.1:9000]", tok.Addresses)
+	}
+}
+
+func TestParsePeeringToken_RejectsTampering(t *testing.T) {
+	d := newSignedForTest(t, "127.0.0.1:9000")
+	token, err := d.GeneratePeeringToken("mesh-b")
+	if err != nil {
+		t.Fatalf("GeneratePeeringToken: %v", err)
+	}
+
+	tampered := token[:len(token)-1] + "x"
+	if _, err := parsePeeringToken(tampered); err == nil {
+		t.Fatal("expected error for tampered token")
+	}
+}
+
+func TestMergeFederatedSnapshot_KeyedByMeshAndNode(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+
+	d.mergeFederatedSnapshot(pbdiscovery.Snapshot{
+		MeshID: "mesh-b",
+		Peers: []pbdiscovery.PeerInfo{
+			{NodeID: "peer-1", Addresses: []string{"10.0.0.1:5000"}},
+		},
+	})
+	d.mergeFederatedSnapshot(pbdiscovery.Snapshot{
+		MeshID: "mesh-c",
+		Peers: []pbdiscovery.PeerInfo{
+			{NodeID: "peer-1", Addresses: []string{"10.0.0.2:5000"}},
+		},
+	})
+
+	peers := d.GetFederatedPeers()
+	if len(peers) != 2 {
+		t.Fatalf("GetFederatedPeers = %d peers, want 2", len(peers))
+	}
+}
+
+func TestFilterPeersForExport_NoAllowListExportsEverything(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	peers := []pbdiscovery.PeerInfo{
+		{NodeID: "peer-1", Services: []string{"mesh", "api"}},
+	}
+	out := d.filterPeersForExport("mesh-b", peers)
+	if len(out) != 1 || len(out[0].Services) != 2 {
+		t.Errorf("filterPeersForExport = %+v, want peers unchanged with no allow-list set", out)
+	}
+}
+
+func TestFilterPeersForExport_NarrowsToAllowList(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	d.SetExportedServices("mesh-b", []string{"api"})
+
+	peers := []pbdiscovery.PeerInfo{
+		{NodeID: "peer-1", Services: []string{"mesh", "api"}},
+		{NodeID: "peer-2", Services: []string{"mesh"}},
+	}
+	out := d.filterPeersForExport("mesh-b", peers)
+	if len(out) != 1 {
+		t.Fatalf("filterPeersForExport = %d peers, want 1 (peer-2 has no exported services)", len(out))
+	}
+	if out[0].NodeID != "peer-1" || len(out[0].Services) != 1 || out[0].Services[0] != "api" {
+		t.Errorf("filterPeersForExport = %+v, want peer-1 narrowed to [api]", out[0])
+	}
+}
+
+func TestFilterPeersForExport_IsolatedByMeshID(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	d.SetExportedServices("mesh-b", []string{"api"})
+
+	peers := []pbdiscovery.PeerInfo{{NodeID: "peer-1", Services: []string{"mesh"}}}
+	out := d.filterPeersForExport("mesh-c", peers)
+	if len(out) != 1 {
+		t.Errorf("filterPeersForExport for mesh-c = %d peers, want 1 (allow-list is scoped to mesh-b)", len(out))
+	}
+}
+
+func TestTranslatePeerInfo_FlattensAddresses(t *testing.T) {
+	p := PeerInfo{
+		NodeID:    "node-1",
+		Addresses: [][]any{{"10.0.0.1", float64(5000)}},
+		Services:  []string{"mesh"},
+	}
+	out := translatePeerInfo(p)
+	if len(out.Addresses) != 1 || out.Addresses[0] != "10.0.0.1:5000" {
+		t.Errorf("Addresses = %v, want [10.0.0.1:5000]", out.Addresses)
+	}
+}
@@ -0,0 +1,121 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEwmaRTT_SeedsFromFirstSample(t *testing.T) {
+	if got := ewmaRTT(0, 42); got != 42 {
+		t.Errorf("ewmaRTT(0, 42) = %v, want 42", got)
+	}
+}
+
+func TestEwmaRTT_SmoothsTowardSample(t *testing.T) {
+	got := ewmaRTT(100, 200)
+	if got <= 100 || got >= 200 {
+		t.Errorf("ewmaRTT(100, 200) = %v, want strictly between 100 and 200", got)
+	}
+}
+
+func TestPickRandomSubset_BoundsFanout(t *testing.T) {
+	ids := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	got := pickRandomSubset(ids, 3)
+	if len(got) != 3 {
+		t.Fatalf("len(got) = %d, want 3", len(got))
+	}
+	seen := make(map[string]bool)
+	for _, id := range got {
+		seen[id] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("pickRandomSubset returned duplicates: %v", got)
+	}
+}
+
+func TestPickRandomSubset_ReturnsAllWhenFewerThanN(t *testing.T) {
+	ids := []string{"a", "b"}
+	got := pickRandomSubset(ids, 5)
+	if len(got) != 2 {
+		t.Errorf("len(got) = %d, want 2", len(got))
+	}
+}
+
+func TestHandlePong_UpdatesRTTAndClearsFailures(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	d.peers["peer-1"] = &PeerInfo{NodeID: "peer-1", LastSeen: time.Now()}
+	d.pendingProbes["peer-1"] = time.Now().Add(-10 * time.Millisecond)
+	d.probeFailures["peer-1"] = 2
+
+	d.handlePong(Message{Sender: "peer-1", Payload: mustMarshal(t, PingPayload{})})
+
+	if d.peers["peer-1"].RTTMS <= 0 {
+		t.Errorf("RTTMS = %v, want > 0", d.peers["peer-1"].RTTMS)
+	}
+	if _, pending := d.pendingProbes["peer-1"]; pending {
+		t.Error("pendingProbes should be cleared on a matching pong")
+	}
+	if _, failed := d.probeFailures["peer-1"]; failed {
+		t.Error("probeFailures should be cleared on a matching pong")
+	}
+}
+
+func TestHandlePong_IgnoresUnsolicited(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	d.peers["peer-1"] = &PeerInfo{NodeID: "peer-1", LastSeen: time.Now()}
+
+	d.handlePong(Message{Sender: "peer-1", Payload: mustMarshal(t, PingPayload{})})
+
+	if d.peers["peer-1"].RTTMS != 0 {
+		t.Errorf("RTTMS = %v, want 0 for an unsolicited pong", d.peers["peer-1"].RTTMS)
+	}
+}
+
+func TestRunProbeRound_EvictsAfterFailureLimit(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	d.peers["peer-1"] = &PeerInfo{NodeID: "peer-1", LastSeen: time.Now()}
+	d.probeFailures["peer-1"] = ProbeFailureLimit - 1
+	d.pendingProbes["peer-1"] = time.Now().Add(-ProbeInterval)
+
+	var lost []PeerInfo
+	d.OnPeerLost = func(p PeerInfo) { lost = append(lost, p) }
+
+	d.runProbeRound()
+
+	if _, ok := d.peers["peer-1"]; ok {
+		t.Error("peer-1 should have been evicted after exhausting ProbeFailureLimit")
+	}
+	if len(lost) != 1 || lost[0].NodeID != "peer-1" {
+		t.Errorf("OnPeerLost = %v, want one callback for peer-1", lost)
+	}
+}
+
+func TestCurrentAnnounceInterval_BacksOffWhenStable(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	d.lastChurnAt = time.Now().Add(-2 * ChurnQuietPeriod)
+
+	got := d.currentAnnounceInterval()
+	if got != PeerTimeout/3 {
+		t.Errorf("currentAnnounceInterval() = %v, want %v", got, PeerTimeout/3)
+	}
+}
+
+func TestCurrentAnnounceInterval_StaysDefaultDuringChurn(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+
+	got := d.currentAnnounceInterval()
+	if got != AnnounceInterval {
+		t.Errorf("currentAnnounceInterval() = %v, want %v", got, AnnounceInterval)
+	}
+}
+
+func TestMarkChurn_ResetsBackedOffInterval(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	d.announceInterval = PeerTimeout / 3
+
+	d.markChurn()
+
+	if d.announceInterval != AnnounceInterval {
+		t.Errorf("announceInterval = %v, want %v after markChurn", d.announceInterval, AnnounceInterval)
+	}
+}
@@ -0,0 +1,71 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Transport abstracts how Discovery exchanges wire messages with the
+// outside world. The default, opened by newMulticastTransport, is just
+// the LAN multicast socket Start always used before; bootstrap seed
+// queries and NAT traversal (see bootnode.go and nat.go) plug in
+// additional ways to reach peers without requiring 239.255.77.77 to be
+// routable, e.g. across cloud regions or inside containers.
+type Transport interface {
+	// Send writes data to dst, or to the transport's own default
+	// destination (e.g. the multicast group) when dst is nil.
+	Send(data []byte, dst *net.UDPAddr) error
+	// Recv blocks, up to its own internal read deadline, for the next
+	// inbound message and returns the sender's address.
+	Recv(buf []byte) (n int, addr *net.UDPAddr, err error)
+	Close() error
+}
+
+// udpTransport is a Transport backed by a single UDP socket. It underlies
+// both the multicast transport and unicast bootstrap seed queries.
+type udpTransport struct {
+	conn       *net.UDPConn
+	defaultDst *net.UDPAddr
+}
+
+func (t *udpTransport) Send(data []byte, dst *net.UDPAddr) error {
+	if dst == nil {
+		dst = t.defaultDst
+	}
+	_, err := t.conn.WriteToUDP(data, dst)
+	return err
+}
+
+func (t *udpTransport) Recv(buf []byte) (int, *net.UDPAddr, error) {
+	t.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+	return t.conn.ReadFromUDP(buf)
+}
+
+func (t *udpTransport) Close() error {
+	return t.conn.Close()
+}
+
+// newMulticastTransport opens the mesh's multicast discovery socket,
+// falling back to a plain unicast UDP socket (e.g. in containers where
+// multicast routing isn't available) when the multicast listen fails.
+// The returned bool reports whether the fallback was used, so Start can
+// decide whether bootstrap seeds are needed to find any peers at all.
+func newMulticastTransport(group string, port int) (*udpTransport, bool, error) {
+	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, false, fmt.Errorf("resolve multicast addr: %w", err)
+	}
+
+	dst := &net.UDPAddr{IP: net.ParseIP(group), Port: port}
+	conn, err := net.ListenMulticastUDP("udp4", nil, dst)
+	if err == nil {
+		return &udpTransport{conn: conn, defaultDst: dst}, false, nil
+	}
+
+	conn2, err2 := net.ListenUDP("udp4", addr)
+	if err2 != nil {
+		return nil, false, fmt.Errorf("listen multicast: %w (fallback: %w)", err, err2)
+	}
+	return &udpTransport{conn: conn2, defaultDst: dst}, true, nil
+}
@@ -0,0 +1,162 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestResolveBootstrapSeeds_StaticHostPort(t *testing.T) {
+	d := New("node-1", 5000, nil, "", 0)
+	d.BootstrapSeeds = []string{"127.0.0.1:9999"}
+
+	addrs := d.resolveBootstrapSeeds()
+	if len(addrs) != 1 {
+		t.Fatalf("len(addrs) = %d, want 1", len(addrs))
+	}
+	if addrs[0].IP.String() != "127.0.0.1" || addrs[0].Port != 9999 {
+		t.Errorf("addr = %v, want 127.0.0.1:9999", addrs[0])
+	}
+}
+
+func TestResolveBootstrapSeeds_DefaultsToMcastPort(t *testing.T) {
+	d := New("node-2", 5000, nil, "", 8888)
+	d.BootstrapSeeds = []string{"127.0.0.1"}
+
+	addrs := d.resolveBootstrapSeeds()
+	if len(addrs) != 1 || addrs[0].Port != 8888 {
+		t.Errorf("addrs = %v, want port 8888", addrs)
+	}
+}
+
+func TestResolveBootstrapSeeds_SkipsUnresolvable(t *testing.T) {
+	d := New("node-3", 5000, nil, "", 0)
+	d.BootstrapSeeds = []string{"this-host-does-not-resolve.invalid:9999"}
+
+	if addrs := d.resolveBootstrapSeeds(); len(addrs) != 0 {
+		t.Errorf("len(addrs) = %d, want 0 for an unresolvable seed", len(addrs))
+	}
+}
+
+func TestHandleResponse_AddsPeers(t *testing.T) {
+	d := New("self", 5000, nil, "", 0)
+
+	discovered := []string{}
+	d.OnPeerDiscovered = func(p PeerInfo) { discovered = append(discovered, p.NodeID) }
+
+	payload, _ := json.Marshal(ResponsePayload{Peers: []PeerInfo{
+		{NodeID: "peer-a"},
+		{NodeID: "self"}, // must be skipped: that's us
+	}})
+	msg := Message{Type: MsgResponse, Sender: "bootnode", Payload: payload, TS: time.Now().UnixMilli()}
+	data, _ := json.Marshal(msg)
+	d.handleMessage(data, nil)
+
+	if d.PeerCount() != 1 {
+		t.Fatalf("PeerCount = %d, want 1", d.PeerCount())
+	}
+	if d.GetPeer("peer-a") == nil {
+		t.Error("peer-a should have been added from the bootstrap response")
+	}
+	if len(discovered) != 1 || discovered[0] != "peer-a" {
+		t.Errorf("OnPeerDiscovered called with %v, want [peer-a]", discovered)
+	}
+}
+
+func TestHandleResponse_PeersBypassBucketCap(t *testing.T) {
+	d := New("self", 5000, nil, "", 0)
+
+	peers := make([]PeerInfo, 0, MaxPeersPerBucket+2)
+	for i := 0; i < MaxPeersPerBucket+2; i++ {
+		peers = append(peers, PeerInfo{NodeID: fmt.Sprintf("bulk-peer-%d", i)})
+	}
+	payload, _ := json.Marshal(ResponsePayload{Peers: peers})
+	msg := Message{Type: MsgResponse, Sender: "bootnode", Payload: payload, TS: time.Now().UnixMilli()}
+	data, _ := json.Marshal(msg)
+	d.handleMessage(data, nil)
+
+	if d.PeerCount() != len(peers) {
+		t.Errorf("PeerCount = %d, want %d: response peers shouldn't be capped by address bucket", d.PeerCount(), len(peers))
+	}
+}
+
+func TestUDPTransport_SendRecvRoundTrip(t *testing.T) {
+	conn1, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn1.Close()
+	conn2, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+
+	t1 := &udpTransport{conn: conn1}
+	t2 := &udpTransport{conn: conn2}
+
+	if err := t1.Send([]byte("hello"), conn2.LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64)
+	n, addr, err := t2.Recv(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Errorf("Recv = %q, want %q", buf[:n], "hello")
+	}
+	if addr.IP.String() != "127.0.0.1" {
+		t.Errorf("sender addr = %v", addr)
+	}
+}
+
+// stunResponse builds a minimal STUN Binding Success Response carrying an
+// XOR-MAPPED-ADDRESS attribute for ip:port, to exercise
+// parseXorMappedAddress without needing a real STUN server.
+func stunResponse(ip [4]byte, port uint16) []byte {
+	cookie := make([]byte, 4)
+	binary.BigEndian.PutUint32(cookie, stunMagicCookie)
+
+	attr := make([]byte, 8)
+	attr[1] = 0x01 // IPv4
+	binary.BigEndian.PutUint16(attr[2:4], port^uint16(stunMagicCookie>>16))
+	for i := 0; i < 4; i++ {
+		attr[4+i] = ip[i] ^ cookie[i]
+	}
+
+	header := make([]byte, 20)
+	binary.BigEndian.PutUint16(header[0:2], 0x0101) // Binding Success Response
+	binary.BigEndian.PutUint16(header[2:4], uint16(4+len(attr)))
+	binary.BigEndian.PutUint32(header[4:8], stunMagicCookie)
+
+	attrHeader := make([]byte, 4)
+	binary.BigEndian.PutUint16(attrHeader[0:2], 0x0020) // XOR-MAPPED-ADDRESS
+	binary.BigEndian.PutUint16(attrHeader[2:4], uint16(len(attr)))
+
+	msg := append(header, attrHeader...)
+	msg = append(msg, attr...)
+	return msg
+}
+
+func TestParseXorMappedAddress(t *testing.T) {
+	msg := stunResponse([4]byte{203, 0, 113, 42}, 51820)
+
+	ip, ok := parseXorMappedAddress(msg)
+	if !ok {
+		t.Fatal("expected a successful parse")
+	}
+	if ip != "203.0.113.42" {
+		t.Errorf("ip = %s, want 203.0.113.42", ip)
+	}
+}
+
+func TestParseXorMappedAddress_Truncated(t *testing.T) {
+	if _, ok := parseXorMappedAddress(make([]byte, 20)); ok {
+		t.Error("expected failure for a message with no attributes")
+	}
+}
@@ -0,0 +1,197 @@
+package mesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// federationMagic prefixes packets carrying cross-mesh (federated) traffic
+// so the origin mesh ID travels with the payload without disturbing the
+// plain single-mesh wire format.
+const federationMagic = "X0TF"
+
+// federationHeader tags a forwarded payload with the mesh it originated
+// from, so ACLs and stats can be applied per foreign mesh.
+type federationHeader struct {
+	OriginMeshID string `json:"origin_mesh_id"`
+	Service      string `json:"service"`
+}
+
+// PeeringInfo describes a federation with one foreign mesh.
+type PeeringInfo struct {
+	MeshID          string
+	GatewayAddrs    []string
+	AllowedServices []string
+}
+
+// GatewayPeer is a node on a foreign mesh reachable for federated traffic.
+type GatewayPeer struct {
+	Addr     *net.UDPAddr
+	Healthy  bool
+	LastSeen time.Time
+}
+
+// foreignMesh tracks the gateway peers and ACL for one federated mesh.
+type foreignMesh struct {
+	allowedServices map[string]bool
+	gateways        map[string]*GatewayPeer // addr string -> gateway
+}
+
+// Federation maintains gateway peers per foreign mesh and enforces an
+// allow-list on inbound cross-mesh traffic, letting two meshes bridge
+// without merging into one flat mesh.
+type Federation struct {
+	localMeshID string
+
+	mu     sync.RWMutex
+	meshes map[string]*foreignMesh
+}
+
+// NewFederation creates a Federation for the local mesh identified by
+// localMeshID.
+func NewFederation(localMeshID string) *Federation {
+	return &Federation{
+		localMeshID: localMeshID,
+		meshes:      make(map[string]*foreignMesh),
+	}
+}
+
+// AddPeering registers (or replaces) the gateway set and ACL for a foreign
+// mesh.
+func (f *Federation) AddPeering(info PeeringInfo) error {
+	if info.MeshID == "" {
+		return fmt.Errorf("peering: mesh_id required")
+	}
+
+	fm := &foreignMesh{
+		allowedServices: make(map[string]bool, len(info.AllowedServices)),
+		gateways:        make(map[string]*GatewayPeer, len(info.GatewayAddrs)),
+	}
+	for _, svc := range info.AllowedServices {
+		fm.allowedServices[svc] = true
+	}
+	for _, addrStr := range info.GatewayAddrs {
+		addr, err := net.ResolveUDPAddr("udp4", addrStr)
+		if err != nil {
+			continue
+		}
+		fm.gateways[addrStr] = &GatewayPeer{Addr: addr, Healthy: true, LastSeen: time.Now()}
+	}
+
+	f.mu.Lock()
+	f.meshes[info.MeshID] = fm
+	f.mu.Unlock()
+	return nil
+}
+
+// RemovePeering tears down federation with a foreign mesh.
+func (f *Federation) RemovePeering(meshID string) {
+	f.mu.Lock()
+	delete(f.meshes, meshID)
+	f.mu.Unlock()
+}
+
+// WrapForeign tags data as originating from the local mesh before it is
+// sent to a peered mesh's gateway.
+func (f *Federation) WrapForeign(service string, data []byte) ([]byte, error) {
+	hdr := federationHeader{OriginMeshID: f.localMeshID, Service: service}
+	body, err := json.Marshal(hdr)
+	if err != nil {
+		return nil, fmt.Errorf("marshal federation header: %w", err)
+	}
+
+	framed := make([]byte, 0, len(federationMagic)+2+len(body)+len(data))
+	framed = append(framed, federationMagic...)
+	lenBuf := make([]byte, 2)
+	lenBuf[0] = byte(len(body) >> 8)
+	lenBuf[1] = byte(len(body))
+	framed = append(framed, lenBuf...)
+	framed = append(framed, body...)
+	framed = append(framed, data...)
+	return framed, nil
+}
+
+// IsForeign reports whether data carries a federation envelope.
+func IsForeign(data []byte) bool {
+	return len(data) >= len(federationMagic)+2 && string(data[:len(federationMagic)]) == federationMagic
+}
+
+// UnwrapForeign validates a peering exists for the origin mesh and the
+// requested service is allow-listed, returning the inner payload.
+func (f *Federation) UnwrapForeign(data []byte) (originMeshID string, payload []byte, err error) {
+	if !IsForeign(data) {
+		return "", nil, fmt.Errorf("not a federation frame")
+	}
+
+	rest := data[len(federationMagic):]
+	if len(rest) < 2 {
+		return "", nil, fmt.Errorf("federation frame truncated")
+	}
+	hdrLen := int(rest[0])<<8 | int(rest[1])
+	rest = rest[2:]
+	if len(rest) < hdrLen {
+		return "", nil, fmt.Errorf("federation frame truncated")
+	}
+
+	var hdr federationHeader
+	if err := json.Unmarshal(rest[:hdrLen], &hdr); err != nil {
+		return "", nil, fmt.Errorf("invalid federation header: %w", err)
+	}
+
+	f.mu.RLock()
+	fm, ok := f.meshes[hdr.OriginMeshID]
+	f.mu.RUnlock()
+	if !ok {
+		return "", nil, fmt.Errorf("no peering with mesh %s", hdr.OriginMeshID)
+	}
+	if !fm.allowedServices[hdr.Service] {
+		return "", nil, fmt.Errorf("service %q not allow-listed for mesh %s", hdr.Service, hdr.OriginMeshID)
+	}
+
+	return hdr.OriginMeshID, rest[hdrLen:], nil
+}
+
+// PickGateway returns a healthy gateway for the given foreign mesh, if any.
+func (f *Federation) PickGateway(meshID string) (*net.UDPAddr, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	fm, ok := f.meshes[meshID]
+	if !ok {
+		return nil, false
+	}
+	for _, gw := range fm.gateways {
+		if gw.Healthy {
+			return gw.Addr, true
+		}
+	}
+	return nil, false
+}
+
+// Stats reports peering health separately from local peer stats, for
+// Node.GetStats().
+func (f *Federation) Stats() map[string]any {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	peerings := make(map[string]any, len(f.meshes))
+	for meshID, fm := range f.meshes {
+		healthy := 0
+		for _, gw := range fm.gateways {
+			if gw.Healthy {
+				healthy++
+			}
+		}
+		peerings[meshID] = map[string]any{
+			"gateways_total":   len(fm.gateways),
+			"gateways_healthy": healthy,
+		}
+	}
+
+	return map[string]any{
+		"peerings": peerings,
+	}
+}
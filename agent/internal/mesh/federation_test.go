@@ -0,0 +1,121 @@
+package mesh
+
+import "testing"
+
+func TestAddPeering_BuildsGatewaysAndACL(t *testing.T) {
+	f := NewFederation("mesh-a")
+	err := f.AddPeering(PeeringInfo{
+		MeshID:          "mesh-b",
+		GatewayAddrs:    []string{"10.0.0.1:5000", "10.0.0.2:5000"},
+		AllowedServices: []string{"chat"},
+	})
+	if err != nil {
+		t.Fatalf("AddPeering: %v", err)
+	}
+
+	addr, ok := f.PickGateway("mesh-b")
+	if !ok {
+		t.Fatal("expected a healthy gateway for mesh-b")
+	}
+	if addr.Port != 5000 {
+		t.Errorf("gateway port = %d, want 5000", addr.Port)
+	}
+}
+
+func TestAddPeering_RequiresMeshID(t *testing.T) {
+	f := NewFederation("mesh-a")
+	if err := f.AddPeering(PeeringInfo{GatewayAddrs: []string{"10.0.0.1:5000"}}); err == nil {
+		t.Fatal("expected error for missing mesh_id")
+	}
+}
+
+func TestPickGateway_UnknownMesh(t *testing.T) {
+	f := NewFederation("mesh-a")
+	if _, ok := f.PickGateway("mesh-z"); ok {
+		t.Error("expected no gateway for unpeered mesh")
+	}
+}
+
+func TestWrapUnwrapForeign_RoundTrip(t *testing.T) {
+	a := NewFederation("mesh-a")
+	b := NewFederation("mesh-b")
+	if err := b.AddPeering(PeeringInfo{MeshID: "mesh-a", AllowedServices: []string{"chat"}}); err != nil {
+		t.Fatalf("AddPeering: %v", err)
+	}
+
+	framed, err := a.WrapForeign("chat", []byte("hello"))
+	if err != nil {
+		t.Fatalf("WrapForeign: %v", err)
+	}
+	if !IsForeign(framed) {
+		t.Fatal("framed data should be recognized as foreign")
+	}
+
+	origin, payload, err := b.UnwrapForeign(framed)
+	if err != nil {
+		t.Fatalf("UnwrapForeign: %v", err)
+	}
+	if origin != "mesh-a" {
+		t.Errorf("origin = %s, want mesh-a", origin)
+	}
+	if string(payload) != "hello" {
+		t.Errorf("payload = %s, want hello", payload)
+	}
+}
+
+func TestUnwrapForeign_RejectsUnknownMesh(t *testing.T) {
+	a := NewFederation("mesh-a")
+	b := NewFederation("mesh-b") // no peering registered
+
+	framed, _ := a.WrapForeign("chat", []byte("hello"))
+	if _, _, err := b.UnwrapForeign(framed); err == nil {
+		t.Fatal("expected error for unpeered origin mesh")
+	}
+}
+
+func TestUnwrapForeign_RejectsDisallowedService(t *testing.T) {
+	a := NewFederation("mesh-a")
+	b := NewFederation("mesh-b")
+	b.AddPeering(PeeringInfo{MeshID: "mesh-a", AllowedServices: []string{"chat"}})
+
+	framed, _ := a.WrapForeign("admin", []byte("hello"))
+	if _, _, err := b.UnwrapForeign(framed); err == nil {
+		t.Fatal("expected error for service not in allow-list")
+	}
+}
+
+func TestIsForeign_RejectsPlainData(t *testing.T) {
+	if IsForeign([]byte("just some app data")) {
+		t.Error("plain application data should not be detected as foreign")
+	}
+}
+
+func TestRemovePeering(t *testing.T) {
+	f := NewFederation("mesh-a")
+	f.AddPeering(PeeringInfo{MeshID: "mesh-b", GatewayAddrs: []string{"10.0.0.1:5000"}})
+	f.RemovePeering("mesh-b")
+	if _, ok := f.PickGateway("mesh-b"); ok {
+		t.Error("gateway should be gone after RemovePeering")
+	}
+}
+
+func TestFederationStats_ReportsGatewayHealth(t *testing.T) {
+	f := NewFederation("mesh-a")
+	f.AddPeering(PeeringInfo{MeshID: "mesh-b", GatewayAddrs: []string{"10.0.0.1:5000", "10.0.0.2:5000"}})
+
+	stats := f.Stats()
+	peerings, ok := stats["peerings"].(map[string]any)
+	if !ok {
+		t.Fatal("expected peerings map in stats")
+	}
+	meshStats, ok := peerings["mesh-b"].(map[string]any)
+	if !ok {
+		t.Fatal("expected mesh-b entry in peerings")
+	}
+	if meshStats["gateways_total"] != 2 {
+		t.Errorf("gateways_total = %v, want 2", meshStats["gateways_total"])
+	}
+	if meshStats["gateways_healthy"] != 2 {
+		t.Errorf("gateways_healthy = %v, want 2", meshStats["gateways_healthy"])
+	}
+}
@@ -3,16 +3,126 @@
 package mesh
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/x0tta6bl4/agent/internal/mesh/discovery"
+	"github.com/x0tta6bl4/agent/internal/mesh/overlay"
+	"github.com/x0tta6bl4/agent/internal/mesh/relay"
+	"github.com/x0tta6bl4/agent/internal/mesh/routing"
+	subnetrouting "github.com/x0tta6bl4/agent/internal/routing"
+	"github.com/x0tta6bl4/agent/internal/security"
 )
 
+// Node roles. Bootstrap nodes only run discovery and answer PEER_LIST
+// requests; they never forward application traffic.
+const (
+	ModeEdge      = "edge"
+	ModeBootstrap = "bootstrap"
+)
+
+// peerListMagic prefixes the bootstrap PEER_LIST request/response protocol,
+// which lets a node ask a bootstrap (super-)node for its known peers
+// without going through full discovery or the Control Plane.
+const peerListMagic = "X0TP"
+
+const (
+	framePeerListRequest  byte = 0x01
+	framePeerListResponse byte = 0x02
+)
+
+// peerListEntry is one peer in a PEER_LIST response.
+type peerListEntry struct {
+	NodeID string `json:"node_id"`
+	Addr   string `json:"addr"`
+}
+
+// routingMagic prefixes routing-subsystem frames (advertisements and
+// forwarded, multi-hop payloads) so listenLoop can tell them apart from
+// plain application data without changing the wire format for the latter.
+const routingMagic = "X0TR"
+
+const (
+	frameAdvertisement byte = 0x01
+	frameForward       byte = 0x02
+)
+
+// forwardEnvelope wraps a payload that needs multi-hop forwarding.
+type forwardEnvelope struct {
+	Header  routing.ForwardHeader `json:"header"`
+	Payload []byte                `json:"payload"`
+}
+
+// gossipMagic prefixes the IHAVE/IWANT/PUSH lightweight gossip protocol
+// that backfills messages a mesh overlay peer missed (see
+// internal/mesh/overlay and gossipLoop).
+const gossipMagic = "X0TG"
+
+const (
+	frameIHave byte = 0x01
+	frameIWant byte = 0x02
+	framePush  byte = 0x03
+)
+
+// gossipWindow bounds how far back IHAVE advertises message IDs.
+const gossipWindow = 10 * time.Second
+
+type ihaveMsg struct {
+	IDs []string `json:"ids"`
+}
+
+type iwantMsg struct {
+	IDs []string `json:"ids"`
+}
+
+type pushMsg struct {
+	ID      string `json:"id"`
+	Payload []byte `json:"payload"`
+}
+
+// discoMagic prefixes the disco ping/pong NAT-traversal protocol (inspired
+// by Tailscale's magicsock/disco): PING probes a peer's direct UDP address
+// regardless of its current viaRelay state, and PONG echoes back the
+// sender's observed address (a STUN-style reflexive candidate) as proof a
+// direct path works in both directions. See sendDiscoPing/handleDiscoFrame.
+const discoMagic = "X0TD"
+
+const (
+	frameDiscoPing byte = 0x01
+	frameDiscoPong byte = 0x02
+)
+
+type discoPingMsg struct {
+	Nonce uint64 `json:"nonce"`
+}
+
+type discoPongMsg struct {
+	Nonce    uint64 `json:"nonce"`
+	YourAddr string `json:"your_addr"`
+}
+
+// pskMagic prefixes the two-phase PSK rotation control protocol
+// (PROPOSE_PSK / ACK_PSK), see EnableSecurity and rotateLoop.
+const pskMagic = "X0TK"
+
+const (
+	framePSKPropose byte = 0x01
+	framePSKAck     byte = 0x02
+)
+
+// pskRotationMsg carries the candidate key for one phase of a PSK rotation.
+type pskRotationMsg struct {
+	Key []byte `json:"key"`
+}
+
 // Ensure Node implements the stats interface needed by telemetry and healing.
 var _ interface{ GetStats() map[string]any } = (*Node)(nil)
 
@@ -24,6 +134,7 @@ const (
 	StateConnecting
 	StateActive
 	StateDegraded
+	StateDraining
 	StateStopped
 )
 
@@ -37,6 +148,8 @@ func (s NodeState) String() string {
 		return "active"
 	case StateDegraded:
 		return "degraded"
+	case StateDraining:
+		return "draining"
 	case StateStopped:
 		return "stopped"
 	default:
@@ -44,6 +157,19 @@ func (s NodeState) String() string {
 	}
 }
 
+// Endpoint is one candidate path to reach a peer, learned from discovery
+// (Source "direct") or synthesized for the shared relay client (Source
+// "relay", Addr nil). See Peer.Endpoints.
+type Endpoint struct {
+	Addr   *net.UDPAddr
+	Source string
+}
+
+const (
+	endpointDirect = "direct"
+	endpointRelay  = "relay"
+)
+
 // Peer represents a connected peer in the mesh.
 type Peer struct {
 	NodeID    string
@@ -53,6 +179,30 @@ type Peer struct {
 	BytesSent int64
 	BytesRecv int64
 	Healthy   bool
+	PubKey    string
+
+	// Endpoints lists every path discovery learned to reach this peer,
+	// direct UDP candidates plus the relay handle when one is configured.
+	// Addr is always Endpoints' current best direct candidate.
+	Endpoints []Endpoint
+
+	// ReflexiveAddr is the source address this peer's disco pong arrived
+	// from, i.e. its public endpoint as we observe it. It is set only when
+	// that pong promotes the peer off the relay, and is nil until then.
+	ReflexiveAddr *net.UDPAddr
+
+	// viaRelay is true while direct UDP to this peer is considered
+	// unreachable and traffic is instead sent through the relay client.
+	// New peers start here pessimistically whenever a relay is configured
+	// (Tailscale magicsock-style: assume NAT until disco proves otherwise)
+	// and are promoted to direct once a disco ping/pong round-trips.
+	viaRelay bool
+
+	// static is true for peers added via the admin API (AddPeerManual).
+	// Static peers are never marked unhealthy (or moved onto the relay)
+	// by checkPeerHealth and are not removed by discovery's OnPeerLost,
+	// since they weren't discovered in the first place.
+	static bool
 }
 
 // MessageHandler is a callback for received mesh messages.
@@ -71,8 +221,48 @@ type Node struct {
 	discovery  *discovery.Discovery
 	handlers   []MessageHandler
 
-	stopCh  chan struct{}
-	logger  *slog.Logger
+	relayClient *relay.Client
+
+	router   *routing.Router
+	dedup    *routing.DedupCache
+	sendSeq  uint64
+	discoSeq uint64
+
+	// reflexiveAddr is this node's own public endpoint, learned from the
+	// most recent disco pong any peer sent back (see handleDiscoFrame).
+	reflexiveAddr *net.UDPAddr
+
+	// overlay manages scored mesh membership over the candidate set of all
+	// known peers (see internal/mesh/overlay), independent of the
+	// multi-hop routing table above.
+	overlay *overlay.Overlay
+
+	// subnetRoutes, set via SetSubnetRoutes, is the HA subnet-router
+	// failover table (see internal/routing); nil unless the caller opts
+	// in. Its Stats are merged into GetStats for telemetry whenever set.
+	subnetRoutes *subnetrouting.Table
+
+	meshID     string
+	federation *Federation
+
+	mode string
+
+	pskStore         *security.Store
+	obfuscation      string
+	rotationInterval time.Duration
+	peerAddedHandlers []func(peerID string)
+
+	// ctx/cancel govern every background loop started by Start: cancelling
+	// it (via Stop or the parent context passed to Start) is the sole
+	// signal each loop's select waits on. wg tracks those loops so Wait
+	// can block until they've all actually returned, not just been told
+	// to. stopOnce makes Stop safe to call more than once or concurrently.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+
+	logger *slog.Logger
 
 	// Stats
 	msgSent int64
@@ -80,21 +270,152 @@ type Node struct {
 	started time.Time
 }
 
-// NewNode creates a new mesh node.
+// NewNode creates a new mesh node. Its background-loop context defaults to
+// context.Background; pass a caller-derived context to Start to tie the
+// node's lifetime to a wider shutdown signal.
 func NewNode(nodeID string, listenPort int, disc *discovery.Discovery) *Node {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Node{
 		ID:         nodeID,
 		ListenPort: listenPort,
 		State:      StateInit,
 		peers:      make(map[string]*Peer),
 		discovery:  disc,
-		stopCh:     make(chan struct{}),
+		router:     routing.NewRouter(nodeID, routing.DefaultConfig()),
+		dedup:      routing.NewDedupCache(30 * time.Second),
+		overlay:    overlay.New(overlay.DefaultConfig()),
+		mode:       ModeEdge,
+		ctx:        ctx,
+		cancel:     cancel,
 		logger:     slog.Default().With("component", "mesh-node", "node_id", nodeID),
 	}
 }
 
-// Start initializes the node and begins listening.
-func (n *Node) Start() error {
+// SetRoutingConfig overrides the multi-hop routing tuning. Call before Start.
+func (n *Node) SetRoutingConfig(cfg routing.Config) {
+	n.router = routing.NewRouter(n.ID, cfg)
+}
+
+// SetSubnetRoutes attaches a subnet-router failover table whose Stats
+// will be merged into GetStats. The table itself is populated by the
+// caller wiring discovery's OnPeerDiscovered/OnPeerLost to
+// table.UpdateAdvertisement/RemoveNode (see agent/main.go), not by Node.
+func (n *Node) SetSubnetRoutes(table *subnetrouting.Table) {
+	n.subnetRoutes = table
+}
+
+// SetMode sets the node's role (ModeEdge or ModeBootstrap). Call before
+// Start. Bootstrap nodes skip the multi-hop forwarding path and only
+// answer PEER_LIST requests from their known peers.
+func (n *Node) SetMode(mode string) {
+	n.mode = mode
+}
+
+// EnableSecurity turns on per-peer PSK obfuscation: store persists the
+// keying material, and obfuscation selects how payloads are transformed
+// before they hit the wire ("none", "xor", or "aes" — see config.Obfuscation).
+func (n *Node) EnableSecurity(store *security.Store, obfuscation string) {
+	n.pskStore = store
+	n.obfuscation = obfuscation
+}
+
+// SetRotationInterval enables automatic PSK rotation, proposing a new key
+// to every peer roughly every interval. Zero disables rotation. Call
+// before Start.
+func (n *Node) SetRotationInterval(interval time.Duration) {
+	n.rotationInterval = interval
+}
+
+// OnPeerAdded registers a callback invoked whenever a peer is added, either
+// via discovery or AddPeerManual, after its PSK (if security is enabled)
+// has been generated — e.g. so the agent can hand the PSK to the Control
+// Plane via api.Client.ExchangePSK.
+func (n *Node) OnPeerAdded(handler func(peerID string)) {
+	n.peerAddedHandlers = append(n.peerAddedHandlers, handler)
+}
+
+// RequestPeerList asks a bootstrap node at addr for its known peers. Any
+// peers in the response are added as if freshly discovered.
+func (n *Node) RequestPeerList(addr string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return fmt.Errorf("resolve bootstrap addr: %w", err)
+	}
+
+	framed := append([]byte(peerListMagic), framePeerListRequest)
+	if _, err := n.conn.WriteToUDP(framed, udpAddr); err != nil {
+		return fmt.Errorf("send peer list request to %s: %w", addr, err)
+	}
+	return nil
+}
+
+// EnableFederation turns on cross-mesh forwarding for this node: meshID
+// identifies the local mesh to foreign peers, and peerings lists the
+// foreign meshes already established via the Control Plane (see
+// api.Client.EstablishPeering).
+func (n *Node) EnableFederation(meshID string, peerings []PeeringInfo) {
+	n.meshID = meshID
+	n.federation = NewFederation(meshID)
+	for _, p := range peerings {
+		if err := n.federation.AddPeering(p); err != nil {
+			n.logger.Warn("skipping invalid peering", "mesh_id", p.MeshID, "error", err)
+		}
+	}
+}
+
+// UpdateFederationPeering applies an ACL change for an existing peering
+// without a restart — e.g. a Control Plane push (see
+// api.Client.Subscribe's ACLPolicyUpdate) that narrows or widens which
+// services a federated mesh may import. It replaces the peering's
+// gateway set and allow-list wholesale, same as the initial
+// EnableFederation call.
+func (n *Node) UpdateFederationPeering(info PeeringInfo) error {
+	if n.federation == nil {
+		return fmt.Errorf("federation not enabled")
+	}
+	return n.federation.AddPeering(info)
+}
+
+// RemoveFederationPeering tears down an established peering, e.g. when an
+// ACLPolicyUpdate deletes it.
+func (n *Node) RemoveFederationPeering(meshID string) error {
+	if n.federation == nil {
+		return fmt.Errorf("federation not enabled")
+	}
+	n.federation.RemovePeering(meshID)
+	return nil
+}
+
+// SendToForeignMesh delivers data to a peered mesh's gateway, tagged with
+// this mesh's ID and the requested service name so the remote side's ACL
+// can allow or reject it.
+func (n *Node) SendToForeignMesh(meshID, service string, data []byte) error {
+	if n.federation == nil {
+		return fmt.Errorf("federation not enabled")
+	}
+
+	gw, ok := n.federation.PickGateway(meshID)
+	if !ok {
+		return fmt.Errorf("no healthy gateway for mesh %s", meshID)
+	}
+
+	framed, err := n.federation.WrapForeign(service, data)
+	if err != nil {
+		return fmt.Errorf("wrap federation frame: %w", err)
+	}
+
+	if _, err := n.conn.WriteToUDP(framed, gw); err != nil {
+		return fmt.Errorf("send to foreign mesh %s: %w", meshID, err)
+	}
+	return nil
+}
+
+// Start initializes the node and begins listening. ctx governs every
+// background loop: cancelling it (or calling Stop, which cancels an
+// internally derived child of it) tells listenLoop, healthCheckLoop,
+// advertiseLoop, gossipLoop, rotateLoop, and upgradeProbeLoop to return.
+// Call Wait afterward to block until they've all actually exited.
+func (n *Node) Start(ctx context.Context) error {
 	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf(":%d", n.ListenPort))
 	if err != nil {
 		return fmt.Errorf("resolve addr: %w", err)
@@ -105,6 +426,7 @@ func (n *Node) Start() error {
 		return fmt.Errorf("listen UDP: %w", err)
 	}
 
+	n.ctx, n.cancel = context.WithCancel(ctx)
 	n.conn = conn
 	n.State = StateConnecting
 	n.started = time.Now()
@@ -115,35 +437,84 @@ func (n *Node) Start() error {
 			n.addPeerFromDiscovery(peer)
 		}
 		n.discovery.OnPeerLost = func(peer discovery.PeerInfo) {
+			n.mu.RLock()
+			p, ok := n.peers[peer.NodeID]
+			n.mu.RUnlock()
+			if ok && p.static {
+				return // statically-added peers are never evicted by discovery
+			}
 			n.removePeer(peer.NodeID)
 		}
-		if err := n.discovery.Start(); err != nil {
+		if err := n.discovery.Start(n.ctx); err != nil {
 			n.logger.Warn("discovery failed to start", "error", err)
 		}
 	}
 
-	go n.listenLoop()
-	go n.healthCheckLoop()
+	n.startLoop(n.listenLoop)
+	n.startLoop(n.healthCheckLoop)
+	n.startLoop(n.advertiseLoop)
+	n.startLoop(n.gossipLoop)
+	n.overlay.Start(func(grafted, pruned []string) {
+		for _, id := range grafted {
+			n.logger.Info("overlay graft", "node_id", id)
+		}
+		for _, id := range pruned {
+			n.logger.Info("overlay prune", "node_id", id)
+		}
+	})
+	if n.pskStore != nil && n.rotationInterval > 0 {
+		n.startLoop(n.rotateLoop)
+	}
 
 	n.State = StateActive
 	n.logger.Info("mesh node started", "port", n.ListenPort)
 	return nil
 }
 
-// Stop gracefully shuts down the node.
+// startLoop runs loop in its own goroutine, tracked by wg so Wait can block
+// until it returns.
+func (n *Node) startLoop(loop func()) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		loop()
+	}()
+}
+
+// Stop gracefully shuts down the node. Safe to call more than once or
+// concurrently with itself; only the first call has any effect.
 func (n *Node) Stop() {
-	n.State = StateStopped
-	close(n.stopCh)
+	n.stopOnce.Do(func() {
+		n.State = StateStopped
+		if n.cancel != nil {
+			n.cancel()
+		}
+		n.overlay.Stop()
 
-	if n.discovery != nil {
-		n.discovery.Stop()
-	}
+		if n.discovery != nil {
+			n.discovery.Stop()
+		}
 
-	if n.conn != nil {
-		n.conn.Close()
-	}
+		if n.conn != nil {
+			n.conn.Close()
+		}
+
+		n.logger.Info("mesh node stopped")
+	})
+}
+
+// Wait blocks until every background loop started by Start has returned.
+// Call it after Stop (or after the context passed to Start is cancelled)
+// to know shutdown is actually complete rather than merely requested.
+func (n *Node) Wait() {
+	n.wg.Wait()
+}
 
-	n.logger.Info("mesh node stopped")
+// Done returns a channel that's closed once Stop is called or the context
+// passed to Start is cancelled. Unlike Wait, it doesn't block until the
+// loops have exited — just that shutdown has been signaled.
+func (n *Node) Done() <-chan struct{} {
+	return n.ctx.Done()
 }
 
 // OnMessage registers a message handler.
@@ -151,31 +522,413 @@ func (n *Node) OnMessage(handler MessageHandler) {
 	n.handlers = append(n.handlers, handler)
 }
 
-// SendTo sends data to a specific peer.
+// AddPeerManual inserts a peer outside of discovery, e.g. from the admin
+// API. The peer is marked static so checkPeerHealth never marks it
+// unhealthy and discovery's OnPeerLost never evicts it; only an explicit
+// RemovePeer call removes it.
+func (n *Node) AddPeerManual(nodeID, addr string, port int, pubKey string) error {
+	if n.State == StateDraining {
+		return fmt.Errorf("node is draining, not accepting new peers")
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", addr, port))
+	if err != nil {
+		return fmt.Errorf("resolve addr: %w", err)
+	}
+
+	n.mu.Lock()
+	n.peers[nodeID] = &Peer{
+		NodeID:   nodeID,
+		Addr:     udpAddr,
+		PubKey:   pubKey,
+		LastSeen: time.Now(),
+		Healthy:  true,
+		static:   true,
+	}
+	n.mu.Unlock()
+
+	n.overlay.AddCandidate(nodeID)
+	n.ensurePeerPSK(nodeID)
+	n.logger.Info("peer added manually", "node_id", nodeID, "addr", udpAddr)
+	for _, h := range n.peerAddedHandlers {
+		h(nodeID)
+	}
+	return nil
+}
+
+// RemovePeer removes a peer by ID, including static ones added via
+// AddPeerManual. Returns an error if the peer is not found.
+func (n *Node) RemovePeer(nodeID string) error {
+	n.mu.RLock()
+	_, ok := n.peers[nodeID]
+	n.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("peer not found: %s", nodeID)
+	}
+	n.removePeer(nodeID)
+	return nil
+}
+
+// AddPeerFromDiscovery inserts or refreshes a peer the same way local
+// mDNS/UDP discovery does. It's the entry point for peer sources other than
+// Discovery itself — see internal/peering's roster reconciler, which feeds
+// the initial and ongoing peer list learned from a cluster gateway through
+// here rather than duplicating addPeerFromDiscovery's endpoint-parsing and
+// relay-fallback logic.
+func (n *Node) AddPeerFromDiscovery(info discovery.PeerInfo) {
+	n.addPeerFromDiscovery(info)
+}
+
+// Drain transitions the node to StateDraining: it stops accepting new
+// peers and stops broadcasting, so it can be cleanly removed from the
+// mesh without disrupting in-flight traffic.
+func (n *Node) Drain() {
+	n.State = StateDraining
+	n.logger.Info("node draining")
+}
+
+// relayProbeResult is one candidate endpoint's outcome from EnableRelay's
+// concurrent connect race.
+type relayProbeResult struct {
+	endpoint string
+	client   *relay.Client
+	rtt      time.Duration
+	err      error
+}
+
+// EnableRelay connects to the lowest-RTT reachable relay endpoint (dialing
+// every candidate concurrently and keeping the fastest successful
+// connection) and starts the background upgrade-probe loop that retries
+// direct UDP for peers currently routed through it. It is a no-op if
+// endpoints is empty.
+func (n *Node) EnableRelay(endpoints []string, authSecret string) error {
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	results := make(chan relayProbeResult, len(endpoints))
+	for _, ep := range endpoints {
+		go func(ep string) {
+			c := relay.NewClient(n.ID, ep, []byte(authSecret))
+			start := time.Now()
+			err := c.Connect()
+			results <- relayProbeResult{endpoint: ep, client: c, rtt: time.Since(start), err: err}
+		}(ep)
+	}
+
+	var best relayProbeResult
+	var lastErr error
+	for range endpoints {
+		r := <-results
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		if best.client != nil && best.rtt <= r.rtt {
+			r.client.Close()
+			continue
+		}
+		if best.client != nil {
+			best.client.Close()
+		}
+		best = r
+	}
+
+	if best.client == nil {
+		return fmt.Errorf("connect to any relay endpoint: %w", lastErr)
+	}
+
+	best.client.OnFrame = n.handleRelayFrame
+	n.relayClient = best.client
+	n.startLoop(n.upgradeProbeLoop)
+	n.logger.Info("relay enabled", "endpoint", best.endpoint, "rtt", best.rtt)
+	return nil
+}
+
+// SendTo sends data to a specific peer, transparently routing through the
+// relay when the peer is marked viaRelay (no direct UDP path available).
 func (n *Node) SendTo(peerID string, data []byte) error {
 	n.mu.RLock()
 	peer, ok := n.peers[peerID]
 	n.mu.RUnlock()
 
-	if !ok {
-		return fmt.Errorf("peer not found: %s", peerID)
+	if !ok {
+		return fmt.Errorf("peer not found: %s", peerID)
+	}
+
+	return n.rawSendToPeer(peer, n.obfuscate(peerID, data))
+}
+
+// rawSendToPeer writes already-framed bytes to peer, transparently routing
+// through the relay when direct UDP is unreachable. It performs no
+// obfuscation, so it's used both by SendTo (after obfuscating app data) and
+// by internal control protocols (routing, peer-list, PSK rotation) whose
+// magic-byte prefixes must stay recognizable regardless of config.Obfuscation.
+func (n *Node) rawSendToPeer(peer *Peer, data []byte) error {
+	if peer.viaRelay {
+		if n.relayClient == nil {
+			return fmt.Errorf("peer %s unreachable and no relay configured", peer.NodeID)
+		}
+		if err := n.relayClient.Send(peer.NodeID, data); err != nil {
+			return fmt.Errorf("relay send to %s: %w", peer.NodeID, err)
+		}
+		n.mu.Lock()
+		peer.BytesSent += int64(len(data))
+		n.msgSent++
+		n.mu.Unlock()
+		return nil
+	}
+
+	_, err := n.conn.WriteToUDP(data, peer.Addr)
+	if err != nil {
+		return fmt.Errorf("send to %s: %w", peer.NodeID, err)
+	}
+
+	n.mu.Lock()
+	peer.BytesSent += int64(len(data))
+	n.msgSent++
+	n.mu.Unlock()
+
+	return nil
+}
+
+// obfuscate transforms outbound data for peerID per n.obfuscation, using the
+// pair's current PSK. It returns data unchanged if security isn't enabled,
+// no PSK exists yet for the pair, or obfuscation is "none".
+func (n *Node) obfuscate(peerID string, data []byte) []byte {
+	if n.pskStore == nil || n.obfuscation == "" || n.obfuscation == "none" {
+		return data
+	}
+	psk, ok := n.pskStore.Get(n.ID, peerID)
+	if !ok {
+		return data
+	}
+
+	switch n.obfuscation {
+	case "xor":
+		return security.XOR(data, psk.Current)
+	case "aes":
+		out, err := security.EncryptAESGCM(psk.Current, data)
+		if err != nil {
+			n.logger.Warn("PSK encryption failed, sending unobfuscated", "peer_id", peerID, "error", err)
+			return data
+		}
+		return out
+	default:
+		return data
+	}
+}
+
+// deobfuscate reverses obfuscate for data received from peerID, accepting
+// either the current or (during a rotation overlap window) previous PSK.
+// ok is false only when AES decryption fails under every candidate key.
+func (n *Node) deobfuscate(peerID string, data []byte) (plain []byte, ok bool) {
+	if n.pskStore == nil || n.obfuscation == "" || n.obfuscation == "none" {
+		return data, true
+	}
+	psk, found := n.pskStore.Get(n.ID, peerID)
+	if !found {
+		return data, true
+	}
+
+	switch n.obfuscation {
+	case "xor":
+		return security.XOR(data, psk.Current), true
+	case "aes":
+		if plain, err := security.DecryptAESGCM(psk.Current, data); err == nil {
+			return plain, true
+		}
+		if psk.Previous != nil {
+			if plain, err := security.DecryptAESGCM(psk.Previous, data); err == nil {
+				return plain, true
+			}
+		}
+		return nil, false
+	default:
+		return data, true
+	}
+}
+
+// SendVia delivers data to a node that may not be a direct peer, forwarding
+// it hop-by-hop along the path computed by the routing subsystem.
+func (n *Node) SendVia(dstID string, data []byte) error {
+	n.mu.RLock()
+	_, isDirectPeer := n.peers[dstID]
+	n.mu.RUnlock()
+	if isDirectPeer {
+		return n.SendTo(dstID, data)
+	}
+
+	hop, ok := n.router.NextHop(dstID)
+	if !ok {
+		return fmt.Errorf("no route to %s", dstID)
+	}
+
+	n.mu.Lock()
+	n.sendSeq++
+	seq := n.sendSeq
+	n.mu.Unlock()
+
+	env := forwardEnvelope{
+		Header: routing.ForwardHeader{
+			TTL: routing.DefaultTTL,
+			Src: n.ID,
+			Dst: dstID,
+			Seq: seq,
+		},
+		Payload: data,
+	}
+	return n.sendRoutingFrame(hop, frameForward, env)
+}
+
+// sendRoutingFrame marshals v and sends it to peerID prefixed with the
+// routing magic and tag so listenLoop can distinguish it from app data.
+// It bypasses PSK obfuscation, like other control protocols, so the magic
+// prefix stays recognizable at the next hop regardless of config.Obfuscation.
+func (n *Node) sendRoutingFrame(peerID string, tag byte, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal routing frame: %w", err)
+	}
+
+	framed := make([]byte, 0, len(routingMagic)+1+len(body))
+	framed = append(framed, routingMagic...)
+	framed = append(framed, tag)
+	framed = append(framed, body...)
+
+	n.mu.RLock()
+	peer, ok := n.peers[peerID]
+	n.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("peer not found: %s", peerID)
+	}
+	return n.rawSendToPeer(peer, framed)
+}
+
+// advertiseLoop periodically gossips this node's direct neighbor latencies
+// so the mesh can build a shared view of the overlay topology.
+func (n *Node) advertiseLoop() {
+	ticker := time.NewTicker(time.Duration(n.router.Config().AdvertisementIntervalSec) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.gossipAdvertisement()
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
+
+func (n *Node) gossipAdvertisement() {
+	if n.State == StateDraining {
+		return
+	}
+
+	n.mu.RLock()
+	neighbors := make([]routing.NeighborLink, 0, len(n.peers))
+	for _, p := range n.peers {
+		neighbors = append(neighbors, routing.NeighborLink{
+			NeighborID: p.NodeID,
+			LatencyMs:  float64(p.Latency.Milliseconds()),
+		})
+	}
+	peers := make([]*Peer, 0, len(n.peers))
+	for _, p := range n.peers {
+		peers = append(peers, p)
+	}
+	n.mu.RUnlock()
+
+	n.router.SetLocalNeighbors(neighbors)
+
+	adv := routing.Advertisement{NodeID: n.ID, Neighbors: neighbors}
+	body, err := json.Marshal(adv)
+	if err != nil {
+		return
+	}
+	framed := append(append([]byte(routingMagic), frameAdvertisement), body...)
+
+	for _, p := range peers {
+		n.conn.WriteToUDP(framed, p.Addr)
+	}
+}
+
+// handleRelayFrame dispatches a frame received over the relay to the same
+// handlers a direct UDP packet would reach.
+func (n *Node) handleRelayFrame(f relay.Frame) {
+	n.mu.Lock()
+	n.msgRecv++
+	if p, ok := n.peers[f.SrcPeerID]; ok {
+		p.LastSeen = time.Now()
+		p.BytesRecv += int64(len(f.Payload))
+	}
+	n.mu.Unlock()
+
+	for _, handler := range n.handlers {
+		handler(f.Payload, f.SrcPeerID, nil)
+	}
+}
+
+// upgradeProbeLoop periodically retries direct UDP for peers routed through
+// the relay, and flips them back to the P2P fast path once it succeeds.
+func (n *Node) upgradeProbeLoop() {
+	ticker := time.NewTicker(20 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.probeRelayedPeers()
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
+
+func (n *Node) probeRelayedPeers() {
+	n.mu.RLock()
+	candidates := make([]*Peer, 0)
+	for _, p := range n.peers {
+		if p.viaRelay {
+			candidates = append(candidates, p)
+		}
+	}
+	n.mu.RUnlock()
+
+	for _, p := range candidates {
+		n.sendDiscoPing(p)
 	}
+}
 
-	_, err := n.conn.WriteToUDP(data, peer.Addr)
-	if err != nil {
-		return fmt.Errorf("send to %s: %w", peerID, err)
+// sendDiscoPing probes peer's direct UDP address regardless of its current
+// viaRelay state. A disco pong confirms the path works in both directions
+// (see handleDiscoFrame) and promotes the peer off the relay; no reply
+// within the next probe interval leaves it on the relay.
+func (n *Node) sendDiscoPing(peer *Peer) {
+	if peer.Addr == nil || n.conn == nil {
+		return
 	}
 
 	n.mu.Lock()
-	peer.BytesSent += int64(len(data))
-	n.msgSent++
+	n.discoSeq++
+	nonce := n.discoSeq
 	n.mu.Unlock()
 
-	return nil
+	body, err := json.Marshal(discoPingMsg{Nonce: nonce})
+	if err != nil {
+		return
+	}
+	framed := append(append([]byte(discoMagic), frameDiscoPing), body...)
+	n.conn.WriteToUDP(framed, peer.Addr)
 }
 
 // Broadcast sends data to all peers.
 func (n *Node) Broadcast(data []byte) {
+	if n.State == StateDraining {
+		return
+	}
+
 	n.mu.RLock()
 	peers := make([]*Peer, 0, len(n.peers))
 	for _, p := range n.peers {
@@ -184,9 +937,10 @@ func (n *Node) Broadcast(data []byte) {
 	n.mu.RUnlock()
 
 	for _, peer := range peers {
-		n.conn.WriteToUDP(data, peer.Addr)
+		payload := n.obfuscate(peer.NodeID, data)
+		n.conn.WriteToUDP(payload, peer.Addr)
 		n.mu.Lock()
-		peer.BytesSent += int64(len(data))
+		peer.BytesSent += int64(len(payload))
 		n.msgSent++
 		n.mu.Unlock()
 	}
@@ -221,7 +975,7 @@ func (n *Node) GetStats() map[string]any {
 		healthScore = float64(healthyCount) / float64(len(n.peers))
 	}
 
-	return map[string]any{
+	stats := map[string]any{
 		"node_id":       n.ID,
 		"state":         n.State.String(),
 		"peers_total":   len(n.peers),
@@ -231,6 +985,35 @@ func (n *Node) GetStats() map[string]any {
 		"messages_recv": n.msgRecv,
 		"uptime_sec":    time.Since(n.started).Seconds(),
 	}
+	for k, v := range n.router.Stats() {
+		stats[k] = v
+	}
+	for k, v := range n.overlay.Stats() {
+		stats[k] = v
+	}
+	if n.federation != nil {
+		for k, v := range n.federation.Stats() {
+			stats[k] = v
+		}
+	}
+	if n.subnetRoutes != nil {
+		for k, v := range n.subnetRoutes.Stats() {
+			stats[k] = v
+		}
+	}
+	if n.pskStore != nil {
+		pskStats := make(map[string]any, len(n.peers))
+		for id := range n.peers {
+			if psk, ok := n.pskStore.Get(n.ID, id); ok {
+				pskStats[id] = map[string]any{
+					"key_age_sec": time.Since(psk.RotatedAt).Seconds(),
+					"rotating":    len(psk.Pending) > 0,
+				}
+			}
+		}
+		stats["psk"] = pskStats
+	}
+	return stats
 }
 
 // --- internal ---
@@ -239,7 +1022,7 @@ func (n *Node) listenLoop() {
 	buf := make([]byte, 65535)
 	for {
 		select {
-		case <-n.stopCh:
+		case <-n.ctx.Done():
 			return
 		default:
 		}
@@ -274,10 +1057,58 @@ func (n *Node) listenLoop() {
 			if p, ok := n.peers[senderID]; ok {
 				p.LastSeen = time.Now()
 				p.BytesRecv += int64(nBytes)
+				if p.viaRelay {
+					p.viaRelay = false
+					n.logger.Info("direct UDP path recovered, leaving relay", "node_id", senderID)
+				}
 			}
 			n.mu.Unlock()
 		}
 
+		if len(data) >= len(routingMagic)+1 && string(data[:len(routingMagic)]) == routingMagic {
+			n.handleRoutingFrame(data[len(routingMagic)], data[len(routingMagic)+1:], remoteAddr)
+			continue
+		}
+
+		if n.federation != nil && IsForeign(data) {
+			n.handleFederationFrame(data, remoteAddr)
+			continue
+		}
+
+		if len(data) >= len(peerListMagic)+1 && string(data[:len(peerListMagic)]) == peerListMagic {
+			n.handlePeerListFrame(data[len(peerListMagic)], data[len(peerListMagic)+1:], remoteAddr)
+			continue
+		}
+
+		if len(data) >= len(pskMagic)+1 && string(data[:len(pskMagic)]) == pskMagic {
+			n.handlePSKFrame(senderID, data[len(pskMagic)], data[len(pskMagic)+1:])
+			continue
+		}
+
+		if len(data) >= len(gossipMagic)+1 && string(data[:len(gossipMagic)]) == gossipMagic {
+			n.handleGossipFrame(senderID, data[len(gossipMagic)], data[len(gossipMagic)+1:])
+			continue
+		}
+
+		if len(data) >= len(discoMagic)+1 && string(data[:len(discoMagic)]) == discoMagic {
+			n.handleDiscoFrame(senderID, data[len(discoMagic)], data[len(discoMagic)+1:], remoteAddr)
+			continue
+		}
+
+		if senderID != "" {
+			plain, ok := n.deobfuscate(senderID, data)
+			if !ok {
+				n.logger.Warn("dropping packet, PSK decryption failed", "node_id", senderID)
+				n.overlay.RecordInvalidMessage(senderID)
+				continue
+			}
+			data = plain
+
+			msgID := messageID(data)
+			n.overlay.RecordDelivery(senderID, 0, n.overlay.MarkSeen(msgID))
+			n.overlay.CachePayload(msgID, data)
+		}
+
 		// Dispatch to handlers
 		for _, handler := range n.handlers {
 			handler(data, senderID, remoteAddr)
@@ -285,6 +1116,359 @@ func (n *Node) listenLoop() {
 	}
 }
 
+// handleRoutingFrame processes a link-state advertisement or a forwarded,
+// multi-hop payload addressed to this node or one further along its path.
+func (n *Node) handleRoutingFrame(tag byte, body []byte, remoteAddr *net.UDPAddr) {
+	switch tag {
+	case frameAdvertisement:
+		var adv routing.Advertisement
+		if err := json.Unmarshal(body, &adv); err != nil {
+			return
+		}
+		n.router.Ingest(adv)
+
+	case frameForward:
+		if n.mode == ModeBootstrap {
+			return // bootstrap nodes don't carry user traffic
+		}
+
+		var env forwardEnvelope
+		if err := json.Unmarshal(body, &env); err != nil {
+			return
+		}
+
+		if env.Header.Dst == n.ID {
+			for _, handler := range n.handlers {
+				handler(env.Payload, env.Header.Src, remoteAddr)
+			}
+			return
+		}
+
+		if n.dedup.SeenBefore(env.Header.Src, env.Header.Seq) {
+			return
+		}
+		if env.Header.TTL <= 0 {
+			n.logger.Warn("dropping forwarded packet, TTL exceeded", "src", env.Header.Src, "dst", env.Header.Dst)
+			return
+		}
+
+		hop, ok := n.router.NextHop(env.Header.Dst)
+		if !ok {
+			return
+		}
+		env.Header.TTL--
+		n.sendRoutingFrame(hop, frameForward, env)
+	}
+}
+
+// handleFederationFrame validates and unwraps a cross-mesh packet, then
+// dispatches its payload to handlers tagged with the origin mesh ID so
+// callers can distinguish foreign traffic from local peers.
+func (n *Node) handleFederationFrame(data []byte, remoteAddr *net.UDPAddr) {
+	originMeshID, payload, err := n.federation.UnwrapForeign(data)
+	if err != nil {
+		n.logger.Warn("dropping cross-mesh packet", "error", err)
+		return
+	}
+
+	sender := fmt.Sprintf("mesh:%s", originMeshID)
+	for _, handler := range n.handlers {
+		handler(payload, sender, remoteAddr)
+	}
+}
+
+// handlePeerListFrame answers a PEER_LIST request with this node's known
+// peers, or, on the requesting side, folds a response's peers in as newly
+// discovered.
+func (n *Node) handlePeerListFrame(tag byte, body []byte, remoteAddr *net.UDPAddr) {
+	switch tag {
+	case framePeerListRequest:
+		n.mu.RLock()
+		entries := make([]peerListEntry, 0, len(n.peers))
+		for _, p := range n.peers {
+			entries = append(entries, peerListEntry{NodeID: p.NodeID, Addr: p.Addr.String()})
+		}
+		n.mu.RUnlock()
+
+		respBody, err := json.Marshal(entries)
+		if err != nil {
+			return
+		}
+		framed := append(append([]byte(peerListMagic), framePeerListResponse), respBody...)
+		n.conn.WriteToUDP(framed, remoteAddr)
+
+	case framePeerListResponse:
+		var entries []peerListEntry
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return
+		}
+		for _, e := range entries {
+			if e.NodeID == n.ID {
+				continue
+			}
+			host, portStr, err := net.SplitHostPort(e.Addr)
+			if err != nil {
+				continue
+			}
+			port, err := strconv.Atoi(portStr)
+			if err != nil {
+				continue
+			}
+			n.addPeerFromDiscovery(discovery.PeerInfo{
+				NodeID:    e.NodeID,
+				Addresses: [][]any{{host, port}},
+			})
+		}
+	}
+}
+
+// handleDiscoFrame answers a disco ping with a pong carrying the observed
+// reflexive address, and on the pinging side, treats a returned pong as
+// proof the direct path round-trips and promotes the peer off the relay.
+func (n *Node) handleDiscoFrame(senderID string, tag byte, body []byte, remoteAddr *net.UDPAddr) {
+	switch tag {
+	case frameDiscoPing:
+		var msg discoPingMsg
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return
+		}
+		pong := discoPongMsg{Nonce: msg.Nonce, YourAddr: remoteAddr.String()}
+		respBody, err := json.Marshal(pong)
+		if err != nil {
+			return
+		}
+		framed := append(append([]byte(discoMagic), frameDiscoPong), respBody...)
+		n.conn.WriteToUDP(framed, remoteAddr)
+
+	case frameDiscoPong:
+		var msg discoPongMsg
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return
+		}
+		if addr, err := net.ResolveUDPAddr("udp4", msg.YourAddr); err == nil {
+			n.mu.Lock()
+			n.reflexiveAddr = addr
+			n.mu.Unlock()
+		}
+
+		if senderID == "" {
+			return
+		}
+		n.mu.Lock()
+		peer, ok := n.peers[senderID]
+		promoted := ok && peer.viaRelay
+		if promoted {
+			peer.viaRelay = false
+			peer.ReflexiveAddr = remoteAddr
+		}
+		n.mu.Unlock()
+		if promoted {
+			n.logger.Info("disco pong confirmed direct path, leaving relay", "node_id", senderID)
+		}
+	}
+}
+
+// messageID derives a stable identifier for data so the overlay's
+// MarkSeen/first-delivery scoring and IHAVE/IWANT gossip can refer to the
+// same message without re-transmitting it.
+func messageID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// gossipLoop periodically advertises recently-seen message IDs to a random
+// sample of non-mesh candidate peers (IHAVE), decoupling reachability from
+// full flooding: a peer outside the mesh can still pull anything it missed.
+func (n *Node) gossipLoop() {
+	ticker := time.NewTicker(gossipWindow / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.gossipIHave()
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
+
+func (n *Node) gossipIHave() {
+	ids := n.overlay.RecentMessageIDs(gossipWindow)
+	if len(ids) == 0 {
+		return
+	}
+
+	for _, peerID := range n.overlay.GossipTargets() {
+		n.sendGossipFrame(peerID, frameIHave, ihaveMsg{IDs: ids})
+	}
+}
+
+// sendGossipFrame marshals v and sends it to peerID prefixed with the
+// gossip magic and tag. Like other control protocols it bypasses PSK
+// obfuscation so the magic prefix stays recognizable at the receiver.
+func (n *Node) sendGossipFrame(peerID string, tag byte, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal gossip frame: %w", err)
+	}
+
+	framed := make([]byte, 0, len(gossipMagic)+1+len(body))
+	framed = append(framed, gossipMagic...)
+	framed = append(framed, tag)
+	framed = append(framed, body...)
+
+	n.mu.RLock()
+	peer, ok := n.peers[peerID]
+	n.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("peer not found: %s", peerID)
+	}
+	return n.rawSendToPeer(peer, framed)
+}
+
+// handleGossipFrame drives the IHAVE/IWANT/PUSH gossip protocol: IHAVE
+// triggers an IWANT for anything the receiver hasn't seen yet, IWANT
+// triggers a PUSH of the cached payload, and PUSH folds a pulled message
+// into the overlay and local handlers as if it had arrived directly.
+func (n *Node) handleGossipFrame(senderID string, tag byte, body []byte) {
+	if senderID == "" {
+		return
+	}
+
+	switch tag {
+	case frameIHave:
+		var msg ihaveMsg
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return
+		}
+		missing := n.overlay.Missing(msg.IDs)
+		if len(missing) > 0 {
+			n.sendGossipFrame(senderID, frameIWant, iwantMsg{IDs: missing})
+		}
+
+	case frameIWant:
+		var msg iwantMsg
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return
+		}
+		for _, id := range msg.IDs {
+			if payload, ok := n.overlay.Payload(id); ok {
+				n.sendGossipFrame(senderID, framePush, pushMsg{ID: id, Payload: payload})
+			}
+		}
+
+	case framePush:
+		var msg pushMsg
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return
+		}
+		if !n.overlay.MarkSeen(msg.ID) {
+			return // already have it, e.g. via another gossip target
+		}
+		n.overlay.CachePayload(msg.ID, msg.Payload)
+		n.overlay.RecordDelivery(senderID, 0, true)
+		for _, handler := range n.handlers {
+			handler(msg.Payload, senderID, nil)
+		}
+	}
+}
+
+// rotateLoop periodically proposes a fresh PSK to every known peer, driving
+// the two-phase PROPOSE_PSK/ACK_PSK rekey (see handlePSKFrame).
+func (n *Node) rotateLoop() {
+	ticker := time.NewTicker(n.rotationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.proposeRotations()
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
+
+func (n *Node) proposeRotations() {
+	n.mu.RLock()
+	peers := make([]*Peer, 0, len(n.peers))
+	for _, p := range n.peers {
+		peers = append(peers, p)
+	}
+	n.mu.RUnlock()
+
+	for _, p := range peers {
+		if _, ok := n.pskStore.Get(n.ID, p.NodeID); !ok {
+			continue // no established PSK to rotate yet
+		}
+		key, err := n.pskStore.ProposeRotation(n.ID, p.NodeID)
+		if err != nil {
+			n.logger.Warn("failed to propose PSK rotation", "node_id", p.NodeID, "error", err)
+			continue
+		}
+		if err := n.sendPSKFrame(p, framePSKPropose, key); err != nil {
+			n.logger.Warn("failed to send PSK rotation proposal", "node_id", p.NodeID, "error", err)
+		}
+	}
+}
+
+func (n *Node) sendPSKFrame(peer *Peer, tag byte, key []byte) error {
+	body, err := json.Marshal(pskRotationMsg{Key: key})
+	if err != nil {
+		return fmt.Errorf("marshal PSK frame: %w", err)
+	}
+
+	framed := make([]byte, 0, len(pskMagic)+1+len(body))
+	framed = append(framed, pskMagic...)
+	framed = append(framed, tag)
+	framed = append(framed, body...)
+	return n.rawSendToPeer(peer, framed)
+}
+
+// handlePSKFrame drives one side of the two-phase PSK rotation: on
+// PROPOSE_PSK it records the candidate key as pending and immediately
+// acknowledges and commits it (the sender commits once the ack arrives),
+// leaving an overlap window where deobfuscate accepts both the old and
+// new key so no in-flight packets are dropped.
+func (n *Node) handlePSKFrame(senderID string, tag byte, body []byte) {
+	if senderID == "" || n.pskStore == nil {
+		return
+	}
+
+	var msg pskRotationMsg
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return
+	}
+
+	n.mu.RLock()
+	peer, ok := n.peers[senderID]
+	n.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	switch tag {
+	case framePSKPropose:
+		if err := n.pskStore.SetPending(n.ID, senderID, msg.Key); err != nil {
+			n.logger.Warn("failed to record pending PSK", "node_id", senderID, "error", err)
+			return
+		}
+		if err := n.sendPSKFrame(peer, framePSKAck, msg.Key); err != nil {
+			n.logger.Warn("failed to ack PSK rotation", "node_id", senderID, "error", err)
+			return
+		}
+		if err := n.pskStore.Commit(n.ID, senderID); err != nil {
+			n.logger.Warn("failed to commit PSK rotation", "node_id", senderID, "error", err)
+		}
+
+	case framePSKAck:
+		if err := n.pskStore.Commit(n.ID, senderID); err != nil {
+			n.logger.Warn("failed to commit PSK rotation", "node_id", senderID, "error", err)
+		}
+	}
+}
+
 func (n *Node) healthCheckLoop() {
 	ticker := time.NewTicker(15 * time.Second)
 	defer ticker.Stop()
@@ -293,7 +1477,7 @@ func (n *Node) healthCheckLoop() {
 		select {
 		case <-ticker.C:
 			n.checkPeerHealth()
-		case <-n.stopCh:
+		case <-n.ctx.Done():
 			return
 		}
 	}
@@ -306,9 +1490,17 @@ func (n *Node) checkPeerHealth() {
 	now := time.Now()
 	degraded := false
 	for _, peer := range n.peers {
+		if peer.static {
+			peer.Healthy = true
+			continue
+		}
 		if now.Sub(peer.LastSeen) > 30*time.Second {
 			peer.Healthy = false
 			degraded = true
+			if n.relayClient != nil && !peer.viaRelay {
+				peer.viaRelay = true
+				n.logger.Info("peer unreachable, routing via relay", "node_id", peer.NodeID)
+			}
 		} else {
 			peer.Healthy = true
 		}
@@ -322,16 +1514,61 @@ func (n *Node) checkPeerHealth() {
 }
 
 func (n *Node) addPeerFromDiscovery(info discovery.PeerInfo) {
+	if n.State == StateDraining {
+		return
+	}
 	if len(info.Addresses) == 0 {
 		return
 	}
 
-	// Parse first address
-	addrParts := info.Addresses[0]
-	if len(addrParts) < 2 {
+	endpoints := make([]Endpoint, 0, len(info.Addresses))
+	for _, addrParts := range info.Addresses {
+		addr, ok := parseEndpointAddr(addrParts)
+		if !ok {
+			continue
+		}
+		endpoints = append(endpoints, Endpoint{Addr: addr, Source: endpointDirect})
+	}
+	if len(endpoints) == 0 {
 		return
 	}
 
+	startOnRelay := n.relayClient != nil
+	if startOnRelay {
+		endpoints = append(endpoints, Endpoint{Source: endpointRelay})
+	}
+
+	n.mu.Lock()
+	peer := &Peer{
+		NodeID:    info.NodeID,
+		Addr:      endpoints[0].Addr,
+		Endpoints: endpoints,
+		LastSeen:  time.Now(),
+		Healthy:   true,
+		viaRelay:  startOnRelay,
+	}
+	n.peers[info.NodeID] = peer
+	n.mu.Unlock()
+
+	n.overlay.AddCandidate(info.NodeID)
+	n.ensurePeerPSK(info.NodeID)
+	n.logger.Info("peer added", "node_id", info.NodeID, "addr", peer.Addr, "via_relay", startOnRelay)
+	if startOnRelay {
+		n.sendDiscoPing(peer) // race to upgrade off the relay immediately
+	}
+	for _, h := range n.peerAddedHandlers {
+		h(info.NodeID)
+	}
+}
+
+// parseEndpointAddr converts one discovery.PeerInfo.Addresses entry (an
+// [ip, port] pair, port possibly float64/json.Number/int depending on
+// decode path) into a UDP address.
+func parseEndpointAddr(addrParts []any) (*net.UDPAddr, bool) {
+	if len(addrParts) < 2 {
+		return nil, false
+	}
+
 	ip := fmt.Sprint(addrParts[0])
 	port := 0
 	switch v := addrParts[1].(type) {
@@ -347,21 +1584,23 @@ func (n *Node) addPeerFromDiscovery(info discovery.PeerInfo) {
 	}
 
 	if port == 0 {
-		return
+		return nil, false
 	}
+	return &net.UDPAddr{IP: net.ParseIP(ip), Port: port}, true
+}
 
-	udpAddr := &net.UDPAddr{IP: net.ParseIP(ip), Port: port}
-
-	n.mu.Lock()
-	n.peers[info.NodeID] = &Peer{
-		NodeID:   info.NodeID,
-		Addr:     udpAddr,
-		LastSeen: time.Now(),
-		Healthy:  true,
+// ensurePeerPSK generates and persists a fresh PSK for peerID if security
+// is enabled and no PSK already exists for the pair.
+func (n *Node) ensurePeerPSK(peerID string) {
+	if n.pskStore == nil {
+		return
+	}
+	if _, ok := n.pskStore.Get(n.ID, peerID); ok {
+		return
+	}
+	if _, err := n.pskStore.GenerateFor(n.ID, peerID); err != nil {
+		n.logger.Warn("failed to generate PSK for peer", "node_id", peerID, "error", err)
 	}
-	n.mu.Unlock()
-
-	n.logger.Info("peer added", "node_id", info.NodeID, "addr", udpAddr)
 }
 
 func (n *Node) removePeer(nodeID string) {
@@ -369,5 +1608,6 @@ func (n *Node) removePeer(nodeID string) {
 	delete(n.peers, nodeID)
 	n.mu.Unlock()
 
+	n.overlay.RemoveCandidate(nodeID)
 	n.logger.Info("peer removed", "node_id", nodeID)
 }
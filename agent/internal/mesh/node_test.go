@@ -1,11 +1,17 @@
 package mesh
 
 import (
+	"context"
+	"encoding/json"
 	"net"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/x0tta6bl4/agent/internal/mesh/discovery"
+	"github.com/x0tta6bl4/agent/internal/mesh/relay"
+	"github.com/x0tta6bl4/agent/internal/mesh/routing"
+	"github.com/x0tta6bl4/agent/internal/security"
 )
 
 func TestNewNode(t *testing.T) {
@@ -88,6 +94,21 @@ func TestGetStats_WithPeers(t *testing.T) {
 	}
 }
 
+func TestGetStats_ReportsOverlayMembership(t *testing.T) {
+	n := NewNode("stats-node3", 5020, nil)
+	n.started = time.Now()
+	n.overlay.AddCandidate("peer-a")
+
+	stats := n.GetStats()
+
+	if stats["overlay_candidate_count"] != 1 {
+		t.Errorf("overlay_candidate_count = %v, want 1", stats["overlay_candidate_count"])
+	}
+	if stats["overlay_mesh_size"] != 0 {
+		t.Errorf("overlay_mesh_size = %v, want 0 (heartbeat hasn't run)", stats["overlay_mesh_size"])
+	}
+}
+
 func TestGetPeers(t *testing.T) {
 	n := NewNode("peer-node", 5003, nil)
 	n.peers["x"] = &Peer{NodeID: "x", Healthy: true}
@@ -197,6 +218,109 @@ func TestAddPeerFromDiscovery_ShortAddress(t *testing.T) {
 	}
 }
 
+func TestAddPeerFromDiscovery_MultipleAddressesPopulateEndpoints(t *testing.T) {
+	n := NewNode("disc-multi", 5017, nil)
+
+	info := discovery.PeerInfo{
+		NodeID: "peer-multi",
+		Addresses: [][]any{
+			{"192.168.1.10", float64(5000)},
+			{"10.0.0.5", float64(5001)},
+		},
+	}
+	n.addPeerFromDiscovery(info)
+
+	peer, ok := n.peers["peer-multi"]
+	if !ok {
+		t.Fatal("peer-multi not added")
+	}
+	if len(peer.Endpoints) != 2 {
+		t.Fatalf("Endpoints = %v, want 2 entries", peer.Endpoints)
+	}
+	for _, ep := range peer.Endpoints {
+		if ep.Source != endpointDirect {
+			t.Errorf("Source = %s, want %s", ep.Source, endpointDirect)
+		}
+	}
+}
+
+func TestAddPeerFromDiscovery_StartsOnRelayWhenConfigured(t *testing.T) {
+	n := NewNode("disc-relay", 5018, nil)
+	n.relayClient = relay.NewClient("disc-relay", "ws://127.0.0.1:9", nil)
+
+	info := discovery.PeerInfo{
+		NodeID:    "peer-relayed",
+		Addresses: [][]any{{"192.168.1.10", float64(5000)}},
+	}
+	n.addPeerFromDiscovery(info)
+
+	peer, ok := n.peers["peer-relayed"]
+	if !ok {
+		t.Fatal("peer-relayed not added")
+	}
+	if !peer.viaRelay {
+		t.Error("peer discovered while a relay is configured should start viaRelay=true")
+	}
+
+	found := false
+	for _, ep := range peer.Endpoints {
+		if ep.Source == endpointRelay {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Endpoints should include a relay entry when starting on relay")
+	}
+}
+
+func TestCheckPeerHealth_DemotesToRelayWhenConfigured(t *testing.T) {
+	n := NewNode("health-relay", 5019, nil)
+	n.State = StateActive
+	n.started = time.Now()
+	n.relayClient = relay.NewClient("health-relay", "ws://127.0.0.1:9", nil)
+
+	n.peers["stale"] = &Peer{
+		NodeID:   "stale",
+		Healthy:  true,
+		LastSeen: time.Now().Add(-60 * time.Second),
+	}
+
+	n.checkPeerHealth()
+
+	if !n.peers["stale"].viaRelay {
+		t.Error("stale peer should be routed via relay once a relay is configured")
+	}
+}
+
+func TestHandleDiscoFrame_PongPromotesPeerOffRelay(t *testing.T) {
+	n := NewNode("disco-node", 5021, nil)
+	peerAddr := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5000}
+	n.peers["peer-disco"] = &Peer{
+		NodeID:   "peer-disco",
+		Addr:     peerAddr,
+		viaRelay: true,
+	}
+
+	pong := discoPongMsg{Nonce: 1, YourAddr: "203.0.113.5:4242"}
+	body, err := json.Marshal(pong)
+	if err != nil {
+		t.Fatalf("marshal pong: %v", err)
+	}
+
+	n.handleDiscoFrame("peer-disco", frameDiscoPong, body, peerAddr)
+
+	peer := n.peers["peer-disco"]
+	if peer.viaRelay {
+		t.Error("peer should be promoted off the relay after a disco pong")
+	}
+	if peer.ReflexiveAddr == nil || peer.ReflexiveAddr.String() != peerAddr.String() {
+		t.Errorf("ReflexiveAddr = %v, want %v", peer.ReflexiveAddr, peerAddr)
+	}
+	if n.reflexiveAddr == nil || n.reflexiveAddr.Port != 4242 {
+		t.Errorf("node reflexiveAddr = %v, want port 4242", n.reflexiveAddr)
+	}
+}
+
 func TestRemovePeer(t *testing.T) {
 	n := NewNode("remove-node", 5009, nil)
 	n.peers["to-remove"] = &Peer{NodeID: "to-remove"}
@@ -206,6 +330,136 @@ func TestRemovePeer(t *testing.T) {
 	}
 }
 
+func TestSetMode_DefaultsToEdge(t *testing.T) {
+	n := NewNode("mode-node", 5011, nil)
+	if n.mode != ModeEdge {
+		t.Errorf("mode = %s, want %s", n.mode, ModeEdge)
+	}
+}
+
+func TestHandleRoutingFrame_BootstrapSkipsForward(t *testing.T) {
+	n := NewNode("bootstrap-node", 5012, nil)
+	n.SetMode(ModeBootstrap)
+
+	var handlerCalled bool
+	n.OnMessage(func(data []byte, sender string, addr *net.UDPAddr) {
+		handlerCalled = true
+	})
+
+	env := forwardEnvelope{
+		Header:  routing.ForwardHeader{TTL: routing.DefaultTTL, Src: "other", Dst: n.ID, Seq: 1},
+		Payload: []byte("hi"),
+	}
+	body, _ := json.Marshal(env)
+	n.handleRoutingFrame(frameForward, body, nil)
+
+	if handlerCalled {
+		t.Error("bootstrap node should not process forwarded payloads")
+	}
+}
+
+func TestHandlePeerListFrame_RequestRespondsWithPeers(t *testing.T) {
+	n := NewNode("peerlist-node", 5013, nil)
+	n.peers["peer-a"] = &Peer{NodeID: "peer-a", Addr: &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 6000}}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("listen responder: %v", err)
+	}
+	defer conn.Close()
+	n.conn = conn
+
+	requester, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("listen requester: %v", err)
+	}
+	defer requester.Close()
+
+	n.handlePeerListFrame(framePeerListRequest, nil, requester.LocalAddr().(*net.UDPAddr))
+
+	requester.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 4096)
+	nr, _, err := requester.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("read peer list response: %v", err)
+	}
+	prefix := peerListMagic + string(framePeerListResponse)
+	if nr < len(prefix) || string(buf[:len(prefix)]) != prefix {
+		t.Fatalf("response = %q, want peer list response frame", buf[:nr])
+	}
+	var entries []peerListEntry
+	if err := json.Unmarshal(buf[len(prefix):nr], &entries); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].NodeID != "peer-a" {
+		t.Errorf("entries = %+v, want [peer-a]", entries)
+	}
+}
+
+func TestObfuscate_NoSecurityReturnsUnchanged(t *testing.T) {
+	n := NewNode("obf-node", 5014, nil)
+	data := []byte("hello")
+	if got := n.obfuscate("peer-x", data); string(got) != string(data) {
+		t.Errorf("obfuscate() = %q, want unchanged %q", got, data)
+	}
+}
+
+func TestObfuscateDeobfuscate_XORRoundTrip(t *testing.T) {
+	store, err := security.NewStore(filepath.Join(t.TempDir(), "psk.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	n := NewNode("obf-node2", 5015, nil)
+	n.EnableSecurity(store, "xor")
+	if _, err := store.GenerateFor(n.ID, "peer-y"); err != nil {
+		t.Fatalf("GenerateFor: %v", err)
+	}
+
+	data := []byte("hello mesh")
+	cipher := n.obfuscate("peer-y", data)
+	if string(cipher) == string(data) {
+		t.Error("obfuscate() should transform data when a PSK exists")
+	}
+
+	plain, ok := n.deobfuscate("peer-y", cipher)
+	if !ok {
+		t.Fatal("deobfuscate() should succeed")
+	}
+	if string(plain) != string(data) {
+		t.Errorf("deobfuscate() = %q, want %q", plain, data)
+	}
+}
+
+func TestDeobfuscate_AESAcceptsPreviousKeyDuringOverlap(t *testing.T) {
+	store, err := security.NewStore(filepath.Join(t.TempDir(), "psk.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	n := NewNode("obf-node3", 5016, nil)
+	n.EnableSecurity(store, "aes")
+	store.GenerateFor(n.ID, "peer-z")
+
+	data := []byte("pre-rotation payload")
+	cipher := n.obfuscate("peer-z", data)
+
+	// Rotate: the old Current key moves to Previous.
+	store.ProposeRotation(n.ID, "peer-z")
+	if err := store.Commit(n.ID, "peer-z"); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	plain, ok := n.deobfuscate("peer-z", cipher)
+	if !ok {
+		t.Fatal("deobfuscate() should still accept the previous key during overlap")
+	}
+	if string(plain) != string(data) {
+		t.Errorf("deobfuscate() = %q, want %q", plain, data)
+	}
+}
+
 func TestGetStats_UptimeSec(t *testing.T) {
 	n := NewNode("uptime-node", 5010, nil)
 	n.started = time.Now().Add(-5 * time.Second)
@@ -216,3 +470,61 @@ func TestGetStats_UptimeSec(t *testing.T) {
 		t.Errorf("uptime_sec = %v, want >= 4.0", stats["uptime_sec"])
 	}
 }
+
+func TestUpdateFederationPeering_RequiresFederationEnabled(t *testing.T) {
+	n := NewNode("test-node", 5000, nil)
+	if err := n.UpdateFederationPeering(PeeringInfo{MeshID: "mesh-b"}); err == nil {
+		t.Error("expected error when federation is not enabled")
+	}
+}
+
+func TestUpdateFederationPeering_ReplacesACL(t *testing.T) {
+	n := NewNode("test-node", 5000, nil)
+	n.EnableFederation("mesh-a", []PeeringInfo{
+		{MeshID: "mesh-b", AllowedServices: []string{"old-svc"}},
+	})
+
+	if err := n.UpdateFederationPeering(PeeringInfo{MeshID: "mesh-b", AllowedServices: []string{"new-svc"}}); err != nil {
+		t.Fatalf("UpdateFederationPeering: %v", err)
+	}
+	if n.federation.meshes["mesh-b"].allowedServices["old-svc"] {
+		t.Error("old-svc should no longer be allowed")
+	}
+	if !n.federation.meshes["mesh-b"].allowedServices["new-svc"] {
+		t.Error("new-svc should be allowed")
+	}
+}
+
+func TestRemoveFederationPeering(t *testing.T) {
+	n := NewNode("test-node", 5000, nil)
+	n.EnableFederation("mesh-a", []PeeringInfo{{MeshID: "mesh-b"}})
+
+	if err := n.RemoveFederationPeering("mesh-b"); err != nil {
+		t.Fatalf("RemoveFederationPeering: %v", err)
+	}
+	if _, ok := n.federation.meshes["mesh-b"]; ok {
+		t.Error("mesh-b peering should be removed")
+	}
+}
+
+func TestStop_IdempotentAndContextCancelStopsLoops(t *testing.T) {
+	n := NewNode("test-node", 0, nil)
+	if err := n.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	n.Stop()
+	n.Stop() // must not panic
+
+	done := make(chan struct{})
+	go func() {
+		n.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("background loops did not exit within 2s of Stop")
+	}
+}
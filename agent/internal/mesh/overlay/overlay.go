@@ -0,0 +1,430 @@
+// Package overlay implements GossipSub/BlossomSub-style scored mesh
+// management on top of a larger candidate set: a heartbeat goroutine grafts
+// new peers into the active mesh when membership drops below a low
+// watermark and prunes low-scoring or over-provisioned peers above a high
+// watermark, while a lightweight IHAVE/IWANT gossip layer lets non-mesh
+// peers backfill messages they missed without full flooding.
+package overlay
+
+import (
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+// Default target-degree tuning, matching libp2p GossipSub's D/Dlo/Dhi.
+const (
+	DefaultD   = 6
+	DefaultDlo = 4
+	DefaultDhi = 12
+
+	DefaultHeartbeatInterval = 1 * time.Second
+	DefaultScoreThreshold    = -10.0
+	DefaultBackoffWindow     = 1 * time.Minute
+	DefaultGossipFanout      = 3
+	defaultSeenMsgTTL        = 2 * time.Minute
+
+	// scoreEWMAAlpha weights how quickly a peer's latency/invalid-message
+	// EWMAs track new samples vs their history.
+	scoreEWMAAlpha = 0.25
+)
+
+// Config tunes the overlay's target degree, scoring, and gossip fanout.
+type Config struct {
+	D   int
+	Dlo int
+	Dhi int
+
+	HeartbeatInterval time.Duration
+	ScoreThreshold    float64
+	BackoffWindow     time.Duration
+	GossipFanout      int
+}
+
+// DefaultConfig returns GossipSub-style defaults.
+func DefaultConfig() Config {
+	return Config{
+		D:                 DefaultD,
+		Dlo:               DefaultDlo,
+		Dhi:               DefaultDhi,
+		HeartbeatInterval: DefaultHeartbeatInterval,
+		ScoreThreshold:    DefaultScoreThreshold,
+		BackoffWindow:     DefaultBackoffWindow,
+		GossipFanout:      DefaultGossipFanout,
+	}
+}
+
+// peerScore tracks the EWMA components behind one peer's composite score.
+type peerScore struct {
+	latencyEWMAMs   float64
+	firstDeliveries float64
+	invalidEWMA     float64
+	inMesh          bool
+	meshSince       time.Time
+	backoffUntil    time.Time
+}
+
+// value combines the tracked components into a single comparable score:
+// first-message-deliveries push it up, latency and invalid messages pull
+// it down, and time-in-mesh rewards peers that have stuck around.
+func (s *peerScore) value() float64 {
+	v := s.firstDeliveries - s.invalidEWMA*10 - s.latencyEWMAMs/100
+	if s.inMesh {
+		v += time.Since(s.meshSince).Seconds() / 60
+	}
+	return v
+}
+
+// Overlay manages one node's mesh membership and per-peer GossipSub-style
+// scoring over a larger candidate set supplied by the caller (e.g. every
+// peer discovery learns about, mesh or not).
+type Overlay struct {
+	cfg Config
+
+	mu         sync.Mutex
+	candidates map[string]struct{}
+	mesh       map[string]struct{}
+	scores     map[string]*peerScore
+	seenMsgs   map[string]time.Time
+	payloads   map[string][]byte
+
+	stopCh chan struct{}
+}
+
+// New creates an Overlay with cfg. Call Start to begin the heartbeat loop.
+func New(cfg Config) *Overlay {
+	return &Overlay{
+		cfg:        cfg,
+		candidates: make(map[string]struct{}),
+		mesh:       make(map[string]struct{}),
+		scores:     make(map[string]*peerScore),
+		seenMsgs:   make(map[string]time.Time),
+		payloads:   make(map[string][]byte),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Start begins the heartbeat goroutine that GRAFTs and PRUNEs peers. send is
+// called with the GRAFT/PRUNE decisions after every heartbeat.
+func (o *Overlay) Start(send func(grafted, pruned []string)) {
+	go o.heartbeatLoop(send)
+}
+
+// Stop halts the heartbeat goroutine.
+func (o *Overlay) Stop() {
+	close(o.stopCh)
+}
+
+// AddCandidate registers peerID as eligible for grafting into the mesh,
+// e.g. whenever discovery or PEER_LIST learns of a new peer.
+func (o *Overlay) AddCandidate(peerID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.candidates[peerID] = struct{}{}
+	if _, ok := o.scores[peerID]; !ok {
+		o.scores[peerID] = &peerScore{}
+	}
+}
+
+// RemoveCandidate drops peerID entirely — it's no longer reachable at all,
+// not just pruned from the mesh.
+func (o *Overlay) RemoveCandidate(peerID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.candidates, peerID)
+	delete(o.mesh, peerID)
+	delete(o.scores, peerID)
+}
+
+// RecordDelivery updates peerID's score after a message arrives from it:
+// latency is folded into the EWMA, and firstDelivery marks whether this
+// node hadn't seen the message from any other peer yet (see MarkSeen).
+func (o *Overlay) RecordDelivery(peerID string, latency time.Duration, firstDelivery bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s, ok := o.scores[peerID]
+	if !ok {
+		s = &peerScore{}
+		o.scores[peerID] = s
+	}
+	s.latencyEWMAMs = ewma(s.latencyEWMAMs, float64(latency.Milliseconds()))
+	if firstDelivery {
+		s.firstDeliveries++
+	}
+}
+
+// RecordInvalidMessage penalizes peerID's score for sending a message that
+// failed validation (e.g. bad signature, malformed frame).
+func (o *Overlay) RecordInvalidMessage(peerID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s, ok := o.scores[peerID]
+	if !ok {
+		s = &peerScore{}
+		o.scores[peerID] = s
+	}
+	s.invalidEWMA = ewma(s.invalidEWMA, 1)
+}
+
+// ewma folds sample into prev with scoreEWMAAlpha, seeding the average
+// with the first sample instead of smoothing from zero.
+func ewma(prev, sample float64) float64 {
+	if prev == 0 {
+		return sample
+	}
+	return (1-scoreEWMAAlpha)*prev + scoreEWMAAlpha*sample
+}
+
+// InMesh reports whether peerID is currently a full mesh member.
+func (o *Overlay) InMesh(peerID string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, ok := o.mesh[peerID]
+	return ok
+}
+
+// MeshPeers returns the current mesh membership.
+func (o *Overlay) MeshPeers() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	peers := make([]string, 0, len(o.mesh))
+	for id := range o.mesh {
+		peers = append(peers, id)
+	}
+	return peers
+}
+
+// MarkSeen records msgID as seen and reports whether it was the first time
+// (i.e. this is a first-message-delivery from whichever peer calls it).
+func (o *Overlay) MarkSeen(msgID string) (firstDelivery bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range o.seenMsgs {
+		if now.Sub(t) > defaultSeenMsgTTL {
+			delete(o.seenMsgs, id)
+			delete(o.payloads, id)
+		}
+	}
+
+	if _, ok := o.seenMsgs[msgID]; ok {
+		return false
+	}
+	o.seenMsgs[msgID] = now
+	return true
+}
+
+// CachePayload stores payload under msgID so a later IWANT for it can be
+// served. Call after MarkSeen(msgID) returns true.
+func (o *Overlay) CachePayload(msgID string, payload []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.payloads[msgID] = payload
+}
+
+// Payload returns the cached payload for msgID, if it's still within the
+// seen-message window.
+func (o *Overlay) Payload(msgID string) ([]byte, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	p, ok := o.payloads[msgID]
+	return p, ok
+}
+
+// RecentMessageIDs returns message IDs seen within the last window, for
+// gossiping as an IHAVE advertisement to non-mesh peers.
+func (o *Overlay) RecentMessageIDs(window time.Duration) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	ids := make([]string, 0, len(o.seenMsgs))
+	for id, t := range o.seenMsgs {
+		if now.Sub(t) <= window {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Missing filters candidateIDs down to the ones not yet seen, so the
+// caller can IWANT only what it's actually missing from an IHAVE.
+func (o *Overlay) Missing(candidateIDs []string) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	missing := make([]string, 0)
+	for _, id := range candidateIDs {
+		if _, ok := o.seenMsgs[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// GossipTargets picks up to Config.GossipFanout peers from the candidate
+// set that are not currently mesh members, the non-mesh peers IHAVE
+// gossip is sent to so reachability doesn't depend solely on the mesh.
+func (o *Overlay) GossipTargets() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	nonMesh := make([]string, 0, len(o.candidates))
+	for id := range o.candidates {
+		if _, ok := o.mesh[id]; !ok {
+			nonMesh = append(nonMesh, id)
+		}
+	}
+	return pickRandomSubset(nonMesh, o.cfg.GossipFanout)
+}
+
+// Stats exposes mesh membership and score summary in a form suitable for
+// Node.GetStats/telemetry.
+func (o *Overlay) Stats() map[string]any {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	scoreSum := 0.0
+	for id := range o.mesh {
+		if s, ok := o.scores[id]; ok {
+			scoreSum += s.value()
+		}
+	}
+	avgScore := 0.0
+	if len(o.mesh) > 0 {
+		avgScore = scoreSum / float64(len(o.mesh))
+	}
+
+	return map[string]any{
+		"overlay_mesh_size":       len(o.mesh),
+		"overlay_candidate_count": len(o.candidates),
+		"overlay_mesh_score_avg":  avgScore,
+	}
+}
+
+func (o *Overlay) heartbeatLoop(send func(grafted, pruned []string)) {
+	interval := o.cfg.HeartbeatInterval
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			grafted, pruned := o.heartbeat()
+			if send != nil && (len(grafted) > 0 || len(pruned) > 0) {
+				send(grafted, pruned)
+			}
+		case <-o.stopCh:
+			return
+		}
+	}
+}
+
+// heartbeat applies one round of GRAFT/PRUNE decisions and returns the
+// peer IDs that changed mesh membership.
+func (o *Overlay) heartbeat() (grafted, pruned []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+
+	// Prune low-scoring members regardless of current mesh size.
+	for id := range o.mesh {
+		s, ok := o.scores[id]
+		if !ok {
+			continue
+		}
+		if s.value() < o.cfg.ScoreThreshold {
+			delete(o.mesh, id)
+			s.inMesh = false
+			s.backoffUntil = now.Add(o.cfg.BackoffWindow)
+			pruned = append(pruned, id)
+		}
+	}
+
+	// Prune excess members above Dhi, worst score first.
+	if len(o.mesh) > o.cfg.Dhi {
+		members := make([]string, 0, len(o.mesh))
+		for id := range o.mesh {
+			members = append(members, id)
+		}
+		sortByScoreAsc(members, o.scores)
+		for _, id := range members[:len(o.mesh)-o.cfg.Dhi] {
+			delete(o.mesh, id)
+			if s, ok := o.scores[id]; ok {
+				s.inMesh = false
+				s.backoffUntil = now.Add(o.cfg.BackoffWindow)
+			}
+			pruned = append(pruned, id)
+		}
+	}
+
+	// Graft new members up to D when below Dlo.
+	if len(o.mesh) < o.cfg.Dlo {
+		candidates := make([]string, 0, len(o.candidates))
+		for id := range o.candidates {
+			if _, inMesh := o.mesh[id]; inMesh {
+				continue
+			}
+			if s, ok := o.scores[id]; ok && now.Before(s.backoffUntil) {
+				continue
+			}
+			candidates = append(candidates, id)
+		}
+		need := o.cfg.D - len(o.mesh)
+		for _, id := range pickRandomSubset(candidates, need) {
+			o.mesh[id] = struct{}{}
+			s, ok := o.scores[id]
+			if !ok {
+				s = &peerScore{}
+				o.scores[id] = s
+			}
+			s.inMesh = true
+			s.meshSince = now
+			grafted = append(grafted, id)
+		}
+	}
+
+	return grafted, pruned
+}
+
+// pickRandomSubset returns up to n distinct elements of ids in random
+// order.
+func pickRandomSubset(ids []string, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	if len(ids) <= n {
+		return ids
+	}
+	shuffled := make([]string, len(ids))
+	copy(shuffled, ids)
+	mrand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	return shuffled[:n]
+}
+
+// sortByScoreAsc sorts ids in place by ascending score, worst first.
+func sortByScoreAsc(ids []string, scores map[string]*peerScore) {
+	for i := 1; i < len(ids); i++ {
+		for j := i; j > 0; j-- {
+			if valueOf(scores, ids[j]) < valueOf(scores, ids[j-1]) {
+				ids[j], ids[j-1] = ids[j-1], ids[j]
+			} else {
+				break
+			}
+		}
+	}
+}
+
+func valueOf(scores map[string]*peerScore, id string) float64 {
+	if s, ok := scores[id]; ok {
+		return s.value()
+	}
+	return 0
+}
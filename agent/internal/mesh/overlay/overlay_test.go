@@ -0,0 +1,136 @@
+package overlay
+
+import (
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.D, cfg.Dlo, cfg.Dhi = 2, 1, 3
+	return cfg
+}
+
+func TestHeartbeat_GraftsWhenBelowDlo(t *testing.T) {
+	o := New(testConfig())
+	o.AddCandidate("a")
+	o.AddCandidate("b")
+	o.AddCandidate("c")
+
+	grafted, pruned := o.heartbeat()
+	if len(pruned) != 0 {
+		t.Errorf("expected no prunes, got %v", pruned)
+	}
+	if len(grafted) != 2 {
+		t.Errorf("grafted = %v, want 2 peers (D=2)", grafted)
+	}
+	if len(o.MeshPeers()) != 2 {
+		t.Errorf("mesh size = %d, want 2", len(o.MeshPeers()))
+	}
+}
+
+func TestHeartbeat_PrunesLowScoringPeer(t *testing.T) {
+	o := New(testConfig())
+	o.AddCandidate("a")
+	o.mesh["a"] = struct{}{}
+	o.scores["a"] = &peerScore{invalidEWMA: 100} // drives value() well below ScoreThreshold
+
+	_, pruned := o.heartbeat()
+	if len(pruned) != 1 || pruned[0] != "a" {
+		t.Errorf("pruned = %v, want [a]", pruned)
+	}
+	if o.InMesh("a") {
+		t.Error("expected a to be pruned from mesh")
+	}
+}
+
+func TestHeartbeat_PrunedPeerIsBackedOff(t *testing.T) {
+	o := New(testConfig())
+	o.AddCandidate("a")
+	o.mesh["a"] = struct{}{}
+	o.scores["a"] = &peerScore{invalidEWMA: 100}
+	o.heartbeat()
+
+	// Force another graft round: a is the only candidate, but it should be
+	// skipped while backed off.
+	_, _ = o.heartbeat()
+	if o.InMesh("a") {
+		t.Error("backed-off peer should not be re-grafted")
+	}
+}
+
+func TestHeartbeat_PrunesExcessAboveDhi(t *testing.T) {
+	o := New(testConfig())
+	for _, id := range []string{"a", "b", "c", "d"} {
+		o.AddCandidate(id)
+		o.mesh[id] = struct{}{}
+	}
+
+	_, pruned := o.heartbeat()
+	if len(o.MeshPeers()) != o.cfg.Dhi {
+		t.Errorf("mesh size = %d, want Dhi=%d", len(o.MeshPeers()), o.cfg.Dhi)
+	}
+	if len(pruned) != 1 {
+		t.Errorf("pruned = %v, want exactly 1 peer removed", pruned)
+	}
+}
+
+func TestRecordDelivery_TracksFirstDeliveries(t *testing.T) {
+	o := New(DefaultConfig())
+	o.AddCandidate("a")
+	o.RecordDelivery("a", 10*time.Millisecond, true)
+	o.RecordDelivery("a", 10*time.Millisecond, false)
+
+	if o.scores["a"].firstDeliveries != 1 {
+		t.Errorf("firstDeliveries = %v, want 1", o.scores["a"].firstDeliveries)
+	}
+}
+
+func TestMarkSeen_FirstDeliveryOnlyOnce(t *testing.T) {
+	o := New(DefaultConfig())
+	if !o.MarkSeen("msg-1") {
+		t.Error("first MarkSeen should report firstDelivery=true")
+	}
+	if o.MarkSeen("msg-1") {
+		t.Error("second MarkSeen of the same ID should report firstDelivery=false")
+	}
+}
+
+func TestMissing_FiltersAlreadySeen(t *testing.T) {
+	o := New(DefaultConfig())
+	o.MarkSeen("msg-1")
+
+	missing := o.Missing([]string{"msg-1", "msg-2"})
+	if len(missing) != 1 || missing[0] != "msg-2" {
+		t.Errorf("Missing = %v, want [msg-2]", missing)
+	}
+}
+
+func TestCachePayload_RoundTrips(t *testing.T) {
+	o := New(DefaultConfig())
+	o.MarkSeen("msg-1")
+	o.CachePayload("msg-1", []byte("hello"))
+
+	got, ok := o.Payload("msg-1")
+	if !ok || string(got) != "hello" {
+		t.Errorf("Payload(msg-1) = %q, %v, want hello, true", got, ok)
+	}
+	if _, ok := o.Payload("msg-2"); ok {
+		t.Error("Payload(msg-2) should be absent")
+	}
+}
+
+func TestStats_ReportsMeshSizeAndAvgScore(t *testing.T) {
+	o := New(testConfig())
+	o.AddCandidate("a")
+	o.AddCandidate("b")
+	o.heartbeat()
+
+	stats := o.Stats()
+	if stats["overlay_mesh_size"] != 2 {
+		t.Errorf("overlay_mesh_size = %v, want 2", stats["overlay_mesh_size"])
+	}
+	if stats["overlay_candidate_count"] != 2 {
+		t.Errorf("overlay_candidate_count = %v, want 2", stats["overlay_candidate_count"])
+	}
+}
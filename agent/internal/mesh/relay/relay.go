@@ -0,0 +1,300 @@
+// Package relay provides a WebSocket relay fallback for mesh peers that
+// cannot establish a direct UDP path (symmetric NAT, firewalls blocking the
+// mesh data port). A Client opens one long-lived connection to a relay
+// Server and multiplexes per-peer traffic over it; Server fans frames back
+// out to whichever client currently owns the destination peer ID.
+package relay
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Frame is the unit of data multiplexed over the relay WebSocket.
+type Frame struct {
+	SrcPeerID string `json:"src_peer_id"`
+	DstPeerID string `json:"dst_peer_id"`
+	Payload   []byte `json:"payload"`
+	MAC       []byte `json:"mac"`
+}
+
+// sign computes an HMAC-SHA256 over the src/dst/payload using authSecret.
+func sign(secret []byte, f Frame) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(f.SrcPeerID))
+	mac.Write([]byte(f.DstPeerID))
+	mac.Write(f.Payload)
+	return mac.Sum(nil)
+}
+
+func verify(secret []byte, f Frame) bool {
+	return hmac.Equal(f.MAC, sign(secret, Frame{SrcPeerID: f.SrcPeerID, DstPeerID: f.DstPeerID, Payload: f.Payload}))
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// Client maintains one WebSocket connection to a relay endpoint and
+// multiplexes frames for the local node's peers over it.
+type Client struct {
+	nodeID     string
+	endpoint   string
+	authSecret []byte
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	closed  bool
+	OnFrame func(f Frame)
+
+	// writeMu serializes writes to conn: gorilla/websocket forbids
+	// concurrent writers on one *Conn, but Send can be called concurrently
+	// for different destination peers (discovery, routing, healing
+	// reroute, the admin API) while they all share this one connection.
+	writeMu sync.Mutex
+
+	logger *slog.Logger
+}
+
+// NewClient creates a relay client for nodeID that will dial endpoint.
+func NewClient(nodeID, endpoint string, authSecret []byte) *Client {
+	return &Client{
+		nodeID:     nodeID,
+		endpoint:   endpoint,
+		authSecret: authSecret,
+		logger:     slog.Default().With("component", "relay-client", "endpoint", endpoint),
+	}
+}
+
+// Connect dials the relay endpoint, registers nodeID with the server (see
+// Server.handleWS), and starts the read loop. It reconnects automatically
+// in the background until Close is called. Connect doesn't return until
+// the server has acked registration, so once it succeeds the caller can
+// rely on the server's routing table already knowing this peer — no race
+// against a concurrent peer's first Send.
+func (c *Client) Connect() error {
+	conn, _, err := websocket.DefaultDialer.Dial(c.endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("dial relay %s: %w", c.endpoint, err)
+	}
+
+	reg := Frame{SrcPeerID: c.nodeID}
+	reg.MAC = sign(c.authSecret, reg)
+	c.writeMu.Lock()
+	err = conn.WriteJSON(reg)
+	c.writeMu.Unlock()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("register with relay %s: %w", c.endpoint, err)
+	}
+	var ack Frame
+	if err := conn.ReadJSON(&ack); err != nil {
+		conn.Close()
+		return fmt.Errorf("await relay registration ack from %s: %w", c.endpoint, err)
+	}
+	if !verify(c.authSecret, ack) || ack.DstPeerID != c.nodeID {
+		conn.Close()
+		return fmt.Errorf("relay %s sent an invalid registration ack", c.endpoint)
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+
+	go c.readLoop()
+	c.logger.Info("connected to relay")
+	return nil
+}
+
+// Close shuts down the relay connection.
+func (c *Client) Close() {
+	c.mu.Lock()
+	c.closed = true
+	conn := c.conn
+	c.mu.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// Send routes a payload to dstPeerID through the relay.
+func (c *Client) Send(dstPeerID string, payload []byte) error {
+	f := Frame{SrcPeerID: c.nodeID, DstPeerID: dstPeerID, Payload: payload}
+	f.MAC = sign(c.authSecret, f)
+
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("relay: not connected")
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return conn.WriteJSON(f)
+}
+
+func (c *Client) readLoop() {
+	for {
+		c.mu.Lock()
+		conn := c.conn
+		closed := c.closed
+		c.mu.Unlock()
+		if closed || conn == nil {
+			return
+		}
+
+		var f Frame
+		if err := conn.ReadJSON(&f); err != nil {
+			if !closed {
+				c.logger.Warn("relay read failed, reconnecting", "error", err)
+				c.reconnect()
+			}
+			return
+		}
+
+		if !verify(c.authSecret, f) {
+			c.logger.Warn("dropping relay frame with invalid MAC", "src", f.SrcPeerID)
+			continue
+		}
+
+		if c.OnFrame != nil {
+			c.OnFrame(f)
+		}
+	}
+}
+
+func (c *Client) reconnect() {
+	for backoff := time.Second; ; backoff *= 2 {
+		if backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+		time.Sleep(backoff)
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		if err := c.Connect(); err == nil {
+			return
+		}
+	}
+}
+
+// relayConn pairs a registered client's WebSocket with a write mutex:
+// gorilla/websocket forbids concurrent writers on one *Conn, but once a
+// peer is registered its connection can be written to both by its own
+// handleWS goroutine (the registration ack) and by any other connection's
+// handleWS goroutine forwarding a frame to it, so every write has to go
+// through writeJSON rather than conn.WriteJSON directly.
+type relayConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (rc *relayConn) writeJSON(v any) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.conn.WriteJSON(v)
+}
+
+// Server accepts authenticated relay connections from mesh nodes and
+// forwards frames between whichever clients currently hold the src/dst
+// peer IDs.
+type Server struct {
+	authSecret []byte
+
+	mu      sync.RWMutex
+	clients map[string]*relayConn // peerID -> connection
+
+	logger *slog.Logger
+}
+
+// NewServer creates a relay server authenticated with authSecret.
+func NewServer(authSecret []byte) *Server {
+	return &Server{
+		authSecret: authSecret,
+		clients:    make(map[string]*relayConn),
+		logger:     slog.Default().With("component", "relay-server"),
+	}
+}
+
+// ListenAndServe starts the relay HTTP/WebSocket listener on addr.
+func (s *Server) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/relay", s.handleWS)
+	s.logger.Info("relay server listening", "addr", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// handleWS expects the very first frame on a new connection to be a
+// registration frame (see Client.Connect): SrcPeerID set, DstPeerID empty.
+// It registers that peer into s.clients and acks before entering the
+// normal forwarding loop, so a client's Connect can't return — and a peer
+// can't be sent to — before the server's routing table actually has it.
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.Warn("websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	var reg Frame
+	if err := conn.ReadJSON(&reg); err != nil {
+		return
+	}
+	if !verify(s.authSecret, reg) || reg.SrcPeerID == "" {
+		s.logger.Warn("rejecting relay registration with invalid MAC or empty peer ID")
+		return
+	}
+	peerID := reg.SrcPeerID
+	rc := &relayConn{conn: conn}
+
+	s.mu.Lock()
+	s.clients[peerID] = rc
+	s.mu.Unlock()
+
+	ack := Frame{DstPeerID: peerID}
+	ack.MAC = sign(s.authSecret, ack)
+	if err := rc.writeJSON(ack); err != nil {
+		s.mu.Lock()
+		delete(s.clients, peerID)
+		s.mu.Unlock()
+		return
+	}
+
+	for {
+		var f Frame
+		if err := conn.ReadJSON(&f); err != nil {
+			break
+		}
+		if !verify(s.authSecret, f) {
+			continue
+		}
+
+		s.mu.RLock()
+		dst, ok := s.clients[f.DstPeerID]
+		s.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		dst.writeJSON(f)
+	}
+
+	s.mu.Lock()
+	delete(s.clients, peerID)
+	s.mu.Unlock()
+}
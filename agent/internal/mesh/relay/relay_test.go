@@ -0,0 +1,119 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	secret := []byte("shared-secret")
+	f := Frame{SrcPeerID: "a", DstPeerID: "b", Payload: []byte("hello")}
+	f.MAC = sign(secret, f)
+
+	if !verify(secret, f) {
+		t.Error("expected frame to verify with matching secret")
+	}
+}
+
+func TestVerifyRejectsWrongSecret(t *testing.T) {
+	f := Frame{SrcPeerID: "a", DstPeerID: "b", Payload: []byte("hello")}
+	f.MAC = sign([]byte("secret-1"), f)
+
+	if verify([]byte("secret-2"), f) {
+		t.Error("frame should not verify with a different secret")
+	}
+}
+
+func TestClientRelaysFrameThroughServer(t *testing.T) {
+	secret := []byte("test-secret")
+	server := NewServer(secret)
+	ts := httptest.NewServer(http.HandlerFunc(server.handleWS))
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/relay"
+
+	received := make(chan Frame, 1)
+	bob := NewClient("bob", wsURL, secret)
+	bob.OnFrame = func(f Frame) { received <- f }
+	if err := bob.Connect(); err != nil {
+		t.Fatalf("bob connect: %v", err)
+	}
+	defer bob.Close()
+
+	alice := NewClient("alice", wsURL, secret)
+	if err := alice.Connect(); err != nil {
+		t.Fatalf("alice connect: %v", err)
+	}
+	defer alice.Close()
+
+	// Connect doesn't return until the server has acked registration, so
+	// both peers are already in the server's routing table here — no
+	// priming send or wall-clock race needed.
+	if err := alice.Send("bob", []byte("hello bob")); err != nil {
+		t.Fatalf("alice send: %v", err)
+	}
+
+	select {
+	case f := <-received:
+		if string(f.Payload) != "hello bob" {
+			t.Errorf("payload = %q, want %q", f.Payload, "hello bob")
+		}
+		if f.SrcPeerID != "alice" {
+			t.Errorf("src = %s, want alice", f.SrcPeerID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for relayed frame")
+	}
+}
+
+// TestClientSendConcurrent exercises Client.Send from multiple goroutines
+// at once (as Node.SendTo does for different destination peers sharing one
+// relayClient) under the race detector, guarding against a regression of
+// the unserialized-write race on the shared *websocket.Conn.
+func TestClientSendConcurrent(t *testing.T) {
+	secret := []byte("test-secret")
+	server := NewServer(secret)
+	ts := httptest.NewServer(http.HandlerFunc(server.handleWS))
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/relay"
+
+	received := make(chan Frame, 50)
+	bob := NewClient("bob", wsURL, secret)
+	bob.OnFrame = func(f Frame) { received <- f }
+	if err := bob.Connect(); err != nil {
+		t.Fatalf("bob connect: %v", err)
+	}
+	defer bob.Close()
+
+	alice := NewClient("alice", wsURL, secret)
+	if err := alice.Connect(); err != nil {
+		t.Fatalf("alice connect: %v", err)
+	}
+	defer alice.Close()
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := alice.Send("bob", []byte{byte(i)}); err != nil {
+				t.Errorf("send %d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		select {
+		case <-received:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for frame %d/%d", i, n)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Package routing turns the mesh into a multi-hop overlay. Each node
+// gossips a link-state advertisement describing its direct neighbors and
+// their latency; every node combines the advertisements it has seen into a
+// full distance table via Floyd-Warshall and derives a next-hop table used
+// to forward traffic to peers it cannot reach directly.
+package routing
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Vertex identifies a node in the routing graph.
+type Vertex = string
+
+// NeighborLink describes one edge in a node's link-state advertisement.
+type NeighborLink struct {
+	NeighborID string  `json:"neighbor_id"`
+	LatencyMs  float64 `json:"latency_ms"`
+	JitterMs   float64 `json:"jitter_ms"`
+}
+
+// Advertisement is gossiped periodically by every node describing its
+// current set of direct neighbors.
+type Advertisement struct {
+	NodeID    string         `json:"node_id"`
+	Neighbors []NeighborLink `json:"neighbors"`
+}
+
+// Config tunes how aggressively the router recomputes its tables.
+type Config struct {
+	JitterToleranceMs         float64
+	JitterToleranceMultiplier float64
+	RecalculateCooldownSec    int
+	AdvertisementIntervalSec  int
+}
+
+// DefaultConfig returns sane defaults for Config.
+func DefaultConfig() Config {
+	return Config{
+		JitterToleranceMs:         5,
+		JitterToleranceMultiplier: 0.2,
+		RecalculateCooldownSec:    5,
+		AdvertisementIntervalSec:  15,
+	}
+}
+
+// Router maintains the link-state graph and derived routing tables for one
+// local node.
+type Router struct {
+	localID string
+	cfg     Config
+
+	mu          sync.RWMutex
+	edges       map[Vertex]map[Vertex]float64 // directed latency graph from advertisements
+	distTable   map[Vertex]map[Vertex]float64
+	nextHop     map[Vertex]map[Vertex]*Vertex
+	lastRecalc  time.Time
+}
+
+// NewRouter creates a Router for localID.
+func NewRouter(localID string, cfg Config) *Router {
+	return &Router{
+		localID:   localID,
+		cfg:       cfg,
+		edges:     map[Vertex]map[Vertex]float64{},
+		distTable: map[Vertex]map[Vertex]float64{},
+		nextHop:   map[Vertex]map[Vertex]*Vertex{},
+	}
+}
+
+// Ingest applies a received link-state advertisement, recomputing the
+// routing tables if any edge changed by more than the jitter tolerance and
+// the recalculation cooldown has elapsed.
+func (r *Router) Ingest(adv Advertisement) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := false
+	existing := r.edges[adv.NodeID]
+	updated := make(map[Vertex]float64, len(adv.Neighbors))
+
+	for _, nb := range adv.Neighbors {
+		updated[nb.NeighborID] = nb.LatencyMs
+
+		old, had := 0.0, false
+		if existing != nil {
+			old, had = existing[nb.NeighborID]
+		}
+		if !had {
+			changed = true
+			continue
+		}
+
+		tolerance := math.Max(r.cfg.JitterToleranceMs, old*r.cfg.JitterToleranceMultiplier)
+		if math.Abs(nb.LatencyMs-old) > tolerance {
+			changed = true
+		}
+	}
+
+	if existing != nil && len(existing) != len(updated) {
+		changed = true
+	}
+
+	r.edges[adv.NodeID] = updated
+
+	if !changed {
+		return
+	}
+
+	cooldown := time.Duration(r.cfg.RecalculateCooldownSec) * time.Second
+	if time.Since(r.lastRecalc) < cooldown {
+		return
+	}
+
+	r.recomputeLocked()
+	r.lastRecalc = time.Now()
+}
+
+// SetLocalNeighbors records the local node's own directly-measured edges
+// (from Peer.Latency) as if they were an advertisement from ourselves, so
+// the local node is part of the graph it routes over.
+func (r *Router) SetLocalNeighbors(neighbors []NeighborLink) {
+	r.Ingest(Advertisement{NodeID: r.localID, Neighbors: neighbors})
+}
+
+// NextHop returns the next-hop node ID to reach dst, or false if dst is
+// unreachable in the currently known graph.
+func (r *Router) NextHop(dst Vertex) (Vertex, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hops, ok := r.nextHop[r.localID]
+	if !ok {
+		return "", false
+	}
+	hop, ok := hops[dst]
+	if !ok || hop == nil {
+		return "", false
+	}
+	return *hop, true
+}
+
+// Config returns the tuning parameters this router was created with.
+func (r *Router) Config() Config {
+	return r.cfg
+}
+
+// Stats exposes the computed routing table in a form suitable for
+// telemetry/GetStats.
+func (r *Router) Stats() map[string]any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reachable := 0
+	if hops, ok := r.nextHop[r.localID]; ok {
+		reachable = len(hops)
+	}
+
+	return map[string]any{
+		"routing_known_nodes":      len(r.edges),
+		"routing_reachable_nodes":  reachable,
+		"routing_last_recalc_unix": r.lastRecalc.Unix(),
+	}
+}
+
+// recomputeLocked rebuilds distTable/nextHop from the current edge set
+// using Floyd-Warshall. Callers must hold r.mu.
+func (r *Router) recomputeLocked() {
+	vertices := make(map[Vertex]struct{})
+	for from, nbrs := range r.edges {
+		vertices[from] = struct{}{}
+		for to := range nbrs {
+			vertices[to] = struct{}{}
+		}
+	}
+
+	dist := make(map[Vertex]map[Vertex]float64, len(vertices))
+	next := make(map[Vertex]map[Vertex]*Vertex, len(vertices))
+
+	for v := range vertices {
+		dist[v] = make(map[Vertex]float64, len(vertices))
+		next[v] = make(map[Vertex]*Vertex, len(vertices))
+		for u := range vertices {
+			if u == v {
+				dist[v][u] = 0
+			} else {
+				dist[v][u] = math.Inf(1)
+			}
+		}
+	}
+
+	for from, nbrs := range r.edges {
+		for to, latency := range nbrs {
+			if latency < dist[from][to] {
+				dist[from][to] = latency
+				hop := to
+				next[from][to] = &hop
+			}
+		}
+	}
+
+	for _, k := range sortedKeys(vertices) {
+		for _, i := range sortedKeys(vertices) {
+			if math.IsInf(dist[i][k], 1) {
+				continue
+			}
+			for _, j := range sortedKeys(vertices) {
+				viaK := dist[i][k] + dist[k][j]
+				if viaK < dist[i][j] {
+					dist[i][j] = viaK
+					next[i][j] = next[i][k]
+				}
+			}
+		}
+	}
+
+	r.distTable = dist
+	r.nextHop = next
+}
+
+func sortedKeys(m map[Vertex]struct{}) []Vertex {
+	keys := make([]Vertex, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ForwardHeader is prepended to payloads that need multi-hop forwarding.
+type ForwardHeader struct {
+	TTL int    `json:"ttl"`
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+	Seq uint64 `json:"seq"`
+}
+
+// DefaultTTL bounds how many hops a forwarded message may travel.
+const DefaultTTL = 16
+
+// DedupCache rejects messages already seen, keyed by (src, seq).
+type DedupCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	ttl  time.Duration
+}
+
+// NewDedupCache creates a cache that forgets entries after ttl.
+func NewDedupCache(ttl time.Duration) *DedupCache {
+	return &DedupCache{seen: make(map[string]time.Time), ttl: ttl}
+}
+
+// SeenBefore records (src, seq) and reports whether it was already present.
+func (d *DedupCache) SeenBefore(src string, seq uint64) bool {
+	key := fmt.Sprintf("%s:%d", src, seq)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for k, t := range d.seen {
+		if now.Sub(t) > d.ttl {
+			delete(d.seen, k)
+		}
+	}
+
+	if _, ok := d.seen[key]; ok {
+		return true
+	}
+	d.seen[key] = now
+	return false
+}
@@ -0,0 +1,95 @@
+package routing
+
+import (
+	"testing"
+	"time"
+)
+
+// testConfig disables the recompute cooldown so tests can issue several
+// Ingest calls back-to-back and observe each recomputed table immediately.
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.RecalculateCooldownSec = 0
+	return cfg
+}
+
+func TestNextHop_DirectNeighbor(t *testing.T) {
+	r := NewRouter("a", testConfig())
+	r.Ingest(Advertisement{NodeID: "a", Neighbors: []NeighborLink{{NeighborID: "b", LatencyMs: 10}}})
+
+	hop, ok := r.NextHop("b")
+	if !ok || hop != "b" {
+		t.Errorf("NextHop(b) = %s, %v, want b, true", hop, ok)
+	}
+}
+
+func TestNextHop_MultiHopViaFloydWarshall(t *testing.T) {
+	r := NewRouter("a", testConfig())
+	r.Ingest(Advertisement{NodeID: "a", Neighbors: []NeighborLink{{NeighborID: "b", LatencyMs: 10}}})
+	r.Ingest(Advertisement{NodeID: "b", Neighbors: []NeighborLink{{NeighborID: "a", LatencyMs: 10}, {NeighborID: "c", LatencyMs: 10}}})
+
+	hop, ok := r.NextHop("c")
+	if !ok || hop != "b" {
+		t.Errorf("NextHop(c) = %s, %v, want b, true", hop, ok)
+	}
+}
+
+func TestNextHop_UnreachableNode(t *testing.T) {
+	r := NewRouter("a", testConfig())
+	if _, ok := r.NextHop("ghost"); ok {
+		t.Error("expected ghost to be unreachable")
+	}
+}
+
+func TestIngest_SkipsRecomputeWithinJitterTolerance(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.JitterToleranceMs = 50
+	r := NewRouter("a", cfg)
+
+	r.Ingest(Advertisement{NodeID: "a", Neighbors: []NeighborLink{{NeighborID: "b", LatencyMs: 100}}})
+	firstRecalc := r.lastRecalc
+
+	// Small jitter within tolerance should not trigger a recompute.
+	r.Ingest(Advertisement{NodeID: "a", Neighbors: []NeighborLink{{NeighborID: "b", LatencyMs: 110}}})
+	if !r.lastRecalc.Equal(firstRecalc) {
+		t.Error("expected no recompute for latency change within jitter tolerance")
+	}
+}
+
+func TestIngest_RespectsCooldown(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.JitterToleranceMs = 1
+	cfg.RecalculateCooldownSec = 60
+	r := NewRouter("a", cfg)
+
+	r.Ingest(Advertisement{NodeID: "a", Neighbors: []NeighborLink{{NeighborID: "b", LatencyMs: 10}}})
+	firstRecalc := r.lastRecalc
+
+	r.Ingest(Advertisement{NodeID: "a", Neighbors: []NeighborLink{{NeighborID: "b", LatencyMs: 500}}})
+	if !r.lastRecalc.Equal(firstRecalc) {
+		t.Error("expected cooldown to suppress the second recompute")
+	}
+}
+
+func TestDedupCache_RejectsRepeats(t *testing.T) {
+	d := NewDedupCache(time.Minute)
+	if d.SeenBefore("node-a", 1) {
+		t.Error("first occurrence should not be seen before")
+	}
+	if !d.SeenBefore("node-a", 1) {
+		t.Error("second occurrence of same (src, seq) should be seen before")
+	}
+	if d.SeenBefore("node-a", 2) {
+		t.Error("different seq should not be seen before")
+	}
+}
+
+func TestStats_ReportsKnownNodes(t *testing.T) {
+	r := NewRouter("a", testConfig())
+	r.Ingest(Advertisement{NodeID: "a", Neighbors: []NeighborLink{{NeighborID: "b", LatencyMs: 10}}})
+
+	stats := r.Stats()
+	if stats["routing_known_nodes"] != 1 {
+		t.Errorf("routing_known_nodes = %v, want 1", stats["routing_known_nodes"])
+	}
+}
@@ -0,0 +1,336 @@
+package peering
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/x0tta6bl4/agent/internal/crypto/pqc"
+	"github.com/x0tta6bl4/agent/internal/mesh"
+	"github.com/x0tta6bl4/agent/internal/mesh/discovery"
+)
+
+// ReconcileInterval is how often a Reconciler re-fetches the gateway's peer
+// roster, matching the cadence discovery.PeeringSnapshotInterval uses for
+// the analogous mesh-to-mesh federation stream.
+const ReconcileInterval = 15 * time.Second
+
+// maxRosterFrameBytes caps how large a single framed message EstablishPeering
+// or a Reconciler will read from a gateway, so a misbehaving or malicious
+// gateway can't force unbounded memory allocation.
+const maxRosterFrameBytes = 1 << 20
+
+// rosterRequestFrame is what a Reconciler sends to ask the gateway for a
+// fresh roster snapshot; the reply is read as a roster frame the same way
+// EstablishPeering reads the initial one.
+var rosterRequestFrame = []byte("ROSTER?")
+
+// tokenClaims is the payload HMAC-signed into a bootstrap token by
+// GenerateToken. Unlike Manager's Generate/Establish tokens (peering.go) — ed25519-
+// signed by a peer node, for replicating services between two already-
+// running, already-peered meshes — a bootstrap token is minted by a cluster's
+// control plane for a node that isn't a member of anything yet, so it's
+// signed with a secret shared out of band (see
+// config.Config.ClusterGatewaySecret) rather than a per-node key.
+type tokenClaims struct {
+	ClusterID    string   `json:"cluster_id"`
+	Gateways     []string `json:"gateways"`
+	PQCPublicKey []byte   `json:"pqc_public_key"`
+	ExpiresAt    int64    `json:"expires_at"`
+}
+
+// Token is a bootstrap token that has already been parsed and verified by
+// ParseToken.
+type Token struct {
+	ClusterID    string
+	Gateways     []string
+	PQCPublicKey []byte
+	ExpiresAt    time.Time
+}
+
+// GenerateToken mints a bootstrap token for clusterID: a base64 JSON
+// envelope carrying the cluster ID, the gateway endpoints a joining node
+// should dial, the gateway's ML-KEM public key (so the joining node can pin
+// its PQC handshake to it, see EstablishPeering), and an expiration,
+// HMAC-SHA256-signed under secret. Modeled on Kubernetes' bootstrap-token
+// design: the secret lives only on the control plane and whatever gateways
+// it authorizes, never on the joining node until it redeems the token.
+func GenerateToken(secret []byte, clusterID string, gateways []string, pqcPublicKey []byte, ttl time.Duration) (string, error) {
+	payload, err := json.Marshal(tokenClaims{
+		ClusterID:    clusterID,
+		Gateways:     gateways,
+		PQCPublicKey: pqcPublicKey,
+		ExpiresAt:    time.Now().Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+
+	envelope := struct {
+		Payload []byte `json:"payload"`
+		MAC     []byte `json:"mac"`
+	}{Payload: payload, MAC: mac.Sum(nil)}
+
+	raw, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(raw), nil
+}
+
+// ParseToken decodes a token minted by GenerateToken, verifies its HMAC
+// under secret, and rejects it if it has expired.
+func ParseToken(secret []byte, token string) (Token, error) {
+	raw, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return Token{}, fmt.Errorf("decode bootstrap token: %w", err)
+	}
+
+	var envelope struct {
+		Payload []byte `json:"payload"`
+		MAC     []byte `json:"mac"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return Token{}, fmt.Errorf("unmarshal bootstrap token: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(envelope.Payload)
+	if !hmac.Equal(envelope.MAC, mac.Sum(nil)) {
+		return Token{}, fmt.Errorf("bootstrap token: MAC verification failed")
+	}
+
+	var claims tokenClaims
+	if err := json.Unmarshal(envelope.Payload, &claims); err != nil {
+		return Token{}, fmt.Errorf("unmarshal bootstrap claims: %w", err)
+	}
+
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	if time.Now().After(expiresAt) {
+		return Token{}, fmt.Errorf("bootstrap token for cluster %q expired at %s", claims.ClusterID, expiresAt)
+	}
+
+	return Token{
+		ClusterID:    claims.ClusterID,
+		Gateways:     claims.Gateways,
+		PQCPublicKey: claims.PQCPublicKey,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// pinnedGatewayID is the PQC peer ID a gateway's handshake response must
+// report for EstablishPeering to accept it as the node the token actually
+// names: the hex encoding of its declared public key, the same convention
+// discovery.go uses for a signed Discovery's NodeID (see
+// discovery.PubKeyFromNodeID). The handshake wire format never round-trips
+// the responder's actual ML-KEM public key back to the initiator (see
+// pqc.TunnelManager.ProcessHandshakeResponse), so a cluster gateway must be
+// run with its PQC node ID set to this value for pinning to mean anything.
+func pinnedGatewayID(pqcPublicKey []byte) string {
+	return hex.EncodeToString(pqcPublicKey)
+}
+
+// EstablishPeering redeems a bootstrap token: it dials one of token's
+// gateways, completes a PQC handshake pinned to the gateway's declared
+// public key (see pinnedGatewayID), feeds the initial peer roster the
+// gateway returns into node via Node.AddPeerFromDiscovery, and starts a
+// background Reconciler that keeps re-fetching the roster afterward. It
+// returns once the initial roster has been applied, not once the reconciler
+// stops; call the returned Reconciler's Stop when the node leaves the
+// cluster.
+func EstablishPeering(ctx context.Context, token Token, tm *pqc.TunnelManager, node *mesh.Node) (*Reconciler, error) {
+	if len(token.Gateways) == 0 {
+		return nil, fmt.Errorf("peering: bootstrap token for cluster %q carries no gateways", token.ClusterID)
+	}
+
+	var conn net.Conn
+	var dialErr error
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	for _, addr := range token.Gateways {
+		conn, dialErr = dialer.DialContext(ctx, "tcp", addr)
+		if dialErr == nil {
+			break
+		}
+	}
+	if dialErr != nil {
+		return nil, fmt.Errorf("peering: dial gateways for cluster %q: %w", token.ClusterID, dialErr)
+	}
+
+	init, err := tm.CreateHandshakeInitForPeer(token.PQCPublicKey)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("peering: create handshake init: %w", err)
+	}
+	if err := writeFrame(conn, init); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("peering: send handshake init: %w", err)
+	}
+	respFrame, err := readFrame(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("peering: read handshake response: %w", err)
+	}
+	gatewayID, _, err := tm.ProcessHandshakeResponse(respFrame)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("peering: process handshake response: %w", err)
+	}
+	if want := pinnedGatewayID(token.PQCPublicKey); gatewayID != want {
+		tm.RemoveSession(gatewayID)
+		conn.Close()
+		return nil, fmt.Errorf("peering: gateway identity %q does not match token's pinned public key %q", gatewayID, want)
+	}
+
+	roster, err := fetchRoster(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("peering: fetch initial roster: %w", err)
+	}
+	applyRoster(node, roster)
+
+	reconcileCtx, cancel := context.WithCancel(ctx)
+	r := &Reconciler{
+		node:   node,
+		conn:   conn,
+		cancel: cancel,
+		logger: slog.Default().With("component", "peering", "cluster_id", token.ClusterID),
+	}
+	r.wg.Add(1)
+	go r.loop(reconcileCtx)
+	return r, nil
+}
+
+// Reconciler keeps node's peer table in sync with the roster a cluster
+// gateway reports: it periodically asks the gateway (over the connection
+// EstablishPeering opened) for a fresh snapshot and diffs it against
+// node.GetPeers(), adding node IDs the roster names but node doesn't know
+// yet via Node.AddPeerFromDiscovery and removing ones node knows but the
+// roster no longer names via Node.RemovePeer. It assumes the gateway's
+// roster is this node's entire cluster membership — running it alongside
+// independent local mDNS/UDP discovery on the same Node would let the two
+// sources fight over the same peers.
+type Reconciler struct {
+	node   *mesh.Node
+	conn   net.Conn
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	logger *slog.Logger
+}
+
+// Stop ends the reconciler and closes its connection to the gateway.
+func (r *Reconciler) Stop() {
+	r.cancel()
+	r.conn.Close()
+}
+
+// Wait blocks until the reconciler's background loop has actually returned.
+func (r *Reconciler) Wait() {
+	r.wg.Wait()
+}
+
+func (r *Reconciler) loop(ctx context.Context) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := writeFrame(r.conn, rosterRequestFrame); err != nil {
+				r.logger.Warn("roster request failed", "error", err)
+				return
+			}
+			roster, err := fetchRoster(r.conn)
+			if err != nil {
+				r.logger.Warn("roster refetch failed", "error", err)
+				return
+			}
+			applyRoster(r.node, roster)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// applyRoster adds peers roster names that node doesn't already have, and
+// removes peers node has that roster no longer names.
+func applyRoster(node *mesh.Node, roster []discovery.PeerInfo) {
+	wanted := make(map[string]discovery.PeerInfo, len(roster))
+	for _, p := range roster {
+		wanted[p.NodeID] = p
+	}
+
+	known := make(map[string]bool, len(wanted))
+	for _, p := range node.GetPeers() {
+		known[p.NodeID] = true
+		if _, ok := wanted[p.NodeID]; !ok {
+			node.RemovePeer(p.NodeID)
+		}
+	}
+
+	for nodeID, info := range wanted {
+		if !known[nodeID] {
+			node.AddPeerFromDiscovery(info)
+		}
+	}
+}
+
+// fetchRoster reads one framed JSON-encoded peer roster from conn.
+func fetchRoster(conn net.Conn) ([]discovery.PeerInfo, error) {
+	frame, err := readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	var roster []discovery.PeerInfo
+	if err := json.Unmarshal(frame, &roster); err != nil {
+		return nil, fmt.Errorf("unmarshal roster: %w", err)
+	}
+	return roster, nil
+}
+
+// writeFrame and readFrame implement a minimal length-prefixed framing for
+// the gateway connection: a uint32 big-endian byte count followed by that
+// many bytes. Handshake frames, roster snapshots, and the reconciler's
+// roster-request marker all share this framing so any of them can be read
+// off the same connection without ambiguity about where one ends and the
+// next begins — unlike the handshake wire format's own internal length
+// fields (see pqc.TunnelManager.CreateHandshakeInit), which describe a
+// frame's insides but not its total size on a byte stream.
+func writeFrame(w io.Writer, data []byte) error {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(data)))
+	if _, err := w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(lenBuf[:])
+	if n > maxRosterFrameBytes {
+		return nil, fmt.Errorf("frame of %d bytes exceeds %d byte limit", n, maxRosterFrameBytes)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
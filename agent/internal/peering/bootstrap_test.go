@@ -0,0 +1,90 @@
+package peering
+
+import (
+	"testing"
+	"time"
+
+	"github.com/x0tta6bl4/agent/internal/mesh"
+	"github.com/x0tta6bl4/agent/internal/mesh/discovery"
+)
+
+func TestParseToken_RoundTrip(t *testing.T) {
+	secret := []byte("shared-secret")
+	pub := []byte{1, 2, 3, 4}
+	raw, err := GenerateToken(secret, "cluster-a", []string{"gw1:7000", "gw2:7000"}, pub, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	tok, err := ParseToken(secret, raw)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if tok.ClusterID != "cluster-a" {
+		t.Errorf("ClusterID = %q, want cluster-a", tok.ClusterID)
+	}
+	if len(tok.Gateways) != 2 || tok.Gateways[0] != "gw1:7000" {
+		t.Errorf("Gateways = %v", tok.Gateways)
+	}
+}
+
+func TestParseToken_RejectsTamperedMAC(t *testing.T) {
+	secret := []byte("shared-secret")
+	raw, err := GenerateToken(secret, "cluster-a", []string{"gw1:7000"}, []byte{1, 2, 3}, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	if _, err := ParseToken([]byte("wrong-secret"), raw); err == nil {
+		t.Error("ParseToken should reject a token verified under the wrong secret")
+	}
+}
+
+func TestParseToken_RejectsExpiredToken(t *testing.T) {
+	secret := []byte("shared-secret")
+	raw, err := GenerateToken(secret, "cluster-a", []string{"gw1:7000"}, []byte{1, 2, 3}, -time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	if _, err := ParseToken(secret, raw); err == nil {
+		t.Error("ParseToken should reject an already-expired token")
+	}
+}
+
+func TestApplyRoster_AddsAndRemovesPeers(t *testing.T) {
+	node := mesh.NewNode("local-node", 0, nil)
+	node.AddPeerManual("stale-peer", "127.0.0.1", 9001, "")
+
+	roster := []discovery.PeerInfo{
+		{NodeID: "fresh-peer", Addresses: [][]any{{"127.0.0.1", 9002}}},
+	}
+	applyRoster(node, roster)
+
+	peers := node.GetPeers()
+	byID := make(map[string]bool, len(peers))
+	for _, p := range peers {
+		byID[p.NodeID] = true
+	}
+	if byID["stale-peer"] {
+		t.Error("stale-peer should have been removed once it dropped out of the roster")
+	}
+	if !byID["fresh-peer"] {
+		t.Error("fresh-peer from the roster should have been added")
+	}
+}
+
+func TestApplyRoster_NoOpWhenRosterMatchesExistingPeers(t *testing.T) {
+	node := mesh.NewNode("local-node", 0, nil)
+	node.AddPeerManual("peer-1", "127.0.0.1", 9001, "")
+
+	roster := []discovery.PeerInfo{
+		{NodeID: "peer-1", Addresses: [][]any{{"127.0.0.1", 9001}}},
+	}
+	applyRoster(node, roster)
+
+	peers := node.GetPeers()
+	if len(peers) != 1 || peers[0].NodeID != "peer-1" {
+		t.Errorf("GetPeers() = %v, want just peer-1 unchanged", peers)
+	}
+}
@@ -0,0 +1,81 @@
+// Package peering ties together the pieces that make mesh-to-mesh
+// federation work end to end: the Control Plane token exchange
+// (internal/api), the cross-mesh snapshot stream that actually
+// replicates discovery announce/leave traffic (internal/mesh/discovery),
+// and the PQC tunnel identity that gives an operator something to
+// verify out of band before trusting a peered mesh
+// (internal/crypto/pqc). Modeled on Consul's peering workflow:
+// generate a token on one mesh, establish it on the other.
+package peering
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/x0tta6bl4/agent/internal/api"
+	"github.com/x0tta6bl4/agent/internal/crypto/pqc"
+	"github.com/x0tta6bl4/agent/internal/mesh/discovery"
+)
+
+// Manager is the entry point the CLI (see cmd "peering generate"/
+// "peering establish" in agent/main.go) drives. The Terraform provider's
+// x0t_peering resource does not go through it: it calls the Control
+// Plane's peering HTTP API directly.
+type Manager struct {
+	client    *api.Client
+	discovery *discovery.Discovery
+	tunnels   *pqc.TunnelManager // optional: nil if this node runs without PQC tunnels
+}
+
+// NewManager builds a Manager around the agent's already-registered
+// Control Plane client and running Discovery instance. tunnels may be
+// nil; TrustAnchor then reports no trust anchor rather than panicking.
+func NewManager(client *api.Client, d *discovery.Discovery, tunnels *pqc.TunnelManager) *Manager {
+	return &Manager{client: client, discovery: d, tunnels: tunnels}
+}
+
+// TrustAnchor returns this node's PQC public identity, base64-encoded,
+// for an operator to compare out of band against what the remote mesh
+// reports before trusting a peering token. Empty if tunnels is nil.
+func (m *Manager) TrustAnchor() string {
+	if m.tunnels == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(m.tunnels.GetPublicKey())
+}
+
+// Generate mints a peering token for a remote mesh named peerMeshName.
+// It registers the federation with the Control Plane first (so it shows
+// up in this mesh's peering inventory even if the token is never
+// redeemed) and then mints the node-level bearer token the remote
+// actually dials back with via Establish. exportedServices restricts
+// which locally discovered services this node will replicate to
+// peerMeshName once the stream is up; pass nil to export everything.
+func (m *Manager) Generate(peerMeshName string, exportedServices []string) (string, error) {
+	if _, err := m.client.GeneratePeeringToken(peerMeshName); err != nil {
+		return "", fmt.Errorf("peering: register token with control plane: %w", err)
+	}
+
+	token, err := m.discovery.GeneratePeeringToken(peerMeshName)
+	if err != nil {
+		return "", fmt.Errorf("peering: mint node-level token: %w", err)
+	}
+
+	if exportedServices != nil {
+		m.discovery.SetExportedServices(peerMeshName, exportedServices)
+	}
+	return token, nil
+}
+
+// Establish redeems a token minted by Generate on the remote mesh: it
+// opens the long-lived bidirectional snapshot stream with Discovery and
+// records the federation with the Control Plane for visibility.
+func (m *Manager) Establish(token string) error {
+	if err := m.discovery.EstablishPeering(token); err != nil {
+		return fmt.Errorf("peering: establish discovery stream: %w", err)
+	}
+	if err := m.client.EstablishPeering(token); err != nil {
+		return fmt.Errorf("peering: record establishment with control plane: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package peering
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/x0tta6bl4/agent/internal/api"
+	"github.com/x0tta6bl4/agent/internal/crypto/pqc"
+	"github.com/x0tta6bl4/agent/internal/mesh/discovery"
+)
+
+func newRegisteredClientForTest(t *testing.T, meshID string, extra http.HandlerFunc) *api.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/maas/agent/register" {
+			json.NewEncoder(w).Encode(api.RegistrationResponse{MeshID: meshID, APIKey: "key"})
+			return
+		}
+		extra(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client := api.NewClient(server.URL, "token")
+	if _, err := client.Register(api.RegistrationRequest{NodeID: "node-1"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return client
+}
+
+func newSignedDiscoveryForTest(t *testing.T, peeringAddr string) *discovery.Discovery {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	d, err := discovery.NewSigned(priv, nil, 5000, nil, "", 0)
+	if err != nil {
+		t.Fatalf("NewSigned: %v", err)
+	}
+	d.PeeringListenAddr = peeringAddr
+	return d
+}
+
+func TestManager_TrustAnchor_EmptyWithoutTunnels(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	if got := m.TrustAnchor(); got != "" {
+		t.Errorf("TrustAnchor() = %q, want empty string when no tunnels are configured", got)
+	}
+}
+
+func TestManager_TrustAnchor_EncodesTunnelPublicKey(t *testing.T) {
+	tm, err := pqc.NewTunnelManager("node-1")
+	if err != nil {
+		t.Fatalf("NewTunnelManager: %v", err)
+	}
+	m := NewManager(nil, nil, tm)
+	if got := m.TrustAnchor(); got == "" {
+		t.Error("TrustAnchor() = empty, want the base64-encoded tunnel public key")
+	}
+}
+
+func TestManager_Generate_AcceptsExportedServices(t *testing.T) {
+	client := newRegisteredClientForTest(t, "mesh-a", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(api.PeeringToken{Token: "opaque-token", LocalMeshID: "mesh-a"})
+	})
+	d := newSignedDiscoveryForTest(t, "127.0.0.1:9000")
+	m := NewManager(client, d, nil)
+
+	if _, err := m.Generate("mesh-b", []string{"api"}); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+}
+
+func TestManager_Generate_AcceptsNilExports(t *testing.T) {
+	client := newRegisteredClientForTest(t, "mesh-a", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(api.PeeringToken{Token: "opaque-token", LocalMeshID: "mesh-a"})
+	})
+	d := newSignedDiscoveryForTest(t, "127.0.0.1:9000")
+	m := NewManager(client, d, nil)
+
+	if _, err := m.Generate("mesh-b", nil); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+// Package routing implements subnet-router advertisements with HA
+// failover: a node can advertise one or more CIDR prefixes it can route
+// traffic to (e.g. a LAN behind it), and every other node in the mesh
+// derives, per prefix, the healthy advertiser with the lowest priority
+// as that prefix's active next hop — promoting the next-best candidate
+// the instant the active one is lost. This is the subnet-router failover
+// idea Headscale/Tailscale implement against a central coordination
+// server, adapted to our P2P discovery layer instead: candidates are fed
+// in from discovery.PeerInfo.AdvertisedRoutes rather than a control
+// plane, and losing a candidate is driven by discovery's own
+// OnPeerLost/LastSeen-timeout eviction.
+package routing
+
+import (
+	"net/netip"
+	"sort"
+	"sync"
+)
+
+// OnRouteChangedFunc is invoked after a prefix's active next hop
+// changes, including the first time a prefix gets an active route
+// (oldNode == "") and when the last healthy advertiser for a prefix is
+// lost (newNode == "").
+type OnRouteChangedFunc func(prefix netip.Prefix, oldNode, newNode string)
+
+// candidate is one node's advertisement of a prefix.
+type candidate struct {
+	nodeID   string
+	priority int
+	healthy  bool
+}
+
+// Table tracks every node's advertised subnet routes and derives, per
+// prefix, which advertiser is the active next hop: the healthy
+// candidate with the lowest priority, ties broken by node ID so the
+// choice is deterministic across the mesh.
+type Table struct {
+	mu         sync.RWMutex
+	candidates map[netip.Prefix]map[string]*candidate // prefix -> nodeID -> candidate
+	active     map[netip.Prefix]string
+
+	// OnRouteChanged, if set, is called once per prefix whose active
+	// next hop changed as a result of the call that triggered it. Never
+	// called while mu is held.
+	OnRouteChanged OnRouteChangedFunc
+}
+
+// NewTable creates an empty routing table.
+func NewTable() *Table {
+	return &Table{
+		candidates: make(map[netip.Prefix]map[string]*candidate),
+		active:     make(map[netip.Prefix]string),
+	}
+}
+
+// UpdateAdvertisement records nodeID as advertising routes at priority,
+// replacing whatever it last advertised, and recomputes the active next
+// hop for every affected prefix. Call this from a discovery
+// OnPeerDiscovered callback and again on every re-announce, since a
+// peer's advertised routes or priority can change over its lifetime.
+func (t *Table) UpdateAdvertisement(nodeID string, routes []netip.Prefix, priority int) {
+	t.mu.Lock()
+	dirty := t.removeNodeLocked(nodeID)
+	for _, prefix := range routes {
+		if t.candidates[prefix] == nil {
+			t.candidates[prefix] = make(map[string]*candidate)
+		}
+		t.candidates[prefix][nodeID] = &candidate{nodeID: nodeID, priority: priority, healthy: true}
+		dirty[prefix] = struct{}{}
+	}
+	events := t.recomputeLocked(dirty)
+	t.mu.Unlock()
+	t.fire(events)
+}
+
+// RemoveNode drops every route nodeID advertised and promotes the
+// next-best candidate for each affected prefix. Call this from a
+// discovery OnPeerLost callback, so a subnet router that stops
+// announcing (leaves, or its LastSeen passes discovery.PeerTimeout and
+// cleanupExpired evicts it) fails over without any extra plumbing.
+func (t *Table) RemoveNode(nodeID string) {
+	t.mu.Lock()
+	dirty := t.removeNodeLocked(nodeID)
+	events := t.recomputeLocked(dirty)
+	t.mu.Unlock()
+	t.fire(events)
+}
+
+// MarkUnhealthy excludes nodeID from active-route selection without
+// forgetting its advertisement, so it's promoted back automatically by
+// MarkHealthy rather than needing to re-advertise its routes. Intended
+// for a finer-grained health signal than discovery's LastSeen timeout,
+// e.g. a healing.Monitor rule keyed on a specific peer's RTT/loss.
+func (t *Table) MarkUnhealthy(nodeID string) {
+	t.setHealth(nodeID, false)
+}
+
+// MarkHealthy reverses MarkUnhealthy.
+func (t *Table) MarkHealthy(nodeID string) {
+	t.setHealth(nodeID, true)
+}
+
+func (t *Table) setHealth(nodeID string, healthy bool) {
+	t.mu.Lock()
+	dirty := make(map[netip.Prefix]struct{})
+	for prefix, byNode := range t.candidates {
+		if c, ok := byNode[nodeID]; ok && c.healthy != healthy {
+			c.healthy = healthy
+			dirty[prefix] = struct{}{}
+		}
+	}
+	events := t.recomputeLocked(dirty)
+	t.mu.Unlock()
+	t.fire(events)
+}
+
+// removeNodeLocked deletes nodeID from every prefix it was a candidate
+// for and returns the set of prefixes that need recomputing. Callers
+// must hold t.mu.
+func (t *Table) removeNodeLocked(nodeID string) map[netip.Prefix]struct{} {
+	dirty := make(map[netip.Prefix]struct{})
+	for prefix, byNode := range t.candidates {
+		if _, ok := byNode[nodeID]; ok {
+			delete(byNode, nodeID)
+			dirty[prefix] = struct{}{}
+			if len(byNode) == 0 {
+				delete(t.candidates, prefix)
+			}
+		}
+	}
+	return dirty
+}
+
+// routeChangeEvent is an active-next-hop transition queued for delivery
+// to OnRouteChanged once the caller has released t.mu.
+type routeChangeEvent struct {
+	prefix           netip.Prefix
+	oldNode, newNode string
+}
+
+// recomputeLocked picks the active next hop for every prefix in dirty
+// and returns the events to fire for the ones whose active hop changed.
+// Callers must hold t.mu.
+func (t *Table) recomputeLocked(dirty map[netip.Prefix]struct{}) []routeChangeEvent {
+	var events []routeChangeEvent
+	for prefix := range dirty {
+		oldNode := t.active[prefix]
+		newNode := bestCandidate(t.candidates[prefix])
+		if newNode == oldNode {
+			continue
+		}
+		if newNode == "" {
+			delete(t.active, prefix)
+		} else {
+			t.active[prefix] = newNode
+		}
+		events = append(events, routeChangeEvent{prefix: prefix, oldNode: oldNode, newNode: newNode})
+	}
+	return events
+}
+
+// bestCandidate returns the healthy candidate with the lowest priority
+// in byNode, ties broken by node ID, or "" if none are healthy.
+func bestCandidate(byNode map[string]*candidate) string {
+	var best *candidate
+	for _, c := range byNode {
+		if !c.healthy {
+			continue
+		}
+		if best == nil || c.priority < best.priority || (c.priority == best.priority && c.nodeID < best.nodeID) {
+			best = c
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.nodeID
+}
+
+func (t *Table) fire(events []routeChangeEvent) {
+	if t.OnRouteChanged == nil {
+		return
+	}
+	for _, e := range events {
+		t.OnRouteChanged(e.prefix, e.oldNode, e.newNode)
+	}
+}
+
+// ActiveNextHop returns the node ID currently serving as prefix's active
+// router, or false if no healthy advertiser is known.
+func (t *Table) ActiveNextHop(prefix netip.Prefix) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	nodeID, ok := t.active[prefix]
+	return nodeID, ok
+}
+
+// Routes returns every currently-advertised prefix, sorted for
+// deterministic display (e.g. by the admin API).
+func (t *Table) Routes() []netip.Prefix {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	prefixes := make([]netip.Prefix, 0, len(t.candidates))
+	for p := range t.candidates {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return prefixes[i].String() < prefixes[j].String() })
+	return prefixes
+}
+
+// Stats exposes route counts in a form suitable for telemetry.Reporter
+// (see routes_advertised / routes_active in Metrics).
+func (t *Table) Stats() map[string]any {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return map[string]any{
+		"routes_advertised": len(t.candidates),
+		"routes_active":     len(t.active),
+	}
+}
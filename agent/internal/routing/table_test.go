@@ -0,0 +1,122 @@
+package routing
+
+import (
+	"net/netip"
+	"testing"
+)
+
+var testPrefix = netip.MustParsePrefix("10.0.0.0/24")
+
+func TestUpdateAdvertisement_PicksLowestPriority(t *testing.T) {
+	tab := NewTable()
+	tab.UpdateAdvertisement("node-b", []netip.Prefix{testPrefix}, 20)
+	tab.UpdateAdvertisement("node-a", []netip.Prefix{testPrefix}, 10)
+
+	got, ok := tab.ActiveNextHop(testPrefix)
+	if !ok || got != "node-a" {
+		t.Errorf("ActiveNextHop = %q, %v, want node-a", got, ok)
+	}
+}
+
+func TestUpdateAdvertisement_TiesBrokenByNodeID(t *testing.T) {
+	tab := NewTable()
+	tab.UpdateAdvertisement("node-b", []netip.Prefix{testPrefix}, 10)
+	tab.UpdateAdvertisement("node-a", []netip.Prefix{testPrefix}, 10)
+
+	got, ok := tab.ActiveNextHop(testPrefix)
+	if !ok || got != "node-a" {
+		t.Errorf("ActiveNextHop = %q, %v, want node-a (lexically first)", got, ok)
+	}
+}
+
+func TestRemoveNode_PromotesNextBestCandidate(t *testing.T) {
+	tab := NewTable()
+	tab.UpdateAdvertisement("node-a", []netip.Prefix{testPrefix}, 10)
+	tab.UpdateAdvertisement("node-b", []netip.Prefix{testPrefix}, 20)
+
+	var events []string
+	tab.OnRouteChanged = func(prefix netip.Prefix, oldNode, newNode string) {
+		events = append(events, oldNode+"->"+newNode)
+	}
+
+	// Simulates discovery evicting node-a after its LastSeen passes
+	// discovery.PeerTimeout (cleanupExpired) and firing OnPeerLost.
+	tab.RemoveNode("node-a")
+
+	got, ok := tab.ActiveNextHop(testPrefix)
+	if !ok || got != "node-b" {
+		t.Fatalf("ActiveNextHop after failover = %q, %v, want node-b", got, ok)
+	}
+	if len(events) != 1 || events[0] != "node-a->node-b" {
+		t.Errorf("OnRouteChanged events = %v, want [node-a->node-b]", events)
+	}
+}
+
+func TestRemoveNode_LastCandidateClearsActiveRoute(t *testing.T) {
+	tab := NewTable()
+	tab.UpdateAdvertisement("node-a", []netip.Prefix{testPrefix}, 10)
+
+	tab.RemoveNode("node-a")
+
+	if _, ok := tab.ActiveNextHop(testPrefix); ok {
+		t.Error("expected no active next hop once the only advertiser is removed")
+	}
+	if len(tab.Routes()) != 0 {
+		t.Errorf("Routes() = %v, want none left once the prefix has no candidates", tab.Routes())
+	}
+}
+
+func TestMarkUnhealthy_FailsOverAndMarkHealthyRestores(t *testing.T) {
+	tab := NewTable()
+	tab.UpdateAdvertisement("node-a", []netip.Prefix{testPrefix}, 10)
+	tab.UpdateAdvertisement("node-b", []netip.Prefix{testPrefix}, 20)
+
+	tab.MarkUnhealthy("node-a")
+	if got, _ := tab.ActiveNextHop(testPrefix); got != "node-b" {
+		t.Fatalf("ActiveNextHop after MarkUnhealthy = %q, want node-b", got)
+	}
+
+	tab.MarkHealthy("node-a")
+	if got, _ := tab.ActiveNextHop(testPrefix); got != "node-a" {
+		t.Errorf("ActiveNextHop after MarkHealthy = %q, want node-a restored", got)
+	}
+}
+
+func TestUpdateAdvertisement_ReplacesPriorAdvertisement(t *testing.T) {
+	tab := NewTable()
+	other := netip.MustParsePrefix("192.168.5.0/24")
+	tab.UpdateAdvertisement("node-a", []netip.Prefix{testPrefix}, 10)
+
+	// node-a re-announces with a different route set entirely.
+	tab.UpdateAdvertisement("node-a", []netip.Prefix{other}, 5)
+
+	if _, ok := tab.ActiveNextHop(testPrefix); ok {
+		t.Error("old prefix should no longer have node-a as a candidate")
+	}
+	if got, ok := tab.ActiveNextHop(other); !ok || got != "node-a" {
+		t.Errorf("ActiveNextHop(other) = %q, %v, want node-a", got, ok)
+	}
+}
+
+func TestStats(t *testing.T) {
+	tab := NewTable()
+	other := netip.MustParsePrefix("192.168.5.0/24")
+	tab.UpdateAdvertisement("node-a", []netip.Prefix{testPrefix, other}, 10)
+
+	stats := tab.Stats()
+	if stats["routes_advertised"] != 2 {
+		t.Errorf("routes_advertised = %v, want 2", stats["routes_advertised"])
+	}
+	if stats["routes_active"] != 2 {
+		t.Errorf("routes_active = %v, want 2", stats["routes_active"])
+	}
+
+	tab.MarkUnhealthy("node-a")
+	stats = tab.Stats()
+	if stats["routes_advertised"] != 2 {
+		t.Errorf("routes_advertised after MarkUnhealthy = %v, want 2 (still advertised)", stats["routes_advertised"])
+	}
+	if stats["routes_active"] != 0 {
+		t.Errorf("routes_active after MarkUnhealthy = %v, want 0", stats["routes_active"])
+	}
+}
@@ -0,0 +1,336 @@
+// Package security manages per-peer preshared keys (PSKs) used to
+// obfuscate mesh traffic independent of the PQC tunnel layer, including
+// their automatic two-phase rotation.
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/x0tta6bl4/agent/internal/identity"
+)
+
+// PSKSize is the length in bytes of a generated preshared key.
+const PSKSize = 32
+
+// PSK is the keying material for one peer pair, including the overlap
+// state needed for rotation without dropping packets.
+type PSK struct {
+	Current   []byte    `json:"current"`
+	Previous  []byte    `json:"previous,omitempty"`
+	Pending   []byte    `json:"pending,omitempty"`
+	RotatedAt time.Time `json:"rotated_at"`
+}
+
+// Store is a persisted database of per-peer-pair PSKs, keyed by the
+// unordered pair of node IDs so either side looks up the same entry.
+type Store struct {
+	mu    sync.RWMutex
+	path  string
+	peers map[string]*PSK
+}
+
+// PairKey returns the canonical (order-independent) key for a peer pair.
+func PairKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "|" + b
+}
+
+// NewStore opens (or creates) a PSK database at path, e.g. DataDir/psk.db.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, peers: make(map[string]*PSK)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read PSK store: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.peers); err != nil {
+		return nil, fmt.Errorf("parse PSK store: %w", err)
+	}
+	return s, nil
+}
+
+// Get returns the PSK entry for the (a, b) pair, if one exists.
+func (s *Store) Get(a, b string) (*PSK, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.peers[PairKey(a, b)]
+	return p, ok
+}
+
+// GenerateFor creates and persists a fresh random PSK for the (a, b) pair,
+// replacing any existing entry.
+func (s *Store) GenerateFor(a, b string) ([]byte, error) {
+	key := make([]byte, PSKSize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("generate PSK: %w", err)
+	}
+
+	s.mu.Lock()
+	s.peers[PairKey(a, b)] = &PSK{Current: key, RotatedAt: time.Now()}
+	err := s.saveLocked()
+	s.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// SetCurrent records key as the Current PSK for (a, b) as-is, replacing any
+// existing entry, without generating it locally — for the side of the pair
+// that received key via a Control Plane PSK delivery (see
+// UnwrapForPeer) rather than having generated it itself with GenerateFor.
+func (s *Store) SetCurrent(a, b string, key []byte) error {
+	s.mu.Lock()
+	s.peers[PairKey(a, b)] = &PSK{Current: key, RotatedAt: time.Now()}
+	err := s.saveLocked()
+	s.mu.Unlock()
+	return err
+}
+
+// ProposeRotation generates a candidate next key for (a, b), stores it as
+// Pending, and returns it to be sent to the peer as a PROPOSE_PSK message.
+func (s *Store) ProposeRotation(a, b string) ([]byte, error) {
+	key := make([]byte, PSKSize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("generate PSK: %w", err)
+	}
+	if err := s.SetPending(a, b, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// SetPending records a proposed (not yet committed) next key for (a, b),
+// as observed by the receiving side of a PROPOSE_PSK message.
+func (s *Store) SetPending(a, b string, key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pairKey := PairKey(a, b)
+	p, ok := s.peers[pairKey]
+	if !ok {
+		p = &PSK{RotatedAt: time.Now()}
+		s.peers[pairKey] = p
+	}
+	p.Pending = key
+	return s.saveLocked()
+}
+
+// Commit promotes the Pending key to Current (moving the old Current to
+// Previous, so packets encrypted under it are still accepted during the
+// overlap window) for the (a, b) pair.
+func (s *Store) Commit(a, b string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.peers[PairKey(a, b)]
+	if !ok || p.Pending == nil {
+		return fmt.Errorf("no pending rotation for %s/%s", a, b)
+	}
+
+	p.Previous = p.Current
+	p.Current = p.Pending
+	p.Pending = nil
+	p.RotatedAt = time.Now()
+	return s.saveLocked()
+}
+
+func (s *Store) saveLocked() error {
+	data, err := json.Marshal(s.peers)
+	if err != nil {
+		return fmt.Errorf("marshal PSK store: %w", err)
+	}
+
+	if dir := filepath.Dir(s.path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("create PSK store dir: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("open PSK store: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("write PSK store: %w", err)
+	}
+	return f.Sync()
+}
+
+// XOR applies a repeating-key XOR stream to data. It provides obfuscation,
+// not authenticated encryption — use "aes" for confidentiality guarantees.
+func XOR(data, key []byte) []byte {
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = data[i] ^ key[i%len(key)]
+	}
+	return out
+}
+
+// EncryptAESGCM seals data under key (must be 32 bytes), prepending the
+// random nonce: nonce || ciphertext.
+func EncryptAESGCM(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create GCM: %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// DecryptAESGCM opens data sealed by EncryptAESGCM under key.
+func DecryptAESGCM(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create GCM: %w", err)
+	}
+
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+}
+
+// pskWrapInfo is the HKDF info string binding a wrap key to this exchange,
+// so the derived key can't be confused with a key HKDF might derive for
+// some other protocol sharing the same X25519 shared secret.
+const pskWrapInfo = "x0tta6bl4-psk-wrap-v1"
+
+// WrapForPeer obscures a PSK for transport through the Control Plane. The
+// recipient's Ed25519 node identity key (hex-encoded, e.g. Peer.PubKey) is
+// converted to its birationally equivalent X25519 public key (see
+// identity.X25519PublicKey) so no second registered keypair is needed, and
+// an ephemeral X25519 ECDH against it derives a one-off wrapping key via
+// HKDF — so the wrap key depends on the peer's long-lived private key,
+// which the Control Plane never has, rather than on public data the
+// Control Plane already knows. The returned blob is
+// [ephemeral_x25519_pub][AES-256-GCM ciphertext]; the peer calls
+// UnwrapForPeer with its own Ed25519 private key to recover the PSK.
+func WrapForPeer(peerPubKeyHex string, psk []byte) ([]byte, error) {
+	pubBytes, err := hex.DecodeString(peerPubKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("decode peer pubkey: %w", err)
+	}
+	if len(pubBytes) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key length: %d", len(pubBytes))
+	}
+
+	peerPub, err := identity.X25519PublicKey(ed25519.PublicKey(pubBytes))
+	if err != nil {
+		return nil, fmt.Errorf("convert peer pubkey to x25519: %w", err)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate ephemeral x25519 key: %w", err)
+	}
+	sharedSecret, err := ephemeral.ECDH(peerPub)
+	if err != nil {
+		return nil, fmt.Errorf("x25519 ecdh: %w", err)
+	}
+
+	wrapKey, err := deriveWrapKey(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := EncryptAESGCM(wrapKey, psk)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralPub := ephemeral.PublicKey().Bytes()
+	wrapped := make([]byte, 0, len(ephemeralPub)+len(ciphertext))
+	wrapped = append(wrapped, ephemeralPub...)
+	wrapped = append(wrapped, ciphertext...)
+	return wrapped, nil
+}
+
+// UnwrapForPeer recovers a PSK wrapped by WrapForPeer, using the
+// recipient's own Ed25519 private key (hex-encoded, e.g. Config.PrivateKey)
+// converted to X25519 (see identity.X25519PrivateKey) to complete the
+// ECDH the sender started with its ephemeral key.
+func UnwrapForPeer(ownPrivKeyHex string, wrapped []byte) ([]byte, error) {
+	privBytes, err := hex.DecodeString(ownPrivKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("decode own private key: %w", err)
+	}
+	if len(privBytes) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid ed25519 private key length: %d", len(privBytes))
+	}
+
+	ownPriv, err := identity.X25519PrivateKey(ed25519.PrivateKey(privBytes))
+	if err != nil {
+		return nil, fmt.Errorf("convert own private key to x25519: %w", err)
+	}
+
+	const ephemeralPubSize = 32 // X25519 public key length
+	if len(wrapped) < ephemeralPubSize {
+		return nil, fmt.Errorf("wrapped PSK too short")
+	}
+	ephemeralPub, err := ecdh.X25519().NewPublicKey(wrapped[:ephemeralPubSize])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral x25519 public key: %w", err)
+	}
+
+	sharedSecret, err := ownPriv.ECDH(ephemeralPub)
+	if err != nil {
+		return nil, fmt.Errorf("x25519 ecdh: %w", err)
+	}
+
+	wrapKey, err := deriveWrapKey(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecryptAESGCM(wrapKey, wrapped[ephemeralPubSize:])
+}
+
+// deriveWrapKey folds an X25519 shared secret into a 32-byte AES-256-GCM
+// key via HKDF, shared by WrapForPeer and UnwrapForPeer so they always
+// agree on the same derivation.
+func deriveWrapKey(sharedSecret []byte) ([]byte, error) {
+	kdf := hkdf.New(sha256.New, sharedSecret, nil, []byte(pskWrapInfo))
+	wrapKey := make([]byte, 32)
+	if _, err := io.ReadFull(kdf, wrapKey); err != nil {
+		return nil, fmt.Errorf("derive wrap key: %w", err)
+	}
+	return wrapKey, nil
+}
@@ -0,0 +1,197 @@
+package security
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/x0tta6bl4/agent/internal/identity"
+)
+
+func TestPairKey_OrderIndependent(t *testing.T) {
+	if PairKey("a", "b") != PairKey("b", "a") {
+		t.Error("PairKey should be order-independent")
+	}
+}
+
+func TestGenerateFor_PersistsAndReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "psk.db")
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	key, err := s.GenerateFor("node-a", "node-b")
+	if err != nil {
+		t.Fatalf("GenerateFor: %v", err)
+	}
+	if len(key) != PSKSize {
+		t.Errorf("key len = %d, want %d", len(key), PSKSize)
+	}
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore reload: %v", err)
+	}
+	psk, ok := reloaded.Get("node-b", "node-a") // reversed order
+	if !ok {
+		t.Fatal("expected PSK to survive reload")
+	}
+	if string(psk.Current) != string(key) {
+		t.Error("reloaded key does not match generated key")
+	}
+}
+
+func TestNewStore_MissingFileReturnsEmptyStore(t *testing.T) {
+	s, err := NewStore(filepath.Join(t.TempDir(), "nonexistent.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	if _, ok := s.Get("a", "b"); ok {
+		t.Error("expected no PSK for a fresh store")
+	}
+}
+
+func TestProposeRotation_ThenCommit(t *testing.T) {
+	s, _ := NewStore(filepath.Join(t.TempDir(), "psk.db"))
+
+	orig, _ := s.GenerateFor("a", "b")
+	newKey, err := s.ProposeRotation("a", "b")
+	if err != nil {
+		t.Fatalf("ProposeRotation: %v", err)
+	}
+
+	psk, _ := s.Get("a", "b")
+	if string(psk.Current) != string(orig) {
+		t.Error("Current should be unchanged before commit")
+	}
+	if string(psk.Pending) != string(newKey) {
+		t.Error("Pending should hold the proposed key")
+	}
+
+	if err := s.Commit("a", "b"); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	psk, _ = s.Get("a", "b")
+	if string(psk.Current) != string(newKey) {
+		t.Error("Current should be the committed key")
+	}
+	if string(psk.Previous) != string(orig) {
+		t.Error("Previous should hold the pre-rotation key")
+	}
+	if psk.Pending != nil {
+		t.Error("Pending should be cleared after commit")
+	}
+}
+
+func TestCommit_NoPendingReturnsError(t *testing.T) {
+	s, _ := NewStore(filepath.Join(t.TempDir(), "psk.db"))
+	s.GenerateFor("a", "b")
+
+	if err := s.Commit("a", "b"); err == nil {
+		t.Error("expected error committing with no pending rotation")
+	}
+}
+
+func TestXOR_RoundTrip(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	plain := []byte("hello mesh")
+
+	cipher := XOR(plain, key)
+	if string(cipher) == string(plain) {
+		t.Error("XOR output should differ from input")
+	}
+	if string(XOR(cipher, key)) != string(plain) {
+		t.Error("XOR should be its own inverse")
+	}
+}
+
+func TestAESGCM_RoundTrip(t *testing.T) {
+	key := make([]byte, PSKSize)
+	plain := []byte("hello mesh")
+
+	cipher, err := EncryptAESGCM(key, plain)
+	if err != nil {
+		t.Fatalf("EncryptAESGCM: %v", err)
+	}
+
+	decrypted, err := DecryptAESGCM(key, cipher)
+	if err != nil {
+		t.Fatalf("DecryptAESGCM: %v", err)
+	}
+	if string(decrypted) != string(plain) {
+		t.Errorf("decrypted = %q, want %q", decrypted, plain)
+	}
+}
+
+func TestAESGCM_WrongKeyFails(t *testing.T) {
+	key := make([]byte, PSKSize)
+	wrongKey := make([]byte, PSKSize)
+	wrongKey[0] = 1
+
+	cipher, _ := EncryptAESGCM(key, []byte("secret"))
+	if _, err := DecryptAESGCM(wrongKey, cipher); err == nil {
+		t.Error("expected decryption to fail under the wrong key")
+	}
+}
+
+func TestWrapForPeer_ProducesDecryptableBlob(t *testing.T) {
+	kp, err := identity.Generate()
+	if err != nil {
+		t.Fatalf("identity.Generate: %v", err)
+	}
+	psk := []byte("0123456789abcdef0123456789abcdef")
+
+	wrapped, err := WrapForPeer(kp.PublicKeyHex, psk)
+	if err != nil {
+		t.Fatalf("WrapForPeer: %v", err)
+	}
+	if len(wrapped) == 0 {
+		t.Error("expected non-empty wrapped PSK")
+	}
+
+	unwrapped, err := UnwrapForPeer(kp.PrivateKeyHex, wrapped)
+	if err != nil {
+		t.Fatalf("UnwrapForPeer: %v", err)
+	}
+	if string(unwrapped) != string(psk) {
+		t.Errorf("unwrapped PSK = %q, want %q", unwrapped, psk)
+	}
+}
+
+func TestWrapForPeer_InvalidHex(t *testing.T) {
+	if _, err := WrapForPeer("not-hex", []byte("key")); err == nil {
+		t.Error("expected error for invalid pubkey hex")
+	}
+}
+
+func TestUnwrapForPeer_WrongRecipientFails(t *testing.T) {
+	recipient, err := identity.Generate()
+	if err != nil {
+		t.Fatalf("identity.Generate: %v", err)
+	}
+	other, err := identity.Generate()
+	if err != nil {
+		t.Fatalf("identity.Generate: %v", err)
+	}
+
+	wrapped, err := WrapForPeer(recipient.PublicKeyHex, []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("WrapForPeer: %v", err)
+	}
+
+	if _, err := UnwrapForPeer(other.PrivateKeyHex, wrapped); err == nil {
+		t.Error("expected UnwrapForPeer to fail for a different recipient's private key")
+	}
+}
+
+func TestUnwrapForPeer_TooShortFails(t *testing.T) {
+	kp, err := identity.Generate()
+	if err != nil {
+		t.Fatalf("identity.Generate: %v", err)
+	}
+	if _, err := UnwrapForPeer(kp.PrivateKeyHex, []byte("short")); err == nil {
+		t.Error("expected error for a too-short wrapped blob")
+	}
+}
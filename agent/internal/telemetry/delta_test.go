@@ -0,0 +1,97 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeltaTracker_FlushReturnsPendingEventsInOrder(t *testing.T) {
+	d := NewDeltaTracker(time.Hour)
+	d.OnPeerJoined("peer-a")
+	d.OnPeerJoined("peer-b")
+
+	events, seq := d.Flush()
+	if len(events) != 2 {
+		t.Fatalf("len(events) = %d, want 2", len(events))
+	}
+	if events[0].PeerID != "peer-a" || events[1].PeerID != "peer-b" {
+		t.Errorf("events out of order: %+v", events)
+	}
+	if seq != 2 {
+		t.Errorf("seq = %d, want 2", seq)
+	}
+}
+
+func TestDeltaTracker_FlushClearsPending(t *testing.T) {
+	d := NewDeltaTracker(time.Hour)
+	d.OnPeerJoined("peer-a")
+	d.Flush()
+
+	events, seq := d.Flush()
+	if len(events) != 0 {
+		t.Errorf("expected no pending events after Flush, got %d", len(events))
+	}
+	if seq != 1 {
+		t.Errorf("seq should stay at the last assigned value, got %d", seq)
+	}
+}
+
+func TestDeltaTracker_OnHealthChange_SkipsUnchangedScore(t *testing.T) {
+	d := NewDeltaTracker(time.Hour)
+	d.OnHealthChange(0.8)
+	d.OnHealthChange(0.8)
+	d.OnHealthChange(0.5)
+
+	events, _ := d.Flush()
+	if len(events) != 2 {
+		t.Fatalf("len(events) = %d, want 2 (unchanged score should be skipped)", len(events))
+	}
+	if events[1].Score != 0.5 {
+		t.Errorf("events[1].Score = %v, want 0.5", events[1].Score)
+	}
+}
+
+func TestDeltaTracker_OnRouteChange(t *testing.T) {
+	d := NewDeltaTracker(time.Hour)
+	d.OnRouteChange("10.0.0.0/24", "node-a", "node-b")
+
+	events, _ := d.Flush()
+	if len(events) != 1 {
+		t.Fatalf("len(events) = %d, want 1", len(events))
+	}
+	e := events[0]
+	if e.Type != EventRouteChange || e.Prefix != "10.0.0.0/24" || e.OldNode != "node-a" || e.NewNode != "node-b" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}
+
+func TestDeltaTracker_Ready_NilUntilEventRecorded(t *testing.T) {
+	d := NewDeltaTracker(10 * time.Millisecond)
+	if d.Ready() != nil {
+		t.Fatal("Ready should be nil with nothing pending")
+	}
+
+	d.OnPeerJoined("peer-a")
+	select {
+	case <-d.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("Ready did not fire within the batch window")
+	}
+}
+
+func TestDeltaTracker_Ready_NilAfterFlush(t *testing.T) {
+	d := NewDeltaTracker(time.Hour)
+	d.OnPeerJoined("peer-a")
+	d.Flush()
+
+	if d.Ready() != nil {
+		t.Error("Ready should be nil after Flush drains pending events")
+	}
+}
+
+func TestNewDeltaTracker_NonPositiveWindowUsesDefault(t *testing.T) {
+	d := NewDeltaTracker(0)
+	if d.batchWindow != DefaultBatchWindow {
+		t.Errorf("batchWindow = %v, want %v", d.batchWindow, DefaultBatchWindow)
+	}
+}
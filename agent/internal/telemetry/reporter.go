@@ -26,6 +26,10 @@ type Metrics struct {
 	MsgRecv      int64   `json:"messages_recv"`
 	UptimeSec    float64 `json:"uptime_sec"`
 	HealthScore  float64 `json:"health_score"`
+
+	// Subnet routing (see internal/routing)
+	RoutesAdvertised int `json:"routes_advertised"`
+	RoutesActive     int `json:"routes_active"`
 }
 
 // StatsSource provides mesh node statistics.
@@ -90,6 +94,12 @@ func (r *Reporter) Collect() Metrics {
 		if v, ok := stats["health_score"].(float64); ok {
 			m.HealthScore = v
 		}
+		if v, ok := stats["routes_advertised"].(int); ok {
+			m.RoutesAdvertised = v
+		}
+		if v, ok := stats["routes_active"].(int); ok {
+			m.RoutesActive = v
+		}
 	}
 
 	r.mu.Lock()
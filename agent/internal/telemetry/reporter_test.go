@@ -42,11 +42,13 @@ func TestCollect_BasicMetrics(t *testing.T) {
 
 func TestCollect_WithSource(t *testing.T) {
 	src := &mockSource{stats: map[string]any{
-		"peers_total":   5,
-		"peers_healthy": 4,
-		"messages_sent": int64(100),
-		"messages_recv": int64(200),
-		"health_score":  0.95,
+		"peers_total":       5,
+		"peers_healthy":     4,
+		"messages_sent":     int64(100),
+		"messages_recv":     int64(200),
+		"health_score":      0.95,
+		"routes_advertised": 2,
+		"routes_active":     1,
 	}}
 	r := NewReporter(src)
 	m := r.Collect()
@@ -66,6 +68,12 @@ func TestCollect_WithSource(t *testing.T) {
 	if m.HealthScore != 0.95 {
 		t.Errorf("HealthScore = %f, want 0.95", m.HealthScore)
 	}
+	if m.RoutesAdvertised != 2 {
+		t.Errorf("RoutesAdvertised = %d, want 2", m.RoutesAdvertised)
+	}
+	if m.RoutesActive != 1 {
+		t.Errorf("RoutesActive = %d, want 1", m.RoutesActive)
+	}
 }
 
 func TestLatest_BeforeCollect(t *testing.T) {
@@ -5,38 +5,73 @@
 //
 //	x0t-agent --token <JOIN_TOKEN> --api-url https://maas.x0tta6bl4.io
 //	x0t-agent --config /etc/x0t/agent.yaml
+//	x0t-agent --cluster-token <TOKEN> --config /etc/x0t/agent.yaml
+//	x0t-agent genkey --out agent.yaml
+//	x0t-agent nodeinfo --config agent.yaml
+//	x0t-agent peering generate --name mesh-b --export svc-a,svc-b
+//	x0t-agent peering establish --token <TOKEN>
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/x0tta6bl4/agent/internal/admin"
 	"github.com/x0tta6bl4/agent/internal/api"
 	"github.com/x0tta6bl4/agent/internal/config"
 	"github.com/x0tta6bl4/agent/internal/crypto/pqc"
 	"github.com/x0tta6bl4/agent/internal/healing"
+	"github.com/x0tta6bl4/agent/internal/identity"
 	"github.com/x0tta6bl4/agent/internal/mesh"
 	"github.com/x0tta6bl4/agent/internal/mesh/discovery"
+	"github.com/x0tta6bl4/agent/internal/mesh/routing"
+	"github.com/x0tta6bl4/agent/internal/peering"
+	subnetrouting "github.com/x0tta6bl4/agent/internal/routing"
+	"github.com/x0tta6bl4/agent/internal/security"
 	"github.com/x0tta6bl4/agent/internal/telemetry"
 )
 
 var Version = "dev"
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "genkey":
+			runGenKey(os.Args[2:])
+			return
+		case "nodeinfo":
+			runNodeInfo(os.Args[2:])
+			return
+		case "peering":
+			runPeering(os.Args[2:])
+			return
+		}
+	}
+
 	// CLI flags
 	configPath := flag.String("config", config.DefaultConfigPath, "path to config file")
 	token := flag.String("token", "", "mesh join token")
 	apiURL := flag.String("api-url", "", "control plane API URL")
 	port := flag.Int("port", 0, "listen port (0 to use config default)")
 	logLevel := flag.String("log-level", "", "log level (debug/info/warn/error)")
+	advertiseRoutes := flag.String("advertise-routes", "", "comma-separated CIDR subnets this node routes to, e.g. 10.0.0.0/24,192.168.5.0/24")
+	clusterToken := flag.String("cluster-token", "", "one-shot cluster bootstrap peering token (see internal/peering); distinct from --token")
 	showVersion := flag.Bool("version", false, "show version and exit")
 	flag.Parse()
 
@@ -65,6 +100,12 @@ func main() {
 	if *logLevel != "" {
 		cfg.LogLevel = *logLevel
 	}
+	if *advertiseRoutes != "" {
+		cfg.AdvertisedRoutes = strings.Split(*advertiseRoutes, ",")
+	}
+	if *clusterToken != "" {
+		cfg.ClusterToken = *clusterToken
+	}
 
 	// Env overrides
 	cfg.ApplyEnvOverrides()
@@ -93,14 +134,21 @@ func main() {
 	)
 
 	// Initialize components
-	agent, err := newAgent(cfg)
+	agent, err := newAgent(cfg, *configPath)
 	if err != nil {
 		slog.Error("failed to initialize agent", "error", err)
 		os.Exit(1)
 	}
 
+	// runCtx governs every background loop the agent starts (mesh node,
+	// discovery, healing monitor, PQC rekey timer): cancelling it is the
+	// first step of shutdown, started concurrently with the explicit
+	// Stop/Shutdown calls in agent.Shutdown below.
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	defer cancelRun()
+
 	// Start
-	if err := agent.start(); err != nil {
+	if err := agent.start(runCtx); err != nil {
 		slog.Error("failed to start agent", "error", err)
 		os.Exit(1)
 	}
@@ -111,7 +159,8 @@ func main() {
 
 	sig := <-sigCh
 	slog.Info("shutdown signal received", "signal", sig)
-	agent.stop()
+	cancelRun()
+	agent.Shutdown(context.Background())
 	slog.Info("x0t-agent stopped")
 }
 
@@ -124,20 +173,113 @@ type agent struct {
 	healer    *healing.Monitor
 	apiClient *api.Client
 	telem     *telemetry.Reporter
+	adminSrv  *admin.Server
+	services  []string
+
+	// pskStore is this node's per-peer PSK database (see
+	// security.EnableSecurity), kept here too so handleControlEvent can
+	// record a PSK delivered by a peer via the Control Plane (see
+	// api.EventPSKDelivery) alongside node's own obfuscation use of it.
+	pskStore *security.Store
+
+	// subnetRoutes is the HA subnet-router failover table (see
+	// internal/routing), wired to discovery's peer events in start().
+	subnetRoutes *subnetrouting.Table
+
+	// deltaTracker accumulates peer/health/route state changes between
+	// heartbeats (see telemetry.DeltaTracker), wired to discovery, healing,
+	// and subnetRoutes events in start().
+	deltaTracker *telemetry.DeltaTracker
+
+	// cancelSubscribe stops the Control Plane event stream started by
+	// registerAndHeartbeat; nil until start() launches it.
+	cancelSubscribe context.CancelFunc
+
+	// clusterReconciler keeps node's peer table in sync with a cluster
+	// gateway's roster after a successful cfg.ClusterToken bootstrap (see
+	// internal/peering.EstablishPeering); nil unless cfg.ClusterToken was
+	// set.
+	clusterReconciler *peering.Reconciler
 }
 
-func newAgent(cfg *config.Config) (*agent, error) {
+// meshAndPQCStats adapts node and pqcMgr together into the single
+// healing.StatsProvider the MAPE-K loop expects, merging pqcMgr's
+// "sessions_needing_rekey" count (see pqc.TunnelManager.SessionsNeedingRekey)
+// and whether any peer has ShaperCPR traffic shaping active (see
+// pqc.TunnelManager.ShaperStats) into node's own stats, the same way
+// Node.GetStats merges in its own optional components (overlay,
+// subnetRoutes, ...).
+type meshAndPQCStats struct {
+	node   *mesh.Node
+	pqcMgr *pqc.TunnelManager
+}
+
+func (s meshAndPQCStats) GetStats() map[string]any {
+	stats := s.node.GetStats()
+	stats["sessions_needing_rekey"] = s.pqcMgr.SessionsNeedingRekey()
+
+	cprActive := false
+	for _, v := range s.pqcMgr.ShaperStats() {
+		peerStats, ok := v.(map[string]any)
+		if ok && peerStats["mode"] == pqc.ShaperCPR.String() {
+			cprActive = true
+			break
+		}
+	}
+	stats["pqc_shaper_cpr_active"] = cprActive
+	return stats
+}
+
+func newAgent(cfg *config.Config, cfgPath string) (*agent, error) {
+	// A bootstrap node only advertises discovery/peer-list services; it
+	// never registers as carrying user traffic.
+	services := []string{"mesh"}
+	if cfg.Mode == config.ModeBootstrap {
+		services = []string{"bootstrap"}
+	}
+
 	// Discovery
 	disc := discovery.New(
 		cfg.NodeID,
 		cfg.ListenPort,
-		[]string{"mesh"},
+		services,
 		cfg.MulticastGroup,
 		cfg.MulticastPort,
 	)
+	disc.BootstrapSeeds = cfg.BootstrapNodes
+	disc.NATTraversal = cfg.NATTraversal
+	for _, cidr := range cfg.AdvertisedRoutes {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			slog.Warn("skipping invalid advertised route", "cidr", cidr, "error", err)
+			continue
+		}
+		disc.AdvertisedRoutes = append(disc.AdvertisedRoutes, prefix)
+	}
+
+	// Coalesced, event-driven heartbeat: deltaTracker buffers peer/health/
+	// route changes (wired up in start()) so registerAndHeartbeat can send
+	// one batched envelope per flush instead of a full snapshot every tick.
+	deltaTracker := telemetry.NewDeltaTracker(time.Duration(cfg.HeartbeatBatchWindowMs) * time.Millisecond)
+
+	// Subnet-router failover table (see internal/routing): populated as
+	// peers are discovered/lost by the chaining in start(), below.
+	subnetRoutes := subnetrouting.NewTable()
+	subnetRoutes.OnRouteChanged = func(prefix netip.Prefix, oldNode, newNode string) {
+		slog.Info("subnet route failover", "prefix", prefix, "old_node", oldNode, "new_node", newNode)
+		deltaTracker.OnRouteChange(prefix.String(), oldNode, newNode)
+	}
 
 	// Mesh node
 	node := mesh.NewNode(cfg.NodeID, cfg.ListenPort, disc)
+	node.SetMode(cfg.Mode)
+	node.SetSubnetRoutes(subnetRoutes)
+	node.SetRoutingConfig(routing.Config{
+		JitterToleranceMs:         cfg.Routing.JitterToleranceMs,
+		JitterToleranceMultiplier: cfg.Routing.JitterToleranceMultiplier,
+		RecalculateCooldownSec:    cfg.Routing.RecalculateCooldownSec,
+		AdvertisementIntervalSec:  cfg.Routing.AdvertisementIntervalSec,
+	})
 
 	// PQC tunnel manager
 	pqcMgr, err := pqc.NewTunnelManager(cfg.NodeID)
@@ -149,7 +291,8 @@ func newAgent(cfg *config.Config) (*agent, error) {
 	telem := telemetry.NewReporter(node)
 
 	// Healing monitor
-	healer := healing.NewMonitor(node, nil) // no executor yet
+	healer := healing.NewMonitor(meshAndPQCStats{node: node, pqcMgr: pqcMgr}, nil) // no executor yet
+	healer.OnObservation = deltaTracker.OnHealthChange
 
 	// API client
 	var apiClient *api.Client
@@ -157,25 +300,154 @@ func newAgent(cfg *config.Config) (*agent, error) {
 		apiClient = api.NewClient(cfg.APIEndpoint, cfg.JoinToken)
 	}
 
+	// Per-peer PSK store, obfuscation, and automatic rotation
+	pskStore, err := security.NewStore(filepath.Join(cfg.DataDir, "psk.db"))
+	if err != nil {
+		return nil, fmt.Errorf("PSK store init: %w", err)
+	}
+	node.EnableSecurity(pskStore, cfg.Obfuscation)
+	if cfg.RotationIntervalHours > 0 {
+		node.SetRotationInterval(time.Duration(cfg.RotationIntervalHours) * time.Hour)
+	}
+	node.OnPeerAdded(func(peerID string) {
+		if apiClient == nil {
+			return
+		}
+		// Signed-discovery NodeIDs are hex(Ed25519 pubkey); recover the
+		// pubkey from the ID itself rather than Peer.PubKey, which is
+		// only ever populated by the admin AddPeerManual path.
+		if _, ok := discovery.PubKeyFromNodeID(peerID); !ok {
+			return // peerID isn't a signed pubkey-derived NodeID
+		}
+		pubKey := peerID
+		psk, ok := pskStore.Get(cfg.NodeID, peerID)
+		if !ok {
+			return
+		}
+		wrapped, err := security.WrapForPeer(pubKey, psk.Current)
+		if err != nil {
+			slog.Warn("failed to wrap PSK for peer", "peer_id", peerID, "error", err)
+			return
+		}
+		if err := apiClient.ExchangePSK(peerID, wrapped); err != nil {
+			slog.Warn("failed to exchange PSK with control plane", "peer_id", peerID, "error", err)
+		}
+	})
+
+	// Local admin API (127.0.0.1 by default; token from X0T_ADMIN_TOKEN)
+	var adminSrv *admin.Server
+	if cfg.AdminListen != "" {
+		adminSrv = admin.NewServer(node, telem, cfg, cfgPath, cfg.AdminListen, os.Getenv("X0T_ADMIN_TOKEN"))
+	}
+
 	return &agent{
-		cfg:       cfg,
-		node:      node,
-		disc:      disc,
-		pqcMgr:    pqcMgr,
-		healer:    healer,
-		apiClient: apiClient,
-		telem:     telem,
+		cfg:          cfg,
+		node:         node,
+		disc:         disc,
+		pqcMgr:       pqcMgr,
+		healer:       healer,
+		apiClient:    apiClient,
+		telem:        telem,
+		adminSrv:     adminSrv,
+		services:     services,
+		subnetRoutes: subnetRoutes,
+		deltaTracker: deltaTracker,
+		pskStore:     pskStore,
 	}, nil
 }
 
-func (a *agent) start() error {
+// start brings up every agent component. ctx governs their background
+// loops (mesh node, discovery, healing monitor, PQC rekey timer);
+// cancelling it begins graceful shutdown, completed by Shutdown.
+func (a *agent) start(ctx context.Context) error {
 	// Start mesh node (includes discovery)
-	if err := a.node.Start(); err != nil {
+	if err := a.node.Start(ctx); err != nil {
 		return fmt.Errorf("mesh node start: %w", err)
 	}
 
+	// Feed discovery's peer events into the subnet-router failover
+	// table, chaining onto whatever Node.Start already wired up rather
+	// than replacing it.
+	prevDiscovered := a.disc.OnPeerDiscovered
+	a.disc.OnPeerDiscovered = func(peer discovery.PeerInfo) {
+		if prevDiscovered != nil {
+			prevDiscovered(peer)
+		}
+		a.subnetRoutes.UpdateAdvertisement(peer.NodeID, peer.AdvertisedRoutes, peer.RoutePriority)
+		a.deltaTracker.OnPeerJoined(peer.NodeID)
+	}
+	prevLost := a.disc.OnPeerLost
+	a.disc.OnPeerLost = func(peer discovery.PeerInfo) {
+		if prevLost != nil {
+			prevLost(peer)
+		}
+		a.subnetRoutes.RemoveNode(peer.NodeID)
+		a.deltaTracker.OnPeerLeft(peer.NodeID)
+	}
+
+	// Relay fallback for peers behind symmetric NAT/firewalls
+	if len(a.cfg.RelayEndpoints) > 0 {
+		if err := a.node.EnableRelay(a.cfg.RelayEndpoints, a.cfg.RelayAuthSecret); err != nil {
+			slog.Warn("relay fallback unavailable", "error", err)
+		}
+	}
+
+	// Cross-mesh federation with meshes peered via the Control Plane
+	if len(a.cfg.PeeredMeshes) > 0 {
+		peerings := make([]mesh.PeeringInfo, len(a.cfg.PeeredMeshes))
+		for i, p := range a.cfg.PeeredMeshes {
+			peerings[i] = mesh.PeeringInfo{
+				MeshID:          p.MeshID,
+				GatewayAddrs:    p.GatewayAddrs,
+				AllowedServices: p.AllowedServices,
+			}
+		}
+		a.node.EnableFederation(a.cfg.MeshID, peerings)
+	}
+
+	// Cluster bootstrap: redeem a one-time peering token from a cluster
+	// gateway (see internal/peering), feeding its initial peer roster into
+	// the same peer table local discovery populates. Best-effort, like
+	// relay fallback above: a bad or expired token shouldn't keep the rest
+	// of the agent from starting.
+	if a.cfg.ClusterToken != "" {
+		tok, err := peering.ParseToken([]byte(a.cfg.ClusterGatewaySecret), a.cfg.ClusterToken)
+		if err != nil {
+			slog.Warn("cluster bootstrap token rejected", "error", err)
+		} else if reconciler, err := peering.EstablishPeering(ctx, tok, a.pqcMgr, a.node); err != nil {
+			slog.Warn("cluster bootstrap failed", "cluster_id", tok.ClusterID, "error", err)
+		} else {
+			a.clusterReconciler = reconciler
+			slog.Info("joined cluster via bootstrap token", "cluster_id", tok.ClusterID)
+		}
+	}
+
 	// Start self-healing
-	a.healer.Start()
+	a.healer.Start(ctx)
+
+	// PQC session rekey timer. pqcMgr isn't wired into a real send/receive
+	// dispatch path anywhere yet (nothing currently establishes PQC
+	// sessions over the wire), so send just logs what would go out; once
+	// a dispatch path exists this is where it plugs in.
+	a.pqcMgr.StartRekeyLoop(ctx, func(peerID string, frame []byte) {
+		slog.Debug("pqc rekey frame ready to send", "peer_id", peerID, "bytes", len(frame))
+	})
+
+	// PQC traffic shaper: no peer has ShaperCPR configured until something
+	// calls a.pqcMgr.SetShaper, but the loop can run unconditionally — it's
+	// a no-op until then.
+	a.pqcMgr.StartShaperLoop(ctx, func(peerID string, frame []byte) {
+		slog.Debug("pqc shaped frame ready to send", "peer_id", peerID, "bytes", len(frame))
+	})
+
+	// Local admin API
+	if a.adminSrv != nil {
+		go func() {
+			if err := a.adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Warn("admin API stopped", "error", err)
+			}
+		}()
+	}
 
 	// Register with Control Plane (non-blocking)
 	if a.apiClient != nil {
@@ -190,9 +462,33 @@ func (a *agent) start() error {
 	return nil
 }
 
-func (a *agent) stop() {
+// Shutdown tears down every agent component started by start, in reverse
+// order, and blocks until each one's background loops have actually
+// exited: it drains PQC sessions (with no send func, so peers get no
+// close frame), stops
+// discovery (via node.Stop), and flushes the healing monitor's in-flight
+// cycle before returning. Safe to call after ctx was already cancelled —
+// every component's Stop is idempotent — and ctx bounds how long it waits
+// on the admin server.
+func (a *agent) Shutdown(ctx context.Context) {
+	if a.cancelSubscribe != nil {
+		a.cancelSubscribe()
+	}
+	if a.adminSrv != nil {
+		adminCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		a.adminSrv.Stop(adminCtx)
+		cancel()
+	}
+	if a.clusterReconciler != nil {
+		a.clusterReconciler.Stop()
+		a.clusterReconciler.Wait()
+	}
+	a.pqcMgr.Shutdown(nil)
+	a.pqcMgr.Wait()
 	a.healer.Stop()
+	a.healer.Wait()
 	a.node.Stop()
+	a.node.Wait()
 }
 
 func (a *agent) registerAndHeartbeat() {
@@ -205,7 +501,7 @@ func (a *agent) registerAndHeartbeat() {
 		Arch:     runtime.GOARCH,
 		OS:       runtime.GOOS,
 		Version:  Version,
-		Services: []string{"mesh"},
+		Services: a.services,
 	})
 
 	if err != nil {
@@ -216,27 +512,422 @@ func (a *agent) registerAndHeartbeat() {
 		a.cfg.MeshID = resp.MeshID
 	}
 
-	// Heartbeat loop
+	// Control Plane push channel: bootstrap peers, ACL changes, and config
+	// patches reach the agent as they happen, instead of waiting on the
+	// heartbeat ticker below.
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancelSubscribe = cancel
+	events, err := a.apiClient.Subscribe(ctx)
+	if err != nil {
+		slog.Warn("control event stream unavailable", "error", err)
+		events = nil
+	}
+
+	if a.cfg.LegacyMode {
+		a.legacyHeartbeatLoop(events)
+		return
+	}
+
+	// Coalesced, event-driven heartbeat loop: a.deltaTracker buffers peer/
+	// health/route deltas fed in by discovery and healing callbacks (see
+	// start), and we flush them into one batched envelope as soon as either
+	// the batch window elapses or the heartbeat ticker fires, whichever
+	// comes first. This mirrors the batched map-session rework Headscale
+	// did to cut CPU and traffic on large meshes.
+	ticker := time.NewTicker(time.Duration(a.cfg.HeartbeatIntervalSec) * time.Second)
+	defer ticker.Stop()
+
+	full := true // first heartbeat after (re)registration is always a full snapshot
+	for {
+		select {
+		case <-ticker.C:
+			full = a.flushHeartbeat(full)
+
+		case <-a.deltaTracker.Ready():
+			full = a.flushHeartbeat(full)
+
+		case ev, ok := <-events:
+			if !ok {
+				events = nil // stream closed (ctx canceled); keep heartbeating alone
+				continue
+			}
+			a.handleControlEvent(ev)
+		}
+	}
+}
+
+// flushHeartbeat drains a.deltaTracker and sends one batched heartbeat
+// envelope, marked full if the caller asked for one (always true on the
+// first heartbeat after registration). It returns whether the *next*
+// heartbeat must also be full: true if this send failed or the Control
+// Plane asked for a resync, false once a send has gone through cleanly.
+func (a *agent) flushHeartbeat(full bool) bool {
+	deltaEvents, seq := a.deltaTracker.Flush()
+	events := make([]api.Event, len(deltaEvents))
+	for i, e := range deltaEvents {
+		events[i] = api.Event{
+			Seq:       e.Seq,
+			Type:      string(e.Type),
+			Timestamp: e.Timestamp.UnixMilli(),
+			PeerID:    e.PeerID,
+			Score:     e.Score,
+			Prefix:    e.Prefix,
+			OldNode:   e.OldNode,
+			NewNode:   e.NewNode,
+		}
+	}
+
+	metrics := a.telem.Collect()
+	env := api.HeartbeatEnvelope{
+		Full:     full,
+		SinceSeq: seq,
+		Events:   events,
+		Metrics: api.HeartbeatRequest{
+			NodeID:           a.cfg.NodeID,
+			State:            a.node.State.String(),
+			PeersTotal:       metrics.PeersTotal,
+			PeersHealthy:     metrics.PeersHealthy,
+			HealthScore:      metrics.HealthScore,
+			UptimeSec:        metrics.UptimeSec,
+			MsgSent:          metrics.MsgSent,
+			MsgRecv:          metrics.MsgRecv,
+			RoutesAdvertised: metrics.RoutesAdvertised,
+			RoutesActive:     metrics.RoutesActive,
+		},
+	}
+
+	err := a.apiClient.SendHeartbeatDelta(env)
+	var resyncErr api.ResyncRequiredError
+	switch {
+	case errors.As(err, &resyncErr):
+		slog.Info("control plane requested full resync")
+		return true
+	case err != nil:
+		slog.Debug("heartbeat failed", "error", err)
+		return true
+	default:
+		return false
+	}
+}
+
+// legacyHeartbeatLoop is the pre-batched heartbeat loop, kept for
+// Config.LegacyMode: it posts a full HeartbeatRequest every tick instead of
+// a batched HeartbeatEnvelope, for Control Planes that don't understand the
+// envelope format yet.
+func (a *agent) legacyHeartbeatLoop(events <-chan api.ControlEvent) {
 	ticker := time.NewTicker(time.Duration(a.cfg.HeartbeatIntervalSec) * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		metrics := a.telem.Collect()
-		hb := api.HeartbeatRequest{
-			NodeID:       a.cfg.NodeID,
-			State:        a.node.State.String(),
-			PeersTotal:   metrics.PeersTotal,
-			PeersHealthy: metrics.PeersHealthy,
-			HealthScore:  metrics.HealthScore,
-			UptimeSec:    metrics.UptimeSec,
-			MsgSent:      metrics.MsgSent,
-			MsgRecv:      metrics.MsgRecv,
+	for {
+		select {
+		case <-ticker.C:
+			metrics := a.telem.Collect()
+			hb := api.HeartbeatRequest{
+				NodeID:           a.cfg.NodeID,
+				State:            a.node.State.String(),
+				PeersTotal:       metrics.PeersTotal,
+				PeersHealthy:     metrics.PeersHealthy,
+				HealthScore:      metrics.HealthScore,
+				UptimeSec:        metrics.UptimeSec,
+				MsgSent:          metrics.MsgSent,
+				MsgRecv:          metrics.MsgRecv,
+				RoutesAdvertised: metrics.RoutesAdvertised,
+				RoutesActive:     metrics.RoutesActive,
+			}
+
+			if err := a.apiClient.SendHeartbeat(hb); err != nil {
+				slog.Debug("heartbeat failed", "error", err)
+			}
+
+		case ev, ok := <-events:
+			if !ok {
+				events = nil // stream closed (ctx canceled); keep heartbeating alone
+				continue
+			}
+			a.handleControlEvent(ev)
+		}
+	}
+}
+
+// handleControlEvent applies one event from the Control Plane's push
+// channel (see api.Client.Subscribe) to the running agent.
+func (a *agent) handleControlEvent(ev api.ControlEvent) {
+	switch ev.Type {
+	case api.EventPeerAddressUpdate:
+		if ev.PeerAddressUpdate == nil {
+			return
+		}
+		for _, addr := range ev.PeerAddressUpdate.Addresses {
+			a.disc.AddBootstrapSeed(addr)
 		}
 
-		if err := a.apiClient.SendHeartbeat(hb); err != nil {
-			slog.Debug("heartbeat failed", "error", err)
+	case api.EventACLPolicyUpdate:
+		u := ev.ACLPolicyUpdate
+		if u == nil {
+			return
+		}
+		switch u.Action {
+		case "delete":
+			if err := a.node.RemoveFederationPeering(u.MeshID); err != nil {
+				slog.Warn("failed to remove federation peering", "mesh_id", u.MeshID, "error", err)
+			}
+		default: // "upsert"
+			// ACLPolicyUpdate carries no gateway addresses of its own —
+			// UpdateFederationPeering replaces the peering wholesale, so
+			// reuse whatever gateways config.yaml already has on file for
+			// this mesh rather than wiping them out.
+			var gatewayAddrs []string
+			for _, p := range a.cfg.PeeredMeshes {
+				if p.MeshID == u.MeshID {
+					gatewayAddrs = p.GatewayAddrs
+					break
+				}
+			}
+			err := a.node.UpdateFederationPeering(mesh.PeeringInfo{
+				MeshID:          u.MeshID,
+				GatewayAddrs:    gatewayAddrs,
+				AllowedServices: u.AllowedServices,
+			})
+			if err != nil {
+				slog.Warn("failed to apply ACL policy update", "mesh_id", u.MeshID, "error", err)
+			}
 		}
+
+	case api.EventConfigPatch:
+		if ev.ConfigPatch == nil {
+			return
+		}
+		if err := a.cfg.ApplyPatch(ev.ConfigPatch.Patch); err != nil {
+			slog.Warn("failed to apply config patch", "error", err)
+		}
+
+	case api.EventPSKDelivery:
+		if ev.PSKDelivery == nil || a.pskStore == nil {
+			return
+		}
+		psk, err := security.UnwrapForPeer(a.cfg.PrivateKey, ev.PSKDelivery.EncryptedPSK)
+		if err != nil {
+			slog.Warn("failed to unwrap PSK delivered by peer", "peer_id", ev.PSKDelivery.PeerID, "error", err)
+			return
+		}
+		if err := a.pskStore.SetCurrent(a.cfg.NodeID, ev.PSKDelivery.PeerID, psk); err != nil {
+			slog.Warn("failed to store PSK delivered by peer", "peer_id", ev.PSKDelivery.PeerID, "error", err)
+		}
+
+	case api.EventForceReregister:
+		reason := ""
+		if ev.ForceReregister != nil {
+			reason = ev.ForceReregister.Reason
+		}
+		slog.Warn("control plane forced re-registration", "reason", reason)
+		hostname, _ := os.Hostname()
+		resp, err := a.apiClient.Register(api.RegistrationRequest{
+			NodeID:   a.cfg.NodeID,
+			Token:    a.cfg.JoinToken,
+			Hostname: hostname,
+			Arch:     runtime.GOARCH,
+			OS:       runtime.GOOS,
+			Version:  Version,
+			Services: a.services,
+		})
+		if err != nil {
+			slog.Error("re-registration failed", "error", err)
+			return
+		}
+		a.cfg.MeshID = resp.MeshID
+
+	default:
+		slog.Warn("unknown control event type", "type", ev.Type)
+	}
+}
+
+// runGenKey generates a fresh Ed25519 node identity and writes a minimal
+// agent.yaml, so a private mesh can be stood up without a Control Plane.
+func runGenKey(args []string) {
+	fs := flag.NewFlagSet("genkey", flag.ExitOnError)
+	out := fs.String("out", config.DefaultConfigPath, "path to write the generated agent.yaml")
+	fs.Parse(args)
+
+	kp, err := identity.Generate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	b := make([]byte, 4)
+	rand.Read(b)
+
+	cfg := config.DefaultConfig()
+	cfg.NodeID = fmt.Sprintf("x0t-%s", hex.EncodeToString(b))
+	cfg.PrivateKey = kp.PrivateKeyHex
+	cfg.PublicKey = kp.PublicKeyHex
+
+	if err := cfg.SaveToFile(*out); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("generated identity %s\nwrote config to %s\n", cfg.NodeID, *out)
+}
+
+// runNodeInfo prints this node's public identity in a form suitable for
+// pasting into another node's BootstrapNodes.
+func runNodeInfo(args []string) {
+	fs := flag.NewFlagSet("nodeinfo", flag.ExitOnError)
+	cfgPath := fs.String("config", config.DefaultConfigPath, "path to config file")
+	fs.Parse(args)
+
+	cfg, err := config.LoadFromFile(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.PublicKey == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: no public_key in config; run `x0t-agent genkey` first")
+		os.Exit(1)
+	}
+
+	fmt.Println(identity.NodeInfo(cfg.NodeID, localIP(), cfg.ListenPort, cfg.PublicKey))
+}
+
+// runPeering dispatches the "peering generate"/"peering establish"
+// subcommands that bootstrap mesh-to-mesh federation, modeled on Consul's
+// peering workflow: mint a token on one mesh, redeem it on the other.
+func runPeering(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: x0t-agent peering <generate|establish> [flags]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "generate":
+		runPeeringGenerate(args[1:])
+	case "establish":
+		runPeeringEstablish(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "ERROR: unknown peering subcommand %q\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func runPeeringGenerate(args []string) {
+	fs := flag.NewFlagSet("peering generate", flag.ExitOnError)
+	cfgPath := fs.String("config", config.DefaultConfigPath, "path to config file")
+	name := fs.String("name", "", "name of the remote mesh this token will be issued to")
+	export := fs.String("export", "", "comma-separated services to export to the remote mesh (default: all)")
+	fs.Parse(args)
+
+	if *name == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: --name is required")
+		os.Exit(1)
+	}
+
+	mgr, err := newPeeringManagerFromConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	token, err := mgr.Generate(*name, splitServiceList(*export))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("peering token for %s (trust anchor %s):\n%s\n", *name, mgr.TrustAnchor(), token)
+}
+
+func runPeeringEstablish(args []string) {
+	fs := flag.NewFlagSet("peering establish", flag.ExitOnError)
+	cfgPath := fs.String("config", config.DefaultConfigPath, "path to config file")
+	token := fs.String("token", "", "peering token minted by `peering generate` on the remote mesh")
+	fs.Parse(args)
+
+	if *token == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: --token is required")
+		os.Exit(1)
+	}
+
+	mgr, err := newPeeringManagerFromConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := mgr.Establish(*token); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("peering established")
+}
+
+// newPeeringManagerFromConfig builds a peering.Manager around a signed
+// Discovery and Control Plane client derived from cfgPath, for the
+// standalone `peering generate`/`peering establish` subcommands. It does
+// not touch a running agent process.
+func newPeeringManagerFromConfig(cfgPath string) (*peering.Manager, error) {
+	cfg, err := config.LoadFromFile(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PrivateKey == "" {
+		return nil, fmt.Errorf("no private_key in config; run `x0t-agent genkey` first")
+	}
+	if cfg.PeeringListenAddr == "" {
+		return nil, fmt.Errorf("peering_listen_addr is not set in config")
+	}
+	if cfg.JoinToken == "" {
+		return nil, fmt.Errorf("no join_token in config; this node must be registered with the Control Plane")
+	}
+
+	priv, err := hex.DecodeString(cfg.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode private_key: %w", err)
+	}
+
+	disc, err := discovery.NewSigned(ed25519.PrivateKey(priv), nil, cfg.ListenPort, nil, cfg.MulticastGroup, cfg.MulticastPort)
+	if err != nil {
+		return nil, fmt.Errorf("build signed discovery: %w", err)
+	}
+	disc.PeeringListenAddr = cfg.PeeringListenAddr
+
+	apiClient := api.NewClient(cfg.APIEndpoint, cfg.JoinToken)
+	if _, err := apiClient.Register(api.RegistrationRequest{
+		NodeID: cfg.NodeID,
+		Token:  cfg.JoinToken,
+	}); err != nil {
+		return nil, fmt.Errorf("register with control plane: %w", err)
+	}
+
+	tunnels, err := pqc.NewTunnelManager(cfg.NodeID)
+	if err != nil {
+		return nil, fmt.Errorf("pqc init: %w", err)
+	}
+
+	return peering.NewManager(apiClient, disc, tunnels), nil
+}
+
+// splitServiceList parses a comma-separated --export flag into a service
+// list, returning nil (export everything) for an empty flag.
+func splitServiceList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func localIP() string {
+	conn, err := net.Dial("udp", "8.8.8.8:80")
+	if err != nil {
+		return "127.0.0.1"
 	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
 }
 
 func setupLogger(level string) {
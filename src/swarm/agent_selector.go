@@ -0,0 +1,93 @@
+package swarm
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// emaLatencyAlpha — вес самой свежей задержки в экспоненциально
+// взвешенном скользящем среднем, которое Agent.Load учитывает наряду с
+// числом задач в полёте.
+const emaLatencyAlpha = 0.2
+
+// Load возвращает текущий показатель нагрузки агента для AgentSelector:
+// число выполняемых сейчас задач (с большим весом, т.к. это самый
+// прямой сигнал перегрузки) плюс EWMA недавней задержки в мс. Ниже
+// значение — предпочтительнее агент для следующего назначения.
+func (a *Agent) Load() float64 {
+	a.loadMu.Lock()
+	defer a.loadMu.Unlock()
+	return float64(a.inFlight)*1000 + a.emaLatencyMs
+}
+
+// recordStart отмечает начало выполнения задачи агентом — вызывается из
+// runAgentWorker перед executeTask.
+func (a *Agent) recordStart() {
+	a.loadMu.Lock()
+	a.inFlight++
+	a.loadMu.Unlock()
+}
+
+// recordFinish отмечает завершение задачи и подмешивает её задержку в
+// EWMA — вызывается из runAgentWorker после executeTask.
+func (a *Agent) recordFinish(latencyMs float64) {
+	a.loadMu.Lock()
+	a.inFlight--
+	if a.emaLatencyMs == 0 {
+		a.emaLatencyMs = latencyMs
+	} else {
+		a.emaLatencyMs = emaLatencyAlpha*latencyMs + (1-emaLatencyAlpha)*a.emaLatencyMs
+	}
+	a.loadMu.Unlock()
+}
+
+// AgentSelector выбирает, какому агенту роя направить следующую задачу
+// при прямом назначении через SwarmOrchestrator.AssignTask. Реализации
+// должны быть безопасны для конкурентного вызова — AssignTask может
+// выбирать агентов для нескольких задач одновременно.
+type AgentSelector interface {
+	// Select возвращает одного из candidates, которому следует направить
+	// задачу. candidates всегда непустой.
+	Select(candidates []*Agent) *Agent
+}
+
+// P2CLeastLoaded реализует power-of-two-choices: на каждое назначение
+// сэмплируются два случайных агента из candidates, и выбирается менее
+// нагруженный по Agent.Load(). В отличие от полного сканирования по
+// всем агентам, это не создаёт горячую точку на первом в порядке
+// обхода карты IDLE-агенте и остаётся O(1) при любом размере роя.
+type P2CLeastLoaded struct{}
+
+func (P2CLeastLoaded) Select(candidates []*Agent) *Agent {
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	i := rand.Intn(len(candidates))
+	j := rand.Intn(len(candidates) - 1)
+	if j >= i {
+		j++
+	}
+
+	a, b := candidates[i], candidates[j]
+	if b.Load() < a.Load() {
+		return b
+	}
+	return a
+}
+
+// RoundRobin распределяет задачи по candidates по кругу в порядке,
+// переданном в Select; не учитывает нагрузку агентов, но даёт
+// детерминированное распределение, удобное для тестов.
+type RoundRobin struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (rr *RoundRobin) Select(candidates []*Agent) *Agent {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	agent := candidates[rr.next%len(candidates)]
+	rr.next++
+	return agent
+}
@@ -0,0 +1,79 @@
+package swarm
+
+import "testing"
+
+func TestP2CLeastLoaded_PrefersLessLoadedAgent(t *testing.T) {
+	idle := &Agent{ID: "idle"}
+	busy := &Agent{ID: "busy"}
+	busy.recordStart()
+	busy.recordStart()
+
+	selector := P2CLeastLoaded{}
+	for i := 0; i < 20; i++ {
+		if got := selector.Select([]*Agent{idle, busy}); got != idle {
+			t.Fatalf("Select() = %s, want idle agent %s", got.ID, idle.ID)
+		}
+	}
+}
+
+func TestP2CLeastLoaded_SingleCandidate(t *testing.T) {
+	only := &Agent{ID: "only"}
+	if got := (P2CLeastLoaded{}).Select([]*Agent{only}); got != only {
+		t.Errorf("Select() = %s, want %s", got.ID, only.ID)
+	}
+}
+
+func TestRoundRobin_CyclesThroughCandidates(t *testing.T) {
+	a, b, c := &Agent{ID: "a"}, &Agent{ID: "b"}, &Agent{ID: "c"}
+	candidates := []*Agent{a, b, c}
+	rr := &RoundRobin{}
+
+	want := []*Agent{a, b, c, a}
+	for i, w := range want {
+		if got := rr.Select(candidates); got != w {
+			t.Errorf("Select() call %d = %s, want %s", i, got.ID, w.ID)
+		}
+	}
+}
+
+func TestAgentLoad_TracksInFlightAndLatency(t *testing.T) {
+	agent := &Agent{ID: "a"}
+	if agent.Load() != 0 {
+		t.Fatalf("Load() = %v, want 0 for a fresh agent", agent.Load())
+	}
+
+	agent.recordStart()
+	if agent.Load() != 1000 {
+		t.Errorf("Load() = %v, want 1000 with one in-flight task", agent.Load())
+	}
+
+	agent.recordFinish(100)
+	if agent.Load() != 100 {
+		t.Errorf("Load() = %v, want 100 after the only in-flight task finishes at 100ms", agent.Load())
+	}
+}
+
+func TestSwarmOrchestrator_AssignTaskUsesSelector(t *testing.T) {
+	so := NewSwarmOrchestratorWithSelector(&RoundRobin{})
+	so.InitializeSwarm("swarm-1", 2, K2_5Mode_INSTANT)
+
+	agentIDs := make(map[string]*Agent)
+	for id, agent := range so.agents {
+		agentIDs[id] = agent
+	}
+
+	if err := so.AssignTask(&Task{ID: "t1"}); err != nil {
+		t.Fatalf("AssignTask: %v", err)
+	}
+	if err := so.AssignTask(&Task{ID: "t2"}); err != nil {
+		t.Fatalf("AssignTask: %v", err)
+	}
+
+	assigned := 0
+	for _, agent := range agentIDs {
+		assigned += len(agent.taskCh)
+	}
+	if assigned != 2 {
+		t.Errorf("got %d tasks queued across agent channels, want 2", assigned)
+	}
+}
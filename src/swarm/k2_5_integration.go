@@ -1,13 +1,16 @@
 package swarm
 
 import (
+	"container/heap"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/openbao/openbao/api/v2"
@@ -306,7 +309,10 @@ func NewModeAdapter(mm IModeManager, client *KimiK25Client, vm *VisionModule) *M
 // ExecuteTask выполняет задачу в текущем режиме
 func (ma *ModeAdapter) ExecuteTask(ctx context.Context, swarmId string, task *Task) (*TaskResult, error) {
 	mode := ma.modeManager.GetCurrentMode(swarmId)
-	
+	if task.TargetMode != nil {
+		mode = *task.TargetMode
+	}
+
 	switch mode {
 	case K2_5Mode_INSTANT:
 		return ma.executeInstant(ctx, task)
@@ -695,30 +701,56 @@ func manhattanDistance(node, goal Node) float64 {
 	return math.Abs(node.X-goal.X) + math.Abs(node.Y-goal.Y)
 }
 
-// AnalyzeMaze анализирует лабиринт и находит путь
+// PathAlgorithm выбор алгоритма поиска пути для AnalyzeMazeWithAlgorithm
+type PathAlgorithm int
+
+const (
+	PathAlgorithm_BFS PathAlgorithm = iota
+	PathAlgorithm_ASTAR
+)
+
+// AnalyzeMaze анализирует лабиринт и находит путь с помощью BFS
 func (vm *VisionModule) AnalyzeMaze(imageData []byte, start, end Node) (*MazeAnalysisResult, error) {
+	return vm.AnalyzeMazeWithAlgorithm(imageData, start, end, PathAlgorithm_BFS)
+}
+
+// AnalyzeMazeWithAlgorithm анализирует лабиринт, позволяя выбрать
+// алгоритм поиска пути: BFS (всегда оптимален по числу рёбер) или A*
+// (vm.graphAnalyzer.aStarAlgorithm.weight == 1.0 — оптимален по
+// стоимости; weight > 1.0 — взвешенный A*, находит путь быстрее ценой
+// возможной субоптимальности, которую Metrics.Suboptimality и отражает)
+func (vm *VisionModule) AnalyzeMazeWithAlgorithm(imageData []byte, start, end Node, algo PathAlgorithm) (*MazeAnalysisResult, error) {
 	// 1. Извлекаем граф из изображения
 	graph, err := vm.extractGraphFromImage(imageData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract graph: %w", err)
 	}
-	
-	// 2. Находим кратчайший путь с помощью BFS
-	path, err := vm.graphAnalyzer.bfsAlgorithm.FindPath(graph, start.ID, end.ID)
+
+	// 2. Находим путь выбранным алгоритмом
+	var path []string
+	suboptimality := 1.0
+	switch algo {
+	case PathAlgorithm_ASTAR:
+		suboptimality = vm.graphAnalyzer.aStarAlgorithm.weight
+		path, err = vm.graphAnalyzer.aStarAlgorithm.FindPath(graph, start.ID, end.ID)
+	default:
+		path, err = vm.graphAnalyzer.bfsAlgorithm.FindPath(graph, start.ID, end.ID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to find path: %w", err)
 	}
-	
+
 	// 3. Создаём визуальное наложение
 	overlay := vm.createPathOverlay(imageData, path)
-	
+
 	return &MazeAnalysisResult{
 		Path:    path,
 		Overlay: overlay,
 		Metrics: PathMetrics{
-			Length:      len(path),
-			NodesVisited: graph.NodesVisited,
-			TimeMs:      graph.AnalysisTimeMs,
+			Length:        len(path),
+			NodesVisited:  graph.NodesVisited,
+			TimeMs:        graph.AnalysisTimeMs,
+			Suboptimality: suboptimality,
 		},
 	}, nil
 }
@@ -735,6 +767,11 @@ type PathMetrics struct {
 	Length       int
 	NodesVisited int
 	TimeMs       int64
+
+	// Suboptimality — вес эвристики, с которым был найден путь: 1.0 для
+	// BFS и для обычного A* (оба оптимальны), > 1.0 для взвешенного A*,
+	// где путь может быть длиннее оптимального пропорционально весу.
+	Suboptimality float64
 }
 
 // extractGraphFromImage извлекает граф из изображения
@@ -787,31 +824,36 @@ type Graph struct {
 
 // FindPath находит путь с помощью BFS
 func (bfs *BFSAlgorithm) FindPath(graph *Graph, startID, endID string) ([]string, error) {
+	started := time.Now()
 	if _, ok := graph.Nodes[startID]; !ok {
 		return nil, fmt.Errorf("start node not found: %s", startID)
 	}
 	if _, ok := graph.Nodes[endID]; !ok {
 		return nil, fmt.Errorf("end node not found: %s", endID)
 	}
-	
+
 	// BFS с отслеживанием пути
 	visited := make(map[string]bool)
 	queue := [][]string{{startID}}
 	visited[startID] = true
-	
+	nodesVisited := 0
+
 	for len(queue) > 0 {
 		path := queue[0]
 		queue = queue[1:]
-		
+		nodesVisited++
+
 		nodeID := path[len(path)-1]
 		if nodeID == endID {
+			graph.NodesVisited = nodesVisited
+			graph.AnalysisTimeMs = time.Since(started).Milliseconds()
 			return path, nil
 		}
-		
+
 		if len(path) > bfs.maxDepth {
 			continue
 		}
-		
+
 		for _, neighbor := range graph.Edges[nodeID] {
 			if !visited[neighbor] {
 				visited[neighbor] = true
@@ -821,10 +863,137 @@ func (bfs *BFSAlgorithm) FindPath(graph *Graph, startID, endID string) ([]string
 			}
 		}
 	}
-	
+
+	graph.NodesVisited = nodesVisited
+	graph.AnalysisTimeMs = time.Since(started).Milliseconds()
+	return nil, fmt.Errorf("no path found")
+}
+
+// aStarItem элемент очереди с приоритетом A*: g — стоимость пути от
+// старта, зафиксированная на момент постановки в очередь; f = g +
+// weight*h определяет порядок извлечения.
+type aStarItem struct {
+	nodeID string
+	g      float64
+	f      float64
+	index  int
+}
+
+// aStarQueue бинарная куча открытого множества A*, упорядоченная по f
+type aStarQueue []*aStarItem
+
+func (q aStarQueue) Len() int            { return len(q) }
+func (q aStarQueue) Less(i, j int) bool  { return q[i].f < q[j].f }
+func (q aStarQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *aStarQueue) Push(x any) {
+	item := x.(*aStarItem)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *aStarQueue) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*q = old[:n-1]
+	return item
+}
+
+// FindPath находит путь с помощью A* (или взвешенного A*, если
+// a.weight > 1.0), используя бинарную кучу как открытое множество и
+// ленивую релаксацию: вместо удаления устаревших записей из кучи при
+// улучшении gScore, устаревшие записи просто пропускаются при
+// извлечении, если их g хуже текущего gScore узла.
+func (a *AStarAlgorithm) FindPath(graph *Graph, startID, endID string) ([]string, error) {
+	started := time.Now()
+	startNode, ok := graph.Nodes[startID]
+	if !ok {
+		return nil, fmt.Errorf("start node not found: %s", startID)
+	}
+	goalNode, ok := graph.Nodes[endID]
+	if !ok {
+		return nil, fmt.Errorf("end node not found: %s", endID)
+	}
+
+	heuristic := a.heuristic
+	if heuristic == nil {
+		heuristic = manhattanDistance
+	}
+	weight := a.weight
+	if weight <= 0 {
+		weight = 1.0
+	}
+
+	gScore := map[string]float64{startID: 0}
+	cameFrom := make(map[string]string)
+
+	open := &aStarQueue{{nodeID: startID, g: 0, f: weight * heuristic(*startNode, *goalNode)}}
+	heap.Init(open)
+
+	nodesVisited := 0
+	for open.Len() > 0 {
+		current := heap.Pop(open).(*aStarItem)
+		nodesVisited++
+
+		if best, ok := gScore[current.nodeID]; ok && current.g > best {
+			// Устаревшая запись: с момента постановки в очередь для
+			// этого узла уже найден более короткий путь.
+			continue
+		}
+
+		if current.nodeID == endID {
+			graph.NodesVisited = nodesVisited
+			graph.AnalysisTimeMs = time.Since(started).Milliseconds()
+			return reconstructAStarPath(cameFrom, startID, endID), nil
+		}
+
+		for _, neighborID := range graph.Edges[current.nodeID] {
+			neighbor, ok := graph.Nodes[neighborID]
+			if !ok {
+				continue
+			}
+			tentativeG := current.g + neighbor.Cost
+			if best, ok := gScore[neighborID]; ok && tentativeG >= best {
+				continue
+			}
+			gScore[neighborID] = tentativeG
+			cameFrom[neighborID] = current.nodeID
+			f := tentativeG + weight*heuristic(*neighbor, *goalNode)
+			heap.Push(open, &aStarItem{nodeID: neighborID, g: tentativeG, f: f})
+		}
+	}
+
+	graph.NodesVisited = nodesVisited
+	graph.AnalysisTimeMs = time.Since(started).Milliseconds()
 	return nil, fmt.Errorf("no path found")
 }
 
+// reconstructAStarPath восстанавливает путь от startID до goalID,
+// проходя cameFrom от цели к старту и разворачивая результат.
+func reconstructAStarPath(cameFrom map[string]string, startID, goalID string) []string {
+	path := []string{goalID}
+	current := goalID
+	for current != startID {
+		prev, ok := cameFrom[current]
+		if !ok {
+			break
+		}
+		path = append(path, prev)
+		current = prev
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
 // VisualDebug выполняет визуальную отладку UI
 func (vm *VisionModule) VisualDebug(screenshot []byte) (*AnalysisResult, error) {
 	// 1. Отправляем на анализ
@@ -886,6 +1055,13 @@ type Task struct {
 	Complexity    float64
 	Tools         []Tool
 	Subtasks      []Subtask
+
+	// TargetMode overrides ModeManager.GetCurrentMode for this task only
+	// when set — e.g. a KafkaTaskSource relabel rule routing one topic's
+	// tasks straight to K2_5Mode_AGENT_SWARM regardless of the swarm's
+	// current mode. Nil means "use the swarm's current mode" (see
+	// ModeAdapter.ExecuteTask).
+	TargetMode *K2_5Mode
 }
 
 // Subtask подзадача
@@ -940,7 +1116,7 @@ func (c *KimiK25Client) Complete(ctx context.Context, prompt string, opts *Compl
 	// Выполняем запрос к API
 	_ = plaintext
 	
-	return &TaskResult{
+	result := &TaskResult{
 		ID:      generateID(),
 		Content: "Generated response",
 		Usage: TokenUsage{
@@ -949,7 +1125,8 @@ func (c *KimiK25Client) Complete(ctx context.Context, prompt string, opts *Compl
 			TotalTokens:      150,
 		},
 		LatencyMs: 150,
-	}, nil
+	}
+	return result, nil
 }
 
 // CompleteWithTools выполняет completion с инструментами
@@ -968,7 +1145,7 @@ func (c *KimiK25Client) CompleteWithSwarm(ctx context.Context, swarmId, prompt s
 	// Распределяем подзадачи между агентами роя
 	// Используем PARL для оптимизации
 	
-	return &TaskResult{
+	result := &TaskResult{
 		ID:      generateID(),
 		Content: "Swarm processed result",
 		Usage: TokenUsage{
@@ -977,16 +1154,71 @@ func (c *KimiK25Client) CompleteWithSwarm(ctx context.Context, swarmId, prompt s
 			TotalTokens:      1500,
 		},
 		LatencyMs: 800,
-	}, nil
+	}
+	return result, nil
 }
 
 // SwarmOrchestrator оркестратор роя агентов
 type SwarmOrchestrator struct {
-	mu           sync.RWMutex
-	agents       map[string]*Agent
-	taskQueue    chan *Task
-	results      map[string]*TaskResult
+	mu             sync.RWMutex
+	agents         map[string]*Agent
+	work           chan *Task
+	results        map[string]*TaskResult
 	parlController *PARLController
+
+	// resultDAG и lastAgentRecord реализуют tamper-evident журнал
+	// результатов роя (см. result_dag.go); lastAgentRecord хранит ID
+	// последней записи каждого агента, чтобы RecordResult мог связать
+	// с ней новую запись как с родителем.
+	resultDAG       *ResultDAG
+	lastAgentRecord map[string]string
+
+	// Lifecycle: done закрывается один раз через Stop и останавливает
+	// каждый долгоживущий воркер агента (см. runAgentWorker), а wg
+	// отслеживает их все, так что Shutdown может дождаться завершения
+	// текущих задач.
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	// sem ограничивает число одновременно выполняющихся задач по всему
+	// рою значением parlController.GetOptimalParallelism() на момент
+	// StartWorkers; activeCount — атомарный счётчик занятых воркеров,
+	// так что GetSwarmMetrics читает его без сканирования so.agents под
+	// глобальной блокировкой.
+	sem         chan struct{}
+	activeCount int32
+
+	// selector определяет стратегию AssignTask для прямого назначения
+	// задачи конкретному агенту (см. agent_selector.go); по умолчанию
+	// P2CLeastLoaded.
+	selector AgentSelector
+
+	// progressSubs — подписчики на TaskProgressEvent (см.
+	// task_progress.go), заведённые через StreamTaskProgress/
+	// StreamSwarmProgress; guarded by mu как и остальное состояние роя.
+	progressSubs []*progressSubscription
+}
+
+// NewSwarmOrchestrator создаёт рой агентов с пустыми структурами,
+// жизненным циклом, готовым к StartWorkers/Stop/Shutdown, и назначением
+// задач по умолчанию через P2CLeastLoaded.
+func NewSwarmOrchestrator() *SwarmOrchestrator {
+	return NewSwarmOrchestratorWithSelector(P2CLeastLoaded{})
+}
+
+// NewSwarmOrchestratorWithSelector создаёт рой с явно заданной
+// стратегией AssignTask — например RoundRobin для детерминированных
+// тестов вместо P2CLeastLoaded по умолчанию.
+func NewSwarmOrchestratorWithSelector(selector AgentSelector) *SwarmOrchestrator {
+	return &SwarmOrchestrator{
+		agents:         make(map[string]*Agent),
+		work:           make(chan *Task, 1000),
+		results:        make(map[string]*TaskResult),
+		parlController: NewPARLController(),
+		done:           make(chan struct{}),
+		selector:       selector,
+	}
 }
 
 // Agent агент в рое
@@ -996,6 +1228,19 @@ type Agent struct {
 	Status     AgentStatus
 	TaskCount  int
 	LastActive time.Time
+
+	// taskCh — собственный буферизованный канал агента; runAgentWorker
+	// читает из него либо из общего SwarmOrchestrator.work, так что
+	// направленное назначение задачи конкретному агенту не требует
+	// поиска свободного агента под write-блокировкой so.mu.
+	taskCh chan *Task
+
+	// loadMu защищает inFlight/emaLatencyMs отдельно от
+	// SwarmOrchestrator.mu, чтобы AgentSelector мог читать Load() во
+	// время назначения задач, не беря блокировку всего роя.
+	loadMu       sync.Mutex
+	inFlight     int
+	emaLatencyMs float64
 }
 
 // AgentStatus статус агента
@@ -1007,71 +1252,103 @@ const (
 	AgentStatus_ERROR
 )
 
-// PARLController контроллер Parallel-Agent RL
+// PARLController контроллер Parallel-Agent RL. Вместо двухпорогового
+// эвристического множителя GetOptimalParallelism — это настоящий
+// бандит: он держит набор дискретных уровней параллелизма (см.
+// parl_bandit.go) и на каждый вызов выбирает через UCB1 тот, у которого
+// выше сумма накопленного Q_k и бонуса за исследование.
 type PARLController struct {
-	mu              sync.RWMutex
+	mu               sync.RWMutex
 	maxParallelSteps int
 	currentSteps     int
 	learningRate     float64
-	rewardBuffer     []float64
+
+	// rewardBuffer — кольцевой буфер наград фиксированной ёмкости
+	// (см. reward_ring_buffer.go); хранит как сами награды, так и
+	// инкрементально поддерживаемые суммы, так что ни UpdateReward, ни
+	// learningRate-адаптация не сканируют его целиком на каждый вызов.
+	rewardBuffer rewardRingBuffer
+
+	// levels — кандидаты уровней параллелизма для UCB1; currentLevel —
+	// тот, что был выбран последним вызовом GetOptimalParallelism и
+	// которому UpdateReward приписывает следующую награду.
+	levels       []*parallelismLevel
+	currentLevel *parallelismLevel
+
+	// rewardModel, если задан через SetRewardModel, вычисляет награду
+	// по фактическому результату задачи вместо значения по умолчанию,
+	// которое передаёт вызывающий (см. executeTask).
+	rewardModel func(task *Task, result *TaskResult) float64
 }
 
 // NewPARLController создаёт новый PARL контроллер
 func NewPARLController() *PARLController {
-	return &PARLController{
+	pc := &PARLController{
 		maxParallelSteps: 1500,
 		currentSteps:     0,
 		learningRate:     0.01,
-		rewardBuffer:     make([]float64, 0, 1000),
 	}
+	pc.levels = newParallelismLevels(pc.maxParallelSteps / 10)
+	pc.currentLevel = pc.levels[len(pc.levels)/2] // базовый уровень (множитель 1x) по умолчанию
+	return pc
 }
 
-// UpdateReward обновляет награду для RL
+// UpdateReward приписывает награду текущему уровню параллелизма
+// (выбранному последним вызовом GetOptimalParallelism), обновляя его
+// Q-значение скользящим средним, и подмешивает её в rewardBuffer для
+// адаптации learningRate.
 func (pc *PARLController) UpdateReward(reward float64) {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
-	
-	pc.rewardBuffer = append(pc.rewardBuffer, reward)
-	if len(pc.rewardBuffer) > 1000 {
-		pc.rewardBuffer = pc.rewardBuffer[1:]
-	}
-	
+
+	pc.rewardBuffer.push(reward)
+
 	// Обновляем learning rate на основе средней награды
-	if len(pc.rewardBuffer) > 100 {
-		avgReward := 0.0
-		for _, r := range pc.rewardBuffer {
-			avgReward += r
-		}
-		avgReward /= float64(len(pc.rewardBuffer))
-		
+	if pc.rewardBuffer.count > 100 {
+		avgReward := pc.rewardBuffer.sum / float64(pc.rewardBuffer.count)
+
 		// Адаптивный learning rate
 		pc.learningRate = 0.01 * (1 + avgReward)
 	}
+
+	lvl := pc.currentLevel
+	lvl.visits++
+	lvl.qValue += (reward - lvl.qValue) / float64(lvl.visits)
 }
 
-// GetOptimalParallelism возвращает оптимальное количество параллельных операций
+// GetOptimalParallelism выбирает уровень параллелизма через UCB1 —
+// argmax_k(Q_k + c*sqrt(ln(N_total)/N_k)) — среди levels, запоминает
+// его как currentLevel для следующего UpdateReward и возвращает его
+// значение.
 func (pc *PARLController) GetOptimalParallelism() int {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.currentLevel = selectUCB1(pc.levels)
+	return pc.currentLevel.value
+}
+
+// SetRewardModel задаёт функцию, вычисляющую награду по задаче и её
+// результату — например, со штрафом за задержку или провал (см.
+// external doc: r = success ? 1/(1+latencyMs/1000) : -1) — вместо
+// значения по умолчанию, которое передаёт executeTask.
+func (pc *PARLController) SetRewardModel(model func(task *Task, result *TaskResult) float64) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.rewardModel = model
+}
+
+// RewardFor вычисляет награду для task/result через модель, заданную
+// SetRewardModel, либо возвращает def, если модель не задана.
+func (pc *PARLController) RewardFor(task *Task, result *TaskResult, def float64) float64 {
 	pc.mu.RLock()
-	defer pc.mu.RUnlock()
-	
-	// Базовый расчёт на основе RL
-	baseParallelism := pc.maxParallelSteps / 10
-	
-	// Корректировка на основе истории наград
-	if len(pc.rewardBuffer) > 50 {
-		recentAvg := 0.0
-		for _, r := range pc.rewardBuffer[len(pc.rewardBuffer)-50:] {
-			recentAvg += r
-		}
-		recentAvg /= 50
-		
-		// Увеличиваем параллелизм если награды высокие
-		if recentAvg > 0.8 {
-			return int(float64(baseParallelism) * 1.5)
-		}
+	model := pc.rewardModel
+	pc.mu.RUnlock()
+
+	if model == nil {
+		return def
 	}
-	
-	return baseParallelism
+	return model(task, result)
 }
 
 // ModeCapabilities возможности режима
@@ -1107,30 +1384,24 @@ type SwarmMetrics struct {
 	ResourceUtilization float64
 }
 
-// GetSwarmMetrics возвращает метрики роя
+// GetSwarmMetrics возвращает метрики роя, взятые из swarmMetricsPool —
+// вызывающий обязан вызвать Release на результате, когда он больше не
+// нужен. ActiveAgents читается из activeCount атомарно — без
+// сканирования so.agents под блокировкой, которое раньше было узким
+// местом на горячем пути назначения задач.
 func (so *SwarmOrchestrator) GetSwarmMetrics(swarmId string) *SwarmMetrics {
-	so.mu.RLock()
-	defer so.mu.RUnlock()
-	
-	activeCount := 0
-	for _, agent := range so.agents {
-		if agent.Status == AgentStatus_BUSY {
-			activeCount++
-		}
-	}
-	
-	return &SwarmMetrics{
-		ActiveAgents:     activeCount,
-		PendingTasks:     len(so.taskQueue),
-		ParallelismLevel: so.parlController.GetOptimalParallelism(),
-	}
+	m := swarmMetricsPool.Get().(*SwarmMetrics)
+	m.ActiveAgents = int(atomic.LoadInt32(&so.activeCount))
+	m.PendingTasks = len(so.work)
+	m.ParallelismLevel = so.parlController.GetOptimalParallelism()
+	return m
 }
 
 // InitializeSwarm инициализирует рой агентов
 func (so *SwarmOrchestrator) InitializeSwarm(swarmId string, agentCount int, mode K2_5Mode) error {
 	so.mu.Lock()
 	defer so.mu.Unlock()
-	
+
 	// Создаём агентов
 	for i := 0; i < agentCount; i++ {
 		agentID := fmt.Sprintf("%s-agent-%d", swarmId, i)
@@ -1140,70 +1411,277 @@ func (so *SwarmOrchestrator) InitializeSwarm(swarmId string, agentCount int, mod
 			Status:     AgentStatus_IDLE,
 			TaskCount:  0,
 			LastActive: time.Now(),
+			taskCh:     make(chan *Task, 1),
 		}
 	}
-	
+
 	log.Printf("Initialized swarm %s with %d agents in %s mode", swarmId, agentCount, mode)
 	return nil
 }
 
-// SubmitTask отправляет задачу в рой
-func (so *SwarmOrchestrator) SubmitTask(task *Task) error {
+// ProcessTasks запускает воркеры роя (см. StartWorkers) и блокируется,
+// пока не будет вызван Stop или не истечёт ctx.
+func (so *SwarmOrchestrator) ProcessTasks(ctx context.Context) {
+	so.StartWorkers(ctx)
 	select {
-	case so.taskQueue <- task:
-		return nil
-	default:
-		return fmt.Errorf("task queue is full")
+	case <-so.done:
+	case <-ctx.Done():
 	}
 }
 
-// ProcessTasks обрабатывает задачи из очереди
-func (so *SwarmOrchestrator) ProcessTasks(ctx context.Context) {
+// StartWorkers запускает один долгоживущий воркер на каждого уже
+// инициализированного агента, заменяя прежнее сканирование so.agents в
+// поисках свободного агента под глобальной write-блокировкой на каждое
+// назначение задачи. sem сайзится под parlController.GetOptimalParallelism()
+// один раз, при первом вызове.
+func (so *SwarmOrchestrator) StartWorkers(ctx context.Context) {
+	so.mu.Lock()
+	if so.sem == nil {
+		size := so.parlController.GetOptimalParallelism()
+		if size < 1 {
+			size = 1
+		}
+		so.sem = make(chan struct{}, size)
+	}
+	agents := make([]*Agent, 0, len(so.agents))
+	for _, agent := range so.agents {
+		agents = append(agents, agent)
+	}
+	so.mu.Unlock()
+
+	for _, agent := range agents {
+		so.wg.Add(1)
+		go so.runAgentWorker(ctx, agent)
+	}
+}
+
+// runAgentWorker — долгоживущая горутина агента: пока рой не
+// остановлен, читает задачу либо из своего канала, либо из общего
+// SwarmOrchestrator.work, выполняет её не более одной за раз и не более
+// cap(so.sem) по всему рою одновременно.
+func (so *SwarmOrchestrator) runAgentWorker(ctx context.Context, agent *Agent) {
+	defer so.wg.Done()
+
 	for {
+		var task *Task
 		select {
-		case task := <-so.taskQueue:
-			so.assignTaskToAgent(ctx, task)
+		case <-so.done:
+			return
 		case <-ctx.Done():
 			return
+		case task = <-agent.taskCh:
+		case task = <-so.work:
 		}
+
+		select {
+		case so.sem <- struct{}{}:
+		case <-so.done:
+			return
+		}
+
+		so.mu.Lock()
+		agent.Status = AgentStatus_BUSY
+		agent.TaskCount++
+		agent.LastActive = time.Now()
+		so.mu.Unlock()
+		atomic.AddInt32(&so.activeCount, 1)
+		agent.recordStart()
+
+		start := time.Now()
+		so.executeTask(ctx, agent, task)
+		agent.recordFinish(float64(time.Since(start).Milliseconds()))
+
+		atomic.AddInt32(&so.activeCount, -1)
+		so.mu.Lock()
+		agent.Status = AgentStatus_IDLE
+		so.mu.Unlock()
+		<-so.sem
 	}
 }
 
-// assignTaskToAgent назначает задачу свободному агенту
-func (so *SwarmOrchestrator) assignTaskToAgent(ctx context.Context, task *Task) {
-	so.mu.Lock()
-	defer so.mu.Unlock()
-	
-	// Ищем свободного агента
+// AssignTask выбирает агента через so.selector (по умолчанию
+// P2CLeastLoaded) и направляет задачу напрямую в его taskCh, в обход
+// общей очереди so.work и связанного с ней сканирования candidates.
+// Если буфер taskCh выбранного агента уже занят, задача вместо этого
+// попадает в общую очередь — её разберёт первый освободившийся воркер,
+// как при SubmitTask.
+func (so *SwarmOrchestrator) AssignTask(task *Task) error {
+	select {
+	case <-so.done:
+		return fmt.Errorf("swarm orchestrator: shutting down, rejecting task %s", task.ID)
+	default:
+	}
+
+	so.mu.RLock()
+	candidates := make([]*Agent, 0, len(so.agents))
 	for _, agent := range so.agents {
-		if agent.Status == AgentStatus_IDLE {
-			agent.Status = AgentStatus_BUSY
-			agent.TaskCount++
-			agent.LastActive = time.Now()
-			
-			// Запускаем выполнение в горутине
-			go so.executeTask(ctx, agent, task)
-			return
-		}
+		candidates = append(candidates, agent)
+	}
+	so.mu.RUnlock()
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+
+	if len(candidates) == 0 {
+		return so.SubmitTask(task)
+	}
+
+	agent := so.selector.Select(candidates)
+	select {
+	case agent.taskCh <- task:
+		so.emitTaskProgress(TaskProgressEvent{TaskID: task.ID, AgentID: agent.ID, State: TaskStateNew, Timestamp: time.Now()})
+		return nil
+	default:
+		return so.SubmitTask(task)
+	}
+}
+
+// SubmitTask помещает задачу в общую очередь work, которую разбирают
+// воркеры агентов; отклоняет задачу, если Stop/Shutdown уже
+// инициированы или если work полна (см. SubmitTaskAlways, если
+// переполнение должно покрываться горутиной-оверфлоу, а не отклонением).
+func (so *SwarmOrchestrator) SubmitTask(task *Task) error {
+	select {
+	case <-so.done:
+		return fmt.Errorf("swarm orchestrator: shutting down, rejecting task %s", task.ID)
+	default:
+	}
+
+	select {
+	case so.work <- task:
+		so.emitTaskProgress(TaskProgressEvent{TaskID: task.ID, State: TaskStateNew, Timestamp: time.Now()})
+		return nil
+	default:
+		return fmt.Errorf("task queue is full")
 	}
-	
-	// Если нет свободных агентов, возвращаем в очередь
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		so.SubmitTask(task)
-	}()
 }
 
-// executeTask выполняет задачу агентом
+// SubmitTaskAlways всегда принимает задачу: если все воркеры заняты
+// (work полна), задача выполняется в отдельной горутине-оверфлоу, а не
+// отклоняется — аналог AddTaskAlways в taskPoolSimple.
+func (so *SwarmOrchestrator) SubmitTaskAlways(ctx context.Context, task *Task) error {
+	select {
+	case <-so.done:
+		return fmt.Errorf("swarm orchestrator: shutting down, rejecting task %s", task.ID)
+	default:
+	}
+
+	select {
+	case so.work <- task:
+		so.emitTaskProgress(TaskProgressEvent{TaskID: task.ID, State: TaskStateNew, Timestamp: time.Now()})
+		return nil
+	default:
+	}
+
+	// Add(1) must never race a concurrent Stop/Shutdown's wg.Wait(), so
+	// the done-check and the Add happen atomically under so.mu; Stop
+	// closes done under the same lock (see Stop below).
+	so.mu.Lock()
+	select {
+	case <-so.done:
+		so.mu.Unlock()
+		return fmt.Errorf("swarm orchestrator: shutting down, rejecting task %s", task.ID)
+	default:
+	}
+	so.wg.Add(1)
+	so.mu.Unlock()
+
+	so.emitTaskProgress(TaskProgressEvent{TaskID: task.ID, State: TaskStateNew, Timestamp: time.Now()})
+	go so.runOverflowTask(ctx, task)
+	return nil
+}
+
+// runOverflowTask выполняет задачу вне пула воркеров, когда
+// SubmitTaskAlways не смогла поместить её в work; всё ещё учитывается в
+// sem/activeCount, чтобы метрики и ограничение параллелизма оставались
+// верными для всего роя, а не только для его воркеров.
+func (so *SwarmOrchestrator) runOverflowTask(ctx context.Context, task *Task) {
+	defer so.wg.Done()
+
+	select {
+	case so.sem <- struct{}{}:
+	case <-so.done:
+		return
+	}
+	defer func() { <-so.sem }()
+
+	atomic.AddInt32(&so.activeCount, 1)
+	defer atomic.AddInt32(&so.activeCount, -1)
+
+	so.executeTask(ctx, nil, task)
+}
+
+// executeTask выполняет задачу агентом; agent == nil для
+// горутин-оверфлоу из SubmitTaskAlways, у которых нет закреплённого
+// агента.
 func (so *SwarmOrchestrator) executeTask(ctx context.Context, agent *Agent, task *Task) {
+	agentID := ""
+	if agent != nil {
+		agentID = agent.ID
+	}
+
+	// Проводим задачу через состояния Docker-swarm-style конвейера (см.
+	// task_progress.go) вместо одного скачка BUSY→IDLE, транслируя
+	// каждый переход подписчикам StreamTaskProgress/StreamSwarmProgress.
+	for _, state := range taskExecutionStates {
+		so.emitTaskProgress(TaskProgressEvent{
+			TaskID:    task.ID,
+			AgentID:   agentID,
+			State:     state,
+			Timestamp: time.Now(),
+		})
+	}
+
 	// Здесь реальная логика выполнения
 	// ...
-	
-	// Обновляем статус
-	so.mu.Lock()
-	agent.Status = AgentStatus_IDLE
-	so.mu.Unlock()
-	
-	// Обновляем награду для RL
-	so.parlController.UpdateReward(1.0)
+
+	so.emitTaskProgress(TaskProgressEvent{
+		TaskID:    task.ID,
+		AgentID:   agentID,
+		State:     TaskStateComplete,
+		Timestamp: time.Now(),
+	})
+
+	// Обновляем награду для RL: по умолчанию задачи, попавшие на
+	// перегруженного агента (высокий Load — много задач в полёте и/или
+	// высокая EWMA задержки), получают меньшую награду, так что
+	// GetOptimalParallelism со временем подстраивается под реальное
+	// качество балансировки AgentSelector. so.parlController.RewardFor
+	// подменяет это значение, если вызывающий задал свою модель через
+	// SetRewardModel.
+	defaultReward := 1.0
+	if agent != nil {
+		defaultReward = 1.0 / (1.0 + agent.Load()/1000)
+	}
+	so.parlController.UpdateReward(so.parlController.RewardFor(task, nil, defaultReward))
+}
+
+// Stop останавливает все долгоживущие воркеры агентов, закрывая done;
+// безопасен для многократного вызова. done закрывается под so.mu, той
+// же блокировкой, что и проверка-плюс-wg.Add в SubmitTaskAlways, чтобы
+// ни одна оверфлоу-горутина не стартовала Add после того, как Shutdown
+// уже начал wg.Wait.
+func (so *SwarmOrchestrator) Stop() {
+	so.stopOnce.Do(func() {
+		so.mu.Lock()
+		close(so.done)
+		so.mu.Unlock()
+	})
+}
+
+// Shutdown вызывает Stop и блокируется, пока все агенты не вернутся в
+// IDLE (все executeTask-горутины завершатся), либо пока не истечёт ctx.
+func (so *SwarmOrchestrator) Shutdown(ctx context.Context) error {
+	so.Stop()
+
+	drained := make(chan struct{})
+	go func() {
+		so.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("swarm orchestrator: shutdown timed out waiting for in-flight tasks: %w", ctx.Err())
+	}
 }
@@ -0,0 +1,366 @@
+package swarm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// Metadata labels KafkaTaskSource makes available to RelabelRule, so ops
+// can route tasks to different swarms/modes based on which topic or
+// partition they arrived on.
+const (
+	MetaKafkaTopic     = "__meta_kafka_topic"
+	MetaKafkaPartition = "__meta_kafka_partition"
+	MetaKafkaGroupID   = "__meta_kafka_group_id"
+)
+
+// TaskEncoding selects how KafkaTaskSource decodes a message's value
+// into a Task.
+type TaskEncoding int
+
+const (
+	TaskEncodingJSON TaskEncoding = iota
+	TaskEncodingProtobuf
+)
+
+// RelabelRule rewrites one field of an incoming Task based on Kafka
+// metadata, mirroring Prometheus's relabel_configs: SourceLabels are
+// looked up in the message's metadata, joined with Separator, and
+// matched against Regex. On a match, Replacement (which may reference
+// capture groups as $1, $2, ...) is written into TargetField.
+//
+// TargetField is one of "Complexity", "RequiresVision", "RequiresTools",
+// "K2_5Mode", or "SwarmID" — the last two being the dynamic-routing
+// knobs a KafkaTaskSource needs that a Task itself has no field for.
+type RelabelRule struct {
+	SourceLabels []string
+	Separator    string
+	Regex        *regexp.Regexp
+	TargetField  string
+	Replacement  string
+}
+
+// Apply evaluates the rule against meta and, on a match, writes the
+// expanded replacement into task or swarmID.
+func (r RelabelRule) Apply(task *Task, meta map[string]string, swarmID *string) error {
+	parts := make([]string, len(r.SourceLabels))
+	for i, label := range r.SourceLabels {
+		parts[i] = meta[label]
+	}
+	sep := r.Separator
+	if sep == "" {
+		sep = ";"
+	}
+	source := strings.Join(parts, sep)
+
+	match := r.Regex.FindStringSubmatchIndex(source)
+	if match == nil {
+		return nil
+	}
+	value := string(r.Regex.ExpandString(nil, r.Replacement, source, match))
+
+	switch r.TargetField {
+	case "Complexity":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("parse Complexity %q: %w", value, err)
+		}
+		task.Complexity = f
+	case "RequiresVision":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("parse RequiresVision %q: %w", value, err)
+		}
+		task.RequiresVision = b
+	case "RequiresTools":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("parse RequiresTools %q: %w", value, err)
+		}
+		task.RequiresTools = b
+	case "K2_5Mode":
+		mode, err := parseK2_5Mode(value)
+		if err != nil {
+			return err
+		}
+		task.TargetMode = &mode
+	case "SwarmID":
+		*swarmID = value
+	default:
+		return fmt.Errorf("unknown relabel target field %q", r.TargetField)
+	}
+	return nil
+}
+
+// parseK2_5Mode parses the K2_5Mode enum names a RelabelRule's
+// replacement is expected to produce.
+func parseK2_5Mode(value string) (K2_5Mode, error) {
+	switch value {
+	case "INSTANT":
+		return K2_5Mode_INSTANT, nil
+	case "THINKING":
+		return K2_5Mode_THINKING, nil
+	case "AGENT":
+		return K2_5Mode_AGENT, nil
+	case "AGENT_SWARM":
+		return K2_5Mode_AGENT_SWARM, nil
+	default:
+		return 0, fmt.Errorf("unknown K2_5Mode %q", value)
+	}
+}
+
+// KafkaSourceConfig configures a KafkaTaskSource.
+type KafkaSourceConfig struct {
+	Brokers []string
+	GroupID string
+
+	// Topics may be literal topic names or regex patterns (e.g.
+	// "^swarm\\..*"); patterns are resolved against the cluster's live
+	// topic list on NewKafkaTaskSource.
+	Topics []string
+
+	Format TaskEncoding
+
+	// DefaultSwarmID is used for any task whose RelabelRules don't set a
+	// "SwarmID" target field.
+	DefaultSwarmID string
+	RelabelRules   []RelabelRule
+
+	// MaxInFlight bounds how many fetched-but-not-yet-committed messages
+	// may be outstanding at once, across all partitions. Defaults to 100.
+	MaxInFlight int
+}
+
+// KafkaTaskSource pulls Task records from one or more Kafka
+// topics and feeds them through the same ModeAdapter.ExecuteTask path
+// the gRPC API uses, so external producers can stream work into the
+// swarm without going through it. A message's offset is committed only
+// once ExecuteTask has returned for it, successfully or not, or once it
+// has failed to decode or relabel (at-least-once delivery: a crash
+// mid-task redelivers it rather than silently dropping it), and
+// messages within a partition are always processed in order even though
+// MaxInFlight lets multiple partitions make progress concurrently.
+type KafkaTaskSource struct {
+	cfg     KafkaSourceConfig
+	adapter *ModeAdapter
+	reader  *kafka.Reader
+
+	inFlight chan struct{}
+}
+
+// NewKafkaTaskSource resolves cfg.Topics against the cluster and opens a
+// consumer-group reader for them. adapter is where decoded, relabeled
+// tasks are handed off for execution.
+func NewKafkaTaskSource(cfg KafkaSourceConfig, adapter *ModeAdapter) (*KafkaTaskSource, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka task source: at least one broker is required")
+	}
+	if cfg.GroupID == "" {
+		return nil, fmt.Errorf("kafka task source: group_id is required")
+	}
+	if len(cfg.Topics) == 0 {
+		return nil, fmt.Errorf("kafka task source: at least one topic is required")
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 100
+	}
+
+	topics, err := expandTopics(cfg.Brokers, cfg.Topics)
+	if err != nil {
+		return nil, fmt.Errorf("kafka task source: resolve topics: %w", err)
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     cfg.Brokers,
+		GroupID:     cfg.GroupID,
+		GroupTopics: topics,
+		// Offsets are committed explicitly in process, once ExecuteTask
+		// has returned for the message, so the reader's own background
+		// auto-commit is disabled.
+		CommitInterval: 0,
+	})
+
+	return &KafkaTaskSource{
+		cfg:      cfg,
+		adapter:  adapter,
+		reader:   reader,
+		inFlight: make(chan struct{}, cfg.MaxInFlight),
+	}, nil
+}
+
+// expandTopics resolves patterns against the cluster's live topic list,
+// since kafka.ReaderConfig.GroupTopics only accepts concrete names but
+// ops want to write regex patterns like "^swarm\\..*".
+func expandTopics(brokers []string, patterns []string) ([]string, error) {
+	conn, err := kafka.Dial("tcp", brokers[0])
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]struct{}, len(partitions))
+	for _, p := range partitions {
+		known[p.Topic] = struct{}{}
+	}
+
+	seen := make(map[string]struct{})
+	var topics []string
+	addTopic := func(topic string) {
+		if _, dup := seen[topic]; dup {
+			return
+		}
+		seen[topic] = struct{}{}
+		topics = append(topics, topic)
+	}
+
+	for _, pattern := range patterns {
+		if _, ok := known[pattern]; ok {
+			addTopic(pattern)
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid topic pattern %q: %w", pattern, err)
+		}
+		for topic := range known {
+			if re.MatchString(topic) {
+				addTopic(topic)
+			}
+		}
+	}
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topics matched patterns %v", patterns)
+	}
+	return topics, nil
+}
+
+// Run fetches messages until ctx is canceled, dispatching them to one
+// sequential worker goroutine per partition so a partition's messages
+// are always executed in the order they were produced, while different
+// partitions make progress concurrently up to cfg.MaxInFlight.
+func (k *KafkaTaskSource) Run(ctx context.Context) error {
+	workers := make(map[int]chan kafka.Message)
+	var wg sync.WaitGroup
+	defer func() {
+		for _, ch := range workers {
+			close(ch)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		msg, err := k.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("kafka task source: fetch message: %w", err)
+		}
+
+		ch, ok := workers[msg.Partition]
+		if !ok {
+			ch = make(chan kafka.Message, k.cfg.MaxInFlight)
+			workers[msg.Partition] = ch
+			wg.Add(1)
+			go func(partitionCh chan kafka.Message) {
+				defer wg.Done()
+				for m := range partitionCh {
+					k.process(ctx, m)
+				}
+			}(ch)
+		}
+
+		select {
+		case k.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		ch <- msg
+	}
+}
+
+// process decodes and relabels msg, executes it through adapter, and —
+// whether or not execution succeeded — commits its offset, since
+// at-least-once here means "redeliver on crash", not "retry application
+// errors the producer can't fix by resending".
+func (k *KafkaTaskSource) process(ctx context.Context, msg kafka.Message) {
+	defer func() { <-k.inFlight }()
+
+	task, swarmID, err := k.decodeAndRelabel(msg)
+	if err != nil {
+		log.Printf("kafka task source: dropping unparseable message from %s[%d]@%d: %v", msg.Topic, msg.Partition, msg.Offset, err)
+		k.commit(ctx, msg)
+		return
+	}
+
+	if _, err := k.adapter.ExecuteTask(ctx, swarmID, task); err != nil {
+		log.Printf("kafka task source: task %s failed: %v", task.ID, err)
+	}
+	k.commit(ctx, msg)
+}
+
+func (k *KafkaTaskSource) commit(ctx context.Context, msg kafka.Message) {
+	if err := k.reader.CommitMessages(ctx, msg); err != nil {
+		log.Printf("kafka task source: commit offset for %s[%d]@%d: %v", msg.Topic, msg.Partition, msg.Offset, err)
+	}
+}
+
+// decodeAndRelabel decodes msg.Value into a Task and applies
+// cfg.RelabelRules, seeding the metadata they match against with
+// MetaKafkaTopic, MetaKafkaPartition and MetaKafkaGroupID.
+func (k *KafkaTaskSource) decodeAndRelabel(msg kafka.Message) (*Task, string, error) {
+	task, err := k.decode(msg.Value)
+	if err != nil {
+		return nil, "", err
+	}
+
+	meta := map[string]string{
+		MetaKafkaTopic:     msg.Topic,
+		MetaKafkaPartition: strconv.Itoa(msg.Partition),
+		MetaKafkaGroupID:   k.cfg.GroupID,
+	}
+
+	swarmID := k.cfg.DefaultSwarmID
+	for _, rule := range k.cfg.RelabelRules {
+		if err := rule.Apply(task, meta, &swarmID); err != nil {
+			return nil, "", fmt.Errorf("relabel rule for %s: %w", rule.TargetField, err)
+		}
+	}
+	return task, swarmID, nil
+}
+
+// decode unmarshals value per cfg.Format.
+func (k *KafkaTaskSource) decode(value []byte) (*Task, error) {
+	switch k.cfg.Format {
+	case TaskEncodingProtobuf:
+		// No generated protobuf schema for Task is wired into this
+		// build — same compromise package pbdiscovery documents for the
+		// mesh agent's cross-mesh wire format, since there's no protoc
+		// step here either. JSON is the only encoding usable until one
+		// is added.
+		return nil, fmt.Errorf("kafka task source: protobuf decoding requires a generated Task schema, not yet wired in")
+	default:
+		var task Task
+		if err := json.Unmarshal(value, &task); err != nil {
+			return nil, fmt.Errorf("unmarshal task: %w", err)
+		}
+		return &task, nil
+	}
+}
+
+// Close releases the underlying Kafka consumer-group connection.
+func (k *KafkaTaskSource) Close() error {
+	return k.reader.Close()
+}
@@ -0,0 +1,124 @@
+package swarm
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSwarmOrchestrator_SubmitTaskRejectedAfterStop(t *testing.T) {
+	so := NewSwarmOrchestrator()
+	so.Stop()
+
+	if err := so.SubmitTask(&Task{ID: "t1"}); err == nil {
+		t.Error("expected SubmitTask to reject tasks after Stop")
+	}
+}
+
+func TestSwarmOrchestrator_ShutdownWaitsForInFlightTask(t *testing.T) {
+	so := NewSwarmOrchestrator()
+	so.InitializeSwarm("swarm-1", 1, K2_5Mode_INSTANT)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	so.StartWorkers(ctx)
+
+	if err := so.SubmitTask(&Task{ID: "t1"}); err != nil {
+		t.Fatalf("SubmitTask: %v", err)
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutdownCancel()
+	if err := so.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if err := so.SubmitTask(&Task{ID: "t2"}); err == nil {
+		t.Error("expected SubmitTask to reject tasks after Shutdown")
+	}
+}
+
+func TestSwarmOrchestrator_SubmitTaskAlwaysOverflowsWhenWorkersBusy(t *testing.T) {
+	so := NewSwarmOrchestrator()
+	so.InitializeSwarm("swarm-1", 1, K2_5Mode_INSTANT)
+	so.sem = make(chan struct{}, 1)
+	so.sem <- struct{}{} // simulate every worker already executing a task
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < cap(so.work); i++ {
+		so.work <- &Task{ID: "filler"}
+	}
+
+	if err := so.SubmitTaskAlways(ctx, &Task{ID: "overflow"}); err != nil {
+		t.Fatalf("SubmitTaskAlways: %v", err)
+	}
+
+	<-so.sem // release the simulated worker slot so the overflow goroutine can run
+	done := make(chan struct{})
+	go func() {
+		so.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("overflow task did not complete")
+	}
+}
+
+func TestSwarmOrchestrator_SubmitTaskAlwaysRaceWithShutdown(t *testing.T) {
+	so := NewSwarmOrchestrator()
+	so.InitializeSwarm("swarm-1", 1, K2_5Mode_INSTANT)
+	so.sem = make(chan struct{}, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < cap(so.work); i++ {
+		so.work <- &Task{ID: "filler"}
+	}
+
+	shutdownStarted := make(chan struct{})
+	shutdownDone := make(chan error, 1)
+	go func() {
+		<-shutdownStarted
+		shutdownDone <- so.Shutdown(context.Background())
+	}()
+
+	close(shutdownStarted)
+	err := so.SubmitTaskAlways(ctx, &Task{ID: "overflow"})
+
+	if err := <-shutdownDone; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	// Whether SubmitTaskAlways won the race or Shutdown closed done
+	// first, Shutdown above must not have returned while an overflow
+	// goroutine it didn't wait for was still registering with wg.Add.
+	if err == nil {
+		select {
+		case <-so.done:
+		default:
+			t.Error("SubmitTaskAlways accepted a task but so.done was never closed by the racing Shutdown")
+		}
+	}
+}
+
+func TestSwarmOrchestrator_GetSwarmMetricsReflectsActiveAgents(t *testing.T) {
+	so := NewSwarmOrchestrator()
+	so.InitializeSwarm("swarm-1", 2, K2_5Mode_INSTANT)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	so.StartWorkers(ctx)
+
+	metrics := so.GetSwarmMetrics("swarm-1")
+	if metrics.ActiveAgents != 0 {
+		t.Errorf("ActiveAgents = %d, want 0 before any task is submitted", metrics.ActiveAgents)
+	}
+	if metrics.ParallelismLevel != so.parlController.GetOptimalParallelism() {
+		t.Errorf("ParallelismLevel = %d, want %d", metrics.ParallelismLevel, so.parlController.GetOptimalParallelism())
+	}
+}
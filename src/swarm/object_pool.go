@@ -0,0 +1,24 @@
+package swarm
+
+import "sync"
+
+// swarmMetricsPool держит готовые к повторному использованию
+// *SwarmMetrics вместо того, чтобы выделять их заново на каждый вызов
+// GetSwarmMetrics — это и есть горячий путь роя. Вызывающий код обязан
+// вызвать Release, когда объект больше не нужен; до этого момента
+// использовать его после Release нельзя.
+//
+// Task и TaskResult сюда намеренно не включены: ни один из них не
+// берётся из пула на горячем пути SwarmOrchestrator, а
+// KimiK25Client.Complete/CompleteWithSwarm отдают *TaskResult внешним
+// вызывающим без какого-либо обязательства его освобождать — то есть
+// ровно тот escape пул-объекта через границу API, которого этот пул
+// должен избегать.
+var swarmMetricsPool = sync.Pool{New: func() interface{} { return new(SwarmMetrics) }}
+
+// Release обнуляет SwarmMetrics и возвращает его в пул. Поведение после
+// вызова Release на m, кроме него самого, не определено.
+func (m *SwarmMetrics) Release() {
+	*m = SwarmMetrics{}
+	swarmMetricsPool.Put(m)
+}
@@ -0,0 +1,14 @@
+package swarm
+
+import "testing"
+
+func TestSwarmMetricsRelease_ResetsBeforeReuse(t *testing.T) {
+	m := swarmMetricsPool.Get().(*SwarmMetrics)
+	m.ActiveAgents = 7
+	m.Release()
+
+	reused := swarmMetricsPool.Get().(*SwarmMetrics)
+	if reused.ActiveAgents != 0 {
+		t.Errorf("ActiveAgents = %d, want 0 on a released-then-reacquired SwarmMetrics", reused.ActiveAgents)
+	}
+}
@@ -0,0 +1,60 @@
+package swarm
+
+import "math"
+
+// ucbExplorationConstant — c в UCB1 (Q_k + c*sqrt(ln(N_total)/N_k));
+// √2 — стандартный выбор, балансирующий exploration и exploitation.
+var ucbExplorationConstant = math.Sqrt2
+
+// parallelismLevelMultipliers задаёт кандидатов уровня параллелизма
+// относительно базового значения maxParallelSteps/10: четверть,
+// половина, сам базовый уровень, удвоенный и учетверённый.
+var parallelismLevelMultipliers = []float64{0.25, 0.5, 1, 2, 4}
+
+// parallelismLevel — одно из дискретных значений параллелизма, между
+// которыми PARLController.GetOptimalParallelism выбирает через UCB1:
+// qValue — скользящее среднее наград, полученных при работе на этом
+// уровне; visits — сколько раз этот уровень был выбран.
+type parallelismLevel struct {
+	value  int
+	qValue float64
+	visits int
+}
+
+// newParallelismLevels строит кандидатов уровней параллелизма вокруг
+// base по parallelismLevelMultipliers.
+func newParallelismLevels(base int) []*parallelismLevel {
+	levels := make([]*parallelismLevel, len(parallelismLevelMultipliers))
+	for i, multiplier := range parallelismLevelMultipliers {
+		levels[i] = &parallelismLevel{value: int(float64(base) * multiplier)}
+	}
+	return levels
+}
+
+// selectUCB1 реализует UCB1: уровни без посещений выбираются первыми
+// (холодный старт, иначе ln(N)/N_k не определён), а затем — уровень с
+// максимальным Q_k + ucbExplorationConstant*sqrt(ln(N_total)/N_k).
+func selectUCB1(levels []*parallelismLevel) *parallelismLevel {
+	for _, lvl := range levels {
+		if lvl.visits == 0 {
+			return lvl
+		}
+	}
+
+	total := 0
+	for _, lvl := range levels {
+		total += lvl.visits
+	}
+	logTotal := math.Log(float64(total))
+
+	best := levels[0]
+	bestScore := math.Inf(-1)
+	for _, lvl := range levels {
+		score := lvl.qValue + ucbExplorationConstant*math.Sqrt(logTotal/float64(lvl.visits))
+		if score > bestScore {
+			bestScore = score
+			best = lvl
+		}
+	}
+	return best
+}
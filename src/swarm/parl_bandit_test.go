@@ -0,0 +1,67 @@
+package swarm
+
+import "testing"
+
+func TestSelectUCB1_VisitsEachLevelOnceBeforeExploiting(t *testing.T) {
+	levels := newParallelismLevels(100)
+	seen := make(map[*parallelismLevel]bool)
+	for range levels {
+		lvl := selectUCB1(levels)
+		if seen[lvl] {
+			t.Fatalf("level %d selected twice before every level was visited once", lvl.value)
+		}
+		seen[lvl] = true
+		lvl.visits++
+		lvl.qValue = 1.0
+	}
+}
+
+func TestSelectUCB1_PrefersHigherQValueOnceExplored(t *testing.T) {
+	levels := newParallelismLevels(100)
+	for _, lvl := range levels {
+		lvl.visits = 10
+		lvl.qValue = 0.1
+	}
+	best := levels[2]
+	best.qValue = 0.9
+
+	if got := selectUCB1(levels); got != best {
+		t.Errorf("selectUCB1() = level %d, want the level with the highest Q-value (%d)", got.value, best.value)
+	}
+}
+
+func TestPARLController_UpdateRewardUpdatesCurrentLevelQValue(t *testing.T) {
+	pc := NewPARLController()
+	pc.GetOptimalParallelism() // selects the first unvisited level deterministically
+
+	pc.UpdateReward(1.0)
+	if pc.currentLevel.visits != 1 {
+		t.Fatalf("visits = %d, want 1", pc.currentLevel.visits)
+	}
+	if pc.currentLevel.qValue != 1.0 {
+		t.Errorf("qValue = %v, want 1.0 after a single reward of 1.0", pc.currentLevel.qValue)
+	}
+
+	pc.UpdateReward(0.0)
+	if pc.currentLevel.qValue != 0.5 {
+		t.Errorf("qValue = %v, want 0.5 after rewards [1.0, 0.0]", pc.currentLevel.qValue)
+	}
+}
+
+func TestPARLController_SetRewardModelOverridesDefault(t *testing.T) {
+	pc := NewPARLController()
+	pc.SetRewardModel(func(task *Task, result *TaskResult) float64 {
+		return -1.0
+	})
+
+	if got := pc.RewardFor(&Task{ID: "t1"}, nil, 1.0); got != -1.0 {
+		t.Errorf("RewardFor() = %v, want -1.0 from the custom reward model", got)
+	}
+}
+
+func TestPARLController_RewardForFallsBackToDefaultWithoutModel(t *testing.T) {
+	pc := NewPARLController()
+	if got := pc.RewardFor(&Task{ID: "t1"}, nil, 0.42); got != 0.42 {
+		t.Errorf("RewardFor() = %v, want the provided default when no model is set", got)
+	}
+}
@@ -0,0 +1,132 @@
+package swarm
+
+import (
+	"fmt"
+	"testing"
+)
+
+// buildGridGraph создаёт граф size x size с единичной стоимостью рёбер,
+// где каждый узел соединён с соседями по четырём направлениям —
+// тестовый лабиринт для сравнения BFS и A*.
+func buildGridGraph(size int) (graph *Graph, startID, endID string) {
+	id := func(x, y int) string { return fmt.Sprintf("%d_%d", x, y) }
+
+	graph = &Graph{
+		Nodes: make(map[string]*Node, size*size),
+		Edges: make(map[string][]string, size*size),
+	}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			nodeID := id(x, y)
+			graph.Nodes[nodeID] = &Node{ID: nodeID, X: float64(x), Y: float64(y), Cost: 1}
+		}
+	}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			nodeID := id(x, y)
+			var neighbors []string
+			if x+1 < size {
+				neighbors = append(neighbors, id(x+1, y))
+			}
+			if y+1 < size {
+				neighbors = append(neighbors, id(x, y+1))
+			}
+			if x > 0 {
+				neighbors = append(neighbors, id(x-1, y))
+			}
+			if y > 0 {
+				neighbors = append(neighbors, id(x, y-1))
+			}
+			graph.Edges[nodeID] = neighbors
+		}
+	}
+	return graph, id(0, 0), id(size-1, size-1)
+}
+
+func TestAStarFindPath_MatchesBFSLength(t *testing.T) {
+	graph, start, end := buildGridGraph(10)
+	bfs := &BFSAlgorithm{maxDepth: 1000}
+	bfsPath, err := bfs.FindPath(graph, start, end)
+	if err != nil {
+		t.Fatalf("BFS FindPath: %v", err)
+	}
+
+	graph2, _, _ := buildGridGraph(10)
+	astar := &AStarAlgorithm{heuristic: manhattanDistance, weight: 1.0}
+	astarPath, err := astar.FindPath(graph2, start, end)
+	if err != nil {
+		t.Fatalf("A* FindPath: %v", err)
+	}
+
+	if len(astarPath) != len(bfsPath) {
+		t.Errorf("A* path length = %d, want %d (same as BFS on a unit-cost grid)", len(astarPath), len(bfsPath))
+	}
+}
+
+func TestAStarFindPath_RejectsUnknownNodes(t *testing.T) {
+	graph, start, _ := buildGridGraph(3)
+	astar := &AStarAlgorithm{heuristic: manhattanDistance, weight: 1.0}
+
+	if _, err := astar.FindPath(graph, start, "missing"); err == nil {
+		t.Error("expected an error for an unknown end node")
+	}
+	if _, err := astar.FindPath(graph, "missing", start); err == nil {
+		t.Error("expected an error for an unknown start node")
+	}
+}
+
+func TestAStarFindPath_WeightedIsNeverShorterThanOptimal(t *testing.T) {
+	graph, start, end := buildGridGraph(12)
+	astar := &AStarAlgorithm{heuristic: manhattanDistance, weight: 1.0}
+	optimal, err := astar.FindPath(graph, start, end)
+	if err != nil {
+		t.Fatalf("A* FindPath: %v", err)
+	}
+
+	graph2, _, _ := buildGridGraph(12)
+	weighted := &AStarAlgorithm{heuristic: manhattanDistance, weight: 3.0}
+	suboptimal, err := weighted.FindPath(graph2, start, end)
+	if err != nil {
+		t.Fatalf("weighted A* FindPath: %v", err)
+	}
+
+	if len(suboptimal) < len(optimal) {
+		t.Errorf("weighted A* found a path shorter than optimal: %d < %d", len(suboptimal), len(optimal))
+	}
+}
+
+func BenchmarkBFSFindPath(b *testing.B) {
+	bfs := &BFSAlgorithm{maxDepth: 10000}
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		graph, start, end := buildGridGraph(30)
+		b.StartTimer()
+		if _, err := bfs.FindPath(graph, start, end); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAStarFindPath(b *testing.B) {
+	astar := &AStarAlgorithm{heuristic: manhattanDistance, weight: 1.0}
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		graph, start, end := buildGridGraph(30)
+		b.StartTimer()
+		if _, err := astar.FindPath(graph, start, end); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWeightedAStarFindPath(b *testing.B) {
+	astar := &AStarAlgorithm{heuristic: manhattanDistance, weight: 2.0}
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		graph, start, end := buildGridGraph(30)
+		b.StartTimer()
+		if _, err := astar.FindPath(graph, start, end); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
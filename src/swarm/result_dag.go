@@ -0,0 +1,267 @@
+package swarm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DAGRecord запись результата в DAG роя — оборачивает один TaskResult
+// (или одну подзадачу, выполненную под K2_5Mode_AGENT_SWARM) и
+// ссылается не более чем на 2 родителя: предыдущий результат того же агента и/или
+// родительскую подзадачу
+type DAGRecord struct {
+	ID         string
+	AgentID    string
+	SwarmID    string
+	Mode       K2_5Mode
+	PromptHash [32]byte
+	OutputHash [32]byte
+	Parents    []string
+	Height     int
+	Timestamp  time.Time
+}
+
+// computeRecordID хэширует поля записи в её ID; изменение любого поля
+// меняет ID, что и делает цепочку tamper-evident без внешней БД
+func computeRecordID(agentID, swarmID string, mode K2_5Mode, promptHash, outputHash [32]byte, parents []string, timestamp time.Time) string {
+	h := sha256.New()
+	h.Write([]byte(agentID))
+	h.Write([]byte(swarmID))
+	h.Write([]byte(strconv.Itoa(int(mode))))
+	h.Write(promptHash[:])
+	h.Write(outputHash[:])
+	h.Write([]byte(strings.Join(parents, ",")))
+	h.Write([]byte(strconv.FormatInt(timestamp.UnixNano(), 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NewDAGRecord строит запись для dag: высота выводится из максимальной
+// высоты родителей (0, если родителей нет), а ID — из хэша её
+// содержимого. Родители должны уже присутствовать в dag.
+func NewDAGRecord(dag *ResultDAG, agentID, swarmID string, mode K2_5Mode, prompt, output string, parents []string, timestamp time.Time) (*DAGRecord, error) {
+	if len(parents) > 2 {
+		return nil, fmt.Errorf("result dag: record may have at most 2 parents, got %d", len(parents))
+	}
+
+	height := 0
+	if len(parents) > 0 {
+		maxParentHeight := -1
+		for _, p := range parents {
+			parent, ok := dag.Get(p)
+			if !ok {
+				return nil, fmt.Errorf("result dag: unknown parent %q", p)
+			}
+			if parent.Height > maxParentHeight {
+				maxParentHeight = parent.Height
+			}
+		}
+		height = maxParentHeight + 1
+	}
+
+	promptHash := sha256.Sum256([]byte(prompt))
+	outputHash := sha256.Sum256([]byte(output))
+	recordParents := append([]string(nil), parents...)
+
+	return &DAGRecord{
+		ID:         computeRecordID(agentID, swarmID, mode, promptHash, outputHash, recordParents, timestamp),
+		AgentID:    agentID,
+		SwarmID:    swarmID,
+		Mode:       mode,
+		PromptHash: promptHash,
+		OutputHash: outputHash,
+		Parents:    recordParents,
+		Height:     height,
+		Timestamp:  timestamp,
+	}, nil
+}
+
+// ResultDAG append-only DAG результатов задач/подзадач роя. Это
+// единственная точка хранения истории — нет внешней БД — так что
+// IsSafe/VerifyChain являются единственной защитой от дублирующихся
+// или зацикленных эмиссий подзадач, а также дают billing (TokenUsage) и
+// audit (ModeTransition) воспроизводимую, проверяемую историю.
+type ResultDAG struct {
+	mu      sync.RWMutex
+	records map[string]*DAGRecord
+	tips    map[string]struct{}
+}
+
+// NewResultDAG создаёт пустой DAG результатов
+func NewResultDAG() *ResultDAG {
+	return &ResultDAG{
+		records: make(map[string]*DAGRecord),
+		tips:    make(map[string]struct{}),
+	}
+}
+
+// Get возвращает запись по ID
+func (d *ResultDAG) Get(id string) (*DAGRecord, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	record, ok := d.records[id]
+	return record, ok
+}
+
+// Tips возвращает ID записей, на которые пока не сослалась ни одна
+// более поздняя запись
+func (d *ResultDAG) Tips() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	tips := make([]string, 0, len(d.tips))
+	for id := range d.tips {
+		tips = append(tips, id)
+	}
+	return tips
+}
+
+// Add проверяет запись через IsSafe и, если она прошла проверку,
+// добавляет её в DAG, снимая со всех её родителей статус tip.
+func (d *ResultDAG) Add(record *DAGRecord) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.records[record.ID]; exists {
+		return fmt.Errorf("result dag: record %s already present", record.ID)
+	}
+	if err := d.isSafeLocked(record); err != nil {
+		return err
+	}
+
+	d.records[record.ID] = record
+	for _, p := range record.Parents {
+		delete(d.tips, p)
+	}
+	d.tips[record.ID] = struct{}{}
+	return nil
+}
+
+// IsSafe отклоняет записи, являющиеся собственным родителем
+// (самоколлизия), ссылающиеся на неизвестных родителей, либо чья
+// высота не согласуется с высотой родителей.
+func (d *ResultDAG) IsSafe(record *DAGRecord) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.isSafeLocked(record)
+}
+
+func (d *ResultDAG) isSafeLocked(record *DAGRecord) error {
+	for _, p := range record.Parents {
+		if p == record.ID {
+			return fmt.Errorf("result dag: record %s is its own parent", record.ID)
+		}
+	}
+
+	maxParentHeight := -1
+	for _, p := range record.Parents {
+		parent, ok := d.records[p]
+		if !ok {
+			return fmt.Errorf("result dag: record %s references unknown parent %s", record.ID, p)
+		}
+		if parent.Height > maxParentHeight {
+			maxParentHeight = parent.Height
+		}
+	}
+	if record.Height != maxParentHeight+1 {
+		return fmt.Errorf("result dag: record %s has height %d, want %d given its parents", record.ID, record.Height, maxParentHeight+1)
+	}
+	return nil
+}
+
+// VerifyChain проходит DAG от текущих tips свормы swarmId к корню,
+// пересчитывая ID каждой записи по её полям и сверяя его с хранимым, и
+// возвращает ID всех несовпавших записей.
+func (d *ResultDAG) VerifyChain(swarmId string) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var offending []string
+	visited := make(map[string]bool)
+
+	var walk func(id string)
+	walk = func(id string) {
+		if visited[id] {
+			return
+		}
+		visited[id] = true
+
+		record, ok := d.records[id]
+		if !ok {
+			offending = append(offending, id)
+			return
+		}
+
+		wantID := computeRecordID(record.AgentID, record.SwarmID, record.Mode, record.PromptHash, record.OutputHash, record.Parents, record.Timestamp)
+		if wantID != record.ID {
+			offending = append(offending, record.ID)
+		}
+
+		for _, p := range record.Parents {
+			walk(p)
+		}
+	}
+
+	for tip := range d.tips {
+		record, ok := d.records[tip]
+		if !ok || record.SwarmID != swarmId {
+			continue
+		}
+		walk(tip)
+	}
+
+	return offending
+}
+
+// RecordResult оборачивает результат агента (TaskResult или подзадача
+// под K2_5Mode_AGENT_SWARM) в DAGRecord, связывая его с предыдущим
+// результатом того же агента и, если он выполнялся как подзадача, с
+// родительской подзадачей, затем добавляет запись в DAG роя.
+func (so *SwarmOrchestrator) RecordResult(agentID, swarmId string, mode K2_5Mode, prompt, output string, subtaskParent string) (*DAGRecord, error) {
+	so.mu.Lock()
+	if so.resultDAG == nil {
+		so.resultDAG = NewResultDAG()
+	}
+	if so.lastAgentRecord == nil {
+		so.lastAgentRecord = make(map[string]string)
+	}
+	dag := so.resultDAG
+
+	var parents []string
+	if prev, ok := so.lastAgentRecord[agentID]; ok {
+		parents = append(parents, prev)
+	}
+	if subtaskParent != "" {
+		parents = append(parents, subtaskParent)
+	}
+	so.mu.Unlock()
+
+	record, err := NewDAGRecord(dag, agentID, swarmId, mode, prompt, output, parents, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := dag.Add(record); err != nil {
+		return nil, err
+	}
+
+	so.mu.Lock()
+	so.lastAgentRecord[agentID] = record.ID
+	so.mu.Unlock()
+
+	return record, nil
+}
+
+// DAG возвращает DAG результатов роя, создавая его при первом
+// обращении.
+func (so *SwarmOrchestrator) DAG() *ResultDAG {
+	so.mu.Lock()
+	defer so.mu.Unlock()
+	if so.resultDAG == nil {
+		so.resultDAG = NewResultDAG()
+	}
+	return so.resultDAG
+}
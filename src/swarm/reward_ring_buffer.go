@@ -0,0 +1,46 @@
+package swarm
+
+const (
+	// rewardBufferCapacity — ёмкость кольцевого буфера наград
+	// PARLController; совпадает со старым порогом усечения слайса.
+	rewardBufferCapacity = 1000
+
+	// rewardRecentWindow — размер окна последних наград, сумму которых
+	// rewardRingBuffer держит в recentSum.
+	rewardRecentWindow = 50
+)
+
+// rewardRingBuffer — кольцевой буфер наград PARLController фиксированной
+// ёмкости rewardBufferCapacity: push не выделяет память (в отличие от
+// rewardBuffer[1:], который растил новый backing array при каждом
+// усечении), а sum/recentSum поддерживаются инкрементально, так что
+// UpdateReward не сканирует буфер целиком. GetOptimalParallelism (UCB1)
+// буфер не читает.
+type rewardRingBuffer struct {
+	data      [rewardBufferCapacity]float64
+	head      int     // индекс следующей записи
+	count     int     // число валидных записей, не больше rewardBufferCapacity
+	sum       float64 // сумма всех валидных записей
+	recentSum float64 // сумма последних min(count, rewardRecentWindow) записей
+}
+
+// push добавляет награду в буфер, вытесняя самую старую запись, если
+// буфер уже заполнен, и обновляя sum/recentSum без сканирования data.
+func (rb *rewardRingBuffer) push(reward float64) {
+	idx := rb.head
+	if rb.count == rewardBufferCapacity {
+		rb.sum -= rb.data[idx]
+	} else {
+		rb.count++
+	}
+	rb.data[idx] = reward
+	rb.sum += reward
+
+	rb.recentSum += reward
+	if rb.count > rewardRecentWindow {
+		evictIdx := (idx - rewardRecentWindow + rewardBufferCapacity) % rewardBufferCapacity
+		rb.recentSum -= rb.data[evictIdx]
+	}
+
+	rb.head = (idx + 1) % rewardBufferCapacity
+}
@@ -0,0 +1,53 @@
+package swarm
+
+import "testing"
+
+func TestRewardRingBuffer_SumTracksPushedValues(t *testing.T) {
+	var rb rewardRingBuffer
+	for i := 1; i <= 10; i++ {
+		rb.push(float64(i))
+	}
+
+	if rb.count != 10 {
+		t.Fatalf("count = %d, want 10", rb.count)
+	}
+	if rb.sum != 55 {
+		t.Errorf("sum = %v, want 55", rb.sum)
+	}
+	if rb.recentSum != 55 {
+		t.Errorf("recentSum = %v, want 55 (fewer than rewardRecentWindow pushes)", rb.recentSum)
+	}
+}
+
+func TestRewardRingBuffer_RecentSumDropsOldestOutsideWindow(t *testing.T) {
+	var rb rewardRingBuffer
+	for i := 0; i < rewardRecentWindow+1; i++ {
+		rb.push(1.0)
+	}
+	// One extra push of a different value past the window boundary.
+	rb.push(5.0)
+
+	wantRecent := float64(rewardRecentWindow-1)*1.0 + 5.0
+	if rb.recentSum != wantRecent {
+		t.Errorf("recentSum = %v, want %v", rb.recentSum, wantRecent)
+	}
+}
+
+func TestRewardRingBuffer_EvictsOldestBeyondCapacity(t *testing.T) {
+	var rb rewardRingBuffer
+	for i := 0; i < rewardBufferCapacity; i++ {
+		rb.push(1.0)
+	}
+	if rb.count != rewardBufferCapacity {
+		t.Fatalf("count = %d, want %d", rb.count, rewardBufferCapacity)
+	}
+
+	rb.push(3.0)
+	if rb.count != rewardBufferCapacity {
+		t.Errorf("count = %d, want unchanged %d once buffer is full", rb.count, rewardBufferCapacity)
+	}
+	wantSum := float64(rewardBufferCapacity-1)*1.0 + 3.0
+	if rb.sum != wantSum {
+		t.Errorf("sum = %v, want %v after evicting the oldest entry", rb.sum, wantSum)
+	}
+}
@@ -0,0 +1,220 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TaskState отражает положение задачи в конвейере исполнения —
+// нумерация растёт монотонно по мере продвижения задачи вперёд (см.
+// external docs 3, 6, 7, 10 про аналогичный Docker-swarm-style
+// конвейер), так что более позднее состояние всегда имеет большее
+// числовое значение, кроме терминальных Failed/Rejected.
+type TaskState int
+
+const (
+	TaskStateNew TaskState = iota
+	TaskStateAllocated
+	TaskStatePending
+	TaskStateAssigned
+	TaskStateAccepted
+	TaskStatePreparing
+	TaskStateReady
+	TaskStateStarting
+	TaskStateRunning
+	TaskStateComplete
+	TaskStateFailed
+	TaskStateRejected
+)
+
+func (s TaskState) String() string {
+	switch s {
+	case TaskStateNew:
+		return "New"
+	case TaskStateAllocated:
+		return "Allocated"
+	case TaskStatePending:
+		return "Pending"
+	case TaskStateAssigned:
+		return "Assigned"
+	case TaskStateAccepted:
+		return "Accepted"
+	case TaskStatePreparing:
+		return "Preparing"
+	case TaskStateReady:
+		return "Ready"
+	case TaskStateStarting:
+		return "Starting"
+	case TaskStateRunning:
+		return "Running"
+	case TaskStateComplete:
+		return "Complete"
+	case TaskStateFailed:
+		return "Failed"
+	case TaskStateRejected:
+		return "Rejected"
+	default:
+		return "Unknown"
+	}
+}
+
+// taskExecutionStates — состояния, через которые executeTask проводит
+// каждую задачу между TaskStateNew (присвоенным при отправке) и
+// терминальным TaskStateComplete/TaskStateFailed.
+var taskExecutionStates = []TaskState{
+	TaskStateAllocated,
+	TaskStatePending,
+	TaskStateAssigned,
+	TaskStateAccepted,
+	TaskStatePreparing,
+	TaskStateReady,
+	TaskStateStarting,
+	TaskStateRunning,
+}
+
+// IsTerminal сообщает, является ли состояние конечным для задачи —
+// дальнейших переходов после него не будет.
+func (s TaskState) IsTerminal() bool {
+	return s == TaskStateComplete || s == TaskStateFailed || s == TaskStateRejected
+}
+
+// TaskProgressEvent одно изменение состояния задачи, передаваемое через
+// StreamTaskProgress/StreamSwarmProgress.
+type TaskProgressEvent struct {
+	TaskID    string
+	AgentID   string
+	State     TaskState
+	Timestamp time.Time
+	Err       error
+}
+
+// progressSubscription — подписка на TaskProgressEvent, заведённая
+// через StreamTaskProgress (taskID непустой) или StreamSwarmProgress
+// (swarmID непустой, taskID пуст).
+type progressSubscription struct {
+	ch      chan TaskProgressEvent
+	taskID  string
+	swarmID string
+}
+
+// progressChanBuffer — ёмкость канала подписки; события, не
+// прочитанные подписчиком вовремя, отбрасываются, как и переполнение
+// so.work при SubmitTask — стриминг прогресса не должен иметь
+// возможность застопорить executeTask.
+const progressChanBuffer = 32
+
+// emitTaskProgress рассылает событие всем подходящим подписчикам,
+// отбрасывая его для тех, чей канал полон.
+func (so *SwarmOrchestrator) emitTaskProgress(event TaskProgressEvent) {
+	so.mu.RLock()
+	defer so.mu.RUnlock()
+
+	for _, sub := range so.progressSubs {
+		if sub.taskID != "" {
+			if sub.taskID != event.TaskID {
+				continue
+			}
+		} else if sub.swarmID != "" {
+			if !strings.HasPrefix(event.AgentID, sub.swarmID+"-agent-") {
+				continue
+			}
+		}
+
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
+
+// addProgressSub регистрирует sub; используется StreamTaskProgress и
+// StreamSwarmProgress, которые сами отвечают за снятие подписки через
+// removeProgressSub по отмене ctx.
+func (so *SwarmOrchestrator) addProgressSub(sub *progressSubscription) {
+	so.mu.Lock()
+	so.progressSubs = append(so.progressSubs, sub)
+	so.mu.Unlock()
+}
+
+func (so *SwarmOrchestrator) removeProgressSub(sub *progressSubscription) {
+	so.mu.Lock()
+	for i, s := range so.progressSubs {
+		if s == sub {
+			so.progressSubs = append(so.progressSubs[:i], so.progressSubs[i+1:]...)
+			break
+		}
+	}
+	so.mu.Unlock()
+	close(sub.ch)
+}
+
+// StreamTaskProgress возвращает канал, на который приходит по одному
+// TaskProgressEvent за каждый переход состояния задачи taskID; канал
+// закрывается, когда ctx отменяется.
+func (so *SwarmOrchestrator) StreamTaskProgress(ctx context.Context, taskID string) <-chan TaskProgressEvent {
+	sub := &progressSubscription{ch: make(chan TaskProgressEvent, progressChanBuffer), taskID: taskID}
+	so.addProgressSub(sub)
+
+	go func() {
+		<-ctx.Done()
+		so.removeProgressSub(sub)
+	}()
+
+	return sub.ch
+}
+
+// StreamSwarmProgress возвращает агрегированный канал с одним
+// TaskProgressEvent на каждый переход состояния любой задачи,
+// выполняемой агентами роя swarmID (см. InitializeSwarm — их ID имеют
+// вид "<swarmID>-agent-N"); канал закрывается, когда ctx отменяется.
+func (so *SwarmOrchestrator) StreamSwarmProgress(ctx context.Context, swarmID string) <-chan TaskProgressEvent {
+	sub := &progressSubscription{ch: make(chan TaskProgressEvent, progressChanBuffer), swarmID: swarmID}
+	so.addProgressSub(sub)
+
+	go func() {
+		<-ctx.Done()
+		so.removeProgressSub(sub)
+	}()
+
+	return sub.ch
+}
+
+// WaitForConvergence блокируется, пока все агенты роя swarmID не
+// вернутся в IDLE — то есть их текущая задача, если она была, дошла до
+// терминального состояния (см. executeTask) — либо пока не истечёт ctx.
+// Полезно для оркестрованных батч-джобов, которые должны дождаться
+// завершения всей волны задач перед началом следующей. Задачи, ещё
+// ждущие в so.work или в taskCh агента, не учитываются: если ни один
+// агент роя не BUSY, функция возвращается сразу.
+func (so *SwarmOrchestrator) WaitForConvergence(ctx context.Context, swarmID string) error {
+	prefix := swarmID + "-agent-"
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		so.mu.RLock()
+		converged := true
+		for id, agent := range so.agents {
+			if !strings.HasPrefix(id, prefix) {
+				continue
+			}
+			if agent.Status == AgentStatus_BUSY {
+				converged = false
+				break
+			}
+		}
+		so.mu.RUnlock()
+
+		if converged {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("swarm orchestrator: convergence wait for swarm %s timed out: %w", swarmID, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package swarm
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func drainUntilTerminal(t *testing.T, ch <-chan TaskProgressEvent) []TaskState {
+	t.Helper()
+	var states []TaskState
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return states
+			}
+			states = append(states, event.State)
+			if event.State.IsTerminal() {
+				return states
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for a terminal task state")
+		}
+	}
+}
+
+func TestStreamTaskProgress_ReportsMonotonicStatesToComplete(t *testing.T) {
+	so := NewSwarmOrchestrator()
+	so.InitializeSwarm("swarm-1", 1, K2_5Mode_INSTANT)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	so.StartWorkers(ctx)
+
+	events := so.StreamTaskProgress(ctx, "t1")
+	if err := so.SubmitTask(&Task{ID: "t1"}); err != nil {
+		t.Fatalf("SubmitTask: %v", err)
+	}
+
+	states := drainUntilTerminal(t, events)
+	if len(states) == 0 {
+		t.Fatal("expected at least one progress event")
+	}
+	if last := states[len(states)-1]; last != TaskStateComplete {
+		t.Errorf("final state = %s, want %s", last, TaskStateComplete)
+	}
+	for i := 1; i < len(states); i++ {
+		if states[i] < states[i-1] {
+			t.Errorf("state regressed from %s to %s", states[i-1], states[i])
+		}
+	}
+}
+
+func TestStreamSwarmProgress_FiltersByAgentPrefix(t *testing.T) {
+	so := NewSwarmOrchestrator()
+	so.InitializeSwarm("swarm-a", 1, K2_5Mode_INSTANT)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	so.StartWorkers(ctx)
+
+	events := so.StreamSwarmProgress(ctx, "swarm-a")
+	otherSwarmEvents := so.StreamSwarmProgress(ctx, "swarm-b")
+
+	if err := so.AssignTask(&Task{ID: "t-a"}); err != nil {
+		t.Fatalf("AssignTask: %v", err)
+	}
+
+	states := drainUntilTerminal(t, events)
+	if len(states) == 0 {
+		t.Fatal("expected at least one progress event for swarm-a")
+	}
+
+	select {
+	case event := <-otherSwarmEvents:
+		t.Errorf("unexpected event for unrelated swarm-b subscription: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWaitForConvergence_ReturnsOnceAgentIsIdleAgain(t *testing.T) {
+	so := NewSwarmOrchestrator()
+	so.InitializeSwarm("swarm-1", 1, K2_5Mode_INSTANT)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	so.StartWorkers(ctx)
+
+	if err := so.SubmitTask(&Task{ID: "t1"}); err != nil {
+		t.Fatalf("SubmitTask: %v", err)
+	}
+
+	convergeCtx, convergeCancel := context.WithTimeout(context.Background(), time.Second)
+	defer convergeCancel()
+	if err := so.WaitForConvergence(convergeCtx, "swarm-1"); err != nil {
+		t.Fatalf("WaitForConvergence: %v", err)
+	}
+}
+
+func TestWaitForConvergence_TimesOutWhenAgentNeverIdles(t *testing.T) {
+	so := NewSwarmOrchestrator()
+	so.InitializeSwarm("swarm-1", 1, K2_5Mode_INSTANT)
+	so.agents["swarm-1-agent-0"].Status = AgentStatus_BUSY
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := so.WaitForConvergence(ctx, "swarm-1"); err == nil {
+		t.Error("expected WaitForConvergence to time out on a permanently busy agent")
+	}
+}
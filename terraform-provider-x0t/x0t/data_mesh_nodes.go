@@ -0,0 +1,96 @@
+package x0t
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceMeshNodes() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceMeshNodesRead,
+		Schema: map[string]*schema.Schema{
+			"mesh_id": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"nodes": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"hostname": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"state": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"health_score": {
+							Type:     schema.TypeFloat,
+							Computed: true,
+						},
+						"advertised_routes": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"last_seen": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceMeshNodesRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	meshId := d.Get("mesh_id").(string)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/%s/nodes", c.ApiUrl, meshId), nil)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	req.Header.Set("X-API-Key", c.ApiKey)
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return diag.Errorf("Failed to list nodes: %d", resp.StatusCode)
+	}
+
+	var result []map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	nodes := make([]map[string]interface{}, 0, len(result))
+	for _, n := range result {
+		nodes = append(nodes, map[string]interface{}{
+			"id":                n["id"],
+			"hostname":          n["hostname"],
+			"state":             n["state"],
+			"health_score":      n["health_score"],
+			"advertised_routes": n["advertised_routes"],
+			"last_seen":         n["last_seen"],
+		})
+	}
+	d.Set("nodes", nodes)
+	d.SetId(meshId)
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package x0t
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourcePeerings() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourcePeeringsRead,
+		Schema: map[string]*schema.Schema{
+			"mesh_id": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"peerings": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"peer_mesh_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"status": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"exported_services": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourcePeeringsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	meshId := d.Get("mesh_id").(string)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/%s/peerings", c.ApiUrl, meshId), nil)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	req.Header.Set("X-API-Key", c.ApiKey)
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return diag.Errorf("Failed to list peerings: %d", resp.StatusCode)
+	}
+
+	var result []map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	peerings := make([]map[string]interface{}, 0, len(result))
+	for _, p := range result {
+		peerings = append(peerings, map[string]interface{}{
+			"id":                p["id"],
+			"peer_mesh_name":    p["peer_mesh_name"],
+			"status":            p["status"],
+			"exported_services": p["exported_services"],
+		})
+	}
+	d.Set("peerings", peerings)
+	d.SetId(meshId)
+
+	return nil
+}
@@ -2,6 +2,8 @@ package x0t
 
 import (
 	"context"
+	"net/http"
+
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
@@ -24,10 +26,15 @@ func Provider() *schema.Provider {
 			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"x0t_mesh":       resourceMesh(),
-			"x0t_acl_policy": resourceACLPolicy(),
+			"x0t_mesh":              resourceMesh(),
+			"x0t_acl_policy":        resourceACLPolicy(),
+			"x0t_peering":           resourcePeering(),
+			"x0t_exported_services": resourceExportedServices(),
+		},
+		DataSourcesMap: map[string]*schema.Resource{
+			"x0t_mesh_nodes": dataSourceMeshNodes(),
+			"x0t_peerings":   dataSourcePeerings(),
 		},
-		DataSourcesMap: map[string]*schema.Resource{},
 		ConfigureContextFunc: providerConfigure,
 	}
 }
@@ -37,6 +44,16 @@ type Client struct {
 	ApiKey string
 }
 
+// httpClient returns an *http.Client with no fixed Timeout: the requests
+// sent through it are built with http.NewRequestWithContext, so Terraform's
+// own per-operation context (and its --timeout) controls their cancellation
+// instead. x0t_peering and x0t_acl_policy do not use it: they build their
+// requests without the context and send them on their own client with a
+// fixed 10s Timeout.
+func httpClient() *http.Client {
+	return &http.Client{}
+}
+
 func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	apiUrl := d.Get("api_url").(string)
 	apiKey := d.Get("api_key").(string)
@@ -33,6 +33,16 @@ func resourceACLPolicy() *schema.Resource {
 				Required: true,
 				ForceNew: true,
 			},
+			"source_peer": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+			"target_peer": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
 			"action": {
 				Type:     schema.TypeString,
 				Optional: true,
@@ -55,6 +65,12 @@ func resourceACLPolicyCreate(ctx context.Context, d *schema.ResourceData, m inte
 		"target_tag": d.Get("target_tag").(string),
 		"action":     d.Get("action").(string),
 	}
+	if v, ok := d.GetOk("source_peer"); ok {
+		payload["source_peer"] = v.(string)
+	}
+	if v, ok := d.GetOk("target_peer"); ok {
+		payload["target_peer"] = v.(string)
+	}
 
 	buf, _ := json.Marshal(payload)
 	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/%s/policies", c.ApiUrl, meshId), strings.NewReader(string(buf)))
@@ -108,6 +124,8 @@ func resourceACLPolicyRead(ctx context.Context, d *schema.ResourceData, m interf
 		if p["id"].(string) == policyId {
 			d.Set("source_tag", p["source_tag"])
 			d.Set("target_tag", p["target_tag"])
+			d.Set("source_peer", p["source_peer"])
+			d.Set("target_peer", p["target_peer"])
 			d.Set("action", p["action"])
 			found = true
 			break
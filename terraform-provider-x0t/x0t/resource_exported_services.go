@@ -0,0 +1,160 @@
+package x0t
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceExportedServices declares which services are visible across an
+// established peering (see resourcePeering), enforced server-side; Read
+// compares what's on file against what the Control Plane reports, so drift
+// (e.g. someone editing exports outside Terraform) surfaces in plan.
+// Mirrors Consul's exported-services config entries, scoped to one peering
+// instead of one cluster-wide entry.
+func resourceExportedServices() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceExportedServicesCreate,
+		ReadContext:   resourceExportedServicesRead,
+		UpdateContext: resourceExportedServicesUpdate,
+		DeleteContext: resourceExportedServicesDelete,
+		Schema: map[string]*schema.Schema{
+			"mesh_id": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"peering_id": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"services": {
+				Type:     schema.TypeList,
+				Required: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func resourceExportedServicesCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	meshId := d.Get("mesh_id").(string)
+	peeringId := d.Get("peering_id").(string)
+
+	if diags := putExportedServices(ctx, c, meshId, peeringId, d); diags != nil {
+		return diags
+	}
+
+	d.SetId(fmt.Sprintf("%s/%s", meshId, peeringId))
+	return nil
+}
+
+func resourceExportedServicesUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	meshId := d.Get("mesh_id").(string)
+	peeringId := d.Get("peering_id").(string)
+
+	return putExportedServices(ctx, c, meshId, peeringId, d)
+}
+
+func putExportedServices(ctx context.Context, c *Client, meshId, peeringId string, d *schema.ResourceData) diag.Diagnostics {
+	var services []string
+	for _, v := range d.Get("services").([]interface{}) {
+		services = append(services, v.(string))
+	}
+
+	payload := map[string]interface{}{"exported_services": services}
+	buf, _ := json.Marshal(payload)
+	url := fmt.Sprintf("%s/%s/peerings/%s/exported-services", c.ApiUrl, meshId, peeringId)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, strings.NewReader(string(buf)))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	req.Header.Set("X-API-Key", c.ApiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return diag.Errorf("Failed to set exported services: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func resourceExportedServicesRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	meshId := d.Get("mesh_id").(string)
+	peeringId := d.Get("peering_id").(string)
+
+	url := fmt.Sprintf("%s/%s/peerings/%s/exported-services", c.ApiUrl, meshId, peeringId)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	req.Header.Set("X-API-Key", c.ApiKey)
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		d.SetId("")
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return diag.Errorf("Failed to read exported services: %d", resp.StatusCode)
+	}
+
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	if raw, ok := result["exported_services"].([]interface{}); ok {
+		services := make([]string, 0, len(raw))
+		for _, v := range raw {
+			services = append(services, v.(string))
+		}
+		d.Set("services", services)
+	}
+
+	return nil
+}
+
+func resourceExportedServicesDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	meshId := d.Get("mesh_id").(string)
+	peeringId := d.Get("peering_id").(string)
+
+	url := fmt.Sprintf("%s/%s/peerings/%s/exported-services", c.ApiUrl, meshId, peeringId)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	req.Header.Set("X-API-Key", c.ApiKey)
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return diag.Errorf("Failed to delete exported services: %d", resp.StatusCode)
+	}
+
+	d.SetId("")
+	return nil
+}
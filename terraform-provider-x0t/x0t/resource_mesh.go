@@ -7,7 +7,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -63,12 +62,14 @@ func resourceMeshCreate(ctx context.Context, d *schema.ResourceData, m interface
 	}
 	
 	buf, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/deploy", c.ApiUrl), strings.NewReader(string(buf)))
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/deploy", c.ApiUrl), strings.NewReader(string(buf)))
+	if err != nil {
+		return diag.FromErr(err)
+	}
 	req.Header.Set("X-API-Key", c.ApiKey)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := httpClient().Do(req)
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -94,11 +95,13 @@ func resourceMeshRead(ctx context.Context, d *schema.ResourceData, m interface{}
 	var diags diag.Diagnostics
 
 	meshId := d.Id()
-	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/%s/status", c.ApiUrl, meshId), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/%s/status", c.ApiUrl, meshId), nil)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 	req.Header.Set("X-API-Key", c.ApiKey)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := httpClient().Do(req)
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -119,7 +122,34 @@ func resourceMeshRead(ctx context.Context, d *schema.ResourceData, m interface{}
 }
 
 func resourceMeshUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	// Implementation for scaling (POST /{id}/scale)
+	c := m.(*Client)
+
+	if d.HasChange("nodes") {
+		meshId := d.Id()
+		payload := map[string]interface{}{
+			"nodes": d.Get("nodes").(int),
+		}
+
+		buf, _ := json.Marshal(payload)
+		req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/%s/scale", c.ApiUrl, meshId), strings.NewReader(string(buf)))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		req.Header.Set("X-API-Key", c.ApiKey)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := httpClient().Do(req)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(resp.Body)
+			return diag.Errorf("Failed to scale mesh: %s", body)
+		}
+	}
+
 	return resourceMeshRead(ctx, d, m)
 }
 
@@ -128,11 +158,13 @@ func resourceMeshDelete(ctx context.Context, d *schema.ResourceData, m interface
 	var diags diag.Diagnostics
 
 	meshId := d.Id()
-	req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/%s", c.ApiUrl, meshId), nil)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", fmt.Sprintf("%s/%s", c.ApiUrl, meshId), nil)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 	req.Header.Set("X-API-Key", c.ApiKey)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := httpClient().Do(req)
 	if err != nil {
 		return diag.FromErr(err)
 	}
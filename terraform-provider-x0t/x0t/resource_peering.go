@@ -0,0 +1,155 @@
+package x0t
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourcePeering() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourcePeeringCreate,
+		ReadContext:   resourcePeeringRead,
+		DeleteContext: resourcePeeringDelete,
+		Schema: map[string]*schema.Schema{
+			"mesh_id": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"peer_mesh_name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"exported_services": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"token": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+			"peering_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"status": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourcePeeringCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	meshId := d.Get("mesh_id").(string)
+
+	var exported []string
+	for _, v := range d.Get("exported_services").([]interface{}) {
+		exported = append(exported, v.(string))
+	}
+
+	payload := map[string]interface{}{
+		"peer_mesh_name":    d.Get("peer_mesh_name").(string),
+		"exported_services": exported,
+	}
+
+	buf, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/%s/peerings/token", c.ApiUrl, meshId), strings.NewReader(string(buf)))
+	req.Header.Set("X-API-Key", c.ApiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return diag.Errorf("API error: status %d", resp.StatusCode)
+	}
+
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	d.SetId(result["id"].(string))
+	d.Set("peering_id", result["id"].(string))
+	d.Set("token", result["token"].(string))
+	d.Set("status", result["status"].(string))
+
+	return nil
+}
+
+func resourcePeeringRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	meshId := d.Get("mesh_id").(string)
+	peeringId := d.Id()
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/%s/peerings", c.ApiUrl, meshId), nil)
+	req.Header.Set("X-API-Key", c.ApiKey)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return diag.Errorf("Failed to read peerings: %d", resp.StatusCode)
+	}
+
+	var peerings []map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&peerings)
+
+	found := false
+	for _, p := range peerings {
+		if p["id"].(string) == peeringId {
+			d.Set("peer_mesh_name", p["peer_mesh_name"])
+			d.Set("status", p["status"])
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		d.SetId("")
+	}
+
+	return nil
+}
+
+func resourcePeeringDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	meshId := d.Get("mesh_id").(string)
+	peeringId := d.Id()
+
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/%s/peerings/%s", c.ApiUrl, meshId, peeringId), nil)
+	req.Header.Set("X-API-Key", c.ApiKey)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return diag.Errorf("Failed to delete peering: %d", resp.StatusCode)
+	}
+
+	d.SetId("")
+	return nil
+}